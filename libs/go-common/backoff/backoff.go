@@ -0,0 +1,38 @@
+// Package backoff computes exponential retry delays with equal jitter
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff tracks the delay between attempts. It is not safe for concurrent
+// use.
+type Backoff struct {
+	base    time.Duration
+	max     time.Duration
+	attempt int
+}
+
+// New creates a backoff that starts at base and doubles up to max
+func New(base, max time.Duration) *Backoff {
+	return &Backoff{base: base, max: max}
+}
+
+// Next returns the delay before the next attempt: half of the exponential
+// step plus a random share of the other half
+func (b *Backoff) Next() time.Duration {
+	d := b.base << uint(b.attempt)
+	if d <= 0 || d > b.max {
+		d = b.max
+	} else {
+		b.attempt++
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Reset restarts the backoff after a successful attempt
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,23 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := New(time.Second, 8*time.Second)
+
+	for i, step := range []time.Duration{1, 2, 4, 8, 8} {
+		step *= time.Second
+		d := b.Next()
+		if d < step/2 || d > step {
+			t.Errorf("attempt %d: expected delay in [%s, %s], got %s", i, step/2, step, d)
+		}
+	}
+
+	b.Reset()
+	if d := b.Next(); d > time.Second {
+		t.Errorf("expected delay <= 1s after reset, got %s", d)
+	}
+}
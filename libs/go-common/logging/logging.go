@@ -0,0 +1,135 @@
+// Package logging configures structured log/slog output: JSON or text
+// format, a level that can change at runtime, per-component loggers and
+// automatic redaction of secret attributes
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of secret attributes
+const Redacted = "[REDACTED]"
+
+// level is shared by every handler Setup installs so SetLevel applies at once
+var level = new(slog.LevelVar)
+
+// secretKeys are attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"passwd":        true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"private_key":   true,
+	"privatekey":    true,
+	"api_key":       true,
+	"apikey":        true,
+	"x-api-key":     true,
+	"credentials":   true,
+}
+
+// Setup installs the default logger, writing to w at the named level in
+// "json" or "text" format. Output of the standard log package goes through
+// it as well.
+func Setup(w io.Writer, levelName, format string) error {
+	if err := SetLevel(levelName); err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: expected json or text", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel changes the level of the logger installed by Setup
+func SetLevel(name string) error {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+	return nil
+}
+
+// ParseLevel parses debug, info, warn or error; empty means info
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := lvl.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: expected debug, info, warn or error", name)
+	}
+	return lvl, nil
+}
+
+// Component returns a logger tagged with component=name. It writes through
+// whatever default logger is installed when a record is logged, so it can be
+// created in a package variable before Setup runs.
+func Component(name string) *slog.Logger {
+	return slog.New(&defaultHandler{}).With("component", name)
+}
+
+// redact hides the values of attributes with secret keys
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSecret(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// IsSecret reports whether an attribute key names a secret
+func IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	return secretKeys[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_password")
+}
+
+// defaultHandler forwards records to the handler of slog.Default() at the
+// time they are logged, replaying attributes and groups added with With
+type defaultHandler struct {
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *defaultHandler) handler() slog.Handler {
+	out := slog.Default().Handler()
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	return out
+}
+
+func (h *defaultHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, l)
+}
+
+func (h *defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *defaultHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *defaultHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &defaultHandler{ops: append(ops, op)}
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// setupTest installs a logger writing to a buffer and restores the previous
+// default logger when the test ends
+func setupTest(t *testing.T, levelName, format string) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		level.Set(slog.LevelInfo)
+	})
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, levelName, format); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	return &buf
+}
+
+func TestSetupJSON(t *testing.T) {
+	buf := setupTest(t, "info", "json")
+
+	Component("ssh").Info("Session started", "session_id", "abc")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "Session started" || record["component"] != "ssh" || record["session_id"] != "abc" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestSetupInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "info", "xml"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+	if err := Setup(&buf, "verbose", "text"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	buf := setupTest(t, "info", "text")
+
+	logger := Component("grpc")
+	logger.Info("Authenticated", "token", "s3cret", "agent_token", "s3cret", "Authorization", "Bearer s3cret", "user", "alice")
+	logger.With("password", "s3cret").Info("Connected")
+
+	out := buf.String()
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("secret leaked into log output: %q", out)
+	}
+	if !strings.Contains(out, "token="+Redacted) || !strings.Contains(out, "user=alice") {
+		t.Errorf("unexpected log output: %q", out)
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	buf := setupTest(t, "warn", "text")
+	logger := Component("workload")
+
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("expected info to be filtered at warn level, got %q", buf.String())
+	}
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	logger.Debug("visible")
+	if !strings.Contains(buf.String(), "visible") {
+		t.Errorf("expected debug output after SetLevel, got %q", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+// recordSpans installs a tracer provider that records ended spans and
+// restores the previous provider when the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: path}, "codepod-test", "test")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test.span")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read spans: %v", err)
+	}
+	if !strings.Contains(string(data), "test.span") || !strings.Contains(string(data), "codepod-test") {
+		t.Errorf("expected span and service name in output, got %q", data)
+	}
+}
+
+func TestSetupInvalid(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}, "codepod-test", "test"); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterFile}, "codepod-test", "test"); err == nil {
+		t.Error("expected error for file exporter without a file")
+	}
+}
+
+func TestSetupNone(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{}, "codepod-test", "test")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TRACEPARENT", testTraceParent)
+
+	sc := trace.SpanContextFromContext(FromEnv(context.Background()))
+	if sc.TraceID().String() != testTraceID || !sc.IsRemote() {
+		t.Errorf("expected remote trace %s, got %s", testTraceID, sc.TraceID())
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	ctx := Extract(context.Background(), map[string]string{"traceparent": testTraceParent})
+	if got := trace.SpanContextFromContext(ctx).TraceID().String(); got != testTraceID {
+		t.Fatalf("expected trace %s, got %s", testTraceID, got)
+	}
+
+	if got := Inject(ctx)["traceparent"]; got != testTraceParent {
+		t.Errorf("expected traceparent %s, got %s", testTraceParent, got)
+	}
+	if got := Env(ctx)["TRACEPARENT"]; got != testTraceParent {
+		t.Errorf("expected TRACEPARENT %s, got %s", testTraceParent, got)
+	}
+}
+
+func TestExtractWithoutTraceContext(t *testing.T) {
+	ctx := Extract(context.Background(), nil)
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("expected no span context")
+	}
+	if env := Env(ctx); len(env) != 0 {
+		t.Errorf("expected no environment, got %v", env)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	ctx := Extract(context.Background(), map[string]string{"traceparent": testTraceParent})
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+
+	var sent metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := UnaryClientInterceptor()(ctx, "/grpc.health.v1.Health/Check", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("interceptor failed: %v", err)
+	}
+
+	if got := sent.Get("traceparent"); len(got) != 1 || got[0] != testTraceParent {
+		t.Errorf("expected traceparent %s in metadata, got %v", testTraceParent, got)
+	}
+	if got := sent.Get("authorization"); len(got) != 1 {
+		t.Errorf("expected existing metadata to be kept, got %v", sent)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", testTraceParent))
+	info := &grpc.UnaryServerInfo{FullMethod: "/codepod.ExecService/Execute"}
+	var handlerTraceID string
+	_, err := UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerTraceID = trace.SpanContextFromContext(ctx).TraceID().String()
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != info.FullMethod || spans[0].Parent().TraceID().String() != testTraceID {
+		t.Errorf("expected %s span continuing trace %s, got %s in %s", info.FullMethod, testTraceID, spans[0].Name(), spans[0].Parent().TraceID())
+	}
+	if handlerTraceID != testTraceID {
+		t.Errorf("expected handler context in trace %s, got %s", testTraceID, handlerTraceID)
+	}
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", testTraceParent))
+	info := &grpc.StreamServerInfo{FullMethod: "/codepod.ExecService/Execute"}
+	err := StreamServerInterceptor()(nil, &testStream{ctx: ctx}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		if got := trace.SpanContextFromContext(stream.Context()).TraceID().String(); got != testTraceID {
+			t.Errorf("expected stream context in trace %s, got %s", testTraceID, got)
+		}
+		return context.Canceled
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected handler error to be returned, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || len(spans[0].Events()) == 0 {
+		t.Fatalf("expected 1 span with the recorded error, got %d", len(spans))
+	}
+}
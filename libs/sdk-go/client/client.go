@@ -30,6 +30,13 @@ func NewClient(baseURL, apiKey string) *Client {
 	}
 }
 
+// SetHTTPClient replaces the HTTP client used for API requests. Wrapping its
+// transport with otelhttp.NewTransport sends the W3C trace context of each
+// request's context, so sandbox creation shows up in the caller's trace.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.http = hc
+}
+
 // CreateSandbox creates a new sandbox
 func (c *Client) CreateSandbox(ctx context.Context, req *types.CreateSandboxRequest) (*types.CreateSandboxResponse, error) {
 	data, err := json.Marshal(req)
@@ -185,3 +192,111 @@ func (c *Client) GetConnectionToken(ctx context.Context, id string) (string, err
 
 	return result.Token, nil
 }
+
+// CreateScopedToken returns an agent token for the sandbox that only grants
+// scopes (such as "exec") and expires after ttl
+func (c *Client) CreateScopedToken(ctx context.Context, id string, scopes []string, ttl time.Duration) (*types.ScopedToken, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"scopes":     scopes,
+		"ttlSeconds": int(ttl.Seconds()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/sandboxes/"+id+"/scoped-token", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token types.ScopedToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListPorts returns the TCP ports listening inside the sandbox, as last
+// reported by its agent. Each can be opened with GetPreviewURL.
+func (c *Client) ListPorts(ctx context.Context, id string) ([]types.ListeningPort, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/sandboxes/"+id+"/ports", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Ports []types.ListeningPort `json:"ports"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Ports, nil
+}
+
+// GetPreviewURL returns a signed URL that opens the web server listening on
+// port inside the sandbox in a browser
+func (c *Client) GetPreviewURL(ctx context.Context, id string, port int) (string, error) {
+	data, err := json.Marshal(map[string]int{"port": port})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/sandboxes/"+id+"/preview", bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		URL string `json:"url"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.URL, nil
+}
@@ -213,6 +213,112 @@ func TestGetConnectionToken(t *testing.T) {
 	}
 }
 
+func TestGetPreviewURL(t *testing.T) {
+	expected := "http://runner:32768/proxy/3000/?preview_expires=1&preview_signature=abc"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/v1/sandboxes/sbox-123/preview" {
+			t.Errorf("expected /api/v1/sandboxes/sbox-123/preview, got %s", r.URL.Path)
+		}
+
+		var req struct {
+			Port int `json:"port"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Port != 3000 {
+			t.Errorf("expected port 3000, got %d", req.Port)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"url": expected})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	url, err := client.GetPreviewURL(context.Background(), "sbox-123", 3000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if url != expected {
+		t.Errorf("expected url %s, got %s", expected, url)
+	}
+}
+
+func TestListPorts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/v1/sandboxes/sbox-123/ports" {
+			t.Errorf("expected /api/v1/sandboxes/sbox-123/ports, got %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"sandboxId":"sbox-123","ports":[{"port":5173,"address":"0.0.0.0","protocol":"tcp","pid":42,"process":"node","openedAt":"2024-01-01T00:00:00Z"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	ports, err := client.ListPorts(context.Background(), "sbox-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ports) != 1 || ports[0].Port != 5173 || ports[0].Process != "node" || ports[0].PID != 42 {
+		t.Errorf("expected port 5173 opened by node, got %+v", ports)
+	}
+}
+
+func TestCreateScopedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/v1/sandboxes/sbox-123/scoped-token" {
+			t.Errorf("expected /api/v1/sandboxes/sbox-123/scoped-token, got %s", r.URL.Path)
+		}
+
+		var req struct {
+			Scopes     []string `json:"scopes"`
+			TTLSeconds int      `json:"ttlSeconds"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Scopes) != 1 || req.Scopes[0] != "exec" {
+			t.Errorf("expected scopes [exec], got %v", req.Scopes)
+		}
+		if req.TTLSeconds != 600 {
+			t.Errorf("expected ttlSeconds 600, got %d", req.TTLSeconds)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"token":     "cp1.payload.signature",
+			"expiresAt": "2026-01-01T00:10:00Z",
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	token, err := client.CreateScopedToken(context.Background(), "sbox-123", []string{"exec"}, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token.Token != "cp1.payload.signature" {
+		t.Errorf("expected token cp1.payload.signature, got %s", token.Token)
+	}
+	if token.ExpiresAt.IsZero() {
+		t.Error("expected expiry to be set")
+	}
+}
+
 func TestClientTimeout(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(2 * time.Second)
@@ -228,3 +334,32 @@ func TestClientTimeout(t *testing.T) {
 		t.Errorf("expected timeout error")
 	}
 }
+
+// headerTransport sets a header on every request, as a tracing transport would
+type headerTransport struct {
+	key, value string
+}
+
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set(t.key, t.value)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("traceparent"); got != traceparent {
+			t.Errorf("expected traceparent %s, got %q", traceparent, got)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	client.SetHTTPClient(&http.Client{Transport: &headerTransport{key: "traceparent", value: traceparent}})
+
+	if err := client.DeleteSandbox(context.Background(), "sbox-123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/codepod/codepod/libs/sdk-go/types"
+)
+
+// GetTLSCA returns the PEM certificate of the CA that issues agent gRPC
+// server certificates
+func (c *Client) GetTLSCA(ctx context.Context) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/tls/ca", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return string(body), nil
+}
+
+// IssueClientCertificate requests a client certificate for mutual TLS with
+// the agent of a sandbox that requires one. The sandbox's token, as returned
+// when it was created, proves access to it.
+func (c *Client) IssueClientCertificate(ctx context.Context, sandboxID, sandboxToken string) (*types.TLSCertificate, error) {
+	data, err := json.Marshal(map[string]string{"commonName": sandboxID, "usage": "client", "sandboxToken": sandboxToken})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/tls/cert", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result types.TLSCertificate
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// AgentTLSConfig returns a TLS configuration for dialing a sandbox agent's
+// gRPC server, e.g. with grpc.WithTransportCredentials(credentials.NewTLS(cfg)).
+// The agent must present a certificate for the sandbox ID from the server's
+// CA. If clientCert is not nil it is presented for mutual TLS.
+func (c *Client) AgentTLSConfig(ctx context.Context, sandboxID string, clientCert *types.TLSCertificate) (*tls.Config, error) {
+	caPEM, err := c.GetTLSCA(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(caPEM)) {
+		return nil, fmt.Errorf("failed to parse CA certificate")
+	}
+
+	cfg := &tls.Config{
+		RootCAs:    roots,
+		ServerName: sandboxID,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if clientCert != nil {
+		cert, err := tls.X509KeyPair([]byte(clientCert.Certificate), []byte(clientCert.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
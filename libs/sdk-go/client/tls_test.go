@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testCAPEM(t *testing.T) string {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAgentTLSConfig(t *testing.T) {
+	caPEM := testCAPEM(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/tls/ca" {
+			t.Errorf("expected /api/v1/tls/ca, got %s", r.URL.Path)
+		}
+		w.Write([]byte(caPEM))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	cfg, err := client.AgentTLSConfig(context.Background(), "sbox-123", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.ServerName != "sbox-123" {
+		t.Errorf("expected server name sbox-123, got %s", cfg.ServerName)
+	}
+	if cfg.RootCAs == nil {
+		t.Error("expected root CAs to be set")
+	}
+	if len(cfg.Certificates) != 0 {
+		t.Errorf("expected no client certificate, got %d", len(cfg.Certificates))
+	}
+}
+
+func TestAgentTLSConfigInvalidCA(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a certificate"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	if _, err := client.AgentTLSConfig(context.Background(), "sbox-123", nil); err == nil {
+		t.Error("expected error for invalid CA certificate")
+	}
+}
+
+func TestIssueClientCertificate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["commonName"] != "sbox-123" || body["usage"] != "client" || body["sandboxToken"] != "sbox-token" {
+			t.Errorf("unexpected request body %v", body)
+		}
+		if r.Header.Get("X-API-Key") != "test-key" {
+			t.Errorf("expected API key header, got %q", r.Header.Get("X-API-Key"))
+		}
+		w.Write([]byte(`{"certificate":"cert","privateKey":"key","caCertificate":"ca"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key")
+	cert, err := client.IssueClientCertificate(context.Background(), "sbox-123", "sbox-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cert.Certificate != "cert" {
+		t.Errorf("expected certificate, got %+v", cert)
+	}
+}
@@ -74,3 +74,26 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
 }
+
+// TLSCertificate is a PEM certificate and key issued by the server's TLS CA
+type TLSCertificate struct {
+	Certificate   string `json:"certificate"`
+	PrivateKey    string `json:"privateKey"`
+	CACertificate string `json:"caCertificate"`
+}
+
+// ScopedToken is an expiring agent token limited to a set of scopes
+type ScopedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ListeningPort is a TCP port a process inside the sandbox is listening on
+type ListeningPort struct {
+	Port     int       `json:"port"`
+	Address  string    `json:"address,omitempty"`
+	Protocol string    `json:"protocol,omitempty"`
+	PID      int       `json:"pid,omitempty"`
+	Process  string    `json:"process,omitempty"`
+	OpenedAt time.Time `json:"openedAt"`
+}
@@ -16,6 +16,10 @@ import {
   Volume,
   CreateVolumeRequest,
   CreateVolumeResponse,
+  Snapshot,
+  CreateSnapshotRequest,
+  CreateSnapshotResponse,
+  CreateCheckpointRequest,
   APIKey,
   CreateAPIKeyRequest,
   CreateAPIKeyResponse,
@@ -140,6 +144,100 @@ export class CodePodClient {
     await this.client.post(`/api/v1/sandboxes/${id}/restart`);
   }
 
+  /**
+   * Start a stopped sandbox
+   */
+  async startSandbox(id: string): Promise<void> {
+    await this.client.post(`/api/v1/sandboxes/${id}/start`);
+  }
+
+  /**
+   * Pause a sandbox, freezing its processes in memory
+   */
+  async pauseSandbox(id: string): Promise<void> {
+    await this.client.post(`/api/v1/sandboxes/${id}/pause`);
+  }
+
+  /**
+   * Resume a paused sandbox
+   */
+  async unpauseSandbox(id: string): Promise<void> {
+    await this.client.post(`/api/v1/sandboxes/${id}/unpause`);
+  }
+
+  /**
+   * Change a sandbox's memory and CPU limits without restarting it
+   */
+  async resizeSandbox(id: string, resources: { memory?: string; cpu?: number }): Promise<void> {
+    await this.client.post(`/api/v1/sandboxes/${id}/resize`, resources);
+  }
+
+  /**
+   * Snapshot a sandbox's filesystem to an image. The snapshot is pending
+   * until the sandbox's runner has committed and pushed it.
+   */
+  async createSnapshot(id: string, req: CreateSnapshotRequest = {}): Promise<CreateSnapshotResponse> {
+    const response = await this.client.post<CreateSnapshotResponse>(`/api/v1/sandboxes/${id}/snapshots`, req);
+    return response.data;
+  }
+
+  /**
+   * List a sandbox's snapshots, newest first
+   */
+  async listSnapshots(id: string): Promise<Snapshot[]> {
+    const response = await this.client.get<{ snapshots: Snapshot[] }>(`/api/v1/sandboxes/${id}/snapshots`);
+    return response.data.snapshots;
+  }
+
+  /**
+   * Get a snapshot by ID
+   */
+  async getSnapshot(snapshotId: string): Promise<Snapshot> {
+    const response = await this.client.get<{ snapshot: Snapshot }>(`/api/v1/snapshots/${snapshotId}`);
+    return response.data.snapshot;
+  }
+
+  /**
+   * Create a new sandbox from a ready snapshot, with the source sandbox's
+   * env, resources and volumes
+   */
+  async restoreSnapshot(id: string, snapshotId: string, name?: string): Promise<CreateSandboxResponse> {
+    const response = await this.client.post<CreateSandboxResponse>(
+      `/api/v1/sandboxes/${id}/snapshots/${snapshotId}/restore`,
+      { name }
+    );
+    return response.data;
+  }
+
+  /**
+   * Checkpoint a running sandbox's processes along with its filesystem. The
+   * sandbox is stopped once checkpointed unless leaveRunning is set, and the
+   * checkpoint is pending until its runner has uploaded it.
+   */
+  async createCheckpoint(id: string, req: CreateCheckpointRequest = {}): Promise<CreateSnapshotResponse> {
+    const response = await this.client.post<CreateSnapshotResponse>(`/api/v1/sandboxes/${id}/checkpoints`, req);
+    return response.data;
+  }
+
+  /**
+   * List a sandbox's checkpoints, newest first
+   */
+  async listCheckpoints(id: string): Promise<Snapshot[]> {
+    const response = await this.client.get<{ checkpoints: Snapshot[] }>(`/api/v1/sandboxes/${id}/checkpoints`);
+    return response.data.checkpoints;
+  }
+
+  /**
+   * Resume a stopped sandbox from one of its ready checkpoints. The sandbox
+   * keeps its ID and token, and is pending until a runner has restored it.
+   */
+  async restoreCheckpoint(id: string, checkpointId: string): Promise<Sandbox> {
+    const response = await this.client.post<{ sandbox: Sandbox }>(
+      `/api/v1/sandboxes/${id}/checkpoints/${checkpointId}/restore`
+    );
+    return response.data.sandbox;
+  }
+
   /**
    * Get SSH token for a sandbox
    */
@@ -143,6 +143,47 @@ export interface CreateVolumeResponse {
   hostPath: string;
 }
 
+/**
+ * Snapshot represents a sandbox's filesystem committed to an image
+ */
+export interface Snapshot {
+  id: string;
+  sandboxId: string;
+  status: 'pending' | 'ready' | 'failed';
+  image?: string;
+  imageId?: string;
+  sourceImage: string;
+  memory?: string;
+  cpu?: number;
+  volumes?: VolumeMount[];
+  excludeVolumes: boolean;
+  checkpoint: boolean;
+  message?: string;
+  createdAt: string;
+}
+
+/**
+ * CreateSnapshotRequest represents a request to snapshot a sandbox
+ */
+export interface CreateSnapshotRequest {
+  excludeVolumes?: boolean;
+}
+
+/**
+ * CreateSnapshotResponse represents the response after queueing a snapshot
+ */
+export interface CreateSnapshotResponse {
+  snapshot: Snapshot;
+  jobId: string;
+}
+
+/**
+ * CreateCheckpointRequest represents a request to checkpoint a running sandbox
+ */
+export interface CreateCheckpointRequest {
+  leaveRunning?: boolean;
+}
+
 /**
  * APIKey represents an API key
  */
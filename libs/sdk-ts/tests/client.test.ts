@@ -202,6 +202,101 @@ describe('CodePodClient', () => {
     });
   });
 
+  describe('startSandbox', () => {
+    it('should start a sandbox', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/start').reply(202);
+
+      await expect(client.startSandbox('sandbox-123')).resolves.not.toThrow();
+    });
+  });
+
+  describe('pauseSandbox', () => {
+    it('should pause and unpause a sandbox', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/pause').reply(202);
+      mock.onPost('/api/v1/sandboxes/sandbox-123/unpause').reply(202);
+
+      await expect(client.pauseSandbox('sandbox-123')).resolves.not.toThrow();
+      await expect(client.unpauseSandbox('sandbox-123')).resolves.not.toThrow();
+    });
+  });
+
+  describe('resizeSandbox', () => {
+    it('should send the new limits', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/resize', { memory: '2G', cpu: 2 }).reply(202);
+
+      await expect(client.resizeSandbox('sandbox-123', { memory: '2G', cpu: 2 })).resolves.not.toThrow();
+    });
+  });
+
+  describe('snapshots', () => {
+    const snapshot = {
+      id: 'snap-1',
+      sandboxId: 'sandbox-123',
+      status: 'pending',
+      sourceImage: 'python:3.11',
+      excludeVolumes: true,
+      createdAt: '2024-01-01T00:00:00Z',
+    };
+
+    it('should create and list snapshots', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/snapshots', { excludeVolumes: true }).reply(202, { snapshot, jobId: 'job-1' });
+      mock.onGet('/api/v1/sandboxes/sandbox-123/snapshots').reply(200, { snapshots: [snapshot], total: 1 });
+      mock.onGet('/api/v1/snapshots/snap-1').reply(200, { snapshot });
+
+      const created = await client.createSnapshot('sandbox-123', { excludeVolumes: true });
+      expect(created.snapshot.id).toBe('snap-1');
+      expect(created.jobId).toBe('job-1');
+      expect(await client.listSnapshots('sandbox-123')).toHaveLength(1);
+      expect((await client.getSnapshot('snap-1')).sourceImage).toBe('python:3.11');
+    });
+
+    it('should restore a snapshot into a new sandbox', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/snapshots/snap-1/restore', { name: 'fork-1' }).reply(202, {
+        sandbox: { id: 'sandbox-456', name: 'fork-1', status: 'pending' },
+        sshHost: 'localhost',
+        sshPort: 2222,
+        sshUser: 'root',
+        token: 'token-456',
+      });
+
+      const result = await client.restoreSnapshot('sandbox-123', 'snap-1', 'fork-1');
+      expect(result.sandbox.id).toBe('sandbox-456');
+      expect(result.token).toBe('token-456');
+    });
+  });
+
+  describe('checkpoints', () => {
+    const checkpoint = {
+      id: 'snap-2',
+      sandboxId: 'sandbox-123',
+      status: 'pending',
+      sourceImage: 'python:3.11',
+      excludeVolumes: false,
+      checkpoint: true,
+      createdAt: '2024-01-01T00:00:00Z',
+    };
+
+    it('should create and list checkpoints', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/checkpoints', { leaveRunning: true }).reply(202, { snapshot: checkpoint, jobId: 'job-2' });
+      mock.onGet('/api/v1/sandboxes/sandbox-123/checkpoints').reply(200, { checkpoints: [checkpoint], total: 1 });
+
+      const created = await client.createCheckpoint('sandbox-123', { leaveRunning: true });
+      expect(created.snapshot.checkpoint).toBe(true);
+      expect(created.jobId).toBe('job-2');
+      expect(await client.listCheckpoints('sandbox-123')).toHaveLength(1);
+    });
+
+    it('should restore a checkpoint into the same sandbox', async () => {
+      mock.onPost('/api/v1/sandboxes/sandbox-123/checkpoints/snap-2/restore').reply(202, {
+        sandbox: { id: 'sandbox-123', name: 'test', status: 'pending' },
+      });
+
+      const sandbox = await client.restoreCheckpoint('sandbox-123', 'snap-2');
+      expect(sandbox.id).toBe('sandbox-123');
+      expect(sandbox.status).toBe('pending');
+    });
+  });
+
   describe('getSandboxToken', () => {
     it('should get sandbox token', async () => {
       const tokenResponse = { token: 'sandbox-token-123' };
@@ -5,25 +5,44 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/codepod/codepod/libs/go-common/tracing"
 	"github.com/codepod/codepod/sandbox/agent/pkg/config"
+	"github.com/codepod/codepod/sandbox/agent/pkg/filewatch"
 	"github.com/codepod/codepod/sandbox/agent/pkg/grpc"
+	"github.com/codepod/codepod/sandbox/agent/pkg/metrics"
 	"github.com/codepod/codepod/sandbox/agent/pkg/multiplex"
+	"github.com/codepod/codepod/sandbox/agent/pkg/ports"
+	"github.com/codepod/codepod/sandbox/agent/pkg/preview"
 	"github.com/codepod/codepod/sandbox/agent/pkg/reporter"
 	"github.com/codepod/codepod/sandbox/agent/pkg/ssh"
+	"github.com/codepod/codepod/sandbox/agent/pkg/workload"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	sshc "golang.org/x/crypto/ssh"
 )
 
+var logger = logging.Component("agent")
+
 // Version is set at build time via ldflags
 var Version = "v0.0.0-dev"
 
@@ -31,7 +50,7 @@ var Version = "v0.0.0-dev"
 func generateSSHHostKeys() error {
 	keyPath := "/etc/ssh/ssh_host_rsa_key"
 	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-		log.Println("Generating SSH host keys...")
+		logger.Info("Generating SSH host keys")
 
 		// Ensure /etc/ssh directory exists
 		if err := os.MkdirAll("/etc/ssh", 0755); err != nil {
@@ -63,7 +82,7 @@ func generateSSHHostKeys() error {
 			return fmt.Errorf("failed to write public key: %w", err)
 		}
 
-		log.Println("SSH host keys generated using Go crypto library")
+		logger.Info("SSH host keys generated")
 	}
 	return nil
 }
@@ -71,6 +90,9 @@ func generateSSHHostKeys() error {
 func main() {
 	flag.Bool("version", false, "Show version")
 	flag.Bool("v", false, "Show version (shorthand)")
+	grpcReflection := flag.Bool("grpc-reflection", false, "Enable gRPC server reflection (for grpcurl)")
+	configPath := flag.String("config", os.Getenv("AGENT_CONFIG"), "Path to a YAML or JSON config file (env vars override it)")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit")
 	flag.Parse()
 
 	if flag.Lookup("version").Value.String() == "true" || flag.Lookup("v").Value.String() == "true" {
@@ -78,42 +100,111 @@ func main() {
 		os.Exit(0)
 	}
 
-	log.Println("Starting CodePod Agent...")
-
-	// Generate SSH host keys if needed
-	if err := generateSSHHostKeys(); err != nil {
-		log.Printf("Warning: failed to generate SSH host keys: %v", err)
+	cfg, err := config.LoadFile(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *grpcReflection {
+		cfg.GRPC.Reflection = true
 	}
 
-	cfg := config.LoadFromEnv()
+	if *printConfig {
+		data, err := cfg.Redacted().Marshal()
+		if err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		os.Stdout.Write(data)
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		os.Exit(0)
+	}
 
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	log.Printf("Sandbox ID: %s", cfg.Agent.SandboxID)
-	log.Printf("Agent Token: %s (length: %d)", cfg.Agent.Token, len(cfg.Agent.Token))
+	if err := logging.Setup(os.Stderr, cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(slog.Default().With("sandbox_id", cfg.Agent.SandboxID))
+
+	logger.Info("Starting CodePod Agent", "version", Version)
+
+	// Export traces; startup continues the trace of the job that created the
+	// sandbox when the runner passed TRACEPARENT
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+		Insecure: cfg.Tracing.Insecure,
+		File:     cfg.Tracing.File,
+	}, "codepod-agent", Version)
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
+	_, startupSpan := otel.Tracer("github.com/codepod/codepod/sandbox/agent/cmd").Start(
+		tracing.FromEnv(context.Background()), "agent.startup",
+		trace.WithAttributes(attribute.String("sandbox.id", cfg.Agent.SandboxID)),
+	)
+
+	// Generate SSH host keys if needed
+	if err := generateSSHHostKeys(); err != nil {
+		logger.Warn("Failed to generate SSH host keys", "error", err)
+	}
+
+	logger.Info("Agent token configured", "token_length", len(cfg.Agent.Token))
 
 	// Create reporter client
+	var sshServer *ssh.SSHServer
 	reporterCfg := &reporter.Config{
-		ServerURL: cfg.Agent.ServerURL,
-		SandboxID: cfg.Agent.SandboxID,
-		Interval:  30 * time.Second, // Default 30s
+		ServerURL:    cfg.Agent.ServerURL,
+		SandboxID:    cfg.Agent.SandboxID,
+		Token:        cfg.Agent.Token,
+		Interval:     time.Duration(cfg.Agent.HeartbeatInterval) * time.Second,
+		WorkspaceDir: workspaceDir(cfg.Agent.WorkspaceDir),
+		SessionCount: func() int { return sshServer.SessionCount() },
 	}
 	reporterClient := reporter.NewClient(reporterCfg)
 
 	// Create SSH server config (port is not used when using StartWithListener)
-	sshServer := ssh.NewServer(&ssh.ServerConfig{
+	sshServer = ssh.NewServer(&ssh.ServerConfig{
 		Port:              cfg.SSH.Port,
 		HostKeys:          cfg.SSH.HostKeys,
 		MaxSessions:       cfg.SSH.MaxSessions,
 		IdleTimeout:       cfg.SSH.IdleTimeout,
 		Token:             cfg.Agent.Token,
 		TrustedUserCAKeys: cfg.SSH.TrustedUserCAKeys,
+		Events:            reporterClient,
+		Shell:             cfg.SSH.Shell,
 	})
 
+	// Readiness is served through the grpc.health.v1 service; the runner
+	// waits for it before reporting the sandbox as running
+	checks := []string{grpc.CheckHostKeys, grpc.CheckSSH}
+	if len(cfg.Workload.Command()) > 0 {
+		checks = append(checks, grpc.CheckWorkload)
+	}
+	readiness := grpc.NewReadiness(checks...)
+
+	if err := sshServer.LoadHostKeys(); err != nil {
+		logger.Warn("Failed to load SSH host keys", "error", err)
+	} else {
+		readiness.Set(grpc.CheckHostKeys, true)
+	}
+
 	// Create gRPC server
-	grpcServer := grpc.NewServer(cfg.GRPC.Port, cfg.Agent.Token)
+	grpcServer := grpc.NewServer(cfg.GRPC.Port, cfg.Agent.SandboxID, cfg.Agent.Token)
+	grpcServer.SetReadiness(readiness)
+	grpcServer.SetEvents(reporterClient)
+	grpcServer.EnableReflection(cfg.GRPC.Reflection)
+	if cfg.GRPC.TLS.Enabled() {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			fatal("Invalid TLS configuration", err)
+		}
+		grpcServer.SetTLSConfig(tlsConfig)
+		logger.Info("gRPC TLS enabled", "require_client_cert", cfg.GRPC.TLS.RequireClientCert)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -123,12 +214,95 @@ func main() {
 		Status:    "running",
 		Hostname: getHostname(),
 	}
+	reporterDone := make(chan struct{})
 	go func() {
+		defer close(reporterDone)
 		if err := reporterClient.StartHeartbeat(ctx, initialStatus); err != nil && ctx.Err() == nil {
-			log.Printf("Reporter error: %v", err)
+			logger.Error("Reporter error", "error", err)
 		}
 	}()
 
+	// Run the image's original entrypoint and cmd as the sandbox's main workload
+	var supervisor *workload.Supervisor
+	if command := cfg.Workload.Command(); len(command) > 0 {
+		supervisor = workload.NewSupervisor(command)
+		supervisor.OnExit(func(st workload.Status) {
+			readiness.Fail(grpc.CheckWorkload)
+			status := "stopped"
+			severity := reporter.SeverityInfo
+			if st.State == workload.StateFailed {
+				status = "failed"
+				severity = reporter.SeverityError
+			}
+			reporterClient.Emit(reporter.Event{
+				Type:     reporter.EventProcessExited,
+				Severity: severity,
+				Attributes: map[string]string{
+					"process":  "workload",
+					"pid":      strconv.Itoa(st.PID),
+					"exitCode": strconv.Itoa(st.ExitCode),
+				},
+			})
+			if err := reporterClient.SetStatus(ctx, status, map[string]string{
+				"workloadExitCode": strconv.Itoa(st.ExitCode),
+			}); err != nil {
+				logger.Warn("Failed to report workload exit", "error", err)
+			}
+		})
+		if err := supervisor.Start(); err != nil {
+			readiness.Fail(grpc.CheckWorkload)
+			logger.Error("Failed to start workload", "error", err)
+			if err := reporterClient.SetStatus(ctx, "failed", map[string]string{
+				"workloadError": err.Error(),
+			}); err != nil {
+				logger.Warn("Failed to report workload failure", "error", err)
+			}
+		} else {
+			// Ignored if the workload has already exited
+			readiness.Set(grpc.CheckWorkload, true)
+		}
+	}
+
+	// Watch configured paths and report changes as events
+	if len(cfg.Agent.WatchPaths) > 0 {
+		watcher := filewatch.New(cfg.Agent.WatchPaths, 5*time.Second)
+		go watcher.Run(ctx, func(change filewatch.Change) {
+			reporterClient.Emit(reporter.Event{
+				Type: reporter.EventFileChanged,
+				Attributes: map[string]string{
+					"path": change.Path,
+					"op":   string(change.Op),
+				},
+			})
+		})
+	}
+
+	// Report TCP ports as they start and stop listening, so clients can offer
+	// to forward them. The agent's own ports are left out.
+	portsCtx, stopPorts := context.WithCancel(ctx)
+	defer stopPorts()
+	if cfg.Agent.PortScanInterval > 0 {
+		portWatcher := ports.New(time.Duration(cfg.Agent.PortScanInterval)*time.Second,
+			[]int{cfg.Multiplex.Port, cfg.GRPC.Port, cfg.SSH.Port})
+		grpcServer.SetPortWatcher(portWatcher)
+		go portWatcher.Run(portsCtx, func(change ports.Change) {
+			eventType := reporter.EventPortOpened
+			if change.Op == ports.OpClosed {
+				eventType = reporter.EventPortClosed
+			}
+			reporterClient.Emit(reporter.Event{
+				Type: eventType,
+				Attributes: map[string]string{
+					"port":     strconv.Itoa(change.Port.Port),
+					"address":  change.Port.Address,
+					"protocol": change.Port.Protocol,
+					"pid":      strconv.Itoa(change.Port.PID),
+					"process":  change.Port.Process,
+				},
+			})
+		})
+	}
+
 	// Create multiplex server with SSH and gRPC handlers
 	multiplexAddr := fmt.Sprintf(":%d", cfg.Multiplex.Port)
 	multiplexServer := multiplex.New(
@@ -143,30 +317,190 @@ func main() {
 		},
 	)
 
-	log.Printf("Starting multiplexed server on port %d (SSH + gRPC)", cfg.Multiplex.Port)
+	multiplexServer.SetGRPCTLS(cfg.GRPC.TLS.Enabled())
+
+	// Take client addresses from PROXY protocol headers sent by trusted proxies
+	if len(cfg.Multiplex.ProxyProtocolTrusted) > 0 {
+		trusted, err := multiplex.ParseTrustedSources(cfg.Multiplex.ProxyProtocolTrusted)
+		if err != nil {
+			fatal("Invalid PROXY protocol configuration", err)
+		}
+		multiplexServer.SetProxyProtocol(trusted)
+		logger.Info("PROXY protocol enabled", "trusted", cfg.Multiplex.ProxyProtocolTrusted)
+	}
+
+	// Serve sandbox web previews and metrics over HTTP/1.1 on the same port.
+	// Previews selected by Host subdomain take precedence over /metrics.
+	var metricsHandler http.Handler
+	if cfg.Multiplex.Metrics {
+		mux := http.NewServeMux()
+		mux.Handle(metrics.Path, metrics.Handler(cfg.Agent.Token))
+		metricsHandler = mux
+	}
+	if cfg.Multiplex.Preview {
+		previewProxy := preview.New(&preview.Config{
+			SandboxID:   cfg.Agent.SandboxID,
+			Token:       cfg.Agent.Token,
+			DeniedPorts: []int{cfg.Multiplex.Port, cfg.GRPC.Port},
+			Fallback:    metricsHandler,
+		})
+		multiplexServer.SetHTTPHandler(func(listener net.Listener) error {
+			return previewProxy.Serve(ctx, listener)
+		})
+	} else if cfg.Multiplex.Metrics {
+		multiplexServer.SetHTTPHandler(func(listener net.Listener) error {
+			return metrics.Serve(ctx, listener, cfg.Agent.Token)
+		})
+	}
+
+	logger.Info("Starting multiplexed server (SSH + gRPC)", "port", cfg.Multiplex.Port)
+
+	// Mark SSH ready once the server accepts connections, which completes
+	// agent startup
+	go func() {
+		select {
+		case <-sshServer.Ready():
+			readiness.Set(grpc.CheckSSH, true)
+			startupSpan.End()
+		case <-ctx.Done():
+			startupSpan.End()
+		}
+	}()
 
 	// Start multiplexed server in goroutine
 	go func() {
 		if err := multiplexServer.Start(); err != nil {
-			log.Fatalf("Failed to start multiplexed server: %v", err)
+			fatal("Failed to start multiplexed server", err)
 		}
 	}()
 
-	// Handle shutdown signals
+	// Reload the config file on SIGHUP; shut down on SIGINT or SIGTERM
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	sig := <-sigChan
+	for sig == syscall.SIGHUP {
+		reloadConfig(cfg, *configPath, sshServer, grpcServer)
+		sig = <-sigChan
+	}
 
-	log.Println("Shutting down...")
+	logger.Info("Shutting down", "signal", sig.String())
+	readiness.Shutdown()
+	reporterClient.Emit(reporter.Event{
+		Type: reporter.EventAgentShutdown,
+		Attributes: map[string]string{
+			"signal": sig.String(),
+		},
+	})
 
-	// Stop the multiplex server gracefully
+	// Stop accepting connections, then give open SSH sessions and Execute
+	// calls the grace period to finish
 	multiplexServer.Stop()
 
-	// Stop SSH server
-	sshServer.Stop()
+	// Port watches have nothing to finish, so they end before draining
+	stopPorts()
 
-	// Cancel context to stop all background operations
+	gracePeriod := time.Duration(cfg.Agent.ShutdownGracePeriod) * time.Second
+	logger.Info("Draining connections", "grace_period", gracePeriod)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), gracePeriod)
+	var drained sync.WaitGroup
+	drained.Add(2)
+	go func() {
+		defer drained.Done()
+		message := fmt.Sprintf("Sandbox is shutting down in %s", gracePeriod)
+		if err := sshServer.Drain(drainCtx, message); err != nil {
+			logger.Warn("SSH sessions did not finish in time", "error", err)
+		}
+	}()
+	go func() {
+		defer drained.Done()
+		if err := grpcServer.Drain(drainCtx); err != nil {
+			logger.Warn("gRPC calls did not finish in time", "error", err)
+		}
+	}()
+	drained.Wait()
+	drainCancel()
+
+	// Stop the workload, giving it the 10s docker stop would. The runner's
+	// stop timeout leaves room for this and the final flush.
+	if supervisor != nil {
+		if err := supervisor.Stop(10 * time.Second); err != nil {
+			logger.Error("Failed to stop workload", "error", err)
+		}
+	}
+
+	// Cancel context to stop all background operations, and wait for the
+	// reporter to flush its final status
 	cancel()
+	<-reporterDone
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
+	}
+	flushCancel()
+	logger.Info("Shutdown complete")
+}
+
+// reloadConfig re-reads the configuration and applies the settings that can
+// change at runtime. An invalid configuration is logged and ignored.
+func reloadConfig(cfg *config.Config, path string, sshServer *ssh.SSHServer, grpcServer *grpc.Server) {
+	logger.Info("Reloading configuration")
+	next, err := config.LoadFile(path)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		logger.Error("Failed to reload configuration, keeping the current one", "error", err)
+		return
+	}
+	// The flag is not part of the file or environment
+	next.GRPC.Reflection = cfg.GRPC.Reflection
+
+	changed, restartRequired := cfg.Reload(next)
+	if err := logging.SetLevel(cfg.Logging.Level); err != nil {
+		logger.Error("Failed to set log level", "error", err)
+	}
+	sshServer.SetTrustedUserCAKeys(cfg.SSH.TrustedUserCAKeys)
+	sshServer.SetLimits(cfg.SSH.MaxSessions, cfg.SSH.IdleTimeout)
+	if slices.Contains(changed, "grpc.tls") {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			logger.Error("Invalid TLS configuration, keeping the current one", "error", err)
+		} else {
+			grpcServer.SetTLSConfig(tlsConfig)
+		}
+	}
+
+	if len(changed) == 0 {
+		logger.Info("Configuration reloaded, nothing changed")
+	} else {
+		logger.Info("Configuration reloaded", "applied", strings.Join(changed, ", "))
+	}
+	if restartRequired {
+		logger.Warn("Some changed settings only take effect after a restart")
+	}
+}
+
+// newTLSConfig builds the gRPC TLS configuration. Client certificates must
+// name this sandbox or one of the configured allowed clients.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	t := cfg.GRPC.TLS
+	allowed := append([]string{cfg.Agent.SandboxID}, t.AllowedClients...)
+	return grpc.NewTLSConfig(t.Cert, t.Key, t.ClientCA, t.RequireClientCert, allowed)
+}
+
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// workspaceDir returns dir if it exists, otherwise the root filesystem
+func workspaceDir(dir string) string {
+	if _, err := os.Stat(dir); err != nil {
+		return "/"
+	}
+	return dir
 }
 
 func getHostname() string {
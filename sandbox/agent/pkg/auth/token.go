@@ -0,0 +1,135 @@
+// Package auth verifies the credentials clients present to the agent: the
+// sandbox's agent token, or a scoped, expiring token signed by the server
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is a permission granted by a scoped token
+type Scope string
+
+const (
+	ScopeAll  Scope = "*"    // Everything the agent token allows
+	ScopeExec Scope = "exec" // Run commands and open sessions
+)
+
+// scopedPrefix marks a scoped token: cp1.<base64url claims>.<base64url HMAC>
+const scopedPrefix = "cp1."
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Claims describe what a token may do
+type Claims struct {
+	SandboxID string  `json:"sid"`
+	Scopes    []Scope `json:"scopes"`
+	ExpiresAt int64   `json:"exp"` // Unix seconds; 0 for the agent token, which does not expire
+}
+
+// Allows reports whether the claims grant scope
+func (c *Claims) Allows(scope Scope) bool {
+	for _, s := range c.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier checks tokens for one sandbox. Scoped tokens are signed by the
+// server with HMAC-SHA256 keyed by the agent token, so the agent can verify
+// them without calling back to the server.
+type Verifier struct {
+	sandboxID string
+	token     string
+}
+
+// NewVerifier creates a verifier for the sandbox's agent token
+func NewVerifier(sandboxID, token string) *Verifier {
+	return &Verifier{sandboxID: sandboxID, token: token}
+}
+
+// Verify returns the claims of a valid token
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	if v.token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if !strings.HasPrefix(token, scopedPrefix) {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(v.token)) != 1 {
+			return nil, ErrInvalidToken
+		}
+		return &Claims{SandboxID: v.sandboxID, Scopes: []Scope{ScopeAll}}, nil
+	}
+
+	payload, signature, ok := strings.Cut(strings.TrimPrefix(token, scopedPrefix), ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(v.token, payload)) {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.SandboxID != v.sandboxID {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt == 0 || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// Sign creates a scoped token for claims, keyed by the agent token. The
+// server mints tokens the same way.
+func Sign(agentToken string, claims *Claims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return scopedPrefix + payload + "." + base64.RawURLEncoding.EncodeToString(sign(agentToken, payload)), nil
+}
+
+func sign(key, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+type claimsKey struct{}
+
+// NewContext returns a context carrying the caller's claims
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the caller's claims, if authenticated
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
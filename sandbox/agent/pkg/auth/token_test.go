@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyAgentToken(t *testing.T) {
+	v := NewVerifier("sbox-123", "secret")
+
+	claims, err := v.Verify("secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !claims.Allows(ScopeExec) || !claims.Allows(ScopeAll) {
+		t.Errorf("expected agent token to allow all scopes, got %v", claims.Scopes)
+	}
+
+	if _, err := v.Verify("wrong"); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := v.Verify(""); err != ErrMissingToken {
+		t.Errorf("expected ErrMissingToken, got %v", err)
+	}
+}
+
+func TestVerifyScopedToken(t *testing.T) {
+	v := NewVerifier("sbox-123", "secret")
+
+	token, err := Sign("secret", &Claims{
+		SandboxID: "sbox-123",
+		Scopes:    []Scope{ScopeExec},
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !claims.Allows(ScopeExec) {
+		t.Error("expected exec to be allowed")
+	}
+	if claims.Allows(ScopeAll) {
+		t.Error("expected exec token to deny full access")
+	}
+}
+
+func TestVerifyScopedTokenRejected(t *testing.T) {
+	v := NewVerifier("sbox-123", "secret")
+	valid := &Claims{SandboxID: "sbox-123", Scopes: []Scope{ScopeExec}, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	otherKey, _ := Sign("other-secret", valid)
+	otherSandbox, _ := Sign("secret", &Claims{SandboxID: "sbox-456", Scopes: valid.Scopes, ExpiresAt: valid.ExpiresAt})
+	expired, _ := Sign("secret", &Claims{SandboxID: "sbox-123", Scopes: valid.Scopes, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	good, _ := Sign("secret", valid)
+	tampered := strings.Replace(good, "cp1.", "cp1.x", 1)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"wrong key", otherKey, ErrInvalidToken},
+		{"other sandbox", otherSandbox, ErrInvalidToken},
+		{"expired", expired, ErrExpiredToken},
+		{"tampered", tampered, ErrInvalidToken},
+		{"malformed", "cp1.garbage", ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClaimsContext(t *testing.T) {
+	claims := &Claims{SandboxID: "sbox-123", Scopes: []Scope{ScopeExec}}
+	ctx := NewContext(context.Background(), claims)
+
+	got, ok := FromContext(ctx)
+	if !ok || got != claims {
+		t.Error("expected claims from context")
+	}
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("expected no claims in empty context")
+	}
+}
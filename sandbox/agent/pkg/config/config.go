@@ -2,45 +2,113 @@ package config
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
 )
 
-// Config represents the Agent configuration
+var logger = logging.Component("config")
+
+// Config represents the Agent configuration. The yaml tags name the keys of
+// the config file, which may also be written as JSON.
 type Config struct {
-	Agent     AgentConfig
-	SSH       SSHConfig
-	GRPC      GRPCConfig
-	Multiplex MultiplexConfig
+	Agent     AgentConfig     `yaml:"agent"`
+	SSH       SSHConfig       `yaml:"ssh"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	Multiplex MultiplexConfig `yaml:"multiplex"`
+	Workload  WorkloadConfig  `yaml:"workload"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Tracing   TracingConfig   `yaml:"tracing"`
 }
 
 // AgentConfig holds Agent connection settings
 type AgentConfig struct {
-	Token     string
-	ServerURL string
-	SandboxID string
+	Token               string   `yaml:"token"`
+	ServerURL           string   `yaml:"server_url"`
+	SandboxID           string   `yaml:"sandbox_id"`
+	WorkspaceDir        string   `yaml:"workspace_dir"`         // Directory whose disk usage is reported in heartbeats
+	WatchPaths          []string `yaml:"watch_paths"`           // Files or directories whose changes are reported as events
+	PortScanInterval    int      `yaml:"port_scan_interval"`    // Seconds between scans for listening ports; 0 disables port discovery
+	ShutdownGracePeriod int      `yaml:"shutdown_grace_period"` // Seconds to let SSH sessions and Execute calls finish on shutdown
+	HeartbeatInterval   int      `yaml:"heartbeat_interval"`    // Seconds between status heartbeats
 }
 
 // SSHConfig holds SSH server settings
 type SSHConfig struct {
-	Port             int
-	HostKeys         []string
-	MaxSessions      int
-	IdleTimeout      int
-	TrustedUserCAKeys string // SSH CA public key for certificate authentication
+	Port              int      `yaml:"port"`
+	HostKeys          []string `yaml:"host_keys"`
+	MaxSessions       int      `yaml:"max_sessions"`         // 0 allows any number of sessions
+	IdleTimeout       int      `yaml:"idle_timeout"`         // Seconds without traffic before a connection is closed, 0 for none
+	TrustedUserCAKeys string   `yaml:"trusted_user_ca_keys"` // SSH CA public key for certificate authentication
+	Shell             string   `yaml:"shell"`                // Shell for interactive sessions and exec requests
 }
 
 // GRPCConfig holds gRPC server settings
 type GRPCConfig struct {
-	Port int
+	Port       int       `yaml:"port"`
+	Reflection bool      `yaml:"reflection"` // Register the server reflection service (for grpcurl)
+	TLS        TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig holds PEM-encoded certificates for gRPC over TLS, issued by the
+// server's CA and injected by the runner
+type TLSConfig struct {
+	Cert              string `yaml:"cert"`                // Server certificate
+	Key               string `yaml:"key"`                 // Server private key
+	ClientCA          string `yaml:"client_ca"`           // CA that client certificates must chain to
+	RequireClientCert bool   `yaml:"require_client_cert"` // Reject clients without a valid certificate (mTLS)
+
+	// Client certificate names accepted besides the sandbox ID, such as the
+	// ID of the runner hosting the sandbox
+	AllowedClients []string `yaml:"allowed_clients"`
+}
+
+// Enabled reports whether a server certificate is configured
+func (t TLSConfig) Enabled() bool {
+	return t.Cert != "" && t.Key != ""
 }
 
 // MultiplexConfig holds the multiplexed port settings (SSH + gRPC on single port)
 type MultiplexConfig struct {
-	Port int
+	Port    int  `yaml:"port"`
+	Preview bool `yaml:"preview"` // Reverse-proxy HTTP/1.1 requests to ports inside the sandbox
+	Metrics bool `yaml:"metrics"` // Serve Prometheus metrics at /metrics to clients with the agent token
+
+	// IPs or CIDR ranges allowed to send PROXY protocol headers; empty disables it
+	ProxyProtocolTrusted []string `yaml:"proxy_protocol_trusted"`
+}
+
+// WorkloadConfig holds the image's original entrypoint and cmd, which the
+// agent runs as the sandbox's main workload
+type WorkloadConfig struct {
+	Entrypoint []string `yaml:"entrypoint"`
+	Cmd        []string `yaml:"cmd"`
+}
+
+// LoggingConfig holds log output settings
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // text or json
+}
+
+// TracingConfig holds OpenTelemetry tracing settings
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"` // none, otlp or file
+	Endpoint string `yaml:"endpoint"` // OTLP gRPC host:port
+	Insecure bool   `yaml:"insecure"` // Send OTLP without TLS
+	File     string `yaml:"file"`     // Path spans are appended to with the file exporter
+}
+
+// Command returns the full workload command line (entrypoint followed by cmd)
+func (w WorkloadConfig) Command() []string {
+	command := make([]string, 0, len(w.Entrypoint)+len(w.Cmd))
+	command = append(command, w.Entrypoint...)
+	return append(command, w.Cmd...)
 }
 
 func Load() (*Config, error) {
@@ -65,47 +133,99 @@ func Load() (*Config, error) {
 	}, nil
 }
 
+// Default returns the configuration used when neither a config file nor the
+// environment sets a value
+func Default() *Config {
+	return &Config{
+		Agent: AgentConfig{
+			WorkspaceDir:        "/workspace",
+			ShutdownGracePeriod: 5,
+			HeartbeatInterval:   30,
+			PortScanInterval:    2,
+		},
+		SSH: SSHConfig{
+			Port:        22,
+			HostKeys:    []string{"/etc/ssh/ssh_host_rsa_key"},
+			MaxSessions: 10,
+			IdleTimeout: 1800,
+			Shell:       "/bin/sh",
+		},
+		GRPC: GRPCConfig{
+			Port: 50052,
+		},
+		Multiplex: MultiplexConfig{
+			Port:    22,
+			Preview: true,
+			Metrics: true,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// LoadFromEnv loads the default configuration overridden by AGENT_*
+// environment variables
 func LoadFromEnv() *Config {
-	hostKeysEnv := os.Getenv("AGENT_HOST_KEYS")
-	hostKeys := []string{"/etc/ssh/ssh_host_rsa_key"}
-	if hostKeysEnv != "" {
-		hostKeys = parseHostKeys(hostKeysEnv)
+	cfg := Default()
+	cfg.applyEnv()
+	return cfg
+}
+
+// applyEnv overrides settings with the AGENT_* environment variables that are set
+func (c *Config) applyEnv() {
+	if hostKeysEnv := os.Getenv("AGENT_HOST_KEYS"); hostKeysEnv != "" {
+		c.SSH.HostKeys = parseHostKeys(hostKeysEnv)
 	}
 
 	// Get CA public key from environment (base64 encoded for certificate authentication)
-	trustedUserCAKeysEncoded := os.Getenv("AGENT_TRUSTED_USER_CA_KEYS")
-	var trustedUserCAKeys string
-	if trustedUserCAKeysEncoded != "" {
+	if trustedUserCAKeysEncoded := os.Getenv("AGENT_TRUSTED_USER_CA_KEYS"); trustedUserCAKeysEncoded != "" {
 		// Decode base64-encoded CA key
 		decoded, err := base64.StdEncoding.DecodeString(trustedUserCAKeysEncoded)
 		if err != nil {
-			log.Printf("Warning: failed to decode CA key: %v", err)
-			trustedUserCAKeys = ""
+			logger.Warn("Failed to decode CA key", "error", err)
 		} else {
-			trustedUserCAKeys = string(decoded)
+			c.SSH.TrustedUserCAKeys = string(decoded)
 		}
 	}
 
-	return &Config{
-		Agent: AgentConfig{
-			Token:     os.Getenv("AGENT_TOKEN"),
-			ServerURL: os.Getenv("AGENT_SERVER_URL"),
-			SandboxID: os.Getenv("AGENT_SANDBOX_ID"),
-		},
-		SSH: SSHConfig{
-			Port:             getEnvIntOrDefault("AGENT_SSH_PORT", 22),
-			HostKeys:         hostKeys,
-			MaxSessions:      getEnvIntOrDefault("AGENT_MAX_SESSIONS", 10),
-			IdleTimeout:      getEnvIntOrDefault("AGENT_IDLE_TIMEOUT", 1800),
-			TrustedUserCAKeys: trustedUserCAKeys,
-		},
-		GRPC: GRPCConfig{
-			Port: getEnvIntOrDefault("AGENT_GRPC_PORT", 50052),
-		},
-		Multiplex: MultiplexConfig{
-			Port: getEnvIntOrDefault("AGENT_PORT", 22),
-		},
-	}
+	c.Agent.Token = getEnvOrDefault("AGENT_TOKEN", c.Agent.Token)
+	c.Agent.ServerURL = getEnvOrDefault("AGENT_SERVER_URL", c.Agent.ServerURL)
+	c.Agent.SandboxID = getEnvOrDefault("AGENT_SANDBOX_ID", c.Agent.SandboxID)
+	c.Agent.WorkspaceDir = getEnvOrDefault("AGENT_WORKSPACE", c.Agent.WorkspaceDir)
+	c.Agent.WatchPaths = getEnvListOrDefault("AGENT_WATCH_PATHS", c.Agent.WatchPaths)
+	c.Agent.ShutdownGracePeriod = getEnvIntOrDefault("AGENT_SHUTDOWN_GRACE_PERIOD", c.Agent.ShutdownGracePeriod)
+	c.Agent.HeartbeatInterval = getEnvIntOrDefault("AGENT_HEARTBEAT_INTERVAL", c.Agent.HeartbeatInterval)
+	c.Agent.PortScanInterval = getEnvIntOrDefault("AGENT_PORT_SCAN_INTERVAL", c.Agent.PortScanInterval)
+
+	c.SSH.Port = getEnvIntOrDefault("AGENT_SSH_PORT", c.SSH.Port)
+	c.SSH.MaxSessions = getEnvIntOrDefault("AGENT_MAX_SESSIONS", c.SSH.MaxSessions)
+	c.SSH.IdleTimeout = getEnvIntOrDefault("AGENT_IDLE_TIMEOUT", c.SSH.IdleTimeout)
+	c.SSH.Shell = getEnvOrDefault("AGENT_SHELL", c.SSH.Shell)
+
+	c.GRPC.Port = getEnvIntOrDefault("AGENT_GRPC_PORT", c.GRPC.Port)
+	c.GRPC.Reflection = getEnvBoolOrDefault("AGENT_GRPC_REFLECTION", c.GRPC.Reflection)
+	c.GRPC.TLS.Cert = getEnvBase64("AGENT_TLS_CERT", c.GRPC.TLS.Cert)
+	c.GRPC.TLS.Key = getEnvBase64("AGENT_TLS_KEY", c.GRPC.TLS.Key)
+	c.GRPC.TLS.ClientCA = getEnvBase64("AGENT_TLS_CLIENT_CA", c.GRPC.TLS.ClientCA)
+	c.GRPC.TLS.RequireClientCert = getEnvBoolOrDefault("AGENT_TLS_REQUIRE_CLIENT_CERT", c.GRPC.TLS.RequireClientCert)
+	c.GRPC.TLS.AllowedClients = getEnvListOrDefault("AGENT_TLS_ALLOWED_CLIENTS", c.GRPC.TLS.AllowedClients)
+
+	c.Multiplex.Port = getEnvIntOrDefault("AGENT_PORT", c.Multiplex.Port)
+	c.Multiplex.Preview = getEnvBoolOrDefault("AGENT_PREVIEW", c.Multiplex.Preview)
+	c.Multiplex.Metrics = getEnvBoolOrDefault("AGENT_METRICS", c.Multiplex.Metrics)
+	c.Multiplex.ProxyProtocolTrusted = getEnvListOrDefault("AGENT_PROXY_PROTOCOL_TRUSTED", c.Multiplex.ProxyProtocolTrusted)
+
+	c.Workload.Entrypoint = getEnvJSONList("AGENT_WORKLOAD_ENTRYPOINT", c.Workload.Entrypoint)
+	c.Workload.Cmd = getEnvJSONList("AGENT_WORKLOAD_CMD", c.Workload.Cmd)
+
+	c.Logging.Level = getEnvOrDefault("AGENT_LOG_LEVEL", c.Logging.Level)
+	c.Logging.Format = getEnvOrDefault("AGENT_LOG_FORMAT", c.Logging.Format)
+	c.Tracing.Exporter = getEnvOrDefault("AGENT_TRACING_EXPORTER", c.Tracing.Exporter)
+	c.Tracing.Endpoint = getEnvOrDefault("AGENT_TRACING_ENDPOINT", c.Tracing.Endpoint)
+	c.Tracing.Insecure = getEnvBoolOrDefault("AGENT_TRACING_INSECURE", c.Tracing.Insecure)
+	c.Tracing.File = getEnvOrDefault("AGENT_TRACING_FILE", c.Tracing.File)
 }
 
 func parseHostKeys(env string) []string {
@@ -122,6 +242,18 @@ func parseHostKeys(env string) []string {
 	return keys
 }
 
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(env string) []string {
+	var items []string
+	for _, item := range splitComma(env) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func splitComma(s string) []string {
 	if s == "" {
 		return []string{}
@@ -130,6 +262,14 @@ func splitComma(s string) []string {
 	return parts
 }
 
+func getEnvOrDefault(key, defaultVal string) string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	return val
+}
+
 func getEnvIntOrDefault(key string, defaultVal int) int {
 	val := os.Getenv(key)
 	if val == "" {
@@ -142,6 +282,56 @@ func getEnvIntOrDefault(key string, defaultVal int) int {
 	return result
 }
 
+func getEnvBoolOrDefault(key string, defaultVal bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	result, err := strconv.ParseBool(val)
+	if err != nil {
+		return defaultVal
+	}
+	return result
+}
+
+// getEnvBase64 decodes a base64-encoded environment variable, such as PEM
+// data that would otherwise need newlines
+func getEnvBase64(key, defaultVal string) string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	decoded, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		logger.Warn("Failed to decode environment variable", "name", key, "error", err)
+		return ""
+	}
+	return string(decoded)
+}
+
+// getEnvJSONList decodes an environment variable holding a JSON string array
+func getEnvJSONList(key string, defaultVal []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	var result []string
+	if err := json.Unmarshal([]byte(val), &result); err != nil {
+		logger.Warn("Failed to decode environment variable", "name", key, "error", err)
+		return nil
+	}
+	return result
+}
+
+// getEnvListOrDefault splits a comma-separated environment variable
+func getEnvListOrDefault(key string, defaultVal []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	return parseList(val)
+}
+
 func (c *Config) Validate() error {
 	if c.Agent.SandboxID == "" {
 		return fmt.Errorf("sandbox ID is required")
@@ -152,14 +342,59 @@ func (c *Config) Validate() error {
 	if c.Agent.ServerURL == "" {
 		return fmt.Errorf("agent server URL is required")
 	}
-	if c.SSH.Port <= 0 {
-		return fmt.Errorf("SSH port must be positive")
+	if c.SSH.Port <= 0 || c.SSH.Port > 65535 {
+		return fmt.Errorf("SSH port must be between 1 and 65535")
 	}
-	if c.GRPC.Port <= 0 {
-		return fmt.Errorf("gRPC port must be positive")
+	if c.GRPC.Port <= 0 || c.GRPC.Port > 65535 {
+		return fmt.Errorf("gRPC port must be between 1 and 65535")
 	}
-	if c.Multiplex.Port <= 0 {
-		return fmt.Errorf("multiplex port must be positive")
+	if c.Multiplex.Port <= 0 || c.Multiplex.Port > 65535 {
+		return fmt.Errorf("multiplex port must be between 1 and 65535")
+	}
+	if c.SSH.MaxSessions < 0 {
+		return fmt.Errorf("max sessions must not be negative")
+	}
+	if c.SSH.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout must not be negative")
+	}
+	if c.Agent.HeartbeatInterval < 0 {
+		return fmt.Errorf("heartbeat interval must not be negative")
+	}
+	if c.Agent.PortScanInterval < 0 {
+		return fmt.Errorf("port scan interval must not be negative")
+	}
+	if c.Agent.ShutdownGracePeriod < 0 {
+		return fmt.Errorf("shutdown grace period must not be negative")
+	}
+	if (c.GRPC.TLS.Cert == "") != (c.GRPC.TLS.Key == "") {
+		return fmt.Errorf("TLS certificate and key must be set together")
+	}
+	if c.GRPC.TLS.RequireClientCert && c.GRPC.TLS.ClientCA == "" {
+		return fmt.Errorf("client CA is required to verify client certificates")
+	}
+	switch c.Logging.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid log level %q: expected debug, info, warn or error", c.Logging.Level)
+	}
+	switch c.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log format %q: expected text or json", c.Logging.Format)
+	}
+	switch c.Tracing.Exporter {
+	case "", "none", "otlp":
+	case "file":
+		if c.Tracing.File == "" {
+			return fmt.Errorf("tracing file is required with the file exporter")
+		}
+	default:
+		return fmt.Errorf("invalid tracing exporter %q: expected none, otlp or file", c.Tracing.Exporter)
+	}
+	for _, source := range c.Multiplex.ProxyProtocolTrusted {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			return fmt.Errorf("invalid PROXY protocol trusted source %q", source)
+		}
 	}
 	return nil
 }
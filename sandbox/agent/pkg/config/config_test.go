@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/base64"
 	"os"
 	"testing"
 )
@@ -59,6 +60,15 @@ func TestLoadFromEnvDefaults(t *testing.T) {
 	if cfg.SSH.IdleTimeout != 1800 {
 		t.Errorf("expected default idle timeout 1800, got %d", cfg.SSH.IdleTimeout)
 	}
+	if !cfg.Multiplex.Preview {
+		t.Error("expected preview proxy enabled by default")
+	}
+	if cfg.Agent.ShutdownGracePeriod != 5 {
+		t.Errorf("expected default shutdown grace period 5, got %d", cfg.Agent.ShutdownGracePeriod)
+	}
+	if cfg.Agent.PortScanInterval != 2 {
+		t.Errorf("expected default port scan interval 2, got %d", cfg.Agent.PortScanInterval)
+	}
 }
 
 func TestConfigValidation(t *testing.T) {
@@ -138,6 +148,57 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
+func TestTLSConfigFromEnv(t *testing.T) {
+	os.Setenv("AGENT_TLS_CERT", base64.StdEncoding.EncodeToString([]byte("cert-pem")))
+	os.Setenv("AGENT_TLS_KEY", base64.StdEncoding.EncodeToString([]byte("key-pem")))
+	os.Setenv("AGENT_TLS_REQUIRE_CLIENT_CERT", "true")
+	os.Setenv("AGENT_TLS_ALLOWED_CLIENTS", "runner-1")
+	defer os.Unsetenv("AGENT_TLS_CERT")
+	defer os.Unsetenv("AGENT_TLS_KEY")
+	defer os.Unsetenv("AGENT_TLS_REQUIRE_CLIENT_CERT")
+	defer os.Unsetenv("AGENT_TLS_ALLOWED_CLIENTS")
+
+	cfg := LoadFromEnv()
+	cfg.Agent = AgentConfig{Token: "test-token", SandboxID: "sbox-123", ServerURL: "http://localhost:8080"}
+
+	if !cfg.GRPC.TLS.Enabled() || cfg.GRPC.TLS.Cert != "cert-pem" || cfg.GRPC.TLS.Key != "key-pem" {
+		t.Errorf("expected decoded TLS certificate and key, got %+v", cfg.GRPC.TLS)
+	}
+	if len(cfg.GRPC.TLS.AllowedClients) != 1 || cfg.GRPC.TLS.AllowedClients[0] != "runner-1" {
+		t.Errorf("expected allowed client runner-1, got %v", cfg.GRPC.TLS.AllowedClients)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error when requiring client certificates without a client CA")
+	}
+
+	cfg.GRPC.TLS.RequireClientCert = false
+	cfg.GRPC.TLS.Key = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for TLS certificate without key")
+	}
+}
+
+func TestProxyProtocolFromEnv(t *testing.T) {
+	os.Setenv("AGENT_PROXY_PROTOCOL_TRUSTED", "10.0.0.0/8, 192.168.1.5")
+	defer os.Unsetenv("AGENT_PROXY_PROTOCOL_TRUSTED")
+
+	cfg := LoadFromEnv()
+	cfg.Agent = AgentConfig{Token: "test-token", SandboxID: "sbox-123", ServerURL: "http://localhost:8080"}
+
+	trusted := cfg.Multiplex.ProxyProtocolTrusted
+	if len(trusted) != 2 || trusted[0] != "10.0.0.0/8" || trusted[1] != "192.168.1.5" {
+		t.Errorf("expected two trusted sources, got %v", trusted)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.Multiplex.ProxyProtocolTrusted = []string{"not-an-ip"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid trusted source")
+	}
+}
+
 func TestLoad(t *testing.T) {
 	os.Setenv("AGENT_TOKEN", "env-token")
 	os.Setenv("AGENT_SERVER_URL", "http://env:8080")
@@ -198,3 +259,47 @@ func TestMultipleHostKeys(t *testing.T) {
 		t.Errorf("expected 2 host keys, got %d", len(cfg.SSH.HostKeys))
 	}
 }
+
+func TestWorkloadFromEnv(t *testing.T) {
+	os.Setenv("AGENT_WORKLOAD_ENTRYPOINT", `["docker-entrypoint.sh"]`)
+	os.Setenv("AGENT_WORKLOAD_CMD", `["postgres","-c","fsync=off"]`)
+	defer os.Unsetenv("AGENT_WORKLOAD_ENTRYPOINT")
+	defer os.Unsetenv("AGENT_WORKLOAD_CMD")
+
+	cfg := LoadFromEnv()
+
+	command := cfg.Workload.Command()
+	expected := []string{"docker-entrypoint.sh", "postgres", "-c", "fsync=off"}
+	if len(command) != len(expected) {
+		t.Fatalf("expected command %v, got %v", expected, command)
+	}
+	for i := range expected {
+		if command[i] != expected[i] {
+			t.Errorf("expected command %v, got %v", expected, command)
+		}
+	}
+}
+
+func TestWorkloadInvalidJSON(t *testing.T) {
+	os.Setenv("AGENT_WORKLOAD_CMD", "not-json")
+	defer os.Unsetenv("AGENT_WORKLOAD_CMD")
+
+	cfg := LoadFromEnv()
+
+	if len(cfg.Workload.Command()) != 0 {
+		t.Errorf("expected empty workload command, got %v", cfg.Workload.Command())
+	}
+}
+
+func TestGRPCReflectionFromEnv(t *testing.T) {
+	if LoadFromEnv().GRPC.Reflection {
+		t.Error("expected reflection disabled by default")
+	}
+
+	os.Setenv("AGENT_GRPC_REFLECTION", "true")
+	defer os.Unsetenv("AGENT_GRPC_REFLECTION")
+
+	if !LoadFromEnv().GRPC.Reflection {
+		t.Error("expected reflection enabled")
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets when the configuration is printed
+const redacted = "<redacted>"
+
+// LoadFile loads the default configuration, overridden by the YAML or JSON
+// file at path and then by AGENT_* environment variables. ${VAR} references
+// in the file's values are expanded and $$ stands for a literal $. An empty
+// path loads from the environment only.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := cfg.parse(data); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	cfg.applyEnv()
+	return cfg, nil
+}
+
+// parse overlays a YAML or JSON document on the configuration. Unknown keys
+// are rejected so typos do not go unnoticed.
+func (c *Config) parse(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		return nil
+	}
+	expandNode(&doc)
+
+	// Re-encoded so unknown keys are still checked while decoding
+	expanded, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(expanded))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// expandNode expands environment references in the scalar values under n.
+// Mapping keys are left alone.
+func expandNode(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if value := expandEnv(n.Value); value != n.Value {
+			n.Value = value
+			// Resolve the type again, so "${PORT}" can fill an int
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			expandNode(n.Content[i])
+		}
+	default:
+		for _, child := range n.Content {
+			expandNode(child)
+		}
+	}
+}
+
+// expandEnv replaces ${VAR} with the value of the environment variable VAR
+// and $$ with $. Any other $ is kept as is.
+func expandEnv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(os.Getenv(s[i+2 : i+2+end]))
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// Redacted returns a copy of the configuration with secrets removed
+func (c *Config) Redacted() *Config {
+	out := *c
+	if out.Agent.Token != "" {
+		out.Agent.Token = redacted
+	}
+	if out.GRPC.TLS.Key != "" {
+		out.GRPC.TLS.Key = redacted
+	}
+	return &out
+}
+
+// Marshal renders the configuration as YAML, in the config file format
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// Reload applies the settings of next that can change while the agent is
+// running: SSH trusted CA keys, session limits, the log level and, when
+// gRPC TLS stays enabled, its certificate, client CA and allowed clients. It returns
+// the names of the settings that changed, and whether next also changes
+// settings that only take effect after a restart.
+func (c *Config) Reload(next *Config) (changed []string, restartRequired bool) {
+	if c.SSH.TrustedUserCAKeys != next.SSH.TrustedUserCAKeys {
+		c.SSH.TrustedUserCAKeys = next.SSH.TrustedUserCAKeys
+		changed = append(changed, "ssh.trusted_user_ca_keys")
+	}
+	if c.SSH.MaxSessions != next.SSH.MaxSessions {
+		c.SSH.MaxSessions = next.SSH.MaxSessions
+		changed = append(changed, "ssh.max_sessions")
+	}
+	if c.SSH.IdleTimeout != next.SSH.IdleTimeout {
+		c.SSH.IdleTimeout = next.SSH.IdleTimeout
+		changed = append(changed, "ssh.idle_timeout")
+	}
+	if c.Logging.Level != next.Logging.Level {
+		c.Logging.Level = next.Logging.Level
+		changed = append(changed, "logging.level")
+	}
+	if !reflect.DeepEqual(c.GRPC.TLS, next.GRPC.TLS) && c.GRPC.TLS.Enabled() && next.GRPC.TLS.Enabled() {
+		c.GRPC.TLS = next.GRPC.TLS
+		changed = append(changed, "grpc.tls")
+	}
+	return changed, !reflect.DeepEqual(c, next)
+}
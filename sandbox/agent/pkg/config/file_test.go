@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes content to a temporary file with the given extension
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileYAML(t *testing.T) {
+	os.Setenv("TEST_AGENT_TOKEN", "file-token")
+	defer os.Unsetenv("TEST_AGENT_TOKEN")
+
+	path := writeConfigFile(t, "agent.yaml", `
+agent:
+  token: "${TEST_AGENT_TOKEN}"
+  server_url: "http://server:8080"
+  sandbox_id: "sbox-123"
+  heartbeat_interval: 10
+ssh:
+  max_sessions: 3
+  shell: "/bin/bash"
+  host_keys:
+    - "/keys/a"
+    - "/keys/b"
+logging:
+  level: "debug"
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.Agent.Token != "file-token" {
+		t.Errorf("expected expanded token file-token, got %s", cfg.Agent.Token)
+	}
+	if cfg.Agent.HeartbeatInterval != 10 {
+		t.Errorf("expected heartbeat interval 10, got %d", cfg.Agent.HeartbeatInterval)
+	}
+	if cfg.SSH.MaxSessions != 3 || cfg.SSH.Shell != "/bin/bash" {
+		t.Errorf("expected max sessions 3 and /bin/bash, got %d and %s", cfg.SSH.MaxSessions, cfg.SSH.Shell)
+	}
+	if len(cfg.SSH.HostKeys) != 2 || cfg.SSH.HostKeys[1] != "/keys/b" {
+		t.Errorf("expected two host keys, got %v", cfg.SSH.HostKeys)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Errorf("expected log level debug, got %s", cfg.Logging.Level)
+	}
+	// Unset keys keep their defaults
+	if cfg.SSH.Port != 22 || cfg.GRPC.Port != 50052 || !cfg.Multiplex.Preview {
+		t.Errorf("expected defaults for unset keys, got %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestLoadFileJSON(t *testing.T) {
+	path := writeConfigFile(t, "agent.json", `{
+		"agent": {"token": "json-token", "server_url": "http://server:8080", "sandbox_id": "sbox-123"},
+		"grpc": {"port": 50060, "tls": {"require_client_cert": false}}
+	}`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Agent.Token != "json-token" || cfg.GRPC.Port != 50060 {
+		t.Errorf("expected JSON values, got token %s and port %d", cfg.Agent.Token, cfg.GRPC.Port)
+	}
+}
+
+func TestLoadFileExpansion(t *testing.T) {
+	os.Setenv("TEST_AGENT_PORT", "50070")
+	defer os.Unsetenv("TEST_AGENT_PORT")
+
+	path := writeConfigFile(t, "agent.yaml", `
+agent:
+  token: "pa$word$$1"
+  server_url: "http://server:8080/$${TEST_AGENT_PORT}"
+grpc:
+  port: ${TEST_AGENT_PORT}
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Agent.Token != "pa$word$1" {
+		t.Errorf("expected literal $ kept, got %s", cfg.Agent.Token)
+	}
+	if cfg.Agent.ServerURL != "http://server:8080/${TEST_AGENT_PORT}" {
+		t.Errorf("expected escaped reference kept, got %s", cfg.Agent.ServerURL)
+	}
+	if cfg.GRPC.Port != 50070 {
+		t.Errorf("expected expanded port 50070, got %d", cfg.GRPC.Port)
+	}
+}
+
+func TestLoadFileEnvOverrides(t *testing.T) {
+	os.Setenv("AGENT_MAX_SESSIONS", "7")
+	os.Setenv("AGENT_SHELL", "/bin/zsh")
+	defer os.Unsetenv("AGENT_MAX_SESSIONS")
+	defer os.Unsetenv("AGENT_SHELL")
+
+	path := writeConfigFile(t, "agent.yaml", "ssh:\n  max_sessions: 3\n  shell: /bin/bash\n")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.SSH.MaxSessions != 7 || cfg.SSH.Shell != "/bin/zsh" {
+		t.Errorf("expected env to override file, got %d and %s", cfg.SSH.MaxSessions, cfg.SSH.Shell)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	if _, err := LoadFile("/nonexistent/agent.yaml"); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	unknown := writeConfigFile(t, "agent.yaml", "ssh:\n  max_sesions: 3\n")
+	if _, err := LoadFile(unknown); err == nil {
+		t.Error("expected error for unknown key")
+	}
+
+	invalid := writeConfigFile(t, "agent.yaml", "ssh:\n  port: not-a-number\n")
+	if _, err := LoadFile(invalid); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestLoadShippedConfig(t *testing.T) {
+	if _, err := LoadFile("../../../../config/agent.yaml"); err != nil {
+		t.Errorf("failed to load config/agent.yaml: %v", err)
+	}
+}
+
+func TestValidateLogLevel(t *testing.T) {
+	cfg := Default()
+	cfg.Agent = AgentConfig{Token: "test-token", SandboxID: "sbox-123", ServerURL: "http://localhost:8080"}
+	cfg.Logging.Level = "verbose"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid log level")
+	}
+}
+
+func TestValidateLogFormat(t *testing.T) {
+	cfg := Default()
+	cfg.Agent = AgentConfig{Token: "test-token", SandboxID: "sbox-123", ServerURL: "http://localhost:8080"}
+	cfg.Logging.Format = "xml"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid log format")
+	}
+	cfg.Logging.Format = "json"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected json format to be valid, got %v", err)
+	}
+}
+
+func TestValidateTracing(t *testing.T) {
+	cfg := Default()
+	cfg.Agent = AgentConfig{Token: "test-token", SandboxID: "sbox-123", ServerURL: "http://localhost:8080"}
+
+	cfg.Tracing.Exporter = "zipkin"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for unknown tracing exporter")
+	}
+	cfg.Tracing.Exporter = "file"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for file exporter without a file")
+	}
+	cfg.Tracing.File = "/tmp/spans.json"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected file exporter to be valid, got %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Agent.Token = "secret-token"
+	cfg.GRPC.TLS.Key = "secret-key"
+
+	data, err := cfg.Redacted().Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", data)
+	}
+	if cfg.Agent.Token != "secret-token" {
+		t.Error("expected original config to be unchanged")
+	}
+}
+
+func TestReload(t *testing.T) {
+	cfg := Default()
+	next := Default()
+	next.SSH.MaxSessions = 2
+	next.SSH.TrustedUserCAKeys = "ssh-ed25519 AAAA"
+	next.Logging.Level = "debug"
+
+	changed, restartRequired := cfg.Reload(next)
+	if len(changed) != 3 {
+		t.Errorf("expected 3 changed settings, got %v", changed)
+	}
+	if restartRequired {
+		t.Error("expected no restart for reloadable settings")
+	}
+	if cfg.SSH.MaxSessions != 2 || cfg.Logging.Level != "debug" {
+		t.Errorf("expected reloadable settings to be applied, got %+v", cfg.SSH)
+	}
+
+	// TLS settings reload while TLS stays enabled
+	cfg.GRPC.TLS = TLSConfig{Cert: "cert", Key: "key"}
+	next.GRPC.TLS = TLSConfig{Cert: "cert", Key: "key", ClientCA: "ca"}
+	changed, restartRequired = cfg.Reload(next)
+	if len(changed) != 1 || changed[0] != "grpc.tls" || restartRequired {
+		t.Errorf("expected grpc.tls to reload, got %v (restart %v)", changed, restartRequired)
+	}
+	if cfg.GRPC.TLS.ClientCA != "ca" {
+		t.Errorf("expected client CA to be applied, got %q", cfg.GRPC.TLS.ClientCA)
+	}
+
+	next.SSH.Port = 2222
+	if _, restartRequired := cfg.Reload(next); !restartRequired {
+		t.Error("expected a port change to require a restart")
+	}
+	if cfg.SSH.Port != 22 {
+		t.Errorf("expected port to be unchanged, got %d", cfg.SSH.Port)
+	}
+}
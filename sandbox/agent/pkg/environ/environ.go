@@ -0,0 +1,29 @@
+// Package environ builds the environment of the processes the agent starts
+// on behalf of clients and the workload
+package environ
+
+import (
+	"os"
+	"strings"
+)
+
+// agentPrefix marks the agent's own settings, such as its token
+const agentPrefix = "AGENT_"
+
+// Child returns the agent's environment without its own settings, so
+// commands run in the sandbox cannot read the agent's credentials
+func Child() []string {
+	return Filter(os.Environ())
+}
+
+// Filter strips the agent's own settings from environ
+func Filter(environ []string) []string {
+	env := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, agentPrefix) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
@@ -0,0 +1,15 @@
+package environ
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	env := Filter([]string{"PATH=/usr/bin", "AGENT_TOKEN=secret", "HOME=/root", "AGENT_TLS_KEY=/etc/key.pem"})
+	if len(env) != 2 {
+		t.Fatalf("expected 2 env vars, got %v", env)
+	}
+	for _, kv := range env {
+		if kv == "AGENT_TOKEN=secret" || kv == "AGENT_TLS_KEY=/etc/key.pem" {
+			t.Errorf("expected %q to be stripped", kv)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package filewatch detects changes to files by polling their metadata
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Op describes the kind of change
+type Op string
+
+const (
+	OpCreated  Op = "created"
+	OpModified Op = "modified"
+	OpRemoved  Op = "removed"
+)
+
+// Change is reported for each file that changed between two polls
+type Change struct {
+	Path string
+	Op   Op
+}
+
+// fileState is the metadata compared between polls
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher polls a set of paths. A directory path watches the files directly
+// inside it (not recursively).
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	state    map[string]fileState
+}
+
+// New creates a watcher for the given paths
+func New(paths []string, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Watcher{
+		paths:    paths,
+		interval: interval,
+	}
+}
+
+// Run polls until ctx is cancelled, calling onChange for every change.
+// The first poll only records the initial state.
+func (w *Watcher) Run(ctx context.Context, onChange func(Change)) {
+	w.state = w.scan()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, change := range w.Poll() {
+				onChange(change)
+			}
+		}
+	}
+}
+
+// Poll rescans the watched paths and returns the changes since the last scan
+func (w *Watcher) Poll() []Change {
+	current := w.scan()
+	var changes []Change
+
+	if w.state != nil {
+		for path, st := range current {
+			prev, ok := w.state[path]
+			switch {
+			case !ok:
+				changes = append(changes, Change{Path: path, Op: OpCreated})
+			case prev != st:
+				changes = append(changes, Change{Path: path, Op: OpModified})
+			}
+		}
+		for path := range w.state {
+			if _, ok := current[path]; !ok {
+				changes = append(changes, Change{Path: path, Op: OpRemoved})
+			}
+		}
+	}
+
+	w.state = current
+	return changes
+}
+
+// scan records the metadata of all watched files
+func (w *Watcher) scan() map[string]fileState {
+	state := make(map[string]fileState)
+	for _, path := range w.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			state[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			state[filepath.Join(path, e.Name())] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return state
+}
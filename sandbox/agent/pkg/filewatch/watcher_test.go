@@ -0,0 +1,42 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("v1"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	w := New([]string{dir}, time.Second)
+	if changes := w.Poll(); len(changes) != 0 {
+		t.Fatalf("expected no changes on first poll, got %v", changes)
+	}
+
+	created := filepath.Join(dir, "new.txt")
+	os.WriteFile(created, []byte("hello"), 0644)
+	os.WriteFile(existing, []byte("version 2"), 0644)
+
+	ops := map[string]Op{}
+	for _, c := range w.Poll() {
+		ops[c.Path] = c.Op
+	}
+	if ops[created] != OpCreated {
+		t.Errorf("expected %s created, got %v", created, ops)
+	}
+	if ops[existing] != OpModified {
+		t.Errorf("expected %s modified, got %v", existing, ops)
+	}
+
+	os.Remove(created)
+	changes := w.Poll()
+	if len(changes) != 1 || changes[0].Op != OpRemoved || changes[0].Path != created {
+		t.Errorf("expected %s removed, got %v", created, changes)
+	}
+}
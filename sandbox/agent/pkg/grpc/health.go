@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Readiness checks reported through the gRPC health service
+const (
+	CheckHostKeys = "host-keys" // SSH host keys were loaded
+	CheckSSH      = "ssh"       // SSH server is accepting connections
+	CheckWorkload = "workload"  // Supervised workload is running
+)
+
+// ServiceViable is SERVING while the agent can still become ready and
+// NOT_SERVING once a check has failed for good, so clients waiting for
+// readiness can give up early
+const ServiceViable = "viable"
+
+// Readiness aggregates named checks into the standard grpc.health.v1
+// service. Each check is also exposed as its own service name, and the
+// overall ("") service is SERVING once every registered check has passed.
+type Readiness struct {
+	mu     sync.Mutex
+	checks map[string]bool
+	failed map[string]bool // Checks that failed for good
+	health *health.Server
+}
+
+// NewReadiness creates a readiness tracker with the given checks, all
+// initially failing
+func NewReadiness(checks ...string) *Readiness {
+	r := &Readiness{
+		checks: make(map[string]bool),
+		failed: make(map[string]bool),
+		health: health.NewServer(),
+	}
+	r.health.SetServingStatus(ServiceViable, healthpb.HealthCheckResponse_SERVING)
+	for _, check := range checks {
+		r.checks[check] = false
+		r.health.SetServingStatus(check, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	r.update()
+	return r
+}
+
+// Set records the result of a check. Unknown checks are registered on
+// first use, and checks that have failed for good are left failed.
+func (r *Readiness) Set(check string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed[check] {
+		return
+	}
+	r.checks[check] = ok
+	r.health.SetServingStatus(check, servingStatus(ok))
+	r.update()
+}
+
+// Fail marks a check as failed for good. The agent can no longer become
+// ready, which is reported through ServiceViable.
+func (r *Readiness) Fail(check string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failed[check] = true
+	r.checks[check] = false
+	r.health.SetServingStatus(check, healthpb.HealthCheckResponse_NOT_SERVING)
+	r.health.SetServingStatus(ServiceViable, healthpb.HealthCheckResponse_NOT_SERVING)
+	r.update()
+}
+
+// Ready reports whether every check has passed
+func (r *Readiness) Ready() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready()
+}
+
+// Shutdown marks every service as NOT_SERVING and ignores later updates,
+// so clients stop routing to the agent while it drains
+func (r *Readiness) Shutdown() {
+	r.health.Shutdown()
+}
+
+// update sets the overall serving status. Callers must hold r.mu.
+func (r *Readiness) update() {
+	r.health.SetServingStatus("", servingStatus(r.ready()))
+}
+
+// ready reports whether every check has passed. Callers must hold r.mu.
+func (r *Readiness) ready() bool {
+	for _, ok := range r.checks {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,98 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadiness(t *testing.T) {
+	r := NewReadiness(CheckHostKeys, CheckSSH)
+	if r.Ready() {
+		t.Fatal("expected not ready before checks pass")
+	}
+
+	r.Set(CheckHostKeys, true)
+	if r.Ready() {
+		t.Fatal("expected not ready with a failing check")
+	}
+
+	r.Set(CheckSSH, true)
+	if !r.Ready() {
+		t.Fatal("expected ready once all checks pass")
+	}
+
+	r.Set(CheckWorkload, false)
+	if r.Ready() {
+		t.Fatal("expected a new failing check to clear readiness")
+	}
+
+	r.Fail(CheckWorkload)
+	r.Set(CheckWorkload, true)
+	if r.Ready() {
+		t.Fatal("expected a check that failed for good to stay failed")
+	}
+}
+
+func TestHealthService(t *testing.T) {
+	readiness := NewReadiness(CheckSSH)
+	server := NewServer(0, "sbox-123", "secret")
+	server.SetReadiness(readiness)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.StartWithListener(ctx, lis); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("health check %q failed: %v", service, err)
+		}
+		return resp.Status
+	}
+
+	if got := check(""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING, got %s", got)
+	}
+
+	readiness.Set(CheckSSH, true)
+	if got := check(""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %s", got)
+	}
+	if got := check(CheckSSH); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected %s SERVING, got %s", CheckSSH, got)
+	}
+
+	if got := check(ServiceViable); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected %s SERVING, got %s", ServiceViable, got)
+	}
+	readiness.Fail(CheckSSH)
+	if got := check(ServiceViable); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected %s NOT_SERVING after a failure, got %s", ServiceViable, got)
+	}
+
+	readiness.Shutdown()
+	if got := check(""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING after shutdown, got %s", got)
+	}
+}
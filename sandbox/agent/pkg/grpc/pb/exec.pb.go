@@ -67,6 +67,52 @@ func (OutputChannel) EnumDescriptor() ([]byte, []int) {
 	return file_proto_exec_proto_rawDescGZIP(), []int{0}
 }
 
+type PortState int32
+
+const (
+	PortState_PORT_OPEN   PortState = 0
+	PortState_PORT_CLOSED PortState = 1
+)
+
+// Enum value maps for PortState.
+var (
+	PortState_name = map[int32]string{
+		0: "PORT_OPEN",
+		1: "PORT_CLOSED",
+	}
+	PortState_value = map[string]int32{
+		"PORT_OPEN":   0,
+		"PORT_CLOSED": 1,
+	}
+)
+
+func (x PortState) Enum() *PortState {
+	p := new(PortState)
+	*p = x
+	return p
+}
+
+func (x PortState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PortState) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_exec_proto_enumTypes[1].Descriptor()
+}
+
+func (PortState) Type() protoreflect.EnumType {
+	return &file_proto_exec_proto_enumTypes[1]
+}
+
+func (x PortState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PortState.Descriptor instead.
+func (PortState) EnumDescriptor() ([]byte, []int) {
+	return file_proto_exec_proto_rawDescGZIP(), []int{1}
+}
+
 type OpenSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SandboxId     string                 `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId,proto3" json:"sandbox_id,omitempty"`
@@ -255,6 +301,126 @@ func (x *CommandOutput) GetExitCode() int32 {
 	return 0
 }
 
+type WatchPortsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchPortsRequest) Reset() {
+	*x = WatchPortsRequest{}
+	mi := &file_proto_exec_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchPortsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchPortsRequest) ProtoMessage() {}
+
+func (x *WatchPortsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_exec_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchPortsRequest.ProtoReflect.Descriptor instead.
+func (*WatchPortsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_exec_proto_rawDescGZIP(), []int{3}
+}
+
+type PortEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         PortState              `protobuf:"varint,1,opt,name=state,proto3,enum=grpc.PortState" json:"state,omitempty"`
+	Port          uint32                 `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	Address       string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	Protocol      string                 `protobuf:"bytes,4,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Pid           int32                  `protobuf:"varint,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	Process       string                 `protobuf:"bytes,6,opt,name=process,proto3" json:"process,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PortEvent) Reset() {
+	*x = PortEvent{}
+	mi := &file_proto_exec_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PortEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortEvent) ProtoMessage() {}
+
+func (x *PortEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_exec_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortEvent.ProtoReflect.Descriptor instead.
+func (*PortEvent) Descriptor() ([]byte, []int) {
+	return file_proto_exec_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PortEvent) GetState() PortState {
+	if x != nil {
+		return x.State
+	}
+	return PortState_PORT_OPEN
+}
+
+func (x *PortEvent) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *PortEvent) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *PortEvent) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *PortEvent) GetPid() int32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *PortEvent) GetProcess() string {
+	if x != nil {
+		return x.Process
+	}
+	return ""
+}
+
 var File_proto_exec_proto protoreflect.FileDescriptor
 
 const file_proto_exec_proto_rawDesc = "" +
@@ -276,15 +442,28 @@ const file_proto_exec_proto_rawDesc = "" +
 	"\x04line\x18\x01 \x01(\tR\x04line\x12-\n" +
 	"\achannel\x18\x02 \x01(\x0e2\x13.grpc.OutputChannelR\achannel\x12\x10\n" +
 	"\x03end\x18\x03 \x01(\bR\x03end\x12\x1b\n" +
-	"\texit_code\x18\x04 \x01(\x05R\bexitCode*'\n" +
+	"\texit_code\x18\x04 \x01(\x05R\bexitCode\"\x13\n" +
+	"\x11WatchPortsRequest\"\xa8\x01\n" +
+	"\tPortEvent\x12%\n" +
+	"\x05state\x18\x01 \x01(\x0e2\x0f.grpc.PortStateR\x05state\x12\x12\n" +
+	"\x04port\x18\x02 \x01(\rR\x04port\x12\x18\n" +
+	"\aaddress\x18\x03 \x01(\tR\aaddress\x12\x1a\n" +
+	"\bprotocol\x18\x04 \x01(\tR\bprotocol\x12\x10\n" +
+	"\x03pid\x18\x05 \x01(\x05R\x03pid\x12\x18\n" +
+	"\aprocess\x18\x06 \x01(\tR\aprocess*'\n" +
 	"\rOutputChannel\x12\n" +
 	"\n" +
 	"\x06STDOUT\x10\x00\x12\n" +
 	"\n" +
-	"\x06STDERR\x10\x012\x85\x01\n" +
+	"\x06STDERR\x10\x01*+\n" +
+	"\tPortState\x12\r\n" +
+	"\tPORT_OPEN\x10\x00\x12\x0f\n" +
+	"\vPORT_CLOSED\x10\x012\xbf\x01\n" +
 	"\vExecService\x12>\n" +
 	"\vOpenSession\x12\x18.grpc.OpenSessionRequest\x1a\x13.grpc.CommandOutput0\x01\x126\n" +
-	"\aExecute\x12\x14.grpc.ExecuteRequest\x1a\x13.grpc.CommandOutput0\x01B6Z4github.com/codepod/codepod/sandbox/agent/pkg/grpc/pbb\x06proto3"
+	"\aExecute\x12\x14.grpc.ExecuteRequest\x1a\x13.grpc.CommandOutput0\x01\x128\n" +
+	"\n" +
+	"WatchPorts\x12\x17.grpc.WatchPortsRequest\x1a\x0f.grpc.PortEvent0\x01B6Z4github.com/codepod/codepod/sandbox/agent/pkg/grpc/pbb\x06proto3"
 
 var (
 	file_proto_exec_proto_rawDescOnce sync.Once
@@ -298,27 +477,33 @@ func file_proto_exec_proto_rawDescGZIP() []byte {
 	return file_proto_exec_proto_rawDescData
 }
 
-var file_proto_exec_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_exec_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_exec_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_exec_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_proto_exec_proto_goTypes = []any{
 	(OutputChannel)(0),         // 0: grpc.OutputChannel
-	(*OpenSessionRequest)(nil), // 1: grpc.OpenSessionRequest
-	(*ExecuteRequest)(nil),     // 2: grpc.ExecuteRequest
-	(*CommandOutput)(nil),      // 3: grpc.CommandOutput
-	nil,                        // 4: grpc.ExecuteRequest.EnvEntry
+	(PortState)(0),             // 1: grpc.PortState
+	(*OpenSessionRequest)(nil), // 2: grpc.OpenSessionRequest
+	(*ExecuteRequest)(nil),     // 3: grpc.ExecuteRequest
+	(*CommandOutput)(nil),      // 4: grpc.CommandOutput
+	(*WatchPortsRequest)(nil),  // 5: grpc.WatchPortsRequest
+	(*PortEvent)(nil),          // 6: grpc.PortEvent
+	nil,                        // 7: grpc.ExecuteRequest.EnvEntry
 }
 var file_proto_exec_proto_depIdxs = []int32{
-	4, // 0: grpc.ExecuteRequest.env:type_name -> grpc.ExecuteRequest.EnvEntry
+	7, // 0: grpc.ExecuteRequest.env:type_name -> grpc.ExecuteRequest.EnvEntry
 	0, // 1: grpc.CommandOutput.channel:type_name -> grpc.OutputChannel
-	1, // 2: grpc.ExecService.OpenSession:input_type -> grpc.OpenSessionRequest
-	2, // 3: grpc.ExecService.Execute:input_type -> grpc.ExecuteRequest
-	3, // 4: grpc.ExecService.OpenSession:output_type -> grpc.CommandOutput
-	3, // 5: grpc.ExecService.Execute:output_type -> grpc.CommandOutput
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	1, // 2: grpc.PortEvent.state:type_name -> grpc.PortState
+	2, // 3: grpc.ExecService.OpenSession:input_type -> grpc.OpenSessionRequest
+	3, // 4: grpc.ExecService.Execute:input_type -> grpc.ExecuteRequest
+	5, // 5: grpc.ExecService.WatchPorts:input_type -> grpc.WatchPortsRequest
+	4, // 6: grpc.ExecService.OpenSession:output_type -> grpc.CommandOutput
+	4, // 7: grpc.ExecService.Execute:output_type -> grpc.CommandOutput
+	6, // 8: grpc.ExecService.WatchPorts:output_type -> grpc.PortEvent
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_exec_proto_init() }
@@ -331,8 +516,8 @@ func file_proto_exec_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_exec_proto_rawDesc), len(file_proto_exec_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   4,
+			NumEnums:      2,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	ExecService_OpenSession_FullMethodName = "/grpc.ExecService/OpenSession"
 	ExecService_Execute_FullMethodName     = "/grpc.ExecService/Execute"
+	ExecService_WatchPorts_FullMethodName  = "/grpc.ExecService/WatchPorts"
 )
 
 // ExecServiceClient is the client API for ExecService service.
@@ -31,6 +32,9 @@ type ExecServiceClient interface {
 	OpenSession(ctx context.Context, in *OpenSessionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CommandOutput], error)
 	// Execute runs a single command
 	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CommandOutput], error)
+	// WatchPorts streams listening TCP ports, first those already open, then
+	// each one as it opens or closes
+	WatchPorts(ctx context.Context, in *WatchPortsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PortEvent], error)
 }
 
 type execServiceClient struct {
@@ -79,6 +83,25 @@ func (c *execServiceClient) Execute(ctx context.Context, in *ExecuteRequest, opt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ExecService_ExecuteClient = grpc.ServerStreamingClient[CommandOutput]
 
+func (c *execServiceClient) WatchPorts(ctx context.Context, in *WatchPortsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PortEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ExecService_ServiceDesc.Streams[2], ExecService_WatchPorts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchPortsRequest, PortEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecService_WatchPortsClient = grpc.ServerStreamingClient[PortEvent]
+
 // ExecServiceServer is the server API for ExecService service.
 // All implementations must embed UnimplementedExecServiceServer
 // for forward compatibility.
@@ -87,6 +110,9 @@ type ExecServiceServer interface {
 	OpenSession(*OpenSessionRequest, grpc.ServerStreamingServer[CommandOutput]) error
 	// Execute runs a single command
 	Execute(*ExecuteRequest, grpc.ServerStreamingServer[CommandOutput]) error
+	// WatchPorts streams listening TCP ports, first those already open, then
+	// each one as it opens or closes
+	WatchPorts(*WatchPortsRequest, grpc.ServerStreamingServer[PortEvent]) error
 	mustEmbedUnimplementedExecServiceServer()
 }
 
@@ -103,6 +129,9 @@ func (UnimplementedExecServiceServer) OpenSession(*OpenSessionRequest, grpc.Serv
 func (UnimplementedExecServiceServer) Execute(*ExecuteRequest, grpc.ServerStreamingServer[CommandOutput]) error {
 	return status.Error(codes.Unimplemented, "method Execute not implemented")
 }
+func (UnimplementedExecServiceServer) WatchPorts(*WatchPortsRequest, grpc.ServerStreamingServer[PortEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchPorts not implemented")
+}
 func (UnimplementedExecServiceServer) mustEmbedUnimplementedExecServiceServer() {}
 func (UnimplementedExecServiceServer) testEmbeddedByValue()                     {}
 
@@ -146,6 +175,17 @@ func _ExecService_Execute_Handler(srv interface{}, stream grpc.ServerStream) err
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type ExecService_ExecuteServer = grpc.ServerStreamingServer[CommandOutput]
 
+func _ExecService_WatchPorts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPortsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExecServiceServer).WatchPorts(m, &grpc.GenericServerStream[WatchPortsRequest, PortEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ExecService_WatchPortsServer = grpc.ServerStreamingServer[PortEvent]
+
 // ExecService_ServiceDesc is the grpc.ServiceDesc for ExecService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -164,6 +204,11 @@ var ExecService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _ExecService_Execute_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchPorts",
+			Handler:       _ExecService_WatchPorts_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/exec.proto",
 }
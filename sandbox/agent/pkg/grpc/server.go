@@ -4,38 +4,94 @@ package grpc
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
 	"net"
-	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/codepod/codepod/libs/go-common/tracing"
+	"github.com/codepod/codepod/sandbox/agent/pkg/auth"
+	"github.com/codepod/codepod/sandbox/agent/pkg/environ"
 	"github.com/codepod/codepod/sandbox/agent/pkg/grpc/pb"
+	"github.com/codepod/codepod/sandbox/agent/pkg/metrics"
+	"github.com/codepod/codepod/sandbox/agent/pkg/ports"
+	"github.com/codepod/codepod/sandbox/agent/pkg/reporter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+var logger = logging.Component("grpc")
+
+var tracer = otel.Tracer("github.com/codepod/codepod/sandbox/agent/pkg/grpc")
+
 // Server represents the gRPC execution server
 type Server struct {
 	pb.UnimplementedExecServiceServer
-	port   int
-	token  string
-	mu     sync.Mutex
-	conns  int
+	port     int
+	verifier *auth.Verifier
+	mu       sync.Mutex
+	conns    int
+
+	readiness  *Readiness
+	ports      *ports.Watcher
+	events     reporter.Emitter // Receives auth failure events (optional)
+	reflection bool
+	tlsConfig  atomic.Pointer[tls.Config]
+	grpcServer *grpc.Server
 }
 
-// NewServer creates a new gRPC server
-func NewServer(port int, token string) *Server {
+// NewServer creates a new gRPC server. Callers authenticate with the
+// sandbox's agent token or a scoped token the server signed with it.
+func NewServer(port int, sandboxID, token string) *Server {
 	return &Server{
-		port:  port,
-		token: token,
+		port:     port,
+		verifier: auth.NewVerifier(sandboxID, token),
 	}
 }
 
+// SetReadiness sets the readiness tracker served by the grpc.health.v1 service
+func (s *Server) SetReadiness(r *Readiness) {
+	s.readiness = r
+}
+
+// SetPortWatcher serves listening ports found by w through WatchPorts
+func (s *Server) SetPortWatcher(w *ports.Watcher) {
+	s.ports = w
+}
+
+// SetEvents reports rejected calls to e
+func (s *Server) SetEvents(e reporter.Emitter) {
+	s.events = e
+}
+
+// EnableReflection registers the server reflection service (for grpcurl)
+func (s *Server) EnableReflection(enabled bool) {
+	s.reflection = enabled
+}
+
+// SetTLSConfig serves gRPC over TLS; the listener must carry TLS connections.
+// Once serving over TLS, calling it again replaces the certificate and client
+// CA for new connections.
+func (s *Server) SetTLSConfig(cfg *tls.Config) {
+	s.tlsConfig.Store(cfg)
+}
+
 // Start starts the gRPC server
 func (s *Server) Start(ctx context.Context) error {
 	addr := fmt.Sprintf(":%d", s.port)
@@ -55,21 +111,45 @@ func (s *Server) StartWithListener(ctx context.Context, lis net.Listener) error
 // serve starts the gRPC server with the given listener
 func (s *Server) serve(ctx context.Context, lis net.Listener) error {
 	// Configure gRPC server with keepalive
-	grpcServer := grpc.NewServer(
+	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
-			Time:    30 * time.Second,    // send keepalive every 30s
-			Timeout: 10 * time.Second,    // timeout for keepalive response
+			Time:    30 * time.Second, // send keepalive every 30s
+			Timeout: 10 * time.Second, // timeout for keepalive response
 		}),
-		grpc.StreamInterceptor(s.authStreamInterceptor),
-	)
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor(), s.authStreamInterceptor),
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(), s.authUnaryInterceptor),
+	}
+	if s.tlsConfig.Load() != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return s.tlsConfig.Load(), nil
+			},
+		})))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	s.mu.Lock()
+	s.grpcServer = grpcServer
+	s.mu.Unlock()
 
 	pb.RegisterExecServiceServer(grpcServer, s)
 
-	log.Printf("gRPC server listening on %s", lis.Addr().String())
+	// Health checks are answered without a token so probes need no secrets
+	if s.readiness == nil {
+		s.readiness = NewReadiness()
+	}
+	healthpb.RegisterHealthServer(grpcServer, s.readiness.health)
+
+	if s.reflection {
+		logger.Info("gRPC server reflection enabled")
+		reflection.Register(grpcServer)
+	}
+
+	logger.Info("gRPC server listening", "addr", lis.Addr().String())
 
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil && ctx.Err() == nil {
-			log.Printf("gRPC server error: %v", err)
+			logger.Error("gRPC server error", "error", err)
 		}
 	}()
 
@@ -81,6 +161,33 @@ func (s *Server) serve(ctx context.Context, lis net.Listener) error {
 	return nil
 }
 
+// Drain stops accepting connections and RPCs and waits for running RPCs,
+// such as Execute streams, to finish. RPCs still running when ctx is done are
+// cancelled, which kills their commands.
+func (s *Server) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	grpcServer := s.grpcServer
+	s.mu.Unlock()
+	if grpcServer == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		logger.Warn("Cancelling gRPC calls still running after the grace period")
+		grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
 // CreateListener creates a TCP listener with retry logic
 func CreateListener(addr string) (net.Listener, error) {
 	var ln net.Listener
@@ -97,42 +204,122 @@ func CreateListener(addr string) (net.Listener, error) {
 	return nil, err
 }
 
-// authStreamInterceptor validates the token from metadata
+// methodScopes maps RPCs to the scope a token needs to call them. RPCs not
+// listed here require the full agent token.
+var methodScopes = map[string]auth.Scope{
+	pb.ExecService_Execute_FullMethodName:     auth.ScopeExec,
+	pb.ExecService_OpenSession_FullMethodName: auth.ScopeExec,
+}
+
+// authStreamInterceptor authenticates streaming RPCs
 func (s *Server) authStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	if err := s.validateToken(ss.Context()); err != nil {
+	ctx, err := s.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
 		return err
 	}
-	return handler(srv, ss)
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
 }
 
-// validateToken validates the token from gRPC metadata
-func (s *Server) validateToken(ctx context.Context) error {
-	md, ok := metadata.FromIncomingContext(ctx)
+// authUnaryInterceptor authenticates unary RPCs
+func (s *Server) authUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authorize verifies the caller's token and checks that it grants the
+// method's scope. The returned context carries the token's claims.
+func (s *Server) authorize(ctx context.Context, method string) (context.Context, error) {
+	// Health checks are answered without a token so probes need no secrets
+	if isHealthMethod(method) {
+		return ctx, nil
+	}
+
+	claims, err := s.verifier.Verify(tokenFromContext(ctx))
+	if err != nil {
+		logger.Warn("Rejected call", "method", method, "peer", peerAddr(ctx), "error", err)
+		s.authFailed(ctx, method, err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	scope, ok := methodScopes[method]
 	if !ok {
-		log.Printf("DEBUG: No metadata in gRPC request")
-		return fmt.Errorf("missing metadata")
+		scope = auth.ScopeAll
 	}
+	if !claims.Allows(scope) {
+		logger.Warn("Rejected call: token lacks scope", "method", method, "peer", peerAddr(ctx), "scope", scope)
+		s.authFailed(ctx, method, fmt.Sprintf("token lacks %q scope", scope))
+		return nil, status.Errorf(codes.PermissionDenied, "token lacks %q scope", scope)
+	}
+	return auth.NewContext(ctx, claims), nil
+}
 
-	tokens := md.Get("token")
-	if len(tokens) == 0 {
-		log.Printf("DEBUG: No token in metadata")
-		return fmt.Errorf("missing token")
+// authFailed counts a rejected call and reports it as an event. The token
+// itself is left out.
+func (s *Server) authFailed(ctx context.Context, method, reason string) {
+	metrics.AuthFailures.WithLabelValues(metrics.TransportGRPC).Inc()
+	if s.events == nil {
+		return
 	}
+	s.events.Emit(reporter.Event{
+		Type:     reporter.EventAuthFailed,
+		Severity: reporter.SeverityWarning,
+		Attributes: map[string]string{
+			"method":     method,
+			"remoteAddr": peerAddr(ctx),
+			"reason":     reason,
+		},
+	})
+}
 
-	log.Printf("DEBUG: Received token: '%s' (len=%d), expected: '%s' (len=%d)", tokens[0], len(tokens[0]), s.token, len(s.token))
+// peerAddr returns the caller's address, which is the real client address
+// when the multiplexer accepted a PROXY protocol header
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
 
-	// TEMPORARILY DISABLED FOR DEBUGGING
-	// if tokens[0] != s.token {
-	// 	return fmt.Errorf("invalid token")
-	// }
+// isHealthMethod reports whether method belongs to the gRPC health service
+func isHealthMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
 
-	return nil
+// tokenFromContext reads the token from the "token" metadata key or a bearer
+// authorization header
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if tokens := md.Get("token"); len(tokens) > 0 {
+		return tokens[0]
+	}
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// authenticatedStream carries the caller's claims in the stream context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
 }
 
 // OpenSession opens an execution session for multiplexing.
 // This allows a single connection to execute multiple commands.
 func (s *Server) OpenSession(req *pb.OpenSessionRequest, stream pb.ExecService_OpenSessionServer) error {
-	log.Printf("OpenSession: sandbox_id=%s", req.SandboxId)
+	logger.Info("OpenSession", "sandbox_id", req.SandboxId)
 
 	// Send welcome message
 	if err := stream.Send(&pb.CommandOutput{
@@ -147,7 +334,7 @@ func (s *Server) OpenSession(req *pb.OpenSessionRequest, stream pb.ExecService_O
 	// For now, we'll just wait for the context to be cancelled
 	<-stream.Context().Done()
 
-	log.Printf("OpenSession closed: sandbox_id=%s", req.SandboxId)
+	logger.Info("OpenSession closed", "sandbox_id", req.SandboxId)
 	return nil
 }
 
@@ -157,7 +344,12 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 	s.conns++
 	connCount := s.conns
 	s.mu.Unlock()
-	log.Printf("Execute: command=%s, cwd=%s, timeout=%d, active_connections=%d", req.Command, req.Cwd, req.Timeout, connCount)
+	log := logger.With("exec_id", connCount)
+	log.Info("Execute", "command", req.Command, "cwd", req.Cwd, "timeout_ms", req.Timeout)
+	start := time.Now()
+
+	_, span := tracer.Start(stream.Context(), "agent.exec", trace.WithAttributes(attribute.Int("exec.id", connCount)))
+	defer span.End()
 
 	// Build the command
 	cmd := exec.Command("sh", "-c", req.Command)
@@ -167,16 +359,13 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 		cmd.Dir = req.Cwd
 	}
 
-	// Set environment variables
-	if len(req.Env) > 0 {
-		for k, v := range req.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
+	// Inherit the agent's environment without its own settings, then apply
+	// the requested variables, which take precedence
+	cmd.Env = environ.Child()
+	for k, v := range req.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	// Also inherit current environment
-	cmd.Env = append(cmd.Env, os.Environ()...)
-
 	// Create pipes for stdout and stderr
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -231,23 +420,7 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 		}
 	}()
 
-	// Wait for command to complete and close pipes
-	// Use context to properly terminate goroutine if context is cancelled
-	cmdCtx, cmdCancel := context.WithCancel(ctx)
-	go func() {
-		defer cmdCancel()
-		cmd.Wait()
-		stdoutPipe.Close()
-		stderrPipe.Close()
-	}()
-
-	// Ensure command is cleaned up when context is cancelled
-	defer func() {
-		if cmdCtx.Err() != nil && cmd.Process != nil {
-			cmd.Process.Kill()
-		}
-	}()
-
+	// The pipes must be read to EOF before cmd.Wait, which closes them
 	// Wait for all streaming to complete or context cancellation
 	doneChan := make(chan struct{})
 	go func() {
@@ -264,6 +437,7 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 			// Only kill if process is still running
 			if cmd.Process != nil {
 				cmd.Process.Kill()
+				cmd.Wait()
 			}
 			return err
 		}
@@ -271,6 +445,7 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 		// Only kill if process is still running
 		if cmd.Process != nil {
 			cmd.Process.Kill()
+			cmd.Wait()
 		}
 		return ctx.Err()
 	}
@@ -297,12 +472,62 @@ func (s *Server) Execute(req *pb.ExecuteRequest, stream pb.ExecService_ExecuteSe
 		return err
 	}
 
-	log.Printf("Execute completed: command=%s, exit_code=%d", req.Command, exitCode)
+	metrics.ObserveExec(metrics.TransportGRPC, int(exitCode), time.Since(start))
+	span.SetAttributes(attribute.Int("process.exit_code", int(exitCode)))
+	log.Info("Execute completed", "command", req.Command, "exit_code", exitCode)
 	return nil
 }
 
+// WatchPorts sends the ports already listening, then each port as it opens
+// or closes, until the client disconnects or the agent shuts down
+func (s *Server) WatchPorts(req *pb.WatchPortsRequest, stream pb.ExecService_WatchPortsServer) error {
+	if s.ports == nil {
+		return status.Error(codes.FailedPrecondition, "port discovery is disabled")
+	}
+
+	open, changes, cancel := s.ports.Subscribe()
+	defer cancel()
+
+	for _, p := range open {
+		if err := stream.Send(portEvent(p, pb.PortState_PORT_OPEN)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-changes:
+			if !ok {
+				return status.Error(codes.Unavailable, "port watch ended, reconnect to resume")
+			}
+			state := pb.PortState_PORT_OPEN
+			if change.Op == ports.OpClosed {
+				state = pb.PortState_PORT_CLOSED
+			}
+			if err := stream.Send(portEvent(change.Port, state)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// portEvent converts a listening port to its protobuf message
+func portEvent(p ports.Port, state pb.PortState) *pb.PortEvent {
+	return &pb.PortEvent{
+		State:    state,
+		Port:     uint32(p.Port),
+		Address:  p.Address,
+		Protocol: p.Protocol,
+		Pid:      int32(p.PID),
+		Process:  p.Process,
+	}
+}
+
 // streamOutput reads from the reader and streams to the client
 func streamOutput(reader *bufio.Reader, stream pb.ExecService_ExecuteServer, channel pb.OutputChannel) error {
+	streamed := metrics.StreamedBytes.WithLabelValues(metrics.TransportGRPC, strings.ToLower(channel.String()))
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -323,5 +548,6 @@ func streamOutput(reader *bufio.Reader, stream pb.ExecService_ExecuteServer, cha
 		}); err != nil {
 			return err
 		}
+		streamed.Add(float64(len(line)))
 	}
 }
@@ -0,0 +1,281 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/agent/pkg/auth"
+	"github.com/codepod/codepod/sandbox/agent/pkg/grpc/pb"
+	"github.com/codepod/codepod/sandbox/agent/pkg/ports"
+	"github.com/codepod/codepod/sandbox/agent/pkg/reporter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	executeMethod     = "/grpc.ExecService/Execute"
+	openSessionMethod = "/grpc.ExecService/OpenSession"
+	healthMethod      = "/grpc.health.v1.Health/Check"
+)
+
+func TestAuthorize(t *testing.T) {
+	server := NewServer(0, "sbox-123", "secret")
+	expires := time.Now().Add(time.Hour).Unix()
+
+	execToken, _ := auth.Sign("secret", &auth.Claims{SandboxID: "sbox-123", Scopes: []auth.Scope{auth.ScopeExec}, ExpiresAt: expires})
+	otherToken, _ := auth.Sign("secret", &auth.Claims{SandboxID: "sbox-123", Scopes: []auth.Scope{"logs"}, ExpiresAt: expires})
+	expiredToken, _ := auth.Sign("secret", &auth.Claims{SandboxID: "sbox-123", Scopes: []auth.Scope{auth.ScopeExec}, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		method string
+		code   codes.Code
+	}{
+		{"missing token", nil, executeMethod, codes.Unauthenticated},
+		{"wrong token", metadata.Pairs("token", "wrong"), executeMethod, codes.Unauthenticated},
+		{"agent token", metadata.Pairs("token", "secret"), executeMethod, codes.OK},
+		{"bearer token", metadata.Pairs("authorization", "Bearer secret"), openSessionMethod, codes.OK},
+		{"exec token", metadata.Pairs("token", execToken), executeMethod, codes.OK},
+		{"token without exec scope", metadata.Pairs("token", otherToken), executeMethod, codes.PermissionDenied},
+		{"expired token", metadata.Pairs("token", expiredToken), executeMethod, codes.Unauthenticated},
+		{"exec token on other method", metadata.Pairs("token", execToken), "/grpc.ExecService/Other", codes.PermissionDenied},
+		{"health without token", nil, healthMethod, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			ctx, err := server.authorize(ctx, tt.method)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("expected %s, got %s (%v)", tt.code, code, err)
+			}
+			if tt.code == codes.OK && tt.method != healthMethod {
+				if _, ok := auth.FromContext(ctx); !ok {
+					t.Error("expected claims in context")
+				}
+			}
+		})
+	}
+}
+
+// recordingEmitter keeps the events emitted to it
+type recordingEmitter struct {
+	events []reporter.Event
+}
+
+func (r *recordingEmitter) Emit(evt reporter.Event) {
+	r.events = append(r.events, evt)
+}
+
+func TestAuthInterceptorsEmitFailures(t *testing.T) {
+	server := NewServer(0, "sbox-123", "secret")
+	events := &recordingEmitter{}
+	server.SetEvents(events)
+	scoped, _ := auth.Sign("secret", &auth.Claims{SandboxID: "sbox-123", Scopes: []auth.Scope{auth.ScopeExec}, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	call := func(token string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 4242}})
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("token", token))
+	}
+	unary := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	if _, err := server.authUnaryInterceptor(call("wrong"), nil, &grpc.UnaryServerInfo{FullMethod: executeMethod}, unary); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated, got %v", err)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+	if err := server.authStreamInterceptor(nil, &authenticatedStream{ctx: call(scoped)}, &grpc.StreamServerInfo{FullMethod: "/grpc.ExecService/Other"}, stream); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := server.authUnaryInterceptor(call("secret"), nil, &grpc.UnaryServerInfo{FullMethod: executeMethod}, unary); err != nil {
+		t.Fatalf("expected the agent token to be accepted, got %v", err)
+	}
+
+	if len(events.events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events.events)
+	}
+	for i, method := range []string{executeMethod, "/grpc.ExecService/Other"} {
+		evt := events.events[i]
+		if evt.Type != reporter.EventAuthFailed || evt.Attributes["method"] != method || evt.Attributes["remoteAddr"] != "10.0.0.7:4242" {
+			t.Errorf("unexpected event %+v", evt)
+		}
+		for _, value := range evt.Attributes {
+			if value == "wrong" || value == scoped {
+				t.Errorf("event carries the token: %+v", evt)
+			}
+		}
+	}
+}
+
+// startTestServer serves on a local port and returns a connected client
+func startTestServer(t *testing.T, server *Server) pb.ExecServiceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := server.StartWithListener(ctx, lis); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewExecServiceClient(conn)
+}
+
+func TestDrainIdle(t *testing.T) {
+	server := NewServer(0, "sbox-123", "secret")
+	startTestServer(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Drain(ctx); err != nil {
+		t.Errorf("expected idle server to drain, got %v", err)
+	}
+}
+
+func TestDrainCancelsStreamsAfterGracePeriod(t *testing.T) {
+	server := NewServer(0, "sbox-123", "secret")
+	client := startTestServer(t, server)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "token", "secret")
+	stream, err := client.OpenSession(ctx, &pb.OpenSessionRequest{SandboxId: "sbox-123"})
+	if err != nil {
+		t.Fatalf("failed to open session: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("failed to receive welcome message: %v", err)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := server.Drain(drainCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if _, err := stream.Recv(); err == nil {
+		t.Error("expected session stream to be closed")
+	}
+}
+
+func TestWatchPortsDisabled(t *testing.T) {
+	server := NewServer(0, "sbox-123", "secret")
+	client := startTestServer(t, server)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "token", "secret")
+	stream, err := client.WatchPorts(ctx, &pb.WatchPortsRequest{})
+	if err != nil {
+		t.Fatalf("failed to watch ports: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestWatchPorts(t *testing.T) {
+	if _, err := os.Stat("/proc/net/tcp"); err != nil {
+		t.Skip("/proc/net/tcp is not available")
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+	port := uint32(lis.Addr().(*net.TCPAddr).Port)
+
+	watcher := ports.New(time.Second, nil)
+	if _, err := watcher.Poll(); err != nil {
+		t.Fatalf("failed to scan ports: %v", err)
+	}
+
+	server := NewServer(0, "sbox-123", "secret")
+	server.SetPortWatcher(watcher)
+	client := startTestServer(t, server)
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "token", "secret"))
+	defer cancel()
+	stream, err := client.WatchPorts(ctx, &pb.WatchPortsRequest{})
+	if err != nil {
+		t.Fatalf("failed to watch ports: %v", err)
+	}
+
+	// Ports already open are sent first
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("expected port %d to be reported open: %v", port, err)
+		}
+		if event.Port == port {
+			if event.State != pb.PortState_PORT_OPEN || int(event.Pid) != os.Getpid() {
+				t.Errorf("expected port %d open by pid %d, got %v", port, os.Getpid(), event)
+			}
+			break
+		}
+	}
+
+	// Closing the listener is reported on the next poll, along with any
+	// other ports that changed meanwhile, such as the test server's
+	lis.Close()
+	if _, err := watcher.Poll(); err != nil {
+		t.Fatalf("failed to scan ports: %v", err)
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("expected port %d to be reported closed: %v", port, err)
+		}
+		if event.Port == port {
+			if event.State != pb.PortState_PORT_CLOSED {
+				t.Errorf("expected port %d closed, got %v", port, event)
+			}
+			break
+		}
+	}
+}
+
+func TestExecuteHidesAgentSettings(t *testing.T) {
+	t.Setenv("AGENT_TOKEN", "secret")
+	server := NewServer(0, "sbox-123", "secret")
+	client := startTestServer(t, server)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "token", "secret")
+	stream, err := client.Execute(ctx, &pb.ExecuteRequest{
+		Command: `echo "token=$AGENT_TOKEN greeting=$GREETING"`,
+		Env:     map[string]string{"GREETING": "hello"},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+
+	var output string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("failed to receive output: %v", err)
+		}
+		if msg.End {
+			break
+		}
+		output += msg.Line
+	}
+	if output != "token= greeting=hello" {
+		t.Errorf("expected agent token to be hidden from the command, got %q", output)
+	}
+}
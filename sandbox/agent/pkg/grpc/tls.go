@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"slices"
+)
+
+// NewTLSConfig builds the server TLS configuration from PEM data. When
+// clientCA is set, client certificates are verified against it; they are
+// mandatory only if requireClientCert is true. The CA issues certificates
+// for every sandbox and runner, so a client certificate must also name one
+// of allowedClients, such as this sandbox or the runner hosting it.
+func NewTLSConfig(certPEM, keyPEM, clientCAPEM string, requireClientCert bool, allowedClients []string) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(clientCAPEM)) {
+			return nil, fmt.Errorf("failed to parse client CA certificate")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyClientIdentity(cs, allowedClients)
+		}
+	} else if requireClientCert {
+		return nil, fmt.Errorf("client CA is required to verify client certificates")
+	}
+
+	return cfg, nil
+}
+
+// verifyClientIdentity checks that a verified client certificate names one
+// of allowed in its common name or DNS names. Connections without a client
+// certificate are left to the ClientAuth policy.
+func verifyClientIdentity(cs tls.ConnectionState, allowed []string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := cs.PeerCertificates[0]
+	for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		if name != "" && slices.Contains(allowed, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate for %q is not allowed to connect to this agent", leaf.Subject.CommonName)
+}
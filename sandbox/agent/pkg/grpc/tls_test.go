@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA issues certificates the way the server's TLS CA does
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+// issue returns a PEM certificate and key for name with the given usage
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// startTLSServer runs a gRPC server with the given TLS config and returns its address
+func startTLSServer(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	readiness := NewReadiness()
+	server := NewServer(0, "sbox-123", "secret")
+	server.SetReadiness(readiness)
+	server.SetTLSConfig(cfg)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := server.StartWithListener(ctx, lis); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	return lis.Addr().String()
+}
+
+// checkHealth performs a health check over TLS with the given client config
+func checkHealth(t *testing.T, addr string, cfg *tls.Config) error {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "sbox-123", x509.ExtKeyUsageServerAuth)
+
+	cfg, err := NewTLSConfig(certPEM, keyPEM, "", false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addr := startTLSServer(t, cfg)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if err := checkHealth(t, addr, &tls.Config{RootCAs: roots, ServerName: "sbox-123"}); err != nil {
+		t.Errorf("expected TLS health check to succeed: %v", err)
+	}
+	if err := checkHealth(t, addr, &tls.Config{RootCAs: x509.NewCertPool(), ServerName: "sbox-123"}); err == nil {
+		t.Error("expected untrusted server certificate to be rejected")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "sbox-123", x509.ExtKeyUsageServerAuth)
+	clientCertPEM, clientKeyPEM := ca.issue(t, "runner-1", x509.ExtKeyUsageClientAuth)
+
+	cfg, err := NewTLSConfig(certPEM, keyPEM, ca.pem, true, []string{"sbox-123", "runner-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addr := startTLSServer(t, cfg)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if err := checkHealth(t, addr, &tls.Config{RootCAs: roots, ServerName: "sbox-123"}); err == nil {
+		t.Error("expected connection without client certificate to be rejected")
+	}
+
+	clientCert, err := tls.X509KeyPair([]byte(clientCertPEM), []byte(clientKeyPEM))
+	if err != nil {
+		t.Fatalf("failed to load client certificate: %v", err)
+	}
+	if err := checkHealth(t, addr, &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "sbox-123",
+		Certificates: []tls.Certificate{clientCert},
+	}); err != nil {
+		t.Errorf("expected mutual TLS health check to succeed: %v", err)
+	}
+
+	// The CA also issues certificates for other sandboxes, which must not
+	// reach this agent
+	otherCertPEM, otherKeyPEM := ca.issue(t, "sbox-456", x509.ExtKeyUsageClientAuth)
+	otherCert, err := tls.X509KeyPair([]byte(otherCertPEM), []byte(otherKeyPEM))
+	if err != nil {
+		t.Fatalf("failed to load client certificate: %v", err)
+	}
+	if err := checkHealth(t, addr, &tls.Config{
+		RootCAs:      roots,
+		ServerName:   "sbox-123",
+		Certificates: []tls.Certificate{otherCert},
+	}); err == nil {
+		t.Error("expected certificate for another sandbox to be rejected")
+	}
+}
+
+func TestNewTLSConfigRequiresClientCA(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "sbox-123", x509.ExtKeyUsageServerAuth)
+
+	if _, err := NewTLSConfig(certPEM, keyPEM, "", true, nil); err == nil {
+		t.Error("expected error when requiring client certificates without a CA")
+	}
+	if _, err := NewTLSConfig("not a cert", keyPEM, "", false, nil); err == nil {
+		t.Error("expected error for invalid certificate")
+	}
+}
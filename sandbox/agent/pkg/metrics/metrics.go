@@ -0,0 +1,140 @@
+// Package metrics defines the agent's Prometheus metrics and serves them in
+// the text exposition format
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var logger = logging.Component("metrics")
+
+// Path is where metrics are served on the multiplexed port
+const Path = "/metrics"
+
+// Transports label metrics by how a command reached the agent
+const (
+	TransportSSH  = "ssh"
+	TransportGRPC = "grpc"
+)
+
+// Registry holds the agent's metrics along with Go runtime and process
+// metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// Execs counts finished commands by transport and exit code
+	Execs = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_agent_execs_total",
+		Help: "Commands executed, by transport and exit code.",
+	}, []string{"transport", "exit_code"})
+
+	// ExecDuration observes how long commands ran
+	ExecDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "codepod_agent_exec_duration_seconds",
+		Help:    "Command execution latency, by transport.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"transport"})
+
+	// SSHSessions is the number of open SSH sessions
+	SSHSessions = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "codepod_agent_ssh_sessions_active",
+		Help: "Open SSH sessions.",
+	})
+
+	// AuthFailures counts rejected authentication attempts by transport
+	AuthFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_agent_auth_failures_total",
+		Help: "Rejected authentication attempts, by transport.",
+	}, []string{"transport"})
+
+	// StreamedBytes counts bytes streamed between clients and processes
+	StreamedBytes = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_agent_streamed_bytes_total",
+		Help: "Bytes streamed to and from processes, by transport and stream.",
+	}, []string{"transport", "stream"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveExec records a finished command
+func ObserveExec(transport string, exitCode int, duration time.Duration) {
+	Execs.WithLabelValues(transport, strconv.Itoa(exitCode)).Inc()
+	ExecDuration.WithLabelValues(transport).Observe(duration.Seconds())
+}
+
+// CountingWriter returns a writer that adds the bytes written to w to
+// StreamedBytes
+func CountingWriter(w io.Writer, transport, stream string) io.Writer {
+	return &countingWriter{w: w, counter: StreamedBytes.WithLabelValues(transport, stream)}
+}
+
+type countingWriter struct {
+	w       io.Writer
+	counter prometheus.Counter
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.counter.Add(float64(n))
+	return n, err
+}
+
+// Handler serves the registry in the Prometheus text format to clients that
+// send the agent token as a bearer token, since the multiplexed port is
+// published with sandbox previews
+func Handler(token string) http.Handler {
+	metrics := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// Serve serves metrics at Path on lis until ctx is cancelled. It is used when
+// no other HTTP handler owns the multiplexed port.
+func Serve(ctx context.Context, lis net.Listener, token string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler(token))
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	logger.Info("Metrics listening", "addr", lis.Addr().String(), "path", Path)
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveExec(t *testing.T) {
+	before := testutil.ToFloat64(Execs.WithLabelValues(TransportGRPC, "3"))
+	ObserveExec(TransportGRPC, 3, 250*time.Millisecond)
+
+	if got := testutil.ToFloat64(Execs.WithLabelValues(TransportGRPC, "3")); got != before+1 {
+		t.Errorf("expected exec count %v, got %v", before+1, got)
+	}
+}
+
+func TestCountingWriter(t *testing.T) {
+	counter := StreamedBytes.WithLabelValues(TransportSSH, "stdout")
+	before := testutil.ToFloat64(counter)
+
+	var buf bytes.Buffer
+	w := CountingWriter(&buf, TransportSSH, "stdout")
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	if buf.String() != "hello" {
+		t.Errorf("expected data to be passed through, got %q", buf.String())
+	}
+	if got := testutil.ToFloat64(counter); got != before+5 {
+		t.Errorf("expected %v bytes counted, got %v", before+5, got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	AuthFailures.WithLabelValues(TransportSSH).Inc()
+
+	rec := httptest.NewRecorder()
+	Handler("secret").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, Path, nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	Handler("secret").ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, Path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	Handler("secret").ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	for _, name := range []string{"codepod_agent_auth_failures_total", "codepod_agent_ssh_sessions_active", "go_goroutines"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("expected %s in metrics output", name)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, lis, "secret") }()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+lis.Addr().String()+Path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for %s, got %d", Path, resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + lis.Addr().String() + "/other")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for other paths, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
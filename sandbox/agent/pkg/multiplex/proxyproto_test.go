@@ -0,0 +1,128 @@
+package multiplex
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// acceptOne dials the listener, writes data and returns the accepted
+// connection's remote address and the payload read after any header
+func acceptOne(t *testing.T, trusted []string, data []byte) (net.Addr, string, error) {
+	t.Helper()
+	nets, err := ParseTrustedSources(trusted)
+	if err != nil {
+		t.Fatalf("failed to parse trusted sources: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+	pl := &proxyListener{Listener: lis, trusted: nets}
+
+	client, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+	go func() {
+		client.Write(data)
+		client.(*net.TCPConn).CloseWrite()
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	defer conn.Close()
+
+	addr := conn.RemoteAddr()
+	payload, err := io.ReadAll(conn)
+	return addr, string(payload), err
+}
+
+func TestProxyProtocolV1(t *testing.T) {
+	addr, payload, err := acceptOne(t, []string{"127.0.0.0/8"}, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 22\r\nSSH-2.0-test\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr.String() != "203.0.113.7:51234" {
+		t.Errorf("expected 203.0.113.7:51234, got %s", addr)
+	}
+	if payload != "SSH-2.0-test\r\n" {
+		t.Errorf("expected header to be stripped, got %q", payload)
+	}
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x21, 0x11) // v2 PROXY, TCP over IPv4
+	header = binary.BigEndian.AppendUint16(header, 12)
+	header = append(header, 198, 51, 100, 9, 10, 0, 0, 1)
+	header = binary.BigEndian.AppendUint16(header, 40000)
+	header = binary.BigEndian.AppendUint16(header, 22)
+
+	addr, payload, err := acceptOne(t, []string{"127.0.0.1"}, append(header, "SSH-2.0-test\r\n"...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr.String() != "198.51.100.9:40000" {
+		t.Errorf("expected 198.51.100.9:40000, got %s", addr)
+	}
+	if payload != "SSH-2.0-test\r\n" {
+		t.Errorf("expected header to be stripped, got %q", payload)
+	}
+}
+
+func TestProxyProtocolOptional(t *testing.T) {
+	addr, payload, err := acceptOne(t, []string{"127.0.0.1"}, []byte("SSH-2.0-test\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(addr.String()); host != "127.0.0.1" {
+		t.Errorf("expected peer address, got %s", addr)
+	}
+	if payload != "SSH-2.0-test\r\n" {
+		t.Errorf("expected payload to be untouched, got %q", payload)
+	}
+}
+
+func TestProxyProtocolUntrusted(t *testing.T) {
+	data := "PROXY TCP4 203.0.113.7 10.0.0.1 51234 22\r\n"
+	addr, payload, err := acceptOne(t, []string{"10.0.0.0/8"}, []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(addr.String()); host != "127.0.0.1" {
+		t.Errorf("expected untrusted header to be ignored, got %s", addr)
+	}
+	if payload != data {
+		t.Errorf("expected untrusted header to be passed through, got %q", payload)
+	}
+}
+
+func TestProxyProtocolInvalidHeader(t *testing.T) {
+	_, _, err := acceptOne(t, []string{"127.0.0.1"}, []byte("PROXY TCP4 not-an-ip\r\n"))
+	if err == nil {
+		t.Error("expected an error for a malformed header")
+	}
+}
+
+func TestParseTrustedSources(t *testing.T) {
+	nets, err := ParseTrustedSources([]string{"10.0.0.0/8", "192.168.1.5", "::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(nets))
+	}
+	if !nets[1].Contains(net.ParseIP("192.168.1.5")) || nets[1].Contains(net.ParseIP("192.168.1.6")) {
+		t.Error("expected a single IP to match only itself")
+	}
+
+	if _, err := ParseTrustedSources([]string{"not-an-ip"}); err == nil {
+		t.Error("expected an error for an invalid source")
+	}
+}
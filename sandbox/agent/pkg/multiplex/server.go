@@ -1,14 +1,18 @@
-// Package multiplex provides cmux-based SSH + gRPC port multiplexing
+// Package multiplex provides cmux-based SSH + gRPC + HTTP port multiplexing
 package multiplex
 
 import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
 	"github.com/soheilhy/cmux"
 )
 
+var logger = logging.Component("multiplex")
+
 // sshMatcher creates a cmux matcher that detects SSH protocol connections
 // by checking for the "SSH-" protocol header
 func sshMatcher(r io.Reader) bool {
@@ -25,7 +29,13 @@ type Server struct {
 	sshAddr     string
 	sshHandler  func(net.Listener) error
 	grpcHandler func(net.Listener) error
-	listener    net.Listener
+	httpHandler func(net.Listener) error
+	grpcTLS     bool
+	trusted     []*net.IPNet
+
+	mu       sync.Mutex
+	listener net.Listener
+	stopped  bool // Set by Stop so Start reports the closed listener as a clean exit
 }
 
 // New creates a new multiplex server
@@ -37,6 +47,25 @@ func New(sshAddr string, sshHandler func(net.Listener) error, grpcHandler func(n
 	}
 }
 
+// SetHTTPHandler sets the handler for HTTP/1.1 connections. Without one,
+// HTTP/1.1 connections are not matched and get closed.
+func (s *Server) SetHTTPHandler(handler func(net.Listener) error) {
+	s.httpHandler = handler
+}
+
+// SetGRPCTLS routes TLS handshakes, rather than plaintext HTTP/2, to the
+// gRPC handler, which must then terminate TLS itself
+func (s *Server) SetGRPCTLS(enabled bool) {
+	s.grpcTLS = enabled
+}
+
+// SetProxyProtocol accepts PROXY protocol v1/v2 headers from peers in
+// trusted, such as a load balancer in front of the agent, so the SSH and gRPC
+// servers see the real client address. Other peers cannot spoof addresses.
+func (s *Server) SetProxyProtocol(trusted []*net.IPNet) {
+	s.trusted = trusted
+}
+
 // Start starts the multiplexed server
 func (s *Server) Start() error {
 	// Create a TCP listener
@@ -46,7 +75,19 @@ func (s *Server) Start() error {
 	}
 
 	// Store listener reference for graceful shutdown
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
 	s.listener = listener
+	s.mu.Unlock()
+
+	// Read PROXY protocol headers before protocols are matched
+	if len(s.trusted) > 0 {
+		listener = &proxyListener{Listener: listener, trusted: s.trusted}
+	}
 
 	// Create cmux matcher
 	m := cmux.New(listener)
@@ -54,30 +95,55 @@ func (s *Server) Start() error {
 	// Match SSH connections using custom matcher to detect "SSH-" protocol header
 	sshListener := m.Match(sshMatcher)
 
-	// Match HTTP/2 for gRPC
-	grpcListener := m.Match(cmux.HTTP2())
+	// Match HTTP/2 for gRPC, or the TLS handshake when gRPC runs over TLS
+	var grpcListener net.Listener
+	if s.grpcTLS {
+		grpcListener = m.Match(cmux.TLS())
+	} else {
+		grpcListener = m.Match(cmux.HTTP2())
+	}
+
+	// Match HTTP/1.1 (including WebSocket upgrades) for the preview proxy
+	if s.httpHandler != nil {
+		httpListener := m.Match(cmux.HTTP1Fast())
+		go func() {
+			if err := s.httpHandler(httpListener); err != nil {
+				logger.Error("HTTP server error", "error", err)
+			}
+		}()
+	}
 
 	// Start SSH server in goroutine
 	go func() {
 		if err := s.sshHandler(sshListener); err != nil {
-			fmt.Printf("SSH server error: %v\n", err)
+			logger.Error("SSH server error", "error", err)
 		}
 	}()
 
 	// Start gRPC server in goroutine
 	go func() {
 		if err := s.grpcHandler(grpcListener); err != nil {
-			fmt.Printf("gRPC server error: %v\n", err)
+			logger.Error("gRPC server error", "error", err)
 		}
 	}()
 
 	// Start serving - this blocks and routes connections to matched listeners
 	// It will return when the listener is closed (e.g., by Stop())
-	return m.Serve()
+	err = m.Serve()
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return nil
+	}
+	return err
 }
 
-// Stop gracefully stops the multiplexed server
+// Stop gracefully stops the multiplexed server. Start then returns nil.
 func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
 	if s.listener != nil {
 		s.listener.Close()
 	}
@@ -0,0 +1,49 @@
+package multiplex
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStartReturnsNilAfterStop(t *testing.T) {
+	serve := func(lis net.Listener) error {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return err
+			}
+			conn.Close()
+		}
+	}
+	s := New("127.0.0.1:0", serve, serve)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start() }()
+
+	// Wait for the listener so Stop closes a serving server
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		started := s.listener != nil
+		s.mu.Unlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected Start to return nil after Stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+}
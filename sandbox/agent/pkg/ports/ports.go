@@ -0,0 +1,378 @@
+// Package ports discovers listening TCP ports by polling /proc/net/tcp and
+// /proc/net/tcp6, and finds the process that owns each socket
+package ports
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
+)
+
+var logger = logging.Component("ports")
+
+// Port is a TCP port with at least one listening socket
+type Port struct {
+	Port     int
+	Address  string // Local address of the socket, such as 0.0.0.0 or ::1
+	Protocol string // tcp or tcp6
+	PID      int    // Owning process, 0 if it is not visible to the agent
+	Process  string // Command name of the owning process
+}
+
+// Op describes the kind of change
+type Op string
+
+const (
+	OpOpened Op = "opened"
+	OpClosed Op = "closed"
+)
+
+// Change is reported for each port that opened or closed between two polls
+type Change struct {
+	Port Port
+	Op   Op
+}
+
+// stateListen is the TCP_LISTEN socket state in /proc/net/tcp
+const stateListen = "0A"
+
+// subscriberBuffer is how many changes a subscriber may fall behind by
+// before it is dropped
+const subscriberBuffer = 64
+
+// Watcher polls for listening ports and reports them as they open and close
+type Watcher struct {
+	procRoot string
+	interval time.Duration
+	exclude  map[int]bool
+
+	mu     sync.Mutex
+	ports  map[int]Port
+	subs   map[chan Change]struct{}
+	closed bool
+}
+
+// New creates a watcher polling every interval. Ports in exclude, such as
+// the agent's own, are never reported.
+func New(interval time.Duration, exclude []int) *Watcher {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	w := &Watcher{
+		procRoot: "/proc",
+		interval: interval,
+		exclude:  make(map[int]bool),
+		subs:     make(map[chan Change]struct{}),
+	}
+	for _, port := range exclude {
+		w.exclude[port] = true
+	}
+	return w
+}
+
+// Run polls until ctx is cancelled, calling onChange for every change. The
+// first poll only records the ports already open. Subscriptions are closed
+// when Run returns.
+func (w *Watcher) Run(ctx context.Context, onChange func(Change)) {
+	defer w.close()
+
+	if _, err := w.Poll(); err != nil {
+		logger.Warn("Failed to scan listening ports", "error", err)
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changes, err := w.Poll()
+			if err != nil {
+				logger.Warn("Failed to scan listening ports", "error", err)
+				continue
+			}
+			for _, change := range changes {
+				onChange(change)
+			}
+		}
+	}
+}
+
+// Poll rescans the listening ports and returns the changes since the last
+// scan, which are also sent to subscribers. The first scan reports nothing.
+func (w *Watcher) Poll() ([]Change, error) {
+	current, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changes []Change
+	if w.ports != nil {
+		for port, p := range current {
+			if _, ok := w.ports[port]; !ok {
+				changes = append(changes, Change{Port: p, Op: OpOpened})
+			}
+		}
+		for port, p := range w.ports {
+			if _, ok := current[port]; !ok {
+				changes = append(changes, Change{Port: p, Op: OpClosed})
+			}
+		}
+	}
+	// The owner of an open port is looked up once, when it opens
+	for port, p := range w.ports {
+		if _, ok := current[port]; ok {
+			current[port] = p
+		}
+	}
+	w.ports = current
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Port.Port < changes[j].Port.Port })
+	for _, change := range changes {
+		w.publish(change)
+	}
+	return changes, nil
+}
+
+// Listening returns the open ports found by the last poll, sorted by number
+func (w *Watcher) Listening() []Port {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.listening()
+}
+
+// Subscribe returns the open ports and a channel of later changes. The
+// channel is closed when the watcher stops, or when the subscriber falls
+// too far behind. cancel must be called once the subscriber is done.
+func (w *Watcher) Subscribe() (open []Port, changes <-chan Change, cancel func()) {
+	ch := make(chan Change, subscriberBuffer)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		close(ch)
+	} else {
+		w.subs[ch] = struct{}{}
+	}
+	cancel = func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+	return w.listening(), ch, cancel
+}
+
+// listening returns the open ports. Callers must hold w.mu.
+func (w *Watcher) listening() []Port {
+	ports := make([]Port, 0, len(w.ports))
+	for _, p := range w.ports {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// publish sends a change to every subscriber, dropping those whose buffer
+// is full. Callers must hold w.mu.
+func (w *Watcher) publish(change Change) {
+	for ch := range w.subs {
+		select {
+		case ch <- change:
+		default:
+			logger.Warn("Dropping port subscriber that fell behind")
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends every subscription
+func (w *Watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// socket is a listening socket read from /proc/net/tcp{,6}
+type socket struct {
+	port  Port
+	inode string
+}
+
+// scan returns the listening ports, one per port number. IPv4 sockets are
+// preferred over IPv6 ones for the same port.
+func (w *Watcher) scan() (map[int]Port, error) {
+	var sockets []socket
+	for _, protocol := range []string{"tcp", "tcp6"} {
+		found, err := readSockets(filepath.Join(w.procRoot, "net", protocol), protocol)
+		if err != nil {
+			// IPv6 may be disabled in the sandbox
+			if protocol == "tcp6" && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		sockets = append(sockets, found...)
+	}
+
+	ports := make(map[int]Port)
+	inodes := make(map[int]string)
+	for _, s := range sockets {
+		if w.exclude[s.port.Port] {
+			continue
+		}
+		if _, ok := ports[s.port.Port]; !ok {
+			ports[s.port.Port] = s.port
+			inodes[s.port.Port] = s.inode
+		}
+	}
+
+	// Only look up the owners of ports that were not open at the last poll
+	w.mu.Lock()
+	lookup := make(map[string]int)
+	for port, inode := range inodes {
+		if _, known := w.ports[port]; !known && inode != "0" {
+			lookup[inode] = port
+		}
+	}
+	w.mu.Unlock()
+
+	if len(lookup) > 0 {
+		for inode, pid := range w.findOwners(lookup) {
+			p := ports[lookup[inode]]
+			p.PID = pid
+			p.Process = w.processName(pid)
+			ports[lookup[inode]] = p
+		}
+	}
+	return ports, nil
+}
+
+// readSockets parses the listening sockets from a /proc/net/tcp{,6} file
+func readSockets(path, protocol string) ([]socket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sockets []socket
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip the header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != stateListen {
+			continue
+		}
+		addr, port, err := parseAddress(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		sockets = append(sockets, socket{
+			port:  Port{Port: port, Address: addr, Protocol: protocol},
+			inode: fields[9],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sockets, nil
+}
+
+// parseAddress parses a hex address:port, where the address is stored as
+// 32-bit words in host (little-endian) byte order
+func parseAddress(s string) (string, int, error) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid address %q", s)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in %q", s)
+	}
+	ip, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return "", 0, fmt.Errorf("invalid address %q", s)
+	}
+	for i := 0; i < len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+	return net.IP(ip).String(), int(port), nil
+}
+
+// findOwners maps socket inodes to the PIDs of processes holding them, by
+// reading the file descriptor links of every visible process
+func (w *Watcher) findOwners(inodes map[string]int) map[string]int {
+	owners := make(map[string]int)
+	entries, err := os.ReadDir(w.procRoot)
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(w.procRoot, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue // The process exited or belongs to another user
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			inode, ok := strings.CutPrefix(link, "socket:[")
+			if !ok {
+				continue
+			}
+			inode = strings.TrimSuffix(inode, "]")
+			if _, wanted := inodes[inode]; wanted {
+				if _, found := owners[inode]; !found {
+					owners[inode] = pid
+				}
+			}
+		}
+		if len(owners) == len(inodes) {
+			break
+		}
+	}
+	return owners
+}
+
+// processName returns the command name of pid
+func (w *Watcher) processName(pid int) string {
+	comm, err := os.ReadFile(filepath.Join(w.procRoot, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
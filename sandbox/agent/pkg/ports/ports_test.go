@@ -0,0 +1,165 @@
+package ports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const tcpHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+// writeProc writes a fake /proc with the given net/tcp and net/tcp6 bodies
+func writeProc(t *testing.T, root, tcp, tcp6 string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, "net"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "net", "tcp"), []byte(tcpHeader+tcp), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "net", "tcp6"), []byte(tcpHeader+tcp6), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listenLine formats a listening socket entry for /proc/net/tcp
+func listenLine(addr, inode string) string {
+	return "   0: " + addr + " 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 " + inode + " 1 0000000000000000 100 0 0 10 0\n"
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		addr string
+		port int
+	}{
+		{"00000000:1F90", "0.0.0.0", 8080},
+		{"0100007F:1435", "127.0.0.1", 5173},
+		{"00000000000000000000000000000000:0016", "::", 22},
+		{"00000000000000000000000001000000:0BB8", "::1", 3000},
+	}
+	for _, tt := range tests {
+		addr, port, err := parseAddress(tt.in)
+		if err != nil {
+			t.Fatalf("parseAddress(%q): %v", tt.in, err)
+		}
+		if addr != tt.addr || port != tt.port {
+			t.Errorf("parseAddress(%q) = %s, %d; want %s, %d", tt.in, addr, port, tt.addr, tt.port)
+		}
+	}
+
+	if _, _, err := parseAddress("zz:0016"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+func TestPoll(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, listenLine("00000000:0016", "100"), "")
+
+	w := New(time.Second, []int{22})
+	w.procRoot = root
+
+	// The first poll records the ports already open
+	changes, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes on first poll, got %v", changes)
+	}
+
+	// A process starts listening on 5173 over IPv4 and IPv6
+	pidDir := filepath.Join(root, "4242")
+	if err := os.MkdirAll(filepath.Join(pidDir, "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("socket:[200]", filepath.Join(pidDir, "fd", "3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pidDir, "comm"), []byte("node\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeProc(t, root,
+		listenLine("00000000:0016", "100")+listenLine("0100007F:1435", "200"),
+		listenLine("00000000000000000000000000000000:1435", "201"))
+
+	changes, err = w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %v", changes)
+	}
+	want := Port{Port: 5173, Address: "127.0.0.1", Protocol: "tcp", PID: 4242, Process: "node"}
+	if changes[0].Op != OpOpened || changes[0].Port != want {
+		t.Errorf("expected %v opened, got %v %v", want, changes[0].Port, changes[0].Op)
+	}
+
+	if ports := w.Listening(); len(ports) != 1 || ports[0] != want {
+		t.Errorf("expected %v listening, got %v", want, ports)
+	}
+
+	// The port closes
+	writeProc(t, root, listenLine("00000000:0016", "100"), "")
+	changes, err = w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Op != OpClosed || changes[0].Port != want {
+		t.Errorf("expected %v closed, got %v", want, changes)
+	}
+}
+
+func TestPollMissingTCP6(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, listenLine("00000000:1F90", "100"), "")
+	if err := os.Remove(filepath.Join(root, "net", "tcp6")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(time.Second, nil)
+	w.procRoot = root
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ports := w.Listening(); len(ports) != 1 || ports[0].Port != 8080 {
+		t.Errorf("expected port 8080, got %v", ports)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, listenLine("00000000:1F90", "100"), "")
+
+	w := New(time.Second, nil)
+	w.procRoot = root
+	if _, err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	open, changes, cancel := w.Subscribe()
+	defer cancel()
+	if len(open) != 1 || open[0].Port != 8080 {
+		t.Fatalf("expected port 8080 open, got %v", open)
+	}
+
+	writeProc(t, root, "", "")
+	if _, err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case change := <-changes:
+		if change.Op != OpClosed || change.Port.Port != 8080 {
+			t.Errorf("expected port 8080 closed, got %v", change)
+		}
+	default:
+		t.Fatal("expected a change")
+	}
+
+	w.close()
+	if _, ok := <-changes; ok {
+		t.Error("expected subscription to be closed")
+	}
+}
@@ -0,0 +1,270 @@
+// Package preview reverse-proxies HTTP/1.1 requests arriving on the
+// multiplexed agent port to web servers listening inside the sandbox
+package preview
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
+)
+
+var logger = logging.Component("preview")
+
+const (
+	// pathPrefix selects the target port by path: /proxy/<port>/...
+	pathPrefix = "/proxy/"
+
+	// Query parameters of a signed preview URL
+	expiresParam   = "preview_expires"
+	signatureParam = "preview_signature"
+
+	// cookiePrefix names the cookie that keeps a browser authenticated after
+	// it opened a signed preview URL; the target port is appended
+	cookiePrefix = "codepod_preview_"
+)
+
+// Config holds preview proxy settings
+type Config struct {
+	SandboxID   string
+	Token       string // Agent token; also the key preview URLs are signed with
+	DeniedPorts []int  // Ports that are never proxied, such as the agent's own
+
+	// Fallback serves requests that select no preview target, such as
+	// /metrics; without one they get 404
+	Fallback http.Handler
+}
+
+// Proxy routes requests to 127.0.0.1:<port> inside the sandbox. The port is
+// chosen by a "<port>-<sandbox>" Host subdomain or a /proxy/<port>/ path.
+type Proxy struct {
+	config *Config
+	proxy  *httputil.ReverseProxy
+}
+
+// target is where a request is forwarded to
+type target struct {
+	port   int
+	path   string // Request path inside the sandbox
+	prefix string // Path prefix stripped from the request, if routed by path
+}
+
+type targetKey struct{}
+
+// New creates a preview proxy
+func New(cfg *Config) *Proxy {
+	p := &Proxy{config: cfg}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:      p.rewrite,
+		ErrorHandler: p.errorHandler,
+	}
+	return p
+}
+
+// Serve serves preview requests on lis until ctx is cancelled
+func (p *Proxy) Serve(ctx context.Context, lis net.Listener) error {
+	srv := &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	logger.Info("Preview proxy listening", "addr", lis.Addr().String())
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("preview proxy failed: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP authenticates and forwards a preview request. WebSocket
+// upgrades are passed through by the reverse proxy.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := p.route(r)
+	if !ok {
+		// /proxy/<port> without a trailing slash would break relative links
+		if rest, ok := strings.CutPrefix(r.URL.Path, pathPrefix); ok {
+			if port, ok := parsePort(rest); ok {
+				http.Redirect(w, r, fmt.Sprintf("%s%d/", pathPrefix, port), http.StatusMovedPermanently)
+				return
+			}
+		}
+		if p.config.Fallback != nil {
+			p.config.Fallback.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "no preview target", http.StatusNotFound)
+		return
+	}
+	if p.denied(t.port) {
+		http.Error(w, "port not available for preview", http.StatusForbidden)
+		return
+	}
+	if !p.authorize(w, r, t) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), targetKey{}, t)))
+}
+
+// route picks the target port from the Host subdomain or the path prefix
+func (p *Proxy) route(r *http.Request) (target, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, _, _ := strings.Cut(host, ".")
+	if portStr, sandbox, ok := strings.Cut(label, "-"); ok && strings.EqualFold(sandbox, p.config.SandboxID) {
+		if port, ok := parsePort(portStr); ok {
+			return target{port: port, path: r.URL.Path}, true
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(r.URL.Path, pathPrefix); ok {
+		portStr, path, ok := strings.Cut(rest, "/")
+		if port, valid := parsePort(portStr); ok && valid {
+			return target{
+				port:   port,
+				path:   "/" + path,
+				prefix: pathPrefix + portStr,
+			}, true
+		}
+	}
+	return target{}, false
+}
+
+// denied reports whether port must not be proxied
+func (p *Proxy) denied(port int) bool {
+	for _, d := range p.config.DeniedPorts {
+		if d == port {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize accepts the agent token as a bearer token, a valid signed
+// preview URL, or the cookie set when a signed URL was opened
+func (p *Proxy) authorize(w http.ResponseWriter, r *http.Request, t target) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && p.config.Token != "" {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.config.Token)) == 1 {
+			return true
+		}
+	}
+
+	query := r.URL.Query()
+	if expires, signature := query.Get(expiresParam), query.Get(signatureParam); expires != "" && signature != "" {
+		if !p.verify(t.port, expires, signature) {
+			return false
+		}
+		p.setCookie(w, t, expires, signature)
+		return true
+	}
+
+	if cookie, err := r.Cookie(cookieName(t.port)); err == nil {
+		expires, signature, ok := strings.Cut(cookie.Value, ".")
+		return ok && p.verify(t.port, expires, signature)
+	}
+	return false
+}
+
+// verify checks a preview signature and its expiry
+func (p *Proxy) verify(port int, expires, signature string) bool {
+	if p.config.Token == "" {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	expected := Sign(p.config.Token, p.config.SandboxID, port, time.Unix(unix, 0))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// setCookie remembers a signed URL so assets loaded by the page are authorized
+func (p *Proxy) setCookie(w http.ResponseWriter, t target, expires, signature string) {
+	unix, _ := strconv.ParseInt(expires, 10, 64)
+	path := "/"
+	if t.prefix != "" {
+		path = t.prefix + "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName(t.port),
+		Value:    expires + "." + signature,
+		Path:     path,
+		Expires:  time.Unix(unix, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// rewrite points the outgoing request at the sandbox port and removes the
+// proxy's own credentials
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	t := pr.In.Context().Value(targetKey{}).(target)
+
+	pr.SetURL(&url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", t.port)})
+	pr.Out.URL.Path = t.path
+	pr.Out.URL.RawPath = ""
+	pr.SetXForwarded()
+	if t.prefix != "" {
+		pr.Out.Header.Set("X-Forwarded-Prefix", t.prefix)
+	}
+
+	query := pr.Out.URL.Query()
+	query.Del(expiresParam)
+	query.Del(signatureParam)
+	pr.Out.URL.RawQuery = query.Encode()
+
+	pr.Out.Header.Del("Authorization")
+	pr.Out.Header.Del("Cookie")
+	for _, c := range pr.In.Cookies() {
+		if !strings.HasPrefix(c.Name, cookiePrefix) {
+			pr.Out.AddCookie(c)
+		}
+	}
+}
+
+// errorHandler reports an unreachable sandbox port
+func (p *Proxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	t, _ := r.Context().Value(targetKey{}).(target)
+	logger.Warn("Preview proxy request failed", "port", t.port, "error", err)
+	http.Error(w, fmt.Sprintf("nothing is listening on port %d", t.port), http.StatusBadGateway)
+}
+
+// Sign returns the signature of a preview URL for port that is valid until
+// expires. The server computes the same HMAC-SHA256 to mint preview URLs.
+func Sign(token, sandboxID string, port int, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	fmt.Fprintf(mac, "%s:%d:%d", sandboxID, port, expires.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func cookieName(port int) string {
+	return cookiePrefix + strconv.Itoa(port)
+}
+
+// parsePort parses a TCP port number
+func parsePort(s string) (int, bool) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
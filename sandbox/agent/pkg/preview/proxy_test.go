@@ -0,0 +1,277 @@
+package preview
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSandbox = "sbox-123"
+	testToken   = "secret-token"
+)
+
+// backend starts an HTTP server inside the "sandbox" and returns its port
+func backend(t *testing.T, handler http.HandlerFunc) int {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+func newProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(New(&Config{SandboxID: testSandbox, Token: testToken}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxyByPath(t *testing.T) {
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("agent token must not be forwarded")
+		}
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.Header.Get("X-Forwarded-Prefix"))
+	})
+	proxy := newProxy(t)
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/proxy/%d/app.js", proxy.URL, port), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := fmt.Sprintf("/app.js /proxy/%d", port)
+	if resp.StatusCode != http.StatusOK || string(body) != expected {
+		t.Errorf("expected 200 %q, got %d %q", expected, resp.StatusCode, body)
+	}
+}
+
+func TestProxyByHost(t *testing.T) {
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	})
+	proxy := newProxy(t)
+
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/index.html", nil)
+	req.Host = fmt.Sprintf("%d-%s.preview.example", port, testSandbox)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "/index.html" {
+		t.Errorf("expected 200 /index.html, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestProxyRejectsUnauthorized(t *testing.T) {
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unauthorized request reached the backend")
+	})
+	proxy := newProxy(t)
+
+	expired := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name   string
+		header string
+		query  string
+	}{
+		{"no credentials", "", ""},
+		{"wrong token", "Bearer wrong", ""},
+		{"bad signature", "", fmt.Sprintf("preview_expires=%d&preview_signature=bad", time.Now().Add(time.Hour).Unix())},
+		{"expired signature", "", fmt.Sprintf("preview_expires=%d&preview_signature=%s", expired.Unix(), Sign(testToken, testSandbox, port, expired))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/proxy/%d/?%s", proxy.URL, port, tt.query), nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSignedURLSetsCookie(t *testing.T) {
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(signatureParam) != "" {
+			t.Error("signature must not be forwarded")
+		}
+		for _, c := range r.Cookies() {
+			if strings.HasPrefix(c.Name, cookiePrefix) {
+				t.Error("preview cookie must not be forwarded")
+			}
+		}
+		fmt.Fprint(w, "ok")
+	})
+	proxy := newProxy(t)
+
+	expires := time.Now().Add(time.Hour)
+	signed := fmt.Sprintf("%s/proxy/%d/?%s=%d&%s=%s", proxy.URL, port,
+		expiresParam, expires.Unix(), signatureParam, Sign(testToken, testSandbox, port, expires))
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for signed URL, got %d", resp.StatusCode)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == cookieName(port) {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected preview cookie")
+	}
+
+	// Follow-up requests for assets are authorized by the cookie alone
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/proxy/%d/style.css", proxy.URL, port), nil)
+	req.AddCookie(cookie)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 with cookie, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxyDeniedPort(t *testing.T) {
+	proxy := httptest.NewServer(New(&Config{SandboxID: testSandbox, Token: testToken, DeniedPorts: []int{2222}}))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/proxy/2222/", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxyWebSocketUpgrade(t *testing.T) {
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "expected upgrade", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		// Echo one line back
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	})
+	proxy := newProxy(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL, "http://"))
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /proxy/%d/ws HTTP/1.1\r\nHost: localhost\r\nAuthorization: Bearer %s\r\n"+
+		"Connection: Upgrade\r\nUpgrade: websocket\r\n\r\n", port, testToken)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	fmt.Fprint(conn, "hello\n")
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Errorf("expected echo, got %q (%v)", line, err)
+	}
+}
+
+func TestProxyRedirectsBarePort(t *testing.T) {
+	proxy := newProxy(t)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(proxy.URL + "/proxy/3000")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/proxy/3000/" {
+		t.Errorf("expected redirect to /proxy/3000/, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestProxyFallback(t *testing.T) {
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "fallback "+r.URL.Path)
+	})
+	proxy := httptest.NewServer(New(&Config{SandboxID: testSandbox, Token: testToken, Fallback: fallback}))
+	t.Cleanup(proxy.Close)
+
+	resp, err := http.Get(proxy.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "fallback /metrics" {
+		t.Errorf("expected fallback response, got %d %q", resp.StatusCode, body)
+	}
+
+	// A preview selected by Host subdomain is never handed to the fallback
+	port := backend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "backend")
+	})
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/metrics", nil)
+	req.Host = fmt.Sprintf("%d-%s.preview.example.com", port, testSandbox)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unauthenticated preview request to get 401, got %d", resp.StatusCode)
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/codepod/codepod/sandbox/agent/pkg/environ"
 )
 
 // Manager manages processes in the sandbox
@@ -90,9 +91,7 @@ func (m *Manager) Start(ctx context.Context, cmd string, args []string, opts *St
 	if opts.Dir != "" {
 		command.Dir = opts.Dir
 	}
-	if len(opts.Env) > 0 {
-		command.Env = append(os.Environ(), opts.Env...)
-	}
+	command.Env = append(environ.Child(), opts.Env...)
 	if opts.Stdin != nil {
 		command.Stdin = opts.Stdin
 	}
@@ -1,41 +1,75 @@
 package reporter
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
-	"github.com/shirou/gopsutil/host"
-	"github.com/shirou/gopsutil/mem"
+	"github.com/codepod/codepod/libs/go-common/backoff"
+	"github.com/codepod/codepod/libs/go-common/logging"
 )
 
+var logger = logging.Component("reporter")
+
 // Config holds the configuration for the reporter client.
 type Config struct {
-	ServerURL string        // Server URL (e.g., "http://server:8080")
-	SandboxID string        // The sandbox identifier
-	Interval  time.Duration // Heartbeat interval (default 30s)
+	ServerURL    string        // Server URL (e.g., "http://server:8080")
+	SandboxID    string        // The sandbox identifier
+	Token        string        // Agent token, sent as a bearer token
+	Interval     time.Duration // Heartbeat interval (default 30s, the server may override it)
+	QueueSize    int           // Maximum undelivered messages kept for retry (default 100)
+	WorkspaceDir string        // Directory whose filesystem usage is reported
+	SessionCount func() int    // Returns the number of live SSH sessions
 }
 
 // Status represents the status report sent to the server.
 type Status struct {
-	SandboxID    string            `json:"sandboxId"`
-	Status       string            `json:"status"`
-	CPUPercent   float64           `json:"cpuPercent,omitempty"`
-	MemoryMB     int               `json:"memoryMB,omitempty"`
-	SessionCount int               `json:"sessionCount,omitempty"`
-	UptimeSecs   int64             `json:"uptimeSecs"`
-	Hostname     string            `json:"hostname"`
-	Timestamp    time.Time         `json:"timestamp"`
-	Metadata     map[string]string `json:"metadata,omitempty"`
+	SandboxID     string            `json:"sandboxId"`
+	Status        string            `json:"status"`
+	CPUPercent    float64           `json:"cpuPercent,omitempty"`
+	MemoryMB      int               `json:"memoryMB,omitempty"`
+	MemoryLimitMB int               `json:"memoryLimitMB,omitempty"`
+	Pids          int               `json:"pids,omitempty"`
+	ProcessCount  int               `json:"processCount,omitempty"`
+	SessionCount  int               `json:"sessionCount,omitempty"`
+	IOReadBytes   uint64            `json:"ioReadBytes,omitempty"`
+	IOWriteBytes  uint64            `json:"ioWriteBytes,omitempty"`
+	NetRxBytes    uint64            `json:"netRxBytes,omitempty"`
+	NetTxBytes    uint64            `json:"netTxBytes,omitempty"`
+	DiskUsedMB    int               `json:"diskUsedMB,omitempty"`
+	DiskTotalMB   int               `json:"diskTotalMB,omitempty"`
+	UptimeSecs    int64             `json:"uptimeSecs"`
+	Hostname      string            `json:"hostname"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
 }
 
 // Client is the reporter client that sends heartbeat/status updates to the server.
 type Client struct {
-	config *Config
-	client *http.Client
+	config    *Config
+	client    *http.Client
+	collector *Collector
+	startTime time.Time
+	backoff   *backoff.Backoff
+	mu        sync.Mutex
+	interval  time.Duration
+	status    string
+	metadata  map[string]string
+	queueMu   sync.Mutex
+	queue     []*message
+	flushMu   sync.Mutex
+
+	eventsMu    sync.Mutex
+	events      []Event
+	eventsReady chan struct{}
+
+	// Threshold state, only touched by the heartbeat loop
+	sampled      bool
+	lastOOMKills uint64
+	diskAlerted  bool
 }
 
 // NewClient creates a new Reporter client with the given configuration.
@@ -43,13 +77,23 @@ func NewClient(cfg *Config) *Client {
 	if cfg.Interval == 0 {
 		cfg.Interval = 30 * time.Second
 	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 100
+	}
 	return &Client{
-		config: cfg,
-		client: &http.Client{Timeout: 10 * time.Second},
+		config:    cfg,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		collector: NewCollector(cfg.WorkspaceDir),
+		startTime: time.Now(),
+		backoff:   backoff.New(time.Second, time.Minute),
+		interval:  cfg.Interval,
+
+		eventsReady: make(chan struct{}, 1),
 	}
 }
 
-// Report sends a single status update to the server.
+// Report queues a status update and delivers it, along with anything still
+// queued from earlier failures, in order. On failure the update stays queued.
 func (c *Client) Report(ctx context.Context, status *Status) error {
 	status.SandboxID = c.config.SandboxID
 	status.Timestamp = time.Now()
@@ -59,66 +103,124 @@ func (c *Client) Report(ctx context.Context, status *Status) error {
 		return fmt.Errorf("failed to marshal: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/sandboxes/%s/status", c.config.ServerURL, c.config.SandboxID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send: %w", err)
-	}
-	defer resp.Body.Close()
+	c.enqueue(&message{
+		path:   fmt.Sprintf("/api/v1/sandboxes/%s/status", c.config.SandboxID),
+		body:   data,
+		status: status.Status,
+	})
+	return c.Flush(ctx)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned %d", resp.StatusCode)
-	}
-	return nil
+// SetStatus changes the status carried by subsequent heartbeats and reports
+// it to the server right away.
+func (c *Client) SetStatus(ctx context.Context, status string, metadata map[string]string) error {
+	c.mu.Lock()
+	c.status = status
+	c.metadata = metadata
+	c.mu.Unlock()
+
+	return c.Report(ctx, &Status{
+		Status:   status,
+		Metadata: metadata,
+	})
 }
 
-// StartHeartbeat starts periodic heartbeat updates to the server.
+// StartHeartbeat starts periodic heartbeat updates to the server. While the
+// server is unreachable it retries with exponential backoff instead of
+// waiting for the next interval.
 func (c *Client) StartHeartbeat(ctx context.Context, initialStatus *Status) error {
-	// Send initial status
-	if err := c.Report(ctx, initialStatus); err != nil {
-		fmt.Printf("Initial status failed: %v\n", err)
+	// Send initial status (this also primes the CPU usage baseline)
+	delay := c.currentInterval()
+	if err := c.Report(ctx, c.collectStatus(initialStatus)); err != nil {
+		logger.Warn("Initial status failed", "error", err)
+		delay = c.retryDelay(err)
 	}
 
-	ticker := time.NewTicker(c.config.Interval)
-	defer ticker.Stop()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	eventTicker := time.NewTicker(eventFlushInterval)
+	defer eventTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			// Send final status
-			final := *initialStatus
-			final.Status = "stopped"
-			c.Report(context.Background(), &final)
+			// Send remaining events and the final status, flushing anything
+			// still queued before them
+			final := c.finalStatus(initialStatus)
+			finalCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			logEventError(c.flushEvents(finalCtx))
+			if err := c.Report(finalCtx, final); err != nil {
+				logger.Error("Final status failed", "undelivered", c.QueueLen(), "error", err)
+			}
+			cancel()
 			return nil
-		case <-ticker.C:
+		case <-eventTicker.C:
+			logEventError(c.flushEvents(ctx))
+		case <-c.eventsReady:
+			logEventError(c.flushEvents(ctx))
+		case <-timer.C:
 			status := c.collectStatus(initialStatus)
 			if err := c.Report(ctx, status); err != nil {
-				fmt.Printf("Heartbeat failed: %v\n", err)
+				delay = c.retryDelay(err)
+				logger.Warn("Heartbeat failed", "queued", c.QueueLen(), "retry_in", delay.Round(time.Millisecond), "error", err)
+			} else {
+				c.backoff.Reset()
+				delay = c.currentInterval()
 			}
+			timer.Reset(delay)
 		}
 	}
 }
 
+// finalStatus is the status reported on shutdown: "stopped", unless the
+// status was set to "failed", which is kept along with its metadata so the
+// server sees why the sandbox ended.
+func (c *Client) finalStatus(base *Status) *Status {
+	final := *base
+	final.Status = "stopped"
+	c.mu.Lock()
+	if c.status == "failed" {
+		final.Status = c.status
+		final.Metadata = c.metadata
+	}
+	c.mu.Unlock()
+	return &final
+}
+
 // collectStatus collects the current status for heartbeat.
 func (c *Client) collectStatus(base *Status) *Status {
-	// Get uptime
-	uptime, _ := host.Uptime()
+	c.mu.Lock()
+	state, metadata := c.status, c.metadata
+	c.mu.Unlock()
+	if state == "" {
+		state = base.Status
+	}
 
-	// Get memory
-	v, _ := mem.VirtualMemory()
+	m := c.collector.Collect()
+	c.checkThresholds(m)
+
+	sessionCount := base.SessionCount
+	if c.config.SessionCount != nil {
+		sessionCount = c.config.SessionCount()
+	}
 
 	return &Status{
-		Status:      "running",
-		Hostname:   base.Hostname,
-		UptimeSecs: int64(uptime),
-		MemoryMB:   int(v.Used / 1024 / 1024),
-		CPUPercent: 0, // Requires interval for accurate reading
-		SessionCount: base.SessionCount,
+		Status:        state,
+		Metadata:      metadata,
+		Hostname:      base.Hostname,
+		UptimeSecs:    int64(time.Since(c.startTime).Seconds()),
+		CPUPercent:    m.CPUPercent,
+		MemoryMB:      int(m.MemoryBytes / 1024 / 1024),
+		MemoryLimitMB: int(m.MemoryLimit / 1024 / 1024),
+		Pids:          m.Pids,
+		ProcessCount:  m.ProcessCount,
+		SessionCount:  sessionCount,
+		IOReadBytes:   m.IOReadBytes,
+		IOWriteBytes:  m.IOWriteBytes,
+		NetRxBytes:    m.NetRxBytes,
+		NetTxBytes:    m.NetTxBytes,
+		DiskUsedMB:    int(m.DiskUsedBytes / 1024 / 1024),
+		DiskTotalMB:   int(m.DiskTotal / 1024 / 1024),
 	}
 }
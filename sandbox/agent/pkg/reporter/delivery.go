@@ -0,0 +1,190 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// message is a request to the server that is kept until it is delivered
+type message struct {
+	path   string
+	body   []byte
+	status string // non-empty for status messages; consecutive ones with the same status are coalesced
+}
+
+// serverResponse holds the optional hints the server returns to the agent
+type serverResponse struct {
+	HeartbeatIntervalSecs int `json:"heartbeatIntervalSecs"`
+}
+
+// deliveryError is returned when the server rejected or could not be reached
+type deliveryError struct {
+	statusCode int
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *deliveryError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("failed to send: %v", e.err)
+	}
+	return fmt.Sprintf("server returned %d", e.statusCode)
+}
+
+func (e *deliveryError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether the message may succeed if sent again. Transport
+// errors, server errors and 429 are retried; other client errors are not.
+func (e *deliveryError) retryable() bool {
+	return e.err != nil || e.statusCode >= 500 || e.statusCode == http.StatusTooManyRequests
+}
+
+// enqueue appends a message to the delivery queue. A status message replaces
+// a queued status message with the same status at the tail, so an outage only
+// keeps one heartbeat per status transition. When the queue is full the
+// oldest event batch is dropped, so status transitions are only lost once
+// nothing else is left to evict.
+func (c *Client) enqueue(msg *message) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	if n := len(c.queue); n > 0 && msg.status != "" && c.queue[n-1].status == msg.status {
+		c.queue[n-1] = msg
+		return
+	}
+	if len(c.queue) >= c.config.QueueSize {
+		victim := 0
+		for i, queued := range c.queue {
+			if queued.status == "" {
+				victim = i
+				break
+			}
+		}
+		logger.Warn("Reporter queue full, dropping oldest message", "path", c.queue[victim].path)
+		c.queue = append(c.queue[:victim], c.queue[victim+1:]...)
+	}
+	c.queue = append(c.queue, msg)
+}
+
+// QueueLen returns the number of undelivered messages
+func (c *Client) QueueLen() int {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	return len(c.queue)
+}
+
+// Flush delivers queued messages in order, stopping at the first retryable
+// failure. Undelivered messages stay queued for the next attempt; messages the
+// server rejects outright are dropped so they cannot block the queue.
+func (c *Client) Flush(ctx context.Context) error {
+	// Only one flush at a time so messages are never delivered out of order
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	for {
+		c.queueMu.Lock()
+		if len(c.queue) == 0 {
+			c.queueMu.Unlock()
+			return nil
+		}
+		msg := c.queue[0]
+		c.queueMu.Unlock()
+
+		if err := c.deliver(ctx, msg); err != nil {
+			var de *deliveryError
+			if !errors.As(err, &de) || de.retryable() {
+				return err
+			}
+			logger.Warn("Server rejected message, dropping it", "path", msg.path, "status", de.statusCode)
+		}
+
+		c.queueMu.Lock()
+		// The head may have been dropped or coalesced while we were sending
+		if len(c.queue) > 0 && c.queue[0] == msg {
+			c.queue = c.queue[1:]
+		}
+		c.queueMu.Unlock()
+	}
+}
+
+// deliver sends one message to the server with the agent token
+func (c *Client) deliver(ctx context.Context, msg *message) error {
+	url := c.config.ServerURL + msg.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(msg.body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &deliveryError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &deliveryError{
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	// Honor a server-suggested heartbeat interval, if any
+	var hints serverResponse
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if len(body) > 0 && json.Unmarshal(body, &hints) == nil && hints.HeartbeatIntervalSecs > 0 {
+		c.setInterval(time.Duration(hints.HeartbeatIntervalSecs) * time.Second)
+	}
+	return nil
+}
+
+// retryDelay returns how long to wait before retrying after err
+func (c *Client) retryDelay(err error) time.Duration {
+	delay := c.backoff.Next()
+	var de *deliveryError
+	if errors.As(err, &de) && de.retryAfter > delay {
+		delay = de.retryAfter
+	}
+	return delay
+}
+
+// setInterval changes the heartbeat interval, ignoring implausibly short values
+func (c *Client) setInterval(d time.Duration) {
+	if d < time.Second {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval != d {
+		logger.Info("Reporter heartbeat interval set by server", "interval", d)
+		c.interval = d
+	}
+}
+
+// currentInterval returns the heartbeat interval in effect
+func (c *Client) currentInterval() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.interval
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	secs, err := strconv.Atoi(value)
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
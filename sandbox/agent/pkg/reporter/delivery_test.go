@@ -0,0 +1,203 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer records delivered statuses and can be toggled offline
+type fakeServer struct {
+	mu       sync.Mutex
+	offline  bool
+	statuses []string
+	auth     []string
+	response string
+	reject   map[string]int // status code returned for a reported status
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.offline {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var status Status
+	json.NewDecoder(r.Body).Decode(&status)
+	if code := f.reject[status.Status]; code != 0 {
+		w.WriteHeader(code)
+		return
+	}
+	f.statuses = append(f.statuses, status.Status)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	w.Write([]byte(f.response))
+}
+
+func (f *fakeServer) setOffline(offline bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offline = offline
+}
+
+func TestReportSendsToken(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1", Token: "secret"})
+	if err := c.Report(context.Background(), &Status{Status: "running"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(fake.auth) != 1 || fake.auth[0] != "Bearer secret" {
+		t.Errorf("expected bearer token, got %v", fake.auth)
+	}
+}
+
+func TestReportQueuesAndFlushesInOrder(t *testing.T) {
+	fake := &fakeServer{offline: true}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1"})
+	ctx := context.Background()
+
+	for _, status := range []string{"running", "running", "failed", "stopped"} {
+		if err := c.Report(ctx, &Status{Status: status}); err == nil {
+			t.Fatal("expected error while server is offline")
+		}
+	}
+	// Consecutive "running" heartbeats are coalesced
+	if c.QueueLen() != 3 {
+		t.Fatalf("expected 3 queued messages, got %d", c.QueueLen())
+	}
+
+	fake.setOffline(false)
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"running", "failed", "stopped"}
+	if len(fake.statuses) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, fake.statuses)
+	}
+	for i := range expected {
+		if fake.statuses[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, fake.statuses)
+		}
+	}
+	if c.QueueLen() != 0 {
+		t.Errorf("expected empty queue, got %d", c.QueueLen())
+	}
+}
+
+func TestFinalStatusKeepsFailure(t *testing.T) {
+	c := NewClient(&Config{SandboxID: "sbox-1"})
+	base := &Status{Status: "running", Hostname: "sbox"}
+
+	if final := c.finalStatus(base); final.Status != "stopped" || final.Hostname != "sbox" {
+		t.Errorf("expected stopped, got %+v", final)
+	}
+
+	c.mu.Lock()
+	c.status, c.metadata = "failed", map[string]string{"workloadExitCode": "1"}
+	c.mu.Unlock()
+	final := c.finalStatus(base)
+	if final.Status != "failed" || final.Metadata["workloadExitCode"] != "1" {
+		t.Errorf("expected failed with exit code, got %+v", final)
+	}
+	if base.Status != "running" {
+		t.Errorf("base status modified: %q", base.Status)
+	}
+}
+
+func TestQueueIsBounded(t *testing.T) {
+	fake := &fakeServer{offline: true}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1", QueueSize: 2})
+	for _, status := range []string{"a", "b", "c"} {
+		c.Report(context.Background(), &Status{Status: status})
+	}
+	if c.QueueLen() != 2 {
+		t.Fatalf("expected 2 queued messages, got %d", c.QueueLen())
+	}
+
+	fake.setOffline(false)
+	c.Flush(context.Background())
+	if len(fake.statuses) != 2 || fake.statuses[0] != "b" {
+		t.Errorf("expected oldest message dropped, got %v", fake.statuses)
+	}
+}
+
+func TestQueueKeepsStatusOverEvents(t *testing.T) {
+	c := NewClient(&Config{ServerURL: "http://unused", SandboxID: "sbox-1", QueueSize: 2})
+
+	c.enqueue(&message{path: "/status", status: "running"})
+	c.enqueue(&message{path: "/events"})
+	c.enqueue(&message{path: "/status", status: "stopped"})
+
+	if c.QueueLen() != 2 || c.queue[0].status != "running" || c.queue[1].status != "stopped" {
+		t.Errorf("expected event batch evicted before status messages, got %+v", c.queue)
+	}
+}
+
+func TestFlushDropsRejectedMessages(t *testing.T) {
+	fake := &fakeServer{offline: true, reject: map[string]int{"bad": http.StatusBadRequest, "busy": http.StatusTooManyRequests}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1"})
+	for _, status := range []string{"bad", "running"} {
+		c.Report(context.Background(), &Status{Status: status})
+	}
+
+	fake.setOffline(false)
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.statuses) != 1 || fake.statuses[0] != "running" {
+		t.Errorf("expected rejected message dropped, got %v", fake.statuses)
+	}
+
+	// 429 is retried, so the message stays queued
+	if err := c.Report(context.Background(), &Status{Status: "busy"}); err == nil {
+		t.Fatal("expected error for rate-limited message")
+	}
+	if c.QueueLen() != 1 {
+		t.Errorf("expected rate-limited message kept, got %d queued", c.QueueLen())
+	}
+}
+
+func TestServerSuggestedInterval(t *testing.T) {
+	fake := &fakeServer{response: `{"success":true,"heartbeatIntervalSecs":5}`}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1"})
+	if c.currentInterval() != 30*time.Second {
+		t.Fatalf("expected default interval 30s, got %s", c.currentInterval())
+	}
+	if err := c.Report(context.Background(), &Status{Status: "running"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.currentInterval() != 5*time.Second {
+		t.Errorf("expected interval 5s, got %s", c.currentInterval())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("7"); d != 7*time.Second {
+		t.Errorf("expected 7s, got %s", d)
+	}
+	if d := parseRetryAfter(""); d != 0 {
+		t.Errorf("expected 0, got %s", d)
+	}
+}
@@ -0,0 +1,150 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventType identifies what happened inside the sandbox
+type EventType string
+
+const (
+	EventSessionOpened  EventType = "session.opened"
+	EventSessionClosed  EventType = "session.closed"
+	EventAuthFailed     EventType = "auth.failed"
+	EventProcessExited  EventType = "process.exited"
+	EventOOMKill        EventType = "oom.kill"
+	EventDiskNearlyFull EventType = "disk.nearly_full"
+	EventFileChanged    EventType = "file.changed"
+	EventPortOpened     EventType = "port.opened"
+	EventPortClosed     EventType = "port.closed"
+	EventAgentShutdown  EventType = "agent.shutdown"
+)
+
+// Severity indicates how important an event is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Event is a lifecycle or security event pushed to the server
+type Event struct {
+	Type       EventType         `json:"type"`
+	Severity   Severity          `json:"severity"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Emitter accepts events for delivery to the server
+type Emitter interface {
+	Emit(evt Event)
+}
+
+// eventBatch is the JSON body of an events request
+type eventBatch struct {
+	SandboxID string  `json:"sandboxId"`
+	Events    []Event `json:"events"`
+}
+
+const (
+	// maxEventBatch is the number of buffered events that triggers an early flush
+	maxEventBatch = 50
+	// eventFlushInterval is how long events are buffered before being sent
+	eventFlushInterval = 2 * time.Second
+)
+
+// Emit buffers an event for batched delivery. It never blocks on the network.
+func (c *Client) Emit(evt Event) {
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+	if evt.Severity == "" {
+		evt.Severity = SeverityInfo
+	}
+
+	c.eventsMu.Lock()
+	c.events = append(c.events, evt)
+	full := len(c.events) >= maxEventBatch
+	c.eventsMu.Unlock()
+
+	if full {
+		select {
+		case c.eventsReady <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flushEvents moves buffered events into the delivery queue as one batch
+// and delivers the queue
+func (c *Client) flushEvents(ctx context.Context) error {
+	c.eventsMu.Lock()
+	events := c.events
+	c.events = nil
+	c.eventsMu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(&eventBatch{SandboxID: c.config.SandboxID, Events: events})
+	if err != nil {
+		return fmt.Errorf("failed to marshal events: %w", err)
+	}
+	c.enqueue(&message{
+		path: fmt.Sprintf("/api/v1/sandboxes/%s/events", c.config.SandboxID),
+		body: data,
+	})
+	return c.Flush(ctx)
+}
+
+// checkThresholds emits events for conditions detected in a metrics sample
+func (c *Client) checkThresholds(m *Metrics) {
+	if m.OOMKills > c.lastOOMKills && c.sampled {
+		c.Emit(Event{
+			Type:     EventOOMKill,
+			Severity: SeverityCritical,
+			Attributes: map[string]string{
+				"count": fmt.Sprintf("%d", m.OOMKills-c.lastOOMKills),
+			},
+		})
+	}
+	c.lastOOMKills = m.OOMKills
+	c.sampled = true
+
+	if m.DiskTotal == 0 {
+		return
+	}
+	usedPercent := float64(m.DiskUsedBytes) / float64(m.DiskTotal) * 100
+	switch {
+	case usedPercent >= diskFullPercent && !c.diskAlerted:
+		c.diskAlerted = true
+		c.Emit(Event{
+			Type:     EventDiskNearlyFull,
+			Severity: SeverityWarning,
+			Attributes: map[string]string{
+				"path":        c.config.WorkspaceDir,
+				"usedPercent": fmt.Sprintf("%.1f", usedPercent),
+			},
+		})
+	case usedPercent < diskFullPercent-5:
+		// Re-arm once usage has dropped clearly below the threshold
+		c.diskAlerted = false
+	}
+}
+
+// diskFullPercent is the workspace usage at which a disk event is emitted
+const diskFullPercent = 90
+
+// logEventError logs a failed event delivery; the batch stays queued
+func logEventError(err error) {
+	if err != nil {
+		logger.Warn("Event delivery failed", "error", err)
+	}
+}
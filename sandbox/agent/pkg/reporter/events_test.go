@@ -0,0 +1,72 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlushEventsSendsBatch(t *testing.T) {
+	var batches []eventBatch
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/sandboxes/sbox-1/events" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var batch eventBatch
+		json.NewDecoder(r.Body).Decode(&batch)
+		batches = append(batches, batch)
+	}))
+	defer srv.Close()
+
+	c := NewClient(&Config{ServerURL: srv.URL, SandboxID: "sbox-1"})
+	c.Emit(Event{Type: EventSessionOpened, Attributes: map[string]string{"user": "root"}})
+	c.Emit(Event{Type: EventAuthFailed, Severity: SeverityWarning})
+
+	if err := c.flushEvents(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(batches) != 1 || len(batches[0].Events) != 2 {
+		t.Fatalf("expected one batch of 2 events, got %+v", batches)
+	}
+	first := batches[0].Events[0]
+	if first.Type != EventSessionOpened || first.Severity != SeverityInfo || first.Timestamp.IsZero() {
+		t.Errorf("expected defaulted session event, got %+v", first)
+	}
+	if batches[0].SandboxID != "sbox-1" {
+		t.Errorf("expected sandbox ID sbox-1, got %s", batches[0].SandboxID)
+	}
+
+	// Nothing buffered, nothing sent
+	c.flushEvents(context.Background())
+	if len(batches) != 1 {
+		t.Errorf("expected no further batches, got %d", len(batches))
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	c := NewClient(&Config{ServerURL: "http://unused", SandboxID: "sbox-1"})
+
+	c.checkThresholds(&Metrics{OOMKills: 2, DiskUsedBytes: 50, DiskTotal: 100})
+	if len(c.events) != 0 {
+		t.Fatalf("expected no events on first sample, got %+v", c.events)
+	}
+
+	c.checkThresholds(&Metrics{OOMKills: 3, DiskUsedBytes: 95, DiskTotal: 100})
+	c.checkThresholds(&Metrics{OOMKills: 3, DiskUsedBytes: 96, DiskTotal: 100})
+	if len(c.events) != 2 {
+		t.Fatalf("expected OOM and disk events once each, got %+v", c.events)
+	}
+	if c.events[0].Type != EventOOMKill || c.events[1].Type != EventDiskNearlyFull {
+		t.Errorf("unexpected events %+v", c.events)
+	}
+
+	// Dropping below the threshold re-arms the disk alert
+	c.checkThresholds(&Metrics{OOMKills: 3, DiskUsedBytes: 50, DiskTotal: 100})
+	c.checkThresholds(&Metrics{OOMKills: 3, DiskUsedBytes: 92, DiskTotal: 100})
+	if len(c.events) != 3 {
+		t.Errorf("expected a second disk event, got %+v", c.events)
+	}
+}
@@ -0,0 +1,281 @@
+package reporter
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultCgroupRoot is where the container's own cgroup v2 hierarchy is mounted
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// Metrics holds container-scoped resource usage
+type Metrics struct {
+	CPUPercent    float64
+	MemoryBytes   uint64
+	MemoryLimit   uint64 // 0 when the container has no memory limit
+	OOMKills      uint64 // Processes killed by the OOM killer since the cgroup was created
+	Pids          int
+	IOReadBytes   uint64
+	IOWriteBytes  uint64
+	NetRxBytes    uint64
+	NetTxBytes    uint64
+	DiskUsedBytes uint64 // Used by the workspace
+	DiskTotal     uint64 // Size the workspace can grow to
+	ProcessCount  int
+}
+
+// Collector reads metrics from cgroup v2 and /proc. CPU usage is reported
+// as a percentage of one core over the interval since the previous call.
+type Collector struct {
+	cgroupRoot   string
+	procRoot     string
+	workspaceDir string
+
+	mu        sync.Mutex
+	lastUsage uint64
+	lastTime  time.Time
+}
+
+// NewCollector creates a collector reading the container's own cgroup
+func NewCollector(workspaceDir string) *Collector {
+	return &Collector{
+		cgroupRoot:   defaultCgroupRoot,
+		procRoot:     "/proc",
+		workspaceDir: workspaceDir,
+	}
+}
+
+// Collect gathers a metrics snapshot. Sources that are unavailable (for
+// example when not running under cgroup v2) are left at zero.
+func (c *Collector) Collect() *Metrics {
+	m := &Metrics{}
+
+	m.CPUPercent = c.cpuPercent()
+	m.MemoryBytes, _ = readUint(filepath.Join(c.cgroupRoot, "memory.current"))
+	m.MemoryLimit, _ = readUint(filepath.Join(c.cgroupRoot, "memory.max"))
+	m.OOMKills = readKeyed(filepath.Join(c.cgroupRoot, "memory.events"))["oom_kill"]
+	if pids, err := readUint(filepath.Join(c.cgroupRoot, "pids.current")); err == nil {
+		m.Pids = int(pids)
+	}
+	m.IOReadBytes, m.IOWriteBytes = readIOStat(filepath.Join(c.cgroupRoot, "io.stat"))
+	m.NetRxBytes, m.NetTxBytes = readNetDev(filepath.Join(c.procRoot, "net", "dev"))
+	m.DiskUsedBytes, m.DiskTotal = diskUsage(c.workspaceDir)
+	m.ProcessCount = countProcesses(c.procRoot)
+
+	return m
+}
+
+// cpuPercent computes CPU usage from the cgroup's usage_usec counter
+func (c *Collector) cpuPercent() float64 {
+	usage, ok := readKeyed(filepath.Join(c.cgroupRoot, "cpu.stat"))["usage_usec"]
+	if !ok {
+		return 0
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var percent float64
+	if !c.lastTime.IsZero() && usage >= c.lastUsage {
+		elapsed := now.Sub(c.lastTime).Microseconds()
+		if elapsed > 0 {
+			percent = float64(usage-c.lastUsage) / float64(elapsed) * 100
+		}
+	}
+	c.lastUsage = usage
+	c.lastTime = now
+	return percent
+}
+
+// readUint reads a single-value cgroup file. "max" (no limit) reads as 0.
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readKeyed reads a flat "key value" cgroup file such as cpu.stat
+func readKeyed(path string) map[string]uint64 {
+	result := make(map[string]uint64)
+	f, err := os.Open(path)
+	if err != nil {
+		return result
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			result[fields[0]] = v
+		}
+	}
+	return result
+}
+
+// readIOStat sums read and write bytes over all devices in io.stat
+func readIOStat(path string) (rbytes, wbytes uint64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: "8:0 rbytes=123 wbytes=456 rios=1 wios=2 ..."
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				rbytes += v
+			case "wbytes":
+				wbytes += v
+			}
+		}
+	}
+	return rbytes, wbytes
+}
+
+// readNetDev sums received and transmitted bytes over all non-loopback
+// interfaces in /proc/net/dev
+func readNetDev(path string) (rx, tx uint64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		iface, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		// Fields: rx bytes packets errs drop fifo frame compressed multicast, tx bytes ...
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			rx += v
+		}
+		if v, err := strconv.ParseUint(fields[8], 10, 64); err == nil {
+			tx += v
+		}
+	}
+	return rx, tx
+}
+
+// maxWorkspaceEntries bounds the files walked to measure a workspace that is
+// not its own mount; usage past it is not counted
+const maxWorkspaceEntries = 100_000
+
+// diskUsage returns the bytes the workspace in dir uses and the size it can
+// grow to. A workspace mounted as its own volume reports the volume's usage
+// and size. Otherwise, as in a container's overlay filesystem shared with
+// everything else, its files are counted and it can grow by the space left.
+func diskUsage(dir string) (used, total uint64) {
+	if dir == "" {
+		return 0, 0
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0
+	}
+	if isMountPoint(dir) {
+		total = st.Blocks * uint64(st.Bsize)
+		used = (st.Blocks - st.Bfree) * uint64(st.Bsize)
+		return used, total
+	}
+	used = walkUsage(dir, maxWorkspaceEntries)
+	return used, used + st.Bavail*uint64(st.Bsize)
+}
+
+// isMountPoint reports whether dir is on a different device than its parent
+func isMountPoint(dir string) bool {
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return false
+	}
+	if err := syscall.Stat(filepath.Dir(filepath.Clean(dir)), &parent); err != nil {
+		return false
+	}
+	return st.Dev != parent.Dev || st.Ino == parent.Ino
+}
+
+// walkUsage returns the disk space used by the files under dir, like du -x,
+// counting at most limit entries
+func walkUsage(dir string, limit int) uint64 {
+	var root syscall.Stat_t
+	if err := syscall.Stat(dir, &root); err != nil {
+		return 0
+	}
+	var used uint64
+	entries := 0
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entries++; entries > limit {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		// Volumes mounted inside the workspace are not part of it
+		if d.IsDir() && st.Dev != root.Dev {
+			return filepath.SkipDir
+		}
+		used += uint64(st.Blocks) * 512
+		return nil
+	})
+	return used
+}
+
+// countProcesses counts the processes visible in procRoot
+func countProcesses(procRoot string) int {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,103 @@
+package reporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestCollectorCgroupV2(t *testing.T) {
+	cgroup := t.TempDir()
+	proc := t.TempDir()
+
+	writeFile(t, filepath.Join(cgroup, "memory.current"), "268435456\n")
+	writeFile(t, filepath.Join(cgroup, "memory.max"), "536870912\n")
+	writeFile(t, filepath.Join(cgroup, "pids.current"), "7\n")
+	writeFile(t, filepath.Join(cgroup, "cpu.stat"), "usage_usec 1000\nuser_usec 800\nsystem_usec 200\n")
+	writeFile(t, filepath.Join(cgroup, "io.stat"),
+		"8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1 wbytes=2 rios=1 wios=1\n")
+	writeFile(t, filepath.Join(proc, "net", "dev"), `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    9999      10    0    0    0     0          0         0     9999      10    0    0    0     0       0          0
+  eth0:    5000      40    0    0    0     0          0         0     3000      30    0    0    0     0       0          0
+`)
+	for _, pid := range []string{"1", "42", "self"} {
+		if err := os.MkdirAll(filepath.Join(proc, pid), 0755); err != nil {
+			t.Fatalf("failed to create proc dir: %v", err)
+		}
+	}
+
+	c := &Collector{cgroupRoot: cgroup, procRoot: proc, workspaceDir: cgroup}
+	m := c.Collect()
+
+	if m.MemoryBytes != 256*1024*1024 {
+		t.Errorf("expected memory 256MiB, got %d", m.MemoryBytes)
+	}
+	if m.MemoryLimit != 512*1024*1024 {
+		t.Errorf("expected memory limit 512MiB, got %d", m.MemoryLimit)
+	}
+	if m.Pids != 7 {
+		t.Errorf("expected 7 pids, got %d", m.Pids)
+	}
+	if m.IOReadBytes != 1025 || m.IOWriteBytes != 2050 {
+		t.Errorf("expected io 1025/2050, got %d/%d", m.IOReadBytes, m.IOWriteBytes)
+	}
+	if m.NetRxBytes != 5000 || m.NetTxBytes != 3000 {
+		t.Errorf("expected net 5000/3000 (loopback excluded), got %d/%d", m.NetRxBytes, m.NetTxBytes)
+	}
+	if m.ProcessCount != 2 {
+		t.Errorf("expected 2 processes, got %d", m.ProcessCount)
+	}
+	if m.DiskTotal == 0 {
+		t.Error("expected disk total to be set")
+	}
+	// The first sample only establishes the CPU baseline
+	if m.CPUPercent != 0 {
+		t.Errorf("expected 0 CPU on first sample, got %f", m.CPUPercent)
+	}
+
+	writeFile(t, filepath.Join(cgroup, "cpu.stat"), "usage_usec 1000000000\n")
+	if m := c.Collect(); m.CPUPercent <= 0 {
+		t.Errorf("expected positive CPU percent, got %f", m.CPUPercent)
+	}
+}
+
+func TestDiskUsageCountsWorkspaceFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src", "main.go"), strings.Repeat("x", 256*1024))
+	writeFile(t, filepath.Join(dir, "README"), "hello\n")
+
+	used, total := diskUsage(dir)
+	if used < 256*1024 || used > 1024*1024 {
+		t.Errorf("expected about 256KiB used by the workspace, got %d", used)
+	}
+	if total <= used {
+		t.Errorf("expected total %d above used %d", total, used)
+	}
+
+	// Counting stops at the limit
+	if partial := walkUsage(dir, 2); partial >= used {
+		t.Errorf("expected a bounded walk to count less than %d, got %d", used, partial)
+	}
+}
+
+func TestCollectorUnlimitedMemory(t *testing.T) {
+	cgroup := t.TempDir()
+	writeFile(t, filepath.Join(cgroup, "memory.max"), "max\n")
+
+	c := &Collector{cgroupRoot: cgroup, procRoot: t.TempDir()}
+	if m := c.Collect(); m.MemoryLimit != 0 {
+		t.Errorf("expected no memory limit, got %d", m.MemoryLimit)
+	}
+}
@@ -2,22 +2,35 @@ package ssh
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/codepod/codepod/sandbox/agent/pkg/environ"
+	"github.com/codepod/codepod/sandbox/agent/pkg/metrics"
+	"github.com/codepod/codepod/sandbox/agent/pkg/reporter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
 )
 
+var logger = logging.Component("ssh")
+
+var tracer = otel.Tracer("github.com/codepod/codepod/sandbox/agent/pkg/ssh")
+
 // parseTrustedCAKey parses the trusted CA public key from OpenSSH format
 // and returns it in a format that ssh.ParseAuthorizedKey can handle
 func parseTrustedCAKey(trustedUserCAKeys string) (ssh.PublicKey, error) {
@@ -70,8 +83,8 @@ func getSignedData(cert *ssh.Certificate) []byte {
 		ValidPrincipals: cert.ValidPrincipals,
 		ValidAfter:      cert.ValidAfter,
 		ValidBefore:     cert.ValidBefore,
-		CriticalOptions:  cert.CriticalOptions,
-		Extensions:       cert.Extensions,
+		CriticalOptions: cert.CriticalOptions,
+		Extensions:      cert.Extensions,
 		Reserved:        cert.Reserved,
 	}
 	b = marshalCertBody(b, body)
@@ -166,12 +179,14 @@ func marshalCertBody(b []byte, body *certBody) []byte {
 }
 
 type ServerConfig struct {
-	Port             int
-	HostKeys         []string
-	MaxSessions      int
-	Token            string
-	IdleTimeout      int
-	TrustedUserCAKeys string // SSH CA public key for certificate authentication
+	Port              int
+	HostKeys          []string
+	MaxSessions       int
+	Token             string
+	IdleTimeout       int              // Seconds without traffic before a connection is closed, 0 for none
+	TrustedUserCAKeys string           // SSH CA public key for certificate authentication
+	Events            reporter.Emitter // Receives session and auth events (optional)
+	Shell             string           // Shell for sessions and exec requests (default /bin/sh)
 }
 
 type SSHServer struct {
@@ -180,13 +195,74 @@ type SSHServer struct {
 	listeners  []net.Listener
 	running    bool
 	sessionMgr *SessionManager
+	hostKeys   []ssh.Signer
+	ready      chan struct{}
+	readyOnce  sync.Once
+	conns      map[*ssh.ServerConn]struct{} // Open client connections, for draining
+	channels   map[ssh.Channel]struct{}     // Channels with a running session
+	limits     chan struct{}                // Closed and replaced when SetLimits changes the limits
 }
 
 func NewServer(cfg *ServerConfig) *SSHServer {
 	return &SSHServer{
 		config:     cfg,
 		sessionMgr: NewSessionManager(),
+		ready:      make(chan struct{}),
+		conns:      make(map[*ssh.ServerConn]struct{}),
+		channels:   make(map[ssh.Channel]struct{}),
+		limits:     make(chan struct{}),
+	}
+}
+
+// Ready returns a channel that is closed once the server accepts connections
+func (s *SSHServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// markReady signals that the server is accepting connections
+func (s *SSHServer) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// LoadHostKeys loads the configured host keys. It fails if none could be
+// loaded, since the server cannot complete a handshake without one.
+func (s *SSHServer) LoadHostKeys() error {
+	logger.Info("Loading host keys", "paths", s.config.HostKeys)
+	var signers []ssh.Signer
+	for _, keyPath := range s.config.HostKeys {
+		key, err := loadHostKey(keyPath)
+		if err != nil {
+			logger.Warn("Failed to load host key", "path", keyPath, "error", err)
+			continue
+		}
+		logger.Info("Loaded host key", "path", keyPath)
+		signers = append(signers, key)
+	}
+	if len(signers) == 0 {
+		return fmt.Errorf("no host keys loaded from %v", s.config.HostKeys)
+	}
+
+	s.mu.Lock()
+	s.hostKeys = signers
+	s.mu.Unlock()
+	return nil
+}
+
+// signers returns the loaded host keys, loading them on first use
+func (s *SSHServer) signers() []ssh.Signer {
+	s.mu.RLock()
+	keys := s.hostKeys
+	s.mu.RUnlock()
+	if keys != nil {
+		return keys
+	}
+	if err := s.LoadHostKeys(); err != nil {
+		logger.Error("SSH server will not be able to accept connections", "error", err)
+		return nil
 	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hostKeys
 }
 
 // SetSessionManager sets the session manager (for testing)
@@ -194,6 +270,60 @@ func (s *SSHServer) SetSessionManager(mgr *SessionManager) {
 	s.sessionMgr = mgr
 }
 
+// SetTrustedUserCAKeys replaces the CA that signs user certificates. New
+// connections use it; established connections are unaffected.
+func (s *SSHServer) SetTrustedUserCAKeys(keys string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.TrustedUserCAKeys = keys
+}
+
+// SetLimits changes the maximum number of sessions (0 for no limit) and the
+// idle timeout in seconds. The idle timeout applies to open connections too.
+func (s *SSHServer) SetLimits(maxSessions, idleTimeout int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.MaxSessions = maxSessions
+	s.config.IdleTimeout = idleTimeout
+	close(s.limits)
+	s.limits = make(chan struct{})
+}
+
+// trustedUserCAKeys returns the CA that signs user certificates
+func (s *SSHServer) trustedUserCAKeys() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.TrustedUserCAKeys
+}
+
+// idleTimeout returns how long a connection may go without traffic, 0 for
+// no limit, and a channel closed when it changes
+func (s *SSHServer) idleTimeout() (time.Duration, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Duration(s.config.IdleTimeout) * time.Second, s.limits
+}
+
+// maxSessions returns the session limit, 0 for no limit
+func (s *SSHServer) maxSessions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.MaxSessions
+}
+
+// shell returns the shell that runs sessions and exec requests
+func (s *SSHServer) shell() string {
+	if s.config.Shell != "" {
+		return s.config.Shell
+	}
+	return "/bin/sh"
+}
+
+// SessionCount returns the number of live SSH sessions
+func (s *SSHServer) SessionCount() int {
+	return s.sessionMgr.Count()
+}
+
 func (s *SSHServer) Start(ctx context.Context) error {
 	s.mu.Lock()
 	if s.running {
@@ -212,7 +342,8 @@ func (s *SSHServer) Start(ctx context.Context) error {
 	s.listeners = append(s.listeners, listener)
 	s.mu.Unlock()
 
-	log.Printf("SSH Server listening on %s", addr)
+	logger.Info("SSH server listening", "addr", addr)
+	s.markReady()
 
 	for {
 		select {
@@ -225,7 +356,7 @@ func (s *SSHServer) Start(ctx context.Context) error {
 				if s.isShuttingDown() {
 					return nil
 				}
-				log.Printf("Failed to accept connection: %v", err)
+				logger.Warn("Failed to accept connection", "error", err)
 				continue
 			}
 			go s.handleConnection(conn)
@@ -247,7 +378,8 @@ func (s *SSHServer) StartWithListener(ctx context.Context, listener net.Listener
 	s.listeners = append(s.listeners, listener)
 	s.mu.Unlock()
 
-	log.Printf("SSH Server listening on %s", listener.Addr().String())
+	logger.Info("SSH server listening", "addr", listener.Addr().String())
+	s.markReady()
 
 	for {
 		select {
@@ -260,7 +392,7 @@ func (s *SSHServer) StartWithListener(ctx context.Context, listener net.Listener
 				if s.isShuttingDown() {
 					return nil
 				}
-				log.Printf("Failed to accept connection: %v", err)
+				logger.Warn("Failed to accept connection", "error", err)
 				continue
 			}
 			go s.handleConnection(conn)
@@ -268,30 +400,38 @@ func (s *SSHServer) StartWithListener(ctx context.Context, listener net.Listener
 	}
 }
 
+// emit forwards an event to the configured emitter, if any
+func (s *SSHServer) emit(evt reporter.Event) {
+	if s.config.Events != nil {
+		s.config.Events.Emit(evt)
+	}
+}
+
 func (s *SSHServer) isShuttingDown() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return !s.running
 }
 
-func (s *SSHServer) handleConnection(conn net.Conn) {
+func (s *SSHServer) handleConnection(netConn net.Conn) {
+	conn := newIdleConn(netConn)
 	serverConfig := &ssh.ServerConfig{}
 
 	// Try to configure certificate-based authentication
 	var caPublicKey ssh.PublicKey
-	if s.config.TrustedUserCAKeys != "" {
-		log.Printf("Configuring certificate authentication with CA key")
+	if trustedUserCAKeys := s.trustedUserCAKeys(); trustedUserCAKeys != "" {
+		logger.Debug("Configuring certificate authentication with CA key")
 		var err error
-		caPublicKey, err = parseTrustedCAKey(s.config.TrustedUserCAKeys)
+		caPublicKey, err = parseTrustedCAKey(trustedUserCAKeys)
 		if err != nil {
-			log.Printf("WARNING: failed to parse CA public key: %v, falling back to token auth", err)
+			logger.Warn("Failed to parse CA public key, falling back to token auth", "error", err)
 		} else {
-			log.Printf("CA public key parsed successfully: %s", caPublicKey.Type())
+			logger.Debug("CA public key parsed", "type", caPublicKey.Type())
 			serverConfig.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
 				// Recover from any panics during certificate processing
 				defer func() {
 					if r := recover(); r != nil {
-						log.Printf("WARNING: recovered from panic during certificate processing: %v", r)
+						logger.Error("Recovered from panic during certificate processing", "panic", r)
 					}
 				}()
 
@@ -301,7 +441,7 @@ func (s *SSHServer) handleConnection(conn net.Conn) {
 					return nil, fmt.Errorf("expected certificate, got public key")
 				}
 
-				log.Printf("DEBUG: Received CertType: %v, KeyId: %s", cert.CertType, cert.KeyId)
+				logger.Debug("Received certificate", "cert_type", cert.CertType, "key_id", cert.KeyId)
 
 				// Check certificate type (must be user certificate)
 				if cert.CertType != ssh.UserCert {
@@ -317,7 +457,7 @@ func (s *SSHServer) handleConnection(conn net.Conn) {
 				// The signed data is the certificate without the signature
 				signedData := getSignedData(cert)
 
-				log.Printf("DEBUG: signedData length: %d", len(signedData))
+				logger.Debug("Verifying certificate signature", "signed_data_length", len(signedData))
 
 				if err := caPublicKey.Verify(signedData, cert.Signature); err != nil {
 					return nil, fmt.Errorf("certificate not signed by trusted CA: %v", err)
@@ -332,50 +472,68 @@ func (s *SSHServer) handleConnection(conn net.Conn) {
 					return nil, fmt.Errorf("certificate has expired")
 				}
 
-				log.Printf("Certificate authenticated for user: %s, keyId: %s",
-					conn.User(), cert.KeyId)
+				logger.Info("Certificate authenticated", "user", conn.User(), "key_id", cert.KeyId, "remote_addr", conn.RemoteAddr().String())
 
 				return &ssh.Permissions{
 					CriticalOptions: cert.CriticalOptions,
-					Extensions:     cert.Extensions,
+					Extensions:      cert.Extensions,
 				}, nil
 			}
 		}
 	}
 
 	// Token-based authentication (fallback - works even with CA auth configured)
-	log.Printf("Enabling token-based authentication as fallback")
+	logger.Debug("Enabling token-based authentication as fallback")
 	serverConfig.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
-		if string(password) == s.config.Token {
+		if subtle.ConstantTimeCompare(password, []byte(s.config.Token)) == 1 {
 			return &ssh.Permissions{}, nil
 		}
 		return nil, fmt.Errorf("invalid password")
 	}
 
-	// Add host keys
-	log.Printf("Loading host keys from: %v", s.config.HostKeys)
-	hostKeyCount := 0
-	for _, keyPath := range s.config.HostKeys {
-		key, err := loadHostKey(keyPath)
-		if err != nil {
-			log.Printf("Warning: failed to load host key %s: %v", keyPath, err)
-			continue
+	// Report failed authentication attempts ("none" is the client probing for methods)
+	serverConfig.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		if err == nil || method == "none" {
+			return
 		}
-		log.Printf("Successfully loaded host key: %s", keyPath)
-		serverConfig.AddHostKey(key)
-		hostKeyCount++
+		metrics.AuthFailures.WithLabelValues(metrics.TransportSSH).Inc()
+		s.emit(reporter.Event{
+			Type:     reporter.EventAuthFailed,
+			Severity: reporter.SeverityWarning,
+			Attributes: map[string]string{
+				"user":       conn.User(),
+				"method":     method,
+				"remoteAddr": conn.RemoteAddr().String(),
+			},
+		})
 	}
-	if hostKeyCount == 0 {
-		log.Printf("WARNING: No host keys loaded! SSH server will not be able to accept connections.")
+
+	// Add host keys
+	for _, key := range s.signers() {
+		serverConfig.AddHostKey(key)
 	}
 
 	sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
 	if err != nil {
-		log.Printf("Failed to establish SSH connection: %v", err)
+		logger.Warn("Failed to establish SSH connection", "remote_addr", conn.RemoteAddr().String(), "error", err)
 		return
 	}
 	defer sshConn.Close()
 
+	s.mu.Lock()
+	s.conns[sshConn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, sshConn)
+		s.mu.Unlock()
+	}()
+
+	// Close the connection once it goes quiet for the idle timeout
+	done := make(chan struct{})
+	defer close(done)
+	go s.closeWhenIdle(conn, done)
+
 	// Handle requests in a separate goroutine
 	go ssh.DiscardRequests(reqs)
 
@@ -387,16 +545,83 @@ func (s *SSHServer) handleConnection(conn net.Conn) {
 
 		channel, requests, err := newChannel.Accept()
 		if err != nil {
-			log.Printf("Failed to accept channel: %v", err)
+			logger.Warn("Failed to accept channel", "error", err)
 			continue
 		}
 
-		go s.handleSession(channel, requests, sshConn.User())
+		go s.handleSession(channel, requests, sshConn.User(), sshConn.RemoteAddr().String())
+	}
+}
+
+// idleConn records when a connection last carried traffic in either
+// direction
+type idleConn struct {
+	net.Conn
+	lastActive atomic.Int64 // Unix nanoseconds
+}
+
+func newIdleConn(conn net.Conn) *idleConn {
+	c := &idleConn{Conn: conn}
+	c.touch()
+	return c
+}
+
+func (c *idleConn) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+// idleFor returns how long the connection has carried no traffic
+func (c *idleConn) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastActive.Load()))
+}
+
+// closeWhenIdle closes conn once it has carried no traffic for the idle
+// timeout, until done is closed
+func (s *SSHServer) closeWhenIdle(conn *idleConn, done <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		timeout, changed := s.idleTimeout()
+		var expired <-chan time.Time
+		if timeout > 0 {
+			idle := conn.idleFor()
+			if idle >= timeout {
+				logger.Info("Closing idle connection", "remote_addr", conn.RemoteAddr().String(), "idle", idle.Round(time.Second))
+				conn.Close()
+				return
+			}
+			timer.Reset(timeout - idle)
+			expired = timer.C
+		}
+
+		select {
+		case <-done:
+			return
+		case <-changed:
+		case <-expired:
+		}
 	}
 }
 
-func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, user string) {
-	log.Printf("New session started for user: %s", user)
+func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, user, remoteAddr string) {
+	log := logger.With("user", user, "remote_addr", remoteAddr)
+	log.Info("New session started")
 
 	// Wait for the first request to determine session type
 	var sessionType SessionType = SessionTypeInteractive
@@ -409,18 +634,18 @@ func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Requ
 		select {
 		case req, ok := <-requests:
 			if !ok {
-				log.Printf("Session channel closed for user: %s", user)
+				log.Info("Session channel closed")
 				channel.Close()
 				return
 			}
 
-			log.Printf("DEBUG: Received request type: %s", req.Type)
+			log.Debug("Received request", "type", req.Type)
 
 			switch req.Type {
 			case "shell":
 				// Interactive shell session
 				sessionType = SessionTypeInteractive
-				log.Printf("Starting interactive shell for user: %s", user)
+				log.Info("Starting interactive shell")
 				req.Reply(true, nil)
 				// Break out to create session
 				goto createSession
@@ -430,14 +655,14 @@ func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Requ
 				if err := ssh.Unmarshal(req.Payload, &execReq); err == nil {
 					command = execReq.Command
 					sessionType = SessionTypeExec
-					log.Printf("Executing command for user %s: %s", user, command)
+					log.Info("Executing command", "command", command)
 				}
 				req.Reply(true, nil)
 				// Break out to create session
 				goto createSession
 			case "env":
 				// Environment variable request - just acknowledge it
-				log.Printf("Received env request from user: %s", user)
+				log.Debug("Received env request")
 				req.Reply(true, nil)
 				// Continue to wait for more requests
 			case "pty-req":
@@ -447,12 +672,12 @@ func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Requ
 					cols = ptyReq.Columns
 					rows = ptyReq.Rows
 					sessionType = SessionTypeInteractive
-					log.Printf("PTY requested: cols=%d, rows=%d", cols, rows)
+					log.Debug("PTY requested", "cols", cols, "rows", rows)
 				}
 				req.Reply(true, nil)
 				// Continue to wait for more requests
 			default:
-				log.Printf("Unknown request type: %s", req.Type)
+				log.Debug("Unknown request type", "type", req.Type)
 				req.Reply(true, nil)
 			}
 		}
@@ -460,6 +685,13 @@ func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Requ
 
 createSession:
 
+	if max := s.maxSessions(); max > 0 && s.SessionCount() >= max {
+		log.Warn("Rejecting session: session limit reached", "max_sessions", max)
+		channel.Stderr().Write([]byte(fmt.Sprintf("session limit of %d reached\r\n", max)))
+		channel.Close()
+		return
+	}
+
 	// Create session
 	session, err := s.sessionMgr.Create(&SessionConfig{
 		Type:    sessionType,
@@ -469,11 +701,42 @@ createSession:
 		Command: command,
 	})
 	if err != nil {
-		log.Printf("Failed to create session: %v", err)
+		log.Error("Failed to create session", "error", err)
 		channel.Close()
 		return
 	}
 
+	log = log.With("session_id", session.ID)
+	log.Debug("Session created", "type", sessionType)
+
+	metrics.SSHSessions.Inc()
+	defer metrics.SSHSessions.Dec()
+
+	_, span := tracer.Start(context.Background(), "ssh.session", trace.WithAttributes(
+		attribute.String("ssh.session.id", session.ID),
+		attribute.String("ssh.session.type", string(sessionType)),
+		attribute.String("ssh.user", user),
+	))
+	defer span.End()
+
+	sessionAttrs := map[string]string{
+		"sessionId":  session.ID,
+		"user":       user,
+		"type":       string(sessionType),
+		"remoteAddr": remoteAddr,
+	}
+	s.emit(reporter.Event{Type: reporter.EventSessionOpened, Attributes: sessionAttrs})
+	defer s.emit(reporter.Event{Type: reporter.EventSessionClosed, Attributes: sessionAttrs})
+
+	s.mu.Lock()
+	s.channels[channel] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.channels, channel)
+		s.mu.Unlock()
+	}()
+
 	// Handle remaining requests
 	go func() {
 		for req := range requests {
@@ -493,26 +756,36 @@ createSession:
 	}()
 
 	// Execute based on session type
+	var exitCode int
 	if sessionType == SessionTypeExec {
-		s.handleExec(channel, session, command)
+		exitCode = s.handleExec(channel, session, command)
 	} else {
-		s.handleShell(channel, session)
+		exitCode = s.handleShell(channel, session)
 	}
+	span.SetAttributes(attribute.Int("process.exit_code", exitCode))
 }
 
-func (s *SSHServer) handleShell(channel ssh.Channel, session *Session) {
+// sessionLogger returns a logger tagged with the session's ID and user
+func sessionLogger(session *Session) *slog.Logger {
+	return logger.With("session_id", session.ID, "user", session.User)
+}
+
+// handleShell runs an interactive shell and returns its exit code
+func (s *SSHServer) handleShell(channel ssh.Channel, session *Session) int {
+	log := sessionLogger(session)
+
 	// Start shell process
 	cmd, err := s.startShell(session)
 	if err != nil {
-		log.Printf("Failed to start shell: %v", err)
+		log.Error("Failed to start shell", "error", err)
 		s.sessionMgr.Close(session.ID)
 		channel.Close()
-		return
+		return -1
 	}
 
 	// Copy data between channel and PTY
-	go io.Copy(channel, session.PTY.Master)
-	go io.Copy(session.PTY.Master, channel)
+	go io.Copy(metrics.CountingWriter(channel, metrics.TransportSSH, "stdout"), session.PTY.Master)
+	go io.Copy(metrics.CountingWriter(session.PTY.Master, metrics.TransportSSH, "stdin"), channel)
 
 	// Wait for command to finish
 	cmd.Wait()
@@ -529,17 +802,22 @@ func (s *SSHServer) handleShell(channel ssh.Channel, session *Session) {
 
 	s.sessionMgr.Close(session.ID)
 	channel.Close()
-	log.Printf("Shell session closed for user: %s", session.User)
+	log.Info("Shell session closed", "exit_code", exitCode)
+	return exitCode
 }
 
-func (s *SSHServer) handleExec(channel ssh.Channel, session *Session, command string) {
+// handleExec runs command without a PTY and returns its exit code
+func (s *SSHServer) handleExec(channel ssh.Channel, session *Session, command string) int {
+	log := sessionLogger(session)
 	defer s.sessionMgr.Close(session.ID)
 	defer channel.Close()
 
-	log.Printf("Executing command: %s", command)
+	log.Debug("Executing command", "command", command)
 
 	// Execute command without PTY
-	cmd := exec.Command("/bin/sh", "-c", command)
+	start := time.Now()
+	cmd := exec.Command(s.shell(), "-c", command)
+	cmd.Env = environ.Child()
 	output, err := cmd.Output()
 
 	// Send exit status first
@@ -550,10 +828,10 @@ func (s *SSHServer) handleExec(channel ssh.Channel, session *Session, command st
 		} else {
 			exitCode = 1
 		}
-		log.Printf("Exec command '%s' failed with exit code %d: %v", command, exitCode, err)
+		log.Warn("Exec command failed", "command", command, "exit_code", exitCode, "error", err)
 	} else {
 		exitCode = 0
-		log.Printf("Exec command '%s' succeeded", command)
+		log.Debug("Exec command succeeded", "command", command)
 	}
 
 	// Send exit status
@@ -563,18 +841,22 @@ func (s *SSHServer) handleExec(channel ssh.Channel, session *Session, command st
 
 	// Send output if any
 	if err == nil && len(output) > 0 {
-		channel.Write(output)
+		n, _ := channel.Write(output)
+		metrics.StreamedBytes.WithLabelValues(metrics.TransportSSH, "stdout").Add(float64(n))
 	}
 
-	log.Printf("Exec completed: %s (exit code: %d)", command, exitCode)
+	metrics.ObserveExec(metrics.TransportSSH, exitCode, time.Since(start))
+
+	log.Info("Exec completed", "command", command, "exit_code", exitCode)
+	return exitCode
 }
 
 type ptyRequest struct {
-	Term     string
-	Columns  uint16
-	Rows     uint16
-	Width    uint16
-	Height   uint16
+	Term    string
+	Columns uint16
+	Rows    uint16
+	Width   uint16
+	Height  uint16
 }
 
 type execRequest struct {
@@ -582,11 +864,11 @@ type execRequest struct {
 }
 
 type termRequest struct {
-	Term     string
-	Columns  uint32
-	Rows     uint32
-	Width    uint32
-	Height   uint32
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
 }
 
 type exitStatusMsg struct {
@@ -594,7 +876,8 @@ type exitStatusMsg struct {
 }
 
 func (s *SSHServer) startShell(session *Session) (*exec.Cmd, error) {
-	cmd := exec.Command("/bin/sh")
+	cmd := exec.Command(s.shell())
+	cmd.Env = environ.Child()
 	cmd.Stdin = session.PTY.Slave
 	cmd.Stdout = session.PTY.Slave
 	cmd.Stderr = session.PTY.Slave
@@ -620,6 +903,43 @@ func (s *SSHServer) Stop() error {
 	return nil
 }
 
+// Drain stops accepting connections, writes message to the stderr of every
+// running session and waits for the sessions to end. Connections still open
+// when ctx is done are closed.
+func (s *SSHServer) Drain(ctx context.Context, message string) error {
+	s.Stop()
+
+	s.mu.RLock()
+	channels := make([]ssh.Channel, 0, len(s.channels))
+	for channel := range s.channels {
+		channels = append(channels, channel)
+	}
+	s.mu.RUnlock()
+	for _, channel := range channels {
+		channel.Stderr().Write([]byte("\r\n*** " + message + " ***\r\n"))
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for s.SessionCount() > 0 {
+		select {
+		case <-ctx.Done():
+			s.mu.RLock()
+			logger.Warn("Closing SSH connections with sessions still running", "connections", len(s.conns), "sessions", s.SessionCount())
+			for conn := range s.conns {
+				conn.Close()
+			}
+			s.mu.RUnlock()
+			for _, session := range s.sessionMgr.List() {
+				s.sessionMgr.Close(session.ID)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
 // loadHostKey loads an SSH private key file
 func loadHostKey(path string) (ssh.Signer, error) {
 	data, err := os.ReadFile(path)
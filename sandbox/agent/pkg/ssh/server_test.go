@@ -1,7 +1,20 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 func TestNewServer(t *testing.T) {
@@ -102,3 +115,224 @@ func TestAuthenticate(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadHostKeys(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "host_key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	server := NewServer(&ServerConfig{HostKeys: []string{"/nonexistent/key", keyPath}})
+	if err := server.LoadHostKeys(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(server.signers()) != 1 {
+		t.Errorf("expected 1 host key, got %d", len(server.signers()))
+	}
+
+	missing := NewServer(&ServerConfig{HostKeys: []string{"/nonexistent/key"}})
+	if err := missing.LoadHostKeys(); err == nil {
+		t.Error("expected error when no host keys can be loaded")
+	}
+}
+
+func TestReadyAfterListening(t *testing.T) {
+	server := NewServer(&ServerConfig{})
+
+	select {
+	case <-server.Ready():
+		t.Fatal("server should not be ready before listening")
+	default:
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.StartWithListener(ctx, listener)
+	defer server.Stop()
+
+	select {
+	case <-server.Ready():
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not become ready")
+	}
+}
+
+// startTestServer runs a server with a generated host key and returns the
+// server and its address
+func startTestServer(t *testing.T) (*SSHServer, string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "host_key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	server := NewServer(&ServerConfig{HostKeys: []string{keyPath}, Token: "secret"})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go server.StartWithListener(ctx, listener)
+	return server, listener.Addr().String()
+}
+
+// startTestSession connects to addr and runs command in an exec session
+func startTestSession(t *testing.T, addr, command string, stderr *bytes.Buffer) *ssh.Session {
+	t.Helper()
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("failed to open session: %v", err)
+	}
+	session.Stderr = stderr
+	if err := session.Start(command); err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+	return session
+}
+
+// waitForSessions waits until the server has n sessions
+func waitForSessions(t *testing.T, server *SSHServer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.SessionCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d sessions, got %d", n, server.SessionCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startSessionTestServer runs a server with an exec session running command
+func startSessionTestServer(t *testing.T, command string, stderr *bytes.Buffer) (*SSHServer, *ssh.Session) {
+	t.Helper()
+	server, addr := startTestServer(t)
+	session := startTestSession(t, addr, command, stderr)
+	waitForSessions(t, server, 1)
+	return server, session
+}
+
+func TestDrainWaitsForSessions(t *testing.T) {
+	var stderr bytes.Buffer
+	server, session := startSessionTestServer(t, "sleep 0.5", &stderr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Drain(ctx, "Sandbox is shutting down"); err != nil {
+		t.Fatalf("expected sessions to finish, got %v", err)
+	}
+	if server.SessionCount() != 0 {
+		t.Errorf("expected no sessions after drain, got %d", server.SessionCount())
+	}
+
+	session.Wait()
+	if !strings.Contains(stderr.String(), "Sandbox is shutting down") {
+		t.Errorf("expected wall message on stderr, got %q", stderr.String())
+	}
+}
+
+func TestDrainClosesSessionsAfterGracePeriod(t *testing.T) {
+	var stderr bytes.Buffer
+	server, session := startSessionTestServer(t, "sleep 5", &stderr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := server.Drain(ctx, "Sandbox is shutting down"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client session was not closed")
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	server, addr := startTestServer(t)
+	server.SetLimits(1, 0)
+
+	var first, second bytes.Buffer
+	startTestSession(t, addr, "sleep 1", &first)
+	waitForSessions(t, server, 1)
+
+	session := startTestSession(t, addr, "true", &second)
+	session.Wait()
+	if !strings.Contains(second.String(), "session limit of 1 reached") {
+		t.Errorf("expected second session to be rejected, got %q", second.String())
+	}
+	if server.SessionCount() != 1 {
+		t.Errorf("expected 1 session, got %d", server.SessionCount())
+	}
+}
+
+func TestIdleTimeoutClosesQuietConnections(t *testing.T) {
+	var stderr bytes.Buffer
+	server, session := startSessionTestServer(t, "sleep 5", &stderr)
+	server.SetLimits(0, 1)
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(4 * time.Second):
+		t.Fatal("idle connection was not closed")
+	}
+	waitForSessions(t, server, 0)
+}
+
+func TestIdleTimeoutKeepsBusyConnections(t *testing.T) {
+	var stderr bytes.Buffer
+	server, session := startSessionTestServer(t, "sleep 2", &stderr)
+	server.SetLimits(0, 1)
+
+	// Keepalives are traffic too
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	ticker := time.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("expected the busy session to finish, got %v", err)
+			}
+			return
+		case <-ticker.C:
+			session.SendRequest("keepalive@openssh.com", false, nil)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package workload supervises the image's original entrypoint and cmd,
+// which the agent runs as the sandbox's main workload
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/codepod/codepod/sandbox/agent/pkg/environ"
+)
+
+var logger = logging.Component("workload")
+
+// State represents the workload state
+type State string
+
+const (
+	StatePending State = "pending"
+	StateRunning State = "running"
+	StateExited  State = "exited"
+	StateFailed  State = "failed"
+	StateStopped State = "stopped" // Exited after Stop, whatever its exit code
+)
+
+// Status is a snapshot of the workload state
+type Status struct {
+	State      State
+	PID        int
+	ExitCode   int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Supervisor runs the workload command and tracks its exit
+type Supervisor struct {
+	command  []string
+	mu       sync.RWMutex
+	status   Status
+	cmd      *exec.Cmd
+	done     chan struct{}
+	onExit   func(Status)
+	stopping bool // Set by Stop, so the exit it causes is not a failure
+}
+
+// NewSupervisor creates a supervisor for the given command line
+func NewSupervisor(command []string) *Supervisor {
+	return &Supervisor{
+		command: command,
+		status:  Status{State: StatePending},
+		done:    make(chan struct{}),
+	}
+}
+
+// OnExit registers a callback invoked once the workload has exited
+func (s *Supervisor) OnExit(fn func(Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onExit = fn
+}
+
+// Start launches the workload. Its output goes to the agent's stdout and
+// stderr so it shows up in the container logs.
+func (s *Supervisor) Start() error {
+	if len(s.command) == 0 {
+		return fmt.Errorf("workload command is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd != nil {
+		return fmt.Errorf("workload already started")
+	}
+
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Env = environ.Child()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Run in its own process group so signals reach the whole workload tree
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	if err := cmd.Start(); err != nil {
+		s.status = Status{
+			State:      StateFailed,
+			ExitCode:   127,
+			Error:      err.Error(),
+			FinishedAt: time.Now(),
+		}
+		close(s.done)
+		return fmt.Errorf("failed to start workload: %w", err)
+	}
+
+	s.cmd = cmd
+	s.status = Status{
+		State:     StateRunning,
+		PID:       cmd.Process.Pid,
+		StartedAt: time.Now(),
+	}
+	logger.Info("Workload started", "command", strings.Join(s.command, " "), "pid", cmd.Process.Pid)
+
+	go s.wait()
+	return nil
+}
+
+// wait reaps the workload and records its exit status
+func (s *Supervisor) wait() {
+	err := s.cmd.Wait()
+
+	s.mu.Lock()
+	s.status.FinishedAt = time.Now()
+	s.status.ExitCode = exitCode(s.cmd.ProcessState)
+	if s.stopping {
+		s.status.State = StateStopped
+	} else if err != nil && s.status.ExitCode != 0 {
+		s.status.State = StateFailed
+		s.status.Error = err.Error()
+	} else {
+		s.status.State = StateExited
+	}
+	status := s.status
+	onExit := s.onExit
+	s.mu.Unlock()
+
+	logger.Info("Workload exited", "exit_code", status.ExitCode)
+	close(s.done)
+
+	if onExit != nil {
+		onExit(status)
+	}
+}
+
+// Status returns the current workload status
+func (s *Supervisor) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Done returns a channel that is closed once the workload has exited
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop sends SIGTERM to the workload's process group and escalates to
+// SIGKILL if it has not exited within the timeout
+func (s *Supervisor) Stop(timeout time.Duration) error {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.stopping = true
+	s.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to signal workload: %w", err)
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	logger.Warn("Workload did not exit in time, killing", "timeout", timeout)
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to kill workload: %w", err)
+	}
+	<-s.done
+	return nil
+}
+
+// exitCode converts a process state to a shell-style exit code
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
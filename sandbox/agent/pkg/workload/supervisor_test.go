@@ -0,0 +1,82 @@
+package workload
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupervisorExitCode(t *testing.T) {
+	sup := NewSupervisor([]string{"sh", "-c", "exit 3"})
+
+	exited := make(chan Status, 1)
+	sup.OnExit(func(status Status) {
+		exited <- status
+	})
+
+	if err := sup.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case status := <-exited:
+		if status.State != StateFailed {
+			t.Errorf("expected state failed, got %s", status.State)
+		}
+		if status.ExitCode != 3 {
+			t.Errorf("expected exit code 3, got %d", status.ExitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for workload exit")
+	}
+}
+
+func TestSupervisorCleanExit(t *testing.T) {
+	sup := NewSupervisor([]string{"true"})
+	if err := sup.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-sup.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for workload exit")
+	}
+
+	if status := sup.Status(); status.State != StateExited || status.ExitCode != 0 {
+		t.Errorf("expected clean exit, got %+v", status)
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	sup := NewSupervisor([]string{"sleep", "60"})
+	if err := sup.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := sup.Status(); status.State != StateRunning || status.PID == 0 {
+		t.Fatalf("expected running workload, got %+v", status)
+	}
+
+	if err := sup.Stop(time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// sleep dies from SIGTERM: 128 + 15, which is not a failure when the
+	// agent asked for it
+	status := sup.Status()
+	if status.ExitCode != 143 {
+		t.Errorf("expected exit code 143, got %d", status.ExitCode)
+	}
+	if status.State != StateStopped || status.Error != "" {
+		t.Errorf("expected stopped state, got %+v", status)
+	}
+}
+
+func TestSupervisorStartMissingBinary(t *testing.T) {
+	sup := NewSupervisor([]string{"/nonexistent/binary"})
+	if err := sup.Start(); err == nil {
+		t.Fatal("expected error for missing binary")
+	}
+	if status := sup.Status(); status.State != StateFailed || status.ExitCode != 127 {
+		t.Errorf("expected failed state with exit code 127, got %+v", status)
+	}
+}
@@ -1,22 +1,30 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
 	"github.com/codepod/codepod/sandbox/runner/internal/runner"
 )
 
+var logger = logging.Component("main")
+
 // Version is set at build time via ldflags
 var Version = "v0.0.0-dev"
 
 func main() {
 	flag.Bool("version", false, "Show version")
 	flag.Bool("v", false, "Show version (shorthand)")
+	drain := flag.Bool("drain", false, "Ask the server to drain the runner enrolled on this host, then exit")
+	reschedule := flag.Bool("reschedule", false, "With -drain, hand the runner's sandboxes to other runners")
+	reason := flag.String("reason", "drain requested from the command line", "With -drain, why the runner is drained")
 	flag.Parse()
 
 	if flag.Lookup("version").Value.String() == "true" || flag.Lookup("v").Value.String() == "true" {
@@ -24,24 +32,36 @@ func main() {
 		os.Exit(0)
 	}
 
-	log.Println("Starting CodePod Runner...")
+	if *drain {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := runner.RequestDrain(ctx, &runner.DrainRequest{Reason: *reason, Reschedule: *reschedule}); err != nil {
+			log.Fatalf("Failed to request drain: %v", err)
+		}
+		fmt.Println("Drain requested")
+		return
+	}
 
-	r, err := runner.New()
+	r, err := runner.New(Version)
 	if err != nil {
 		log.Fatalf("Failed to create runner: %v", err)
 	}
+	logger.Info("Starting CodePod Runner", "version", Version)
 
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
+	// Drain on the first shutdown signal, exit at once on the second
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
+		sig := <-sigChan
+		logger.Info("Shutdown signal received, draining runner", "signal", sig.String())
+		go r.Shutdown(&runner.DrainRequest{Reason: "received " + sig.String()})
+
 		<-sigChan
-		log.Println("Shutdown signal received, stopping runner...")
-		r.Stop()
-		os.Exit(0)
+		logger.Warn("Second shutdown signal received, exiting without draining")
+		os.Exit(1)
 	}()
 
-	fmt.Println("Runner started successfully")
+	logger.Info("Runner started")
 	r.Run()
 }
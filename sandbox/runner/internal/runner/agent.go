@@ -0,0 +1,181 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/tracing"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// agentPollInterval is how often the agent's health service is polled while
+// waiting for it to become ready
+const agentPollInterval = 500 * time.Millisecond
+
+// agentViableService is the agent's health service that turns NOT_SERVING
+// once the agent can no longer become ready, e.g. its workload has exited
+const agentViableService = "viable"
+
+// waitForAgent blocks until the sandbox's agent reports SERVING through the
+// grpc.health.v1 service, so a sandbox is only reported running once the
+// agent has loaded its host keys, is accepting SSH and its workload is up
+func (r *Runner) waitForAgent(ctx context.Context, sandboxID string, sb *sandbox.Sandbox) error {
+	creds, err := r.agentCredentials(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%s:%d", r.getHost(), sb.Port)
+
+	ctx, span := tracer.Start(ctx, "runner.waitForAgent", trace.WithAttributes(attribute.String("server.address", addr)))
+	err = waitForAgentReady(ctx, addr, creds, r.cfg.Agent.ReadyTimeout)
+	tracing.End(span, err)
+	return err
+}
+
+// waitForAgentReady polls the health service at addr until it is SERVING or
+// timeout elapses. It gives up early once the agent reports it cannot become
+// ready.
+func waitForAgentReady(ctx context.Context, addr string, creds grpc.DialOption, timeout time.Duration) error {
+	conn, err := grpc.NewClient(addr, creds, grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor()))
+	if err != nil {
+		return fmt.Errorf("failed to create agent client: %w", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(agentPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		checkCtx, checkCancel := context.WithTimeout(ctx, 2*time.Second)
+		resp, err := client.Check(checkCtx, &healthpb.HealthCheckRequest{})
+		checkCancel()
+		switch {
+		case err != nil:
+			lastErr = err
+		case resp.Status == healthpb.HealthCheckResponse_SERVING:
+			return nil
+		default:
+			lastErr = fmt.Errorf("agent status %s", resp.Status)
+			// Agents without the viable service answer NotFound and are
+			// waited on until the timeout
+			checkCtx, checkCancel := context.WithTimeout(ctx, 2*time.Second)
+			viable, err := client.Check(checkCtx, &healthpb.HealthCheckRequest{Service: agentViableService})
+			checkCancel()
+			if err == nil && viable.Status == healthpb.HealthCheckResponse_NOT_SERVING {
+				return fmt.Errorf("agent at %s cannot become ready: %w", addr, lastErr)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("agent at %s not ready after %s: %w", addr, timeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// agentTLSConfig requests a server certificate for the sandbox's agent from
+// the server's CA and returns the agent config file that enables gRPC over
+// TLS. The file is copied into the container so the private key stays out of
+// the environment. Clients must present a certificate for the sandbox or for
+// this runner.
+func (r *Runner) agentTLSConfig(ctx context.Context, sandboxID string) ([]byte, error) {
+	certReq := &TLSCertificateRequest{
+		CommonName:  sandboxID,
+		DNSNames:    []string{sandboxID, "localhost"},
+		IPAddresses: []string{"127.0.0.1"},
+		Usage:       "server",
+	}
+	// Clients connect through the runner's published address, so it must be
+	// in the certificate too
+	if host := r.getHost(); net.ParseIP(host) != nil {
+		certReq.IPAddresses = append(certReq.IPAddresses, host)
+	} else if host != "localhost" {
+		certReq.DNSNames = append(certReq.DNSNames, host)
+	}
+
+	cert, err := r.client.IssueTLSCertificate(ctx, certReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue agent certificate: %w", err)
+	}
+
+	return json.Marshal(map[string]any{
+		"grpc": map[string]any{
+			"tls": map[string]any{
+				"cert":                cert.Certificate,
+				"key":                 cert.PrivateKey,
+				"client_ca":           cert.CACertificate,
+				"require_client_cert": r.cfg.Agent.RequireClientCert,
+				"allowed_clients":     []string{r.cfg.Runner.ID},
+			},
+		},
+	})
+}
+
+// agentCredentials returns the transport credentials for dialing an agent.
+// With TLS enabled, the agent must present a certificate for sandboxID from
+// the server's CA, and the runner presents its own client certificate.
+func (r *Runner) agentCredentials(ctx context.Context, sandboxID string) (grpc.DialOption, error) {
+	if !r.cfg.Agent.TLS {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	base, err := r.agentClientTLS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg := base.Clone()
+	cfg.ServerName = sandboxID
+	return grpc.WithTransportCredentials(credentials.NewTLS(cfg)), nil
+}
+
+// agentClientTLS returns the runner's client TLS configuration, requesting a
+// client certificate from the server's CA on first use
+func (r *Runner) agentClientTLS(ctx context.Context) (*tls.Config, error) {
+	r.tlsMu.Lock()
+	defer r.tlsMu.Unlock()
+
+	if r.agentTLS != nil {
+		return r.agentTLS, nil
+	}
+
+	issued, err := r.client.IssueTLSCertificate(ctx, &TLSCertificateRequest{
+		CommonName: r.cfg.Runner.ID,
+		Usage:      "client",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue runner client certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(issued.CACertificate)) {
+		return nil, fmt.Errorf("failed to parse CA certificate")
+	}
+	cert, err := tls.X509KeyPair([]byte(issued.Certificate), []byte(issued.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runner client certificate: %w", err)
+	}
+
+	r.agentTLS = &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return r.agentTLS, nil
+}
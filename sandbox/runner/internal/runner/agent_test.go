@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startHealthServer serves a grpc.health.v1 service on a local port
+func startHealthServer(t *testing.T) (*health.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return hs, lis.Addr().String()
+}
+
+func TestWaitForAgentReady(t *testing.T) {
+	hs, addr := startHealthServer(t)
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	}()
+
+	creds := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if err := waitForAgentReady(context.Background(), addr, creds, 10*time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWaitForAgentReadyFailsFast(t *testing.T) {
+	hs, addr := startHealthServer(t)
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus(agentViableService, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	creds := grpc.WithTransportCredentials(insecure.NewCredentials())
+	start := time.Now()
+	if err := waitForAgentReady(context.Background(), addr, creds, 30*time.Second); err == nil {
+		t.Fatal("expected error for an agent that cannot become ready")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected to give up early, waited %s", elapsed)
+	}
+}
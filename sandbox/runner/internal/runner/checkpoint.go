@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/checkpoint"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// handleCheckpointJob saves a sandbox's processes with CRIU, commits its
+// filesystem to a snapshot image and uploads the checkpoint to the server,
+// so any runner that can pull the image can resume the sandbox. The sandbox
+// is stopped once checkpointed unless the job leaves it running, in which
+// case files it writes before the commit may be newer in the image than the
+// checkpointed processes expect.
+func (r *Runner) handleCheckpointJob(ctx context.Context, job *Job) error {
+	log := jobLogger(job).With("snapshot_id", job.SnapshotID)
+
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{Status: "failed", Message: err.Error()})
+		return err
+	}
+
+	log.Info("Checkpointing sandbox", "leave_running", job.LeaveRunning)
+	if err := r.sandbox.Checkpoint(ctx, sb, job.SnapshotID, r.cfg.Docker.CheckpointDir, !job.LeaveRunning); err != nil {
+		return r.failCheckpoint(ctx, job, sb, "failed to checkpoint sandbox", err)
+	}
+	dir := filepath.Join(r.cfg.Docker.CheckpointDir, job.SnapshotID)
+	defer os.RemoveAll(dir)
+	if !job.LeaveRunning {
+		r.reportStatus(ctx, job, &SandboxStatusUpdate{
+			Status:      "stopped",
+			ContainerID: sb.ContainerID,
+			Message:     "Sandbox checkpointed",
+		})
+	}
+
+	// Restored processes expect their volumes, so they are always recorded
+	image := r.snapshotReference(job.SnapshotID)
+	imageID, err := r.sandbox.Snapshot(ctx, sb, &sandbox.SnapshotOptions{
+		ID:        job.SnapshotID,
+		Reference: image,
+		Memory:    job.Memory,
+		CPU:       job.CPU,
+		Volumes:   sandboxVolumes(job.Volumes),
+		Push:      r.cfg.Docker.Registry != "",
+		Auth:      r.registryAuth(),
+	})
+	if err != nil {
+		return r.failCheckpoint(ctx, job, sb, "failed to snapshot sandbox", err)
+	}
+
+	if err := r.uploadCheckpoint(ctx, job.SnapshotID, dir); err != nil {
+		return r.failCheckpoint(ctx, job, sb, "failed to upload checkpoint", err)
+	}
+
+	r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{
+		Status:  "ready",
+		Image:   image,
+		ImageID: imageID,
+		Message: "Checkpoint taken",
+	})
+	log.Info("Checkpoint taken", "image", image, "image_id", imageID)
+	return r.completeLifecycleJob(ctx, job, fmt.Sprintf("Checkpoint %s taken", job.SnapshotID))
+}
+
+// handleRestoreCheckpointJob recreates a checkpointed sandbox from its
+// snapshot image and resumes its processes from the checkpoint. The sandbox
+// keeps its ID, as the restored agent still holds its identity and token.
+func (r *Runner) handleRestoreCheckpointJob(ctx context.Context, job *Job) error {
+	log := jobLogger(job).With("snapshot_id", job.SnapshotID)
+	log.Info("Restoring sandbox from checkpoint", "image", job.Image)
+
+	fail := func(err error) error {
+		log.Error("Restore job failed", "error", err)
+		r.reportStatus(ctx, job, &SandboxStatusUpdate{Status: "failed", Message: err.Error()})
+		r.client.CompleteJob(ctx, job.ID, false, err.Error())
+		return err
+	}
+
+	// The processes resume in a new container, so one the checkpoint left
+	// behind on this runner is removed first
+	if existing, err := r.sandbox.GetByName(ctx, job.SandboxID); err == nil {
+		if err := r.sandbox.Delete(ctx, existing); err != nil {
+			return fail(fmt.Errorf("failed to remove checkpointed container: %w", err))
+		}
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:  "creating",
+		Message: "Restoring container",
+	})
+	if err := r.sandbox.PullSnapshot(ctx, job.Image, job.SnapshotID, r.registryAuth()); err != nil {
+		return fail(err)
+	}
+	info, err := r.docker.InspectImage(ctx, job.Image)
+	if err != nil {
+		return fail(fmt.Errorf("failed to inspect snapshot image %s: %w", job.Image, err))
+	}
+
+	dir := filepath.Join(r.cfg.Docker.CheckpointDir, job.SnapshotID)
+	if err := r.downloadCheckpoint(ctx, job.SnapshotID, dir); err != nil {
+		return fail(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The image already holds the agent and the container's environment, and
+	// the container must be set up like the checkpointed one, so nothing is
+	// injected
+	sb, err := r.sandbox.Create(ctx, &sandbox.CreateOptions{
+		Name:              job.SandboxID,
+		Image:             job.Image,
+		Memory:            job.Memory,
+		CPU:               job.CPU,
+		NetworkMode:       r.cfg.Docker.Network,
+		MountDockerSocket: mountsDockerSocket(info.Labels[sandbox.SnapshotSourceImageLabel]),
+		Volumes:           sandboxVolumes(job.Volumes),
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to create sandbox: %w", err))
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "starting",
+		ContainerID: sb.ContainerID,
+		Message:     "Restoring processes from checkpoint",
+	})
+	if err := r.sandbox.StartFromCheckpoint(ctx, sb, job.SnapshotID, r.cfg.Docker.CheckpointDir); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to restore sandbox", err)
+	}
+	return r.finishStart(ctx, job, sb, "Sandbox restored from checkpoint")
+}
+
+// failCheckpoint fails a checkpoint job, reporting the snapshot failed and
+// the state the sandbox's container was left in
+func (r *Runner) failCheckpoint(ctx context.Context, job *Job, sb *sandbox.Sandbox, msg string, err error) error {
+	r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{Status: "failed", Message: fmt.Sprintf("%s: %v", msg, err)})
+	return r.failLifecycleJob(ctx, job, sb, msg, err)
+}
+
+// uploadCheckpoint streams the checkpoint in dir to the server as an archive
+func (r *Runner) uploadCheckpoint(ctx context.Context, snapshotID, dir string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(checkpoint.Pack(dir, pw))
+	}()
+	err := r.client.UploadCheckpoint(ctx, snapshotID, pr)
+	// Unblocks the archiver when the upload stopped reading early
+	pr.Close()
+	return err
+}
+
+// downloadCheckpoint fetches a snapshot's checkpoint archive and unpacks it
+// into dir, replacing what a previous attempt left there
+func (r *Runner) downloadCheckpoint(ctx context.Context, snapshotID, dir string) error {
+	body, err := r.client.DownloadCheckpoint(ctx, snapshotID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear checkpoint directory: %w", err)
+	}
+	return checkpoint.Unpack(body, dir)
+}
@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+)
+
+func TestHandleCheckpointJob(t *testing.T) {
+	r, mock, srv := newTestRunner(t)
+	sb := startSandbox(t, r, "sb-1")
+
+	job := &Job{ID: "job-1", Type: "checkpoint", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleCheckpointJob(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The container exits with the checkpoint, which the server hears about
+	// before the snapshot is ready
+	if status, _ := mock.ContainerStatus(context.Background(), sb.ContainerID); status != string(docker.ContainerStateExited) {
+		t.Errorf("expected the container to exit, got %s", status)
+	}
+	statuses := srv.sandboxStatuses(t)
+	if len(statuses) != 1 || statuses[0].Status != "stopped" || statuses[0].Message != "Sandbox checkpointed" {
+		t.Errorf("expected a stopped status, got %+v", statuses)
+	}
+
+	if uploads := srv.uploads(); len(uploads) != 1 || uploads[0] != "/api/v1/snapshots/snap-1/checkpoint" {
+		t.Errorf("expected the checkpoint to be uploaded, got %v", uploads)
+	}
+	snapshots := srv.snapshotStatuses(t)
+	if len(snapshots) != 1 || snapshots[0].Status != "ready" || snapshots[0].Image != "codepod-snapshots:snap-1" {
+		t.Errorf("expected the snapshot to be ready, got %+v", snapshots)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || !completions[0].Success {
+		t.Errorf("expected the job to succeed, got %+v", completions)
+	}
+
+	if _, err := os.Stat(filepath.Join(r.cfg.Docker.CheckpointDir, "snap-1")); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint directory to be removed, got %v", err)
+	}
+}
+
+func TestHandleCheckpointJobLeaveRunning(t *testing.T) {
+	r, mock, srv := newTestRunner(t)
+	sb := startSandbox(t, r, "sb-1")
+
+	job := &Job{ID: "job-1", Type: "checkpoint", SandboxID: "sb-1", SnapshotID: "snap-1", LeaveRunning: true}
+	if err := r.handleCheckpointJob(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status, _ := mock.ContainerStatus(context.Background(), sb.ContainerID); status != string(docker.ContainerStateRunning) {
+		t.Errorf("expected the container to keep running, got %s", status)
+	}
+	if statuses := srv.sandboxStatuses(t); len(statuses) != 0 {
+		t.Errorf("expected the sandbox status to be left alone, got %+v", statuses)
+	}
+	if snapshots := srv.snapshotStatuses(t); len(snapshots) != 1 || snapshots[0].Status != "ready" {
+		t.Errorf("expected the snapshot to be ready, got %+v", snapshots)
+	}
+}
+
+func TestHandleCheckpointJobNotRunning(t *testing.T) {
+	r, mock, srv := newTestRunner(t)
+	sb := startSandbox(t, r, "sb-1")
+	mock.SetContainerState(sb.ContainerID, docker.ContainerStateExited)
+
+	job := &Job{ID: "job-1", Type: "checkpoint", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleCheckpointJob(context.Background(), job); err == nil {
+		t.Fatal("expected error checkpointing a stopped sandbox")
+	}
+
+	snapshots := srv.snapshotStatuses(t)
+	if len(snapshots) != 1 || snapshots[0].Status != "failed" ||
+		!strings.HasPrefix(snapshots[0].Message, "failed to checkpoint sandbox") {
+		t.Errorf("expected the snapshot to fail, got %+v", snapshots)
+	}
+	// The sandbox is reported as the container was left, not as failed
+	statuses := srv.sandboxStatuses(t)
+	if len(statuses) != 1 || statuses[0].Status != "stopped" {
+		t.Errorf("expected a stopped status, got %+v", statuses)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || completions[0].Success {
+		t.Errorf("expected the job to fail, got %+v", completions)
+	}
+	if uploads := srv.uploads(); len(uploads) != 0 {
+		t.Errorf("expected nothing to be uploaded, got %v", uploads)
+	}
+}
+
+func TestHandleCheckpointJobUploadFails(t *testing.T) {
+	r, _, srv := newTestRunner(t)
+	startSandbox(t, r, "sb-1")
+	srv.failUploads = true
+
+	job := &Job{ID: "job-1", Type: "checkpoint", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleCheckpointJob(context.Background(), job); err == nil {
+		t.Fatal("expected error when the upload fails")
+	}
+
+	snapshots := srv.snapshotStatuses(t)
+	if len(snapshots) != 1 || snapshots[0].Status != "failed" ||
+		!strings.HasPrefix(snapshots[0].Message, "failed to upload checkpoint") {
+		t.Errorf("expected the snapshot to fail, got %+v", snapshots)
+	}
+	statuses := srv.sandboxStatuses(t)
+	if len(statuses) != 2 || statuses[0].Status != "stopped" || statuses[1].Status != "stopped" {
+		t.Errorf("expected the sandbox to be reported stopped, got %+v", statuses)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || completions[0].Success {
+		t.Errorf("expected the job to fail, got %+v", completions)
+	}
+	if _, err := os.Stat(filepath.Join(r.cfg.Docker.CheckpointDir, "snap-1")); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint directory to be removed, got %v", err)
+	}
+}
+
+func TestHandleCheckpointJobMissingSandbox(t *testing.T) {
+	r, _, srv := newTestRunner(t)
+
+	job := &Job{ID: "job-1", Type: "checkpoint", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleCheckpointJob(context.Background(), job); err == nil {
+		t.Fatal("expected error for a missing sandbox")
+	}
+
+	if snapshots := srv.snapshotStatuses(t); len(snapshots) != 1 || snapshots[0].Status != "failed" {
+		t.Errorf("expected the snapshot to fail, got %+v", snapshots)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || completions[0].Success {
+		t.Errorf("expected the job to fail, got %+v", completions)
+	}
+}
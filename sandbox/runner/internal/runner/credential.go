@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/backoff"
+	"github.com/codepod/codepod/sandbox/runner/pkg/credential"
+)
+
+// credentialCheckInterval is how often the credential is checked for rotation
+const credentialCheckInterval = time.Hour
+
+// errNoJoinToken is returned when the runner has no valid credential and no
+// join token to enroll with
+var errNoJoinToken = errors.New("no valid credential and no join token to enroll with")
+
+// authenticate gives the client a credential, retrying enrollment with
+// backoff while the server is unreachable. It returns false if the runner is
+// stopped first.
+func (r *Runner) authenticate() bool {
+	b := backoff.New(time.Second, 30*time.Second)
+	for {
+		err := r.ensureCredential(context.Background())
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, errNoJoinToken) {
+			logger.Error("Runner is not enrolled, set CODEPOD_SERVER_TOKEN to a join token", "credentials_file", r.cfg.Server.CredentialsFile)
+			return true
+		}
+
+		delay := b.Next()
+		logger.Warn("Failed to enroll with server, retrying", "error", err, "retry_in", delay)
+		select {
+		case <-r.stopChan:
+			return false
+		case <-time.After(delay):
+		}
+	}
+}
+
+// ensureCredential loads the saved credential, enrolling with the join token
+// when there is no valid one
+func (r *Runner) ensureCredential(ctx context.Context) error {
+	path := r.cfg.Server.CredentialsFile
+	cred, err := credential.Load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Ignoring unreadable credential", "path", path, "error", err)
+	}
+	if cred.Valid(r.cfg.Runner.ID, time.Now()) {
+		r.client.SetCredential(cred)
+		return nil
+	}
+
+	if r.cfg.Server.Token == "" {
+		return errNoJoinToken
+	}
+	cred, err = r.client.Enroll(ctx, r.cfg.Server.Token)
+	if err != nil {
+		return fmt.Errorf("failed to enroll: %w", err)
+	}
+	r.client.SetCredential(cred)
+	r.saveCredential(cred)
+	logger.Info("Runner enrolled", "expires_at", cred.ExpiresAt)
+	return nil
+}
+
+// credentialLoop rotates the credential once two thirds of its lifetime
+// have passed
+func (r *Runner) credentialLoop() {
+	ticker := time.NewTicker(credentialCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+			r.rotateCredential()
+		}
+	}
+}
+
+func (r *Runner) rotateCredential() {
+	cred := r.client.Credential()
+	if cred == nil || !cred.NeedsRotation(time.Now()) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rotated, err := r.client.RotateCredential(ctx)
+	if err != nil {
+		logger.Error("Failed to rotate credential", "error", err, "expires_at", cred.ExpiresAt)
+		return
+	}
+	r.client.SetCredential(rotated)
+	r.saveCredential(rotated)
+	logger.Info("Rotated runner credential", "expires_at", rotated.ExpiresAt)
+}
+
+func (r *Runner) saveCredential(cred *credential.Credential) {
+	if err := credential.Save(r.cfg.Server.CredentialsFile, cred); err != nil {
+		logger.Warn("Failed to save credential, the runner will need to enroll again after a restart", "error", err)
+	}
+}
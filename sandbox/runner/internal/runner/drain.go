@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/config"
+	"github.com/codepod/codepod/sandbox/runner/pkg/credential"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// drainReportTimeout bounds the reports a drained runner sends the server
+// before leaving
+const drainReportTimeout = 30 * time.Second
+
+// drainCancelTimeout bounds the wait for jobs cancelled at the drain deadline
+// to return
+const drainCancelTimeout = 30 * time.Second
+
+// Drain stops the runner taking jobs, waits for running jobs to finish within
+// the drain deadline and tells the server the runner is leaving. Jobs still
+// running at the deadline are cancelled, and those that do not finish are
+// reported as failed. With Reschedule, the
+// runner's sandboxes are stopped so the server can place them elsewhere.
+// Later calls wait for the first drain to finish.
+func (r *Runner) Drain(drain *DrainRequest) {
+	r.drainOnce.Do(func() { r.drain(drain) })
+}
+
+// Shutdown drains the runner and then stops it
+func (r *Runner) Shutdown(drain *DrainRequest) {
+	r.Drain(drain)
+	r.Stop()
+}
+
+func (r *Runner) drain(drain *DrainRequest) {
+	deadline := time.Duration(drain.DeadlineSeconds) * time.Second
+	if deadline <= 0 {
+		deadline = r.cfg.Runner.DrainTimeout
+	}
+	log := logger.With("reason", drain.Reason)
+	log.Info("Draining runner", "deadline", deadline, "reschedule", drain.Reschedule, "active_jobs", r.jobs.Active())
+
+	// Tell the server right away so it sends new jobs elsewhere
+	r.draining.Store(true)
+	r.sendHeartbeat()
+
+	if !r.waitForJobs(deadline) {
+		log.Warn("Jobs still running after drain deadline, cancelling them", "active_jobs", r.jobs.Active())
+		r.cancelJobs()
+		// A cancelled create must not start its container after the
+		// sandboxes are stopped, or report it running after it is failed
+		if !r.waitForJobs(drainCancelTimeout) {
+			log.Warn("Cancelled jobs did not return", "active_jobs", r.jobs.Active())
+		}
+		r.failRunningJobs(drain.Reschedule)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainReportTimeout)
+	defer cancel()
+	if drain.Reschedule {
+		r.stopSandboxes(ctx)
+	}
+	if err := r.client.Leave(ctx, drain.Reason, drain.Reschedule); err != nil {
+		log.Warn("Failed to tell the server the runner is leaving", "error", err)
+	}
+	close(r.left)
+	log.Info("Runner drained")
+}
+
+// waitForJobs waits up to timeout for the job pool to empty, reporting
+// whether it did
+func (r *Runner) waitForJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		r.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// failRunningJobs reports the accepted jobs that did not finish as failed,
+// so the server does not wait on a runner that is leaving. Sandboxes being
+// created are marked failed too, unless they are about to be rescheduled, and
+// so are snapshots and checkpoints being taken.
+func (r *Runner) failRunningJobs(reschedule bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainReportTimeout)
+	defer cancel()
+
+	r.runningMu.Lock()
+	jobs := make([]*Job, 0, len(r.running))
+	for _, job := range r.running {
+		jobs = append(jobs, job)
+	}
+	r.runningMu.Unlock()
+
+	for _, job := range jobs {
+		log := jobLogger(job)
+		if placesSandbox(job.Type) && !reschedule {
+			if err := r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
+				Status:  "failed",
+				Message: "Runner drained before the sandbox was created",
+			}); err != nil {
+				log.Warn("Failed to report failed status", "error", err)
+			}
+		}
+		if job.Type == "snapshot" || job.Type == "checkpoint" {
+			r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{
+				Status:  "failed",
+				Message: "Runner drained before the snapshot was taken",
+			})
+		}
+		if err := r.client.CompleteJob(ctx, job.ID, false, "Runner drained before the job finished"); err != nil {
+			log.Warn("Failed to fail job", "error", err)
+		}
+	}
+}
+
+// stopSandboxes stops the running sandboxes the server assigns to this
+// runner, so they do not run alongside the copies it places elsewhere. The
+// containers are kept, so a sandbox placed back on this runner starts its
+// old container again.
+func (r *Runner) stopSandboxes(ctx context.Context) {
+	sandboxes, err := r.sandbox.List(ctx)
+	if err != nil {
+		logger.Warn("Failed to list sandboxes to stop", "error", err)
+		return
+	}
+	state, err := r.client.GetDesiredState(ctx)
+	if err != nil {
+		logger.Warn("Failed to fetch sandboxes to stop", "error", err)
+		return
+	}
+	owned := make(map[string]bool, len(state.Sandboxes))
+	for _, s := range state.Sandboxes {
+		if s.RunnerID == r.cfg.Runner.ID {
+			owned[s.ID] = true
+		}
+	}
+
+	for _, sb := range sandboxes {
+		// Other runners on the same Docker host keep theirs
+		if sb.Status != sandbox.SandboxStatusRunning || !owned[strings.TrimPrefix(sb.Name, "/")] {
+			continue
+		}
+		if err := r.sandbox.Stop(ctx, sb); err != nil {
+			logger.Warn("Failed to stop sandbox", "container_id", sb.ContainerID, "error", err)
+			continue
+		}
+		logger.Info("Stopped sandbox for rescheduling", "container_id", sb.ContainerID)
+	}
+}
+
+// RequestDrain asks the server to drain the runner whose credential is saved
+// at the configured path. The server passes the request on to the running
+// runner process.
+func RequestDrain(ctx context.Context, drain *DrainRequest) error {
+	cfg := config.LoadFromEnv()
+
+	// Enrolling here would revoke the running runner's credential
+	cred, err := credential.Load(cfg.Server.CredentialsFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := NewGrpcClient(&GrpcClientConfig{
+		ServerURL: cfg.Server.URL,
+		GRPCAddr:  cfg.Server.GRPCAddr,
+		RunnerID:  cred.RunnerID,
+		CAFile:    cfg.Server.CAFile,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	client.SetCredential(cred)
+
+	return client.RequestDrain(ctx, drain)
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/hostinfo"
+)
+
+func TestDrainCancelsJobsAtDeadline(t *testing.T) {
+	r, _, srv := newTestRunner(t)
+	r.host = hostinfo.New("/")
+	r.left = make(chan struct{})
+	r.cfg.Runner.DrainTimeout = 50 * time.Millisecond
+
+	// One job finishes once cancelled, the other gives up
+	var returned atomic.Int32
+	for _, id := range []string{"job-done", "job-stuck"} {
+		job := &Job{ID: id, Type: "stop", SandboxID: "sb-" + id}
+		r.running[id] = job
+		if err := r.jobs.Submit(id, job.SandboxID, func() {
+			<-r.jobsCtx.Done()
+			if job.ID == "job-done" {
+				r.runningMu.Lock()
+				delete(r.running, job.ID)
+				r.runningMu.Unlock()
+			}
+			returned.Add(1)
+		}); err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+	}
+
+	r.Drain(&DrainRequest{Reason: "test"})
+
+	if returned.Load() != 2 {
+		t.Errorf("expected both jobs to return before the drain finished, got %d", returned.Load())
+	}
+	var completed []string
+	leftAfter := false
+	srv.mu.Lock()
+	for _, req := range srv.requests {
+		if req.method != http.MethodPost {
+			continue
+		}
+		if strings.HasSuffix(req.path, "/complete") {
+			completed = append(completed, strings.Split(req.path, "/")[4])
+		}
+		if strings.HasSuffix(req.path, "/leave") {
+			leftAfter = len(completed) > 0
+		}
+	}
+	srv.mu.Unlock()
+	if len(completed) != 1 || completed[0] != "job-stuck" {
+		t.Errorf("expected only job-stuck to be failed, got %v", completed)
+	}
+	if !leftAfter {
+		t.Error("expected the runner to leave after failing the job")
+	}
+}
@@ -3,27 +3,45 @@ package runner
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/internal/runner/pb"
+	"github.com/codepod/codepod/sandbox/runner/pkg/credential"
+	"github.com/codepod/codepod/sandbox/runner/pkg/placement"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // Job represents a job from the server
 type Job struct {
-	ID          string            `json:"id"`
-	Type        string            `json:"type"`
-	SandboxID   string            `json:"sandboxId"`
-	Image       string            `json:"image"`
-	Token       string            `json:"token"`
-	Status      string            `json:"status"`
-	RunnerID    string            `json:"runnerId,omitempty"`
-	Env         map[string]string `json:"env,omitempty"`
-	Memory      string            `json:"memory,omitempty"`
-	CPU         int               `json:"cpu,omitempty"`
-	NetworkMode string            `json:"networkMode,omitempty"`
-	Volumes     []VolumeInfo      `json:"volumes,omitempty"`
+	ID             string                 `json:"id"`
+	Type           string                 `json:"type"`
+	SandboxID      string                 `json:"sandboxId"`
+	Image          string                 `json:"image"`
+	Token          string                 `json:"token"`
+	Status         string                 `json:"status"`
+	RunnerID       string                 `json:"runnerId,omitempty"`
+	Env            map[string]string      `json:"env,omitempty"`
+	Memory         string                 `json:"memory,omitempty"`
+	CPU            int                    `json:"cpu,omitempty"`
+	NetworkMode    string                 `json:"networkMode,omitempty"`
+	Volumes        []VolumeInfo           `json:"volumes,omitempty"`
+	TraceContext   map[string]string      `json:"traceContext,omitempty"` // W3C trace context of the request that queued the job
+	Constraints    *placement.Constraints `json:"constraints,omitempty"`  // Labels and taints of the runners the job may run on
+	SnapshotID     string                 `json:"snapshotId,omitempty"`   // Snapshot a snapshot job takes or a restore job restores
+	ExcludeVolumes bool                   `json:"excludeVolumes,omitempty"`
+	LeaveRunning   bool                   `json:"leaveRunning,omitempty"` // Keep a checkpointed sandbox running
 }
 
 // VolumeInfo represents a volume to mount
@@ -36,13 +54,27 @@ type VolumeInfo struct {
 // GrpcClientConfig holds the configuration for the gRPC client
 type GrpcClientConfig struct {
 	ServerURL string
+	GRPCAddr  string // Address of the server's job stream
 	RunnerID  string
 	Capacity  int
+	Labels    map[string]string // Sent at registration so the server can place jobs
+	Taints    map[string]string
+	CAFile    string // PEM bundle trusted for the server's certificate
 }
 
-// GrpcClient manages the connection to the server (HTTP-based)
+// GrpcClient manages the connection to the server: jobs arrive over a gRPC
+// stream, everything else goes over HTTP
 type GrpcClient struct {
 	config *GrpcClientConfig
+	conn   *grpc.ClientConn
+	http   *http.Client
+
+	mu          sync.Mutex
+	stream      pb.RunnerService_ConnectClient    // Open job stream, nil when disconnected
+	assignments map[string]chan *pb.JobAssignment // Jobs accepted over the stream awaiting the server's answer, by ID
+
+	credMu sync.RWMutex
+	cred   *credential.Credential // Sent as a bearer token with every request
 }
 
 // NewGrpcClient creates a new client connection to the server
@@ -52,14 +84,109 @@ func NewGrpcClient(config *GrpcClientConfig) (*GrpcClient, error) {
 		return nil, fmt.Errorf("server URL is required")
 	}
 
-	return &GrpcClient{
+	tlsConfig, err := serverTLSConfig(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	c := &GrpcClient{
 		config: config,
-	}, nil
+		http:   &http.Client{Transport: transport},
+	}
+
+	// The job stream uses TLS whenever the server's HTTP API does
+	streamCreds := insecure.NewCredentials()
+	if strings.HasPrefix(config.ServerURL, "https://") {
+		streamCreds = credentials.NewTLS(tlsConfig)
+	}
+	c.conn, err = grpc.NewClient(config.GRPCAddr,
+		grpc.WithTransportCredentials(streamCreds),
+		grpc.WithPerRPCCredentials(bearerCredentials{c}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job stream client: %w", err)
+	}
+
+	return c, nil
+}
+
+// serverTLSConfig returns the TLS configuration for connecting to the server,
+// trusting the system roots plus the certificates in caFile when it is set
+func serverTLSConfig(caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
+
+	bundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server CA bundle: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificates found in server CA bundle %s", caFile)
+	}
+	cfg.RootCAs = roots
+	return cfg, nil
+}
+
+// bearerCredentials sends the runner's credential with job stream calls
+type bearerCredentials struct {
+	c *GrpcClient
+}
+
+func (b bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token := b.c.token()
+	if token == "" {
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity is false so that servers without TLS still work
+func (bearerCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// SetCredential sets the credential sent with every request
+func (c *GrpcClient) SetCredential(cred *credential.Credential) {
+	c.credMu.Lock()
+	defer c.credMu.Unlock()
+	c.cred = cred
+}
+
+// Credential returns the credential sent with every request, nil before
+// the runner has one
+func (c *GrpcClient) Credential() *credential.Credential {
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+	return c.cred
+}
+
+func (c *GrpcClient) token() string {
+	if cred := c.Credential(); cred != nil {
+		return cred.Token
+	}
+	return ""
 }
 
-// Close closes the client connection (no-op for HTTP)
+// do sends req to the server with the runner's ID and credential
+func (c *GrpcClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("X-Runner-Id", c.config.RunnerID)
+	if token := c.token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+// Close closes the job stream connection
 func (c *GrpcClient) Close() error {
-	return nil
+	return c.conn.Close()
 }
 
 // GetConfig returns the client configuration
@@ -73,14 +200,22 @@ func (c *GrpcClient) Register(ctx context.Context) error {
 	url := fmt.Sprintf("%s/api/v1/runners/register", c.config.ServerURL)
 
 	// Use HTTP client
-	body := fmt.Sprintf(`{"id":"%s","capacity":%d}`, c.config.RunnerID, c.config.Capacity)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(body))
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       c.config.RunnerID,
+		"capacity": c.config.Capacity,
+		"labels":   c.config.Labels,
+		"taints":   c.config.Taints,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -90,10 +225,56 @@ func (c *GrpcClient) Register(ctx context.Context) error {
 		return fmt.Errorf("registration failed: %d", resp.StatusCode)
 	}
 
-	fmt.Printf("Runner %s registered successfully\n", c.config.RunnerID)
+	logger.Info("Runner registered", "runner_id", c.config.RunnerID)
 	return nil
 }
 
+// Enroll exchanges a join token for a runner credential
+func (c *GrpcClient) Enroll(ctx context.Context, joinToken string) (*credential.Credential, error) {
+	body, err := json.Marshal(map[string]string{
+		"runnerId":  c.config.RunnerID,
+		"joinToken": joinToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal enrollment request: %w", err)
+	}
+	return c.requestCredential(ctx, "/api/v1/runners/enroll", body)
+}
+
+// RotateCredential exchanges the current credential for a new one
+func (c *GrpcClient) RotateCredential(ctx context.Context) (*credential.Credential, error) {
+	return c.requestCredential(ctx, "/api/v1/runners/credentials/rotate", nil)
+}
+
+func (c *GrpcClient) requestCredential(ctx context.Context, path string, body []byte) (*credential.Credential, error) {
+	url := strings.TrimRight(c.config.ServerURL, "/") + path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request credential: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var cred credential.Credential
+	if err := json.NewDecoder(resp.Body).Decode(&cred); err != nil {
+		return nil, fmt.Errorf("failed to decode credential: %w", err)
+	}
+	if cred.Token == "" {
+		return nil, fmt.Errorf("server returned an empty credential")
+	}
+	return &cred, nil
+}
+
 // PollJobs polls the server for pending jobs
 func (c *GrpcClient) PollJobs(ctx context.Context) ([]Job, error) {
 	// Build URL - remove trailing slash if present
@@ -104,10 +285,9 @@ func (c *GrpcClient) PollJobs(ctx context.Context) ([]Job, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("X-Runner-Id", c.config.RunnerID)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +307,26 @@ func (c *GrpcClient) PollJobs(ctx context.Context) ([]Job, error) {
 	return result.Jobs, nil
 }
 
-// AcceptJob accepts a job for processing
+// ErrJobNotAssigned is returned by AcceptJob when the server refuses the
+// job: it went to another runner or is no longer pending. The job must not
+// be run or completed.
+var ErrJobNotAssigned = errors.New("job is not assigned to this runner")
+
+// assignmentTimeout bounds the wait for the server to answer a job accepted
+// over the job stream, after which the job is accepted over HTTP instead
+const assignmentTimeout = 10 * time.Second
+
+// AcceptJob accepts a job for processing. Over the job stream it waits for
+// the server to assign the job; without the stream, or when it closes before
+// the answer arrives, the job is accepted over HTTP.
 func (c *GrpcClient) AcceptJob(ctx context.Context, jobID string) error {
+	if assignment, ok := c.acceptOverStream(ctx, jobID); ok {
+		if !assignment.Assigned {
+			return fmt.Errorf("%w: %s", ErrJobNotAssigned, assignment.Message)
+		}
+		return nil
+	}
+
 	// Build URL - remove trailing slash if present
 	serverURL := strings.TrimRight(c.config.ServerURL, "/")
 	url := fmt.Sprintf("%s/api/v1/jobs/%s/accept", serverURL, jobID)
@@ -137,37 +335,94 @@ func (c *GrpcClient) AcceptJob(ctx context.Context, jobID string) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Set("X-Runner-Id", c.config.RunnerID)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusForbidden {
+		return ErrJobNotAssigned
+	}
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("accept failed: %d", resp.StatusCode)
 	}
 
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode accept response: %w", err)
+	}
+	if !result.Success {
+		return ErrJobNotAssigned
+	}
 	return nil
 }
 
+// acceptOverStream accepts jobID over the job stream and waits for the
+// server's answer. It reports false when the stream is not open, closes
+// before the answer arrives or the server does not answer in time.
+func (c *GrpcClient) acceptOverStream(ctx context.Context, jobID string) (*pb.JobAssignment, bool) {
+	answer := make(chan *pb.JobAssignment, 1)
+	c.mu.Lock()
+	if c.assignments == nil {
+		c.assignments = make(map[string]chan *pb.JobAssignment)
+	}
+	c.assignments[jobID] = answer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.assignments[jobID] == answer {
+			delete(c.assignments, jobID)
+		}
+		c.mu.Unlock()
+	}()
+
+	if !c.send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Ack{Ack: &pb.JobAck{JobId: jobID, Accepted: true}}}) {
+		return nil, false
+	}
+
+	timer := time.NewTimer(assignmentTimeout)
+	defer timer.Stop()
+	select {
+	case assignment, ok := <-answer:
+		return assignment, ok
+	case <-timer.C:
+		logger.Warn("Server did not answer job accept, accepting over HTTP", "job_id", jobID)
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
 // CompleteJob marks a job as completed
 func (c *GrpcClient) CompleteJob(ctx context.Context, jobID string, success bool, message string) error {
+	if c.send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Completion{Completion: &pb.JobCompletion{
+		JobId:   jobID,
+		Success: success,
+		Message: message,
+	}}}) {
+		return nil
+	}
+
 	// Build URL - remove trailing slash if present
 	serverURL := strings.TrimRight(c.config.ServerURL, "/")
 	url := fmt.Sprintf("%s/api/v1/jobs/%s/complete", serverURL, jobID)
 
-	body := fmt.Sprintf(`{"success":%v,"message":"%s"}`, success, message)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(body))
+	body, err := json.Marshal(map[string]interface{}{"success": success, "message": message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("X-Runner-Id", c.config.RunnerID)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -190,10 +445,9 @@ func (c *GrpcClient) DeleteJob(ctx context.Context, job *Job) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Set("X-Runner-Id", c.config.RunnerID)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -207,6 +461,146 @@ func (c *GrpcClient) DeleteJob(ctx context.Context, job *Job) error {
 	return fmt.Errorf("delete job failed: %d", resp.StatusCode)
 }
 
+// SendHeartbeat reports the runner's state over the job stream, or over
+// HTTP while the stream is down
+func (c *GrpcClient) SendHeartbeat(ctx context.Context, hb *Heartbeat) (*DrainRequest, error) {
+	if c.send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Heartbeat{Heartbeat: heartbeatToProto(hb)}}) {
+		return nil, nil
+	}
+
+	serverURL := strings.TrimRight(c.config.ServerURL, "/")
+	url := fmt.Sprintf("%s/api/v1/runners/%s/heartbeat", serverURL, c.config.RunnerID)
+
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	// The reply carries a drain request when the server wants the runner gone
+	var result struct {
+		Drain *DrainRequest `json:"drain"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode heartbeat response: %w", err)
+	}
+	return result.Drain, nil
+}
+
+// DrainRequest asks a runner to stop taking jobs and leave once its running
+// jobs finish
+type DrainRequest struct {
+	Reason          string `json:"reason"`
+	DeadlineSeconds int    `json:"deadlineSeconds"` // 0 uses the configured drain timeout
+	Reschedule      bool   `json:"reschedule"`      // Hand the runner's sandboxes back to the server
+}
+
+// RequestDrain asks the server to drain this runner, which it passes on to
+// the running runner process
+func (c *GrpcClient) RequestDrain(ctx context.Context, drain *DrainRequest) error {
+	url := fmt.Sprintf("%s/api/v1/runners/%s/drain", strings.TrimRight(c.config.ServerURL, "/"), c.config.RunnerID)
+	return c.post(ctx, url, drain, http.StatusAccepted)
+}
+
+// Leave tells the server the runner has drained and is shutting down. With
+// reschedule, the server places the runner's sandboxes elsewhere.
+func (c *GrpcClient) Leave(ctx context.Context, reason string, reschedule bool) error {
+	if c.send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Leaving{Leaving: &pb.Leaving{
+		Reason:     reason,
+		Reschedule: reschedule,
+	}}}) {
+		return nil
+	}
+
+	url := fmt.Sprintf("%s/api/v1/runners/%s/leave", strings.TrimRight(c.config.ServerURL, "/"), c.config.RunnerID)
+	return c.post(ctx, url, map[string]interface{}{
+		"reason":     reason,
+		"reschedule": reschedule,
+	}, http.StatusOK)
+}
+
+// post sends body as JSON to url, expecting the status want
+func (c *GrpcClient) post(ctx context.Context, url string, body interface{}, want int) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// DesiredState is the server's view of sandboxes and volumes, used to
+// reconcile the runner's containers
+type DesiredState struct {
+	Sandboxes []DesiredSandbox `json:"sandboxes"`
+	Volumes   []string         `json:"volumes"`
+}
+
+// DesiredSandbox is a sandbox as the server records it
+type DesiredSandbox struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	RunnerID string `json:"runnerId,omitempty"`
+}
+
+// GetDesiredState fetches every sandbox and volume the server knows about
+func (c *GrpcClient) GetDesiredState(ctx context.Context) (*DesiredState, error) {
+	serverURL := strings.TrimRight(c.config.ServerURL, "/")
+	url := fmt.Sprintf("%s/api/v1/runners/%s/desired-state", serverURL, c.config.RunnerID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch desired state: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var state DesiredState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("failed to decode desired state: %w", err)
+	}
+	return &state, nil
+}
+
 // SandboxStatusUpdate represents a status update request
 type SandboxStatusUpdate struct {
 	Status      string `json:"status"`
@@ -233,9 +627,8 @@ func (c *GrpcClient) UpdateSandboxStatus(ctx context.Context, sandboxID string,
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Runner-Id", c.config.RunnerID)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send status update: %w", err)
 	}
@@ -248,6 +641,66 @@ func (c *GrpcClient) UpdateSandboxStatus(ctx context.Context, sandboxID string,
 	return nil
 }
 
+// SnapshotStatusUpdate reports the outcome of a snapshot job
+type SnapshotStatusUpdate struct {
+	Status  string `json:"status"`          // ready or failed
+	Image   string `json:"image,omitempty"` // Reference restores pull
+	ImageID string `json:"imageId,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// UpdateSnapshotStatus tells the server whether a snapshot was taken
+func (c *GrpcClient) UpdateSnapshotStatus(ctx context.Context, snapshotID string, update *SnapshotStatusUpdate) error {
+	url := fmt.Sprintf("%s/api/v1/snapshots/%s/runner-status", strings.TrimRight(c.config.ServerURL, "/"), snapshotID)
+	return c.post(ctx, url, update, http.StatusOK)
+}
+
+// checkpointURL returns where the server keeps a snapshot's checkpoint archive
+func (c *GrpcClient) checkpointURL(snapshotID string) string {
+	return fmt.Sprintf("%s/api/v1/snapshots/%s/checkpoint", strings.TrimRight(c.config.ServerURL, "/"), snapshotID)
+}
+
+// UploadCheckpoint sends a snapshot's checkpoint archive to the server
+func (c *GrpcClient) UploadCheckpoint(ctx context.Context, snapshotID string, archive io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.checkpointURL(snapshotID), archive)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload checkpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// DownloadCheckpoint fetches a snapshot's checkpoint archive from the
+// server. The caller closes the returned reader.
+func (c *GrpcClient) DownloadCheckpoint(ctx context.Context, snapshotID string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.checkpointURL(snapshotID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checkpoint: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp.Body, nil
+}
+
 // GetSSHCAPublicKey fetches the SSH CA public key from the server
 func (c *GrpcClient) GetSSHCAPublicKey(ctx context.Context) (string, error) {
 	serverURL := strings.TrimRight(c.config.ServerURL, "/")
@@ -258,7 +711,7 @@ func (c *GrpcClient) GetSSHCAPublicKey(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch CA public key: %w", err)
 	}
@@ -276,3 +729,51 @@ func (c *GrpcClient) GetSSHCAPublicKey(ctx context.Context) (string, error) {
 
 	return string(body), nil
 }
+
+// TLSCertificateRequest asks the server's TLS CA for a certificate
+type TLSCertificateRequest struct {
+	CommonName  string   `json:"commonName"`
+	DNSNames    []string `json:"dnsNames,omitempty"`
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+	Usage       string   `json:"usage"` // "server" or "client"
+}
+
+// TLSCertificate is a PEM certificate and key issued by the server's TLS CA
+type TLSCertificate struct {
+	Certificate   string `json:"certificate"`
+	PrivateKey    string `json:"privateKey"`
+	CACertificate string `json:"caCertificate"`
+}
+
+// IssueTLSCertificate requests a certificate from the server's TLS CA
+func (c *GrpcClient) IssueTLSCertificate(ctx context.Context, certReq *TLSCertificateRequest) (*TLSCertificate, error) {
+	serverURL := strings.TrimRight(c.config.ServerURL, "/")
+	url := fmt.Sprintf("%s/api/v1/tls/cert", serverURL)
+
+	data, err := json.Marshal(certReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal certificate request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request certificate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var cert TLSCertificate
+	if err := json.NewDecoder(resp.Body).Decode(&cert); err != nil {
+		return nil, fmt.Errorf("failed to decode certificate: %w", err)
+	}
+	return &cert, nil
+}
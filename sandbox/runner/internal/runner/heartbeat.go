@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/hostinfo"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// heartbeatInterval is how often the runner reports its load and inventory
+const heartbeatInterval = 15 * time.Second
+
+// healthCheckTimeout bounds how long HealthCheck waits for the Docker daemon
+const healthCheckTimeout = 5 * time.Second
+
+// Heartbeat reports a runner's capacity, load and inventory to the server
+type Heartbeat struct {
+	ActiveJobs       int                 `json:"activeJobs"`
+	FreeSlots        int                 `json:"freeSlots"`
+	RunningSandboxes int                 `json:"runningSandboxes"`
+	Version          string              `json:"version"`
+	Labels           map[string]string   `json:"labels,omitempty"`
+	Resources        *hostinfo.Resources `json:"resources,omitempty"`
+	Images           []string            `json:"images,omitempty"` // Images cached on the runner
+	Healthy          bool                `json:"healthy"`
+	HealthMessage    string              `json:"healthMessage,omitempty"`
+	Draining         bool                `json:"draining"` // Taking no new jobs before leaving
+}
+
+// sendHeartbeat reports the runner's current state to the server
+func (r *Runner) sendHeartbeat() {
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+
+	drain, err := r.client.SendHeartbeat(ctx, r.heartbeat(ctx))
+	if err != nil {
+		logger.Warn("Failed to send heartbeat", "error", err)
+		return
+	}
+	if drain != nil {
+		go r.Shutdown(drain)
+	}
+}
+
+// heartbeat collects the runner's state. Sandbox and image inventory is
+// left out while the Docker daemon is unreachable.
+func (r *Runner) heartbeat(ctx context.Context) *Heartbeat {
+	hb := &Heartbeat{
+		ActiveJobs: r.jobs.Active(),
+		FreeSlots:  r.jobs.Available(),
+		Version:    r.version,
+		Labels:     r.cfg.Runner.Labels,
+		Draining:   r.draining.Load(),
+	}
+
+	resources, err := r.host.Read()
+	if err != nil {
+		logger.Debug("Failed to read host resources", "error", err)
+	} else {
+		hb.Resources = resources
+	}
+
+	if err := r.HealthCheck(); err != nil {
+		hb.HealthMessage = err.Error()
+		return hb
+	}
+	hb.Healthy = true
+
+	if counts, err := r.sandbox.CountByState(ctx); err != nil {
+		logger.Debug("Failed to count sandboxes", "error", err)
+	} else {
+		hb.RunningSandboxes = counts[string(sandbox.SandboxStatusRunning)]
+	}
+	if images, err := r.docker.ListImages(ctx); err != nil {
+		logger.Debug("Failed to list images", "error", err)
+	} else {
+		hb.Images = images
+	}
+	return hb
+}
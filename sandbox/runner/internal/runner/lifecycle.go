@@ -0,0 +1,193 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// handleStopJob stops a sandbox's container, keeping it and its volumes so
+// a start job can bring the sandbox back
+func (r *Runner) handleStopJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "stopping",
+		ContainerID: sb.ContainerID,
+		Message:     "Stopping container",
+	})
+	if err := r.sandbox.Stop(ctx, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to stop sandbox", err)
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "stopped",
+		ContainerID: sb.ContainerID,
+		Message:     "Sandbox stopped",
+	})
+	return r.completeLifecycleJob(ctx, job, "Sandbox stopped")
+}
+
+// handleStartJob starts a stopped sandbox's container and waits for its agent
+func (r *Runner) handleStartJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "starting",
+		ContainerID: sb.ContainerID,
+		Message:     "Starting container",
+	})
+	if err := r.sandbox.Start(ctx, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to start sandbox", err)
+	}
+	return r.finishStart(ctx, job, sb, "Sandbox started")
+}
+
+// handleRestartJob stops a sandbox's container and starts it again
+func (r *Runner) handleRestartJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "starting",
+		ContainerID: sb.ContainerID,
+		Message:     "Restarting container",
+	})
+	if err := r.sandbox.Restart(ctx, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to restart sandbox", err)
+	}
+	return r.finishStart(ctx, job, sb, "Sandbox restarted")
+}
+
+// handlePauseJob freezes a sandbox's processes, keeping their memory, so the
+// sandbox uses no CPU until it is unpaused
+func (r *Runner) handlePauseJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if err := r.sandbox.Pause(ctx, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to pause sandbox", err)
+	}
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "paused",
+		ContainerID: sb.ContainerID,
+		Message:     "Sandbox paused",
+	})
+	return r.completeLifecycleJob(ctx, job, "Sandbox paused")
+}
+
+// handleUnpauseJob resumes a paused sandbox's processes
+func (r *Runner) handleUnpauseJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if err := r.sandbox.Unpause(ctx, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "failed to unpause sandbox", err)
+	}
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "running",
+		ContainerID: sb.ContainerID,
+		Port:        sb.Port,
+		Host:        r.getHost(),
+		Message:     "Sandbox unpaused",
+	})
+	return r.completeLifecycleJob(ctx, job, "Sandbox unpaused")
+}
+
+// handleResizeJob changes a sandbox's memory and CPU limits without
+// restarting it. The sandbox keeps its status.
+func (r *Runner) handleResizeJob(ctx context.Context, job *Job) error {
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if err := r.sandbox.Resize(ctx, sb, job.Memory, job.CPU); err != nil {
+		err = fmt.Errorf("failed to resize sandbox: %w", err)
+		jobLogger(job).Error("Lifecycle job failed", "error", err)
+		r.client.CompleteJob(ctx, job.ID, false, err.Error())
+		return err
+	}
+
+	message := fmt.Sprintf("Sandbox resized (memory %q, cpu %d)", job.Memory, job.CPU)
+	jobLogger(job).Info(message)
+	return r.completeLifecycleJob(ctx, job, message)
+}
+
+// sandboxForJob finds the container of a lifecycle job's sandbox, failing
+// the job when the runner has none
+func (r *Runner) sandboxForJob(ctx context.Context, job *Job) (*sandbox.Sandbox, error) {
+	sb, err := r.sandbox.GetByName(ctx, job.SandboxID)
+	if err != nil {
+		err = fmt.Errorf("sandbox container not found: %w", err)
+		jobLogger(job).Error("Lifecycle job failed", "error", err)
+		r.client.CompleteJob(ctx, job.ID, false, err.Error())
+		return nil, err
+	}
+	sb.NetworkMode = r.cfg.Docker.Network
+	return sb, nil
+}
+
+// finishStart waits for the agent of a started sandbox and reports it running
+func (r *Runner) finishStart(ctx context.Context, job *Job, sb *sandbox.Sandbox, message string) error {
+	if err := r.waitForAgent(ctx, job.SandboxID, sb); err != nil {
+		return r.failLifecycleJob(ctx, job, sb, "agent did not become ready", err)
+	}
+
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      "running",
+		ContainerID: sb.ContainerID,
+		Port:        sb.Port,
+		Host:        r.getHost(),
+		Message:     message,
+	})
+	return r.completeLifecycleJob(ctx, job, message)
+}
+
+// failLifecycleJob fails a lifecycle job, reporting the state the sandbox's
+// container was left in
+func (r *Runner) failLifecycleJob(ctx context.Context, job *Job, sb *sandbox.Sandbox, msg string, err error) error {
+	err = fmt.Errorf("%s: %w", msg, err)
+	jobLogger(job).Error("Lifecycle job failed", "error", err)
+
+	status, statusErr := r.sandbox.GetStatus(ctx, sb)
+	if statusErr != nil || status == sandbox.SandboxStatusPending {
+		status = sandbox.SandboxStatusFailed
+	}
+	r.reportStatus(ctx, job, &SandboxStatusUpdate{
+		Status:      string(status),
+		ContainerID: sb.ContainerID,
+		Message:     err.Error(),
+	})
+	r.client.CompleteJob(ctx, job.ID, false, err.Error())
+	return err
+}
+
+// completeLifecycleJob reports a lifecycle job as done
+func (r *Runner) completeLifecycleJob(ctx context.Context, job *Job, message string) error {
+	if err := r.client.CompleteJob(ctx, job.ID, true, message); err != nil {
+		jobLogger(job).Warn("Failed to complete job", "error", err)
+	}
+	return nil
+}
+
+// reportStatus reports a sandbox status change, logging rather than failing
+// the job when the server cannot be reached
+func (r *Runner) reportStatus(ctx context.Context, job *Job, update *SandboxStatusUpdate) {
+	if err := r.client.UpdateSandboxStatus(ctx, job.SandboxID, update); err != nil {
+		jobLogger(job).Warn("Failed to report sandbox status", "status", update.Status, "error", err)
+	}
+}
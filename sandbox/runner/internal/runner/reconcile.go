@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/metrics"
+	"github.com/codepod/codepod/sandbox/runner/pkg/reconcile"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// reconcileLoop reconciles once at startup, so containers that changed while
+// the runner was down are noticed, and then every ReconcileInterval until
+// the runner stops
+func (r *Runner) reconcileLoop() {
+	interval := r.cfg.Runner.ReconcileInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := r.reconcile(ctx); err != nil {
+			logger.Warn("Failed to reconcile sandboxes", "error", err)
+		}
+		cancel()
+
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile reports the actual state of containers that differ from the
+// server's view, and removes containers and volumes the server no longer
+// knows about
+func (r *Runner) reconcile(ctx context.Context) error {
+	// List containers before fetching the server's state, so a container
+	// created in between is never mistaken for an orphan
+	sandboxes, err := r.sandbox.List(ctx)
+	if err != nil {
+		return err
+	}
+	volumes, err := r.docker.ListVolumes(ctx)
+	if err != nil {
+		return err
+	}
+	state, err := r.client.GetDesiredState(ctx)
+	if err != nil {
+		return err
+	}
+
+	desired := make([]reconcile.Desired, len(state.Sandboxes))
+	for i, s := range state.Sandboxes {
+		desired[i] = reconcile.Desired{ID: s.ID, Status: s.Status, RunnerID: s.RunnerID}
+	}
+	byID := make(map[string]*sandbox.Sandbox, len(sandboxes))
+	containers := make([]reconcile.Container, len(sandboxes))
+	for i, sb := range sandboxes {
+		id := strings.TrimPrefix(sb.Name, "/")
+		byID[id] = sb
+		containers[i] = reconcile.Container{SandboxID: id, ContainerID: sb.ContainerID, State: string(sb.Status), Port: sb.Port}
+	}
+
+	for _, action := range reconcile.Plan(r.cfg.Runner.ID, desired, containers, r.jobs.Busy) {
+		log := logger.With("sandbox_id", action.SandboxID, "action", action.Kind)
+		if err := r.applyAction(ctx, action, byID[action.SandboxID]); err != nil {
+			log.Warn("Failed to reconcile sandbox", "error", err)
+			continue
+		}
+		log.Info("Reconciled sandbox")
+		metrics.ReconcileActions.WithLabelValues(string(action.Kind)).Inc()
+	}
+
+	for _, name := range reconcile.OrphanVolumes(state.Volumes, volumes) {
+		// Docker refuses to remove a volume while a container uses it
+		if err := r.docker.RemoveVolume(ctx, name); err != nil {
+			logger.Warn("Failed to remove orphaned volume", "volume", name, "error", err)
+			continue
+		}
+		logger.Info("Removed orphaned volume", "volume", name)
+		metrics.ReconcileActions.WithLabelValues("remove_volume").Inc()
+	}
+	return nil
+}
+
+// applyAction carries out one step of a reconciliation plan
+func (r *Runner) applyAction(ctx context.Context, action reconcile.Action, sb *sandbox.Sandbox) error {
+	switch action.Kind {
+	case reconcile.ReportStopped:
+		details, err := r.docker.InspectContainer(ctx, action.Container.ContainerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		return r.client.UpdateSandboxStatus(ctx, action.SandboxID, exitStatus(details))
+	case reconcile.ReportMissing:
+		return r.client.UpdateSandboxStatus(ctx, action.SandboxID, &SandboxStatusUpdate{
+			Status:  "failed",
+			Message: "Container not found on runner",
+		})
+	case reconcile.ReportRunning:
+		port := action.Container.Port
+		if r.cfg.Docker.Network == "host" {
+			port = 2222
+		}
+		return r.client.UpdateSandboxStatus(ctx, action.SandboxID, &SandboxStatusUpdate{
+			Status:      "running",
+			ContainerID: action.Container.ContainerID,
+			Port:        port,
+			Host:        r.getHost(),
+			Message:     "Container is running",
+		})
+	case reconcile.RemoveOrphan:
+		return r.sandbox.Delete(ctx, sb)
+	default:
+		return fmt.Errorf("unknown action: %s", action.Kind)
+	}
+}
+
+// exitStatus describes why a container stopped. A clean exit leaves the
+// sandbox stopped; a crash or OOM kill marks it failed.
+func exitStatus(details *docker.ContainerDetails) *SandboxStatusUpdate {
+	update := &SandboxStatusUpdate{
+		Status:      "failed",
+		ContainerID: details.ID,
+	}
+	switch {
+	case details.OOMKilled:
+		update.Message = "Container was killed for running out of memory"
+	case details.ExitCode != 0:
+		update.Message = fmt.Sprintf("Container exited with code %d", details.ExitCode)
+		if details.Error != "" {
+			update.Message += ": " + details.Error
+		}
+	default:
+		update.Status = "stopped"
+		update.Message = "Container exited with code 0"
+	}
+	return update
+}
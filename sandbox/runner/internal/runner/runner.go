@@ -2,44 +2,107 @@ package runner
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/codepod/codepod/libs/go-common/backoff"
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/codepod/codepod/libs/go-common/tracing"
 	"github.com/codepod/codepod/sandbox/runner/pkg/config"
 	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/hostinfo"
+	"github.com/codepod/codepod/sandbox/runner/pkg/metrics"
+	"github.com/codepod/codepod/sandbox/runner/pkg/placement"
 	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+	"github.com/codepod/codepod/sandbox/runner/pkg/workpool"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+var logger = logging.Component("runner")
+
+var tracer = otel.Tracer("github.com/codepod/codepod/sandbox/runner/internal/runner")
+
 type Runner struct {
+	version  string
 	cfg      *config.Config
 	docker   docker.Client
 	sandbox  *sandbox.Manager
 	client   *GrpcClient
+	jobs     *workpool.Pool   // Runs up to MaxJobs jobs, one at a time per sandbox
+	host     *hostinfo.Reader // Host resources reported in heartbeats
 	stopChan chan struct{}
+	stopped  chan struct{} // Closed once Stop has finished
+	stopOnce sync.Once
+
+	draining  atomic.Bool // Set once Drain starts; no new jobs are taken
+	drainOnce sync.Once
+	left      chan struct{} // Closed once the drained runner told the server it is leaving
+
+	runningMu sync.Mutex
+	running   map[string]*Job // Accepted jobs that are still running, by ID
+
+	jobsCtx    context.Context // Jobs run on it; a drain cancels it at its deadline
+	cancelJobs context.CancelFunc
+
+	shutdownTracing func(context.Context) error // Flushes pending spans
+
+	tlsMu    sync.Mutex
+	agentTLS *tls.Config // Client TLS configuration for dialing agents
 }
 
-func New() (*Runner, error) {
+// New creates a runner from the environment; version is reported in traces
+func New(version string) (*Runner, error) {
 	cfg := config.LoadFromEnv()
 
-	// Create Docker client
-	dockerClient, err := docker.NewClient(cfg.Docker.Host)
+	if err := logging.Setup(os.Stderr, cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		return nil, fmt.Errorf("failed to configure logging: %w", err)
+	}
+	slog.SetDefault(slog.Default().With("runner_id", cfg.Runner.ID))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+		Insecure: cfg.Tracing.Insecure,
+		File:     cfg.Tracing.File,
+	}, "codepod-runner", version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tracing: %w", err)
+	}
+
+	// Create Docker client, tracing its calls as part of job traces
+	client, err := docker.NewClient(cfg.Docker.Host)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
+	dockerClient := docker.NewTracedClient(client)
 
 	manager := sandbox.NewManager(dockerClient)
 
-	log.Printf("Runner configured with server: %s", cfg.Server.URL)
+	logger.Info("Runner configured", "server_url", cfg.Server.URL, "server_grpc_addr", cfg.Server.GRPCAddr)
 
 	// Create gRPC client
 	grpcConfig := &GrpcClientConfig{
 		ServerURL: cfg.Server.URL,
+		GRPCAddr:  cfg.Server.GRPCAddr,
 		RunnerID:  cfg.Runner.ID,
 		Capacity:  cfg.Runner.MaxJobs,
+		Labels:    cfg.Runner.Labels,
+		Taints:    cfg.Runner.Taints,
+		CAFile:    cfg.Server.CAFile,
 	}
 
 	grpcClient, err := NewGrpcClient(grpcConfig)
@@ -47,51 +110,129 @@ func New() (*Runner, error) {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
 	return &Runner{
+		version:  version,
 		cfg:      cfg,
 		docker:   dockerClient,
 		sandbox:  manager,
 		client:   grpcClient,
+		jobs:     workpool.New(cfg.Runner.MaxJobs),
+		host:     hostinfo.New("/"),
 		stopChan: make(chan struct{}),
+		stopped:  make(chan struct{}),
+		left:     make(chan struct{}),
+		running:  make(map[string]*Job),
+
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+
+		shutdownTracing: shutdownTracing,
 	}, nil
 }
 
 func (r *Runner) Run() {
-	log.Println("Runner is running...")
+	logger.Info("Runner is running")
+
+	// Get a credential for talking to the server, enrolling on first start
+	if !r.authenticate() {
+		logger.Info("Runner shutting down")
+		<-r.stopped
+		return
+	}
+	go r.credentialLoop()
 
 	// Register with server
 	if err := r.client.Register(context.Background()); err != nil {
-		log.Printf("Warning: Failed to register with server: %v", err)
+		logger.Warn("Failed to register with server", "error", err)
+	}
+
+	// Serve Prometheus metrics if a listen address is configured
+	if r.cfg.Metrics.Addr != "" {
+		go r.serveMetrics()
 	}
 
 	// Start job processing in a separate goroutine
-	go r.processJobs(context.Background())
+	go r.processJobs(r.jobsCtx)
+
+	// Compare containers with the server's state
+	if r.cfg.Runner.ReconcileInterval > 0 {
+		go r.reconcileLoop()
+	}
 
-	// Keep alive ticker
-	ticker := time.NewTicker(30 * time.Second)
+	// Report load and inventory to the server
+	r.sendHeartbeat()
+	ticker := time.NewTicker(heartbeatInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			log.Printf("Runner is healthy, waiting for jobs...")
+			r.sendHeartbeat()
 		case <-r.stopChan:
-			log.Println("Runner shutting down...")
+			logger.Info("Runner shutting down")
+			<-r.stopped
 			return
 		}
 	}
 }
 
+// Stop stops the runner without waiting for running jobs; Shutdown drains
+// it first. Run returns once Stop has finished.
 func (r *Runner) Stop() {
-	log.Println("Stopping runner...")
+	r.stopOnce.Do(r.stop)
+}
+
+func (r *Runner) stop() {
+	defer close(r.stopped)
+	logger.Info("Stopping runner")
+	close(r.stopChan)
+
 	if r.client != nil {
 		r.client.Close()
 	}
-	close(r.stopChan)
+
+	if r.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.shutdownTracing(ctx); err != nil {
+			logger.Warn("Failed to flush traces", "error", err)
+		}
+	}
+}
+
+// serveMetrics serves Prometheus metrics until the runner stops
+func (r *Runner) serveMetrics() {
+	if err := metrics.RegisterSandboxStates(r.sandbox.CountByState); err != nil {
+		logger.Warn("Failed to register sandbox state metrics", "error", err)
+	}
+	if err := metrics.RegisterActiveJobs(r.jobs.Active); err != nil {
+		logger.Warn("Failed to register active job metrics", "error", err)
+	}
+
+	lis, err := net.Listen("tcp", r.cfg.Metrics.Addr)
+	if err != nil {
+		logger.Error("Failed to start metrics listener", "addr", r.cfg.Metrics.Addr, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-r.stopChan
+		cancel()
+	}()
+	if err := metrics.Serve(ctx, lis); err != nil {
+		logger.Error("Metrics server error", "error", err)
+	}
 }
 
+// HealthCheck verifies that the Docker daemon is reachable
 func (r *Runner) HealthCheck() error {
-	// TODO: Implement health check
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	if err := r.docker.Ping(ctx); err != nil {
+		return fmt.Errorf("docker daemon is unreachable: %w", err)
+	}
 	return nil
 }
 
@@ -124,23 +265,90 @@ func (r *Runner) getHost() string {
 	return "localhost"
 }
 
-// processJobs polls for jobs and processes them
+// processJobs receives jobs pushed over the server's job stream and runs
+// them on the job pool with ctx, reconnecting with backoff when the stream
+// drops. Servers without the job stream are polled instead.
 func (r *Runner) processJobs(ctx context.Context) {
+	// The stream outlives ctx, so a drain that cancels its jobs can still
+	// leave over it, and stopping the stream does not abort the jobs
+	streamCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stopChan:
+			cancel()
+		case <-streamCtx.Done():
+		}
+	}()
+
+	b := backoff.New(time.Second, 30*time.Second)
+	handler := JobHandler{
+		Connected: func() {
+			b.Reset()
+			logger.Info("Connected to job stream", "addr", r.cfg.Server.GRPCAddr)
+			// Report capacity and load to the server right away
+			go r.sendHeartbeat()
+		},
+		Submit: func(job *Job) error {
+			return r.submitJob(ctx, job)
+		},
+		Drain: func(drain *DrainRequest) {
+			go r.Shutdown(drain)
+		},
+	}
+
+	for {
+		err := r.client.StreamJobs(streamCtx, handler)
+		if streamCtx.Err() != nil {
+			logger.Info("Job stream stopped")
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			logger.Warn("Server has no job stream, polling for jobs instead")
+			r.pollJobs(ctx)
+			return
+		}
+
+		delay := b.Next()
+		logger.Warn("Job stream disconnected, reconnecting", "error", err, "retry_in", delay)
+		select {
+		case <-streamCtx.Done():
+			logger.Info("Job stream stopped")
+			return
+		case <-r.left:
+			logger.Info("Job stream closed after the runner left")
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// pollJobs polls for jobs every 5 seconds and runs them on the job pool.
+// Polling pauses while every slot is taken, leaving new jobs to other runners.
+func (r *Runner) pollJobs(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Job polling stopped (context cancelled)")
+			logger.Info("Job polling stopped (context cancelled)")
 			return
 		case <-r.stopChan:
-			log.Println("Job polling stopped (runner stopping)")
+			logger.Info("Job polling stopped (runner stopping)")
 			return
 		case <-ticker.C:
+			if r.draining.Load() {
+				continue
+			}
+			if r.jobs.Available() == 0 {
+				logger.Debug("All job slots busy, skipping poll", "max_jobs", r.cfg.Runner.MaxJobs)
+				continue
+			}
+
 			jobs, err := r.client.PollJobs(ctx)
 			if err != nil {
-				log.Printf("Failed to poll jobs: %v", err)
+				logger.Error("Failed to poll jobs", "error", err)
 				continue
 			}
 
@@ -148,28 +356,98 @@ func (r *Runner) processJobs(ctx context.Context) {
 				continue
 			}
 
-			log.Printf("Received %d jobs", len(jobs))
+			logger.Info("Received jobs", "count", len(jobs))
 
-			for _, job := range jobs {
-				if err := r.handleJob(ctx, &job); err != nil {
-					log.Printf("Failed to handle job %s: %v", job.ID, err)
-				}
+			for i := range jobs {
+				r.submitJob(ctx, &jobs[i])
 			}
 		}
 	}
 }
 
+// submitJob queues job on the job pool. Jobs for the same sandbox run one
+// at a time in the order they arrived, so a create and a delete never race.
+// Jobs that place a sandbox whose constraints this runner cannot satisfy are
+// declined; later jobs follow the sandbox to wherever it was placed. It
+// returns why the job was not queued, leaving it pending for another runner
+// or a later poll. A draining runner takes no jobs.
+func (r *Runner) submitJob(ctx context.Context, job *Job) error {
+	if r.draining.Load() {
+		jobLogger(job).Debug("Declining job while draining")
+		return errors.New("runner is draining")
+	}
+	if placesSandbox(job.Type) {
+		if err := placement.Check(job.Constraints, r.cfg.Runner.Labels, r.cfg.Runner.Taints); err != nil {
+			jobLogger(job).Debug("Declining job", "reason", err)
+			return fmt.Errorf("job constraints not satisfied: %w", err)
+		}
+	}
+
+	err := r.jobs.Submit(job.ID, job.SandboxID, func() {
+		if err := r.handleJob(ctx, job); err != nil {
+			metrics.JobsFailed.WithLabelValues(job.Type).Inc()
+			jobLogger(job).Error("Failed to handle job", "error", err)
+		}
+	})
+	switch {
+	case errors.Is(err, workpool.ErrDuplicate):
+		// Still waiting behind an earlier job for the same sandbox
+		return nil
+	case errors.Is(err, workpool.ErrFull):
+		jobLogger(job).Debug("All job slots busy, leaving job pending")
+		return errors.New("runner is at capacity")
+	case err != nil:
+		jobLogger(job).Error("Failed to queue job", "error", err)
+		return err
+	}
+	metrics.JobsPolled.WithLabelValues(job.Type).Inc()
+	return nil
+}
+
+// jobLogger returns a logger tagged with the job and sandbox IDs
+func jobLogger(job *Job) *slog.Logger {
+	return logger.With("job_id", job.ID, "job_type", job.Type, "sandbox_id", job.SandboxID)
+}
+
 // handleJob processes a single job
-func (r *Runner) handleJob(ctx context.Context, job *Job) error {
-	log.Printf("Processing job %s (type: %s, sandbox: %s)", job.ID, job.Type, job.SandboxID)
+func (r *Runner) handleJob(ctx context.Context, job *Job) (err error) {
+	// Continue the trace of the request that queued the job
+	ctx, span := tracer.Start(tracing.Extract(ctx, job.TraceContext), "runner.job", trace.WithAttributes(
+		attribute.String("job.id", job.ID),
+		attribute.String("job.type", job.Type),
+		attribute.String("sandbox.id", job.SandboxID),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	log := jobLogger(job)
+	log.Info("Processing job")
 
 	// Accept the job
 	if err := r.client.AcceptJob(ctx, job.ID); err != nil {
-		log.Printf("Failed to accept job %s: %v", job.ID, err)
+		if errors.Is(err, ErrJobNotAssigned) {
+			// Another runner has the job, which it alone may complete
+			log.Info("Dropping job the server did not assign to this runner", "reason", err)
+			return nil
+		}
+		log.Error("Failed to accept job", "error", err)
 		// Try to complete with failure
 		r.client.CompleteJob(ctx, job.ID, false, fmt.Sprintf("Failed to accept: %v", err))
 		return err
 	}
+	metrics.JobsAccepted.WithLabelValues(job.Type).Inc()
+
+	// Tracked so a drain that runs out of time can report the job failed.
+	// A job the drain cancelled stays tracked unless it finished anyway.
+	r.runningMu.Lock()
+	r.running[job.ID] = job
+	r.runningMu.Unlock()
+	defer func() {
+		r.runningMu.Lock()
+		if err == nil || ctx.Err() == nil {
+			delete(r.running, job.ID)
+		}
+		r.runningMu.Unlock()
+	}()
 
 	// Execute based on job type
 	switch job.Type {
@@ -177,27 +455,60 @@ func (r *Runner) handleJob(ctx context.Context, job *Job) error {
 		return r.handleCreateJob(ctx, job)
 	case "delete":
 		return r.handleDeleteJob(ctx, job)
+	case "stop":
+		return r.handleStopJob(ctx, job)
+	case "start":
+		return r.handleStartJob(ctx, job)
+	case "restart":
+		return r.handleRestartJob(ctx, job)
+	case "pause":
+		return r.handlePauseJob(ctx, job)
+	case "unpause":
+		return r.handleUnpauseJob(ctx, job)
+	case "resize":
+		return r.handleResizeJob(ctx, job)
+	case "snapshot":
+		return r.handleSnapshotJob(ctx, job)
+	case "restore":
+		return r.handleRestoreJob(ctx, job)
+	case "checkpoint":
+		return r.handleCheckpointJob(ctx, job)
+	case "restore-checkpoint":
+		return r.handleRestoreCheckpointJob(ctx, job)
 	default:
 		err := fmt.Errorf("unknown job type: %s", job.Type)
-		log.Printf("Job %s: %v", job.ID, err)
+		log.Error("Rejecting job", "error", err)
 		r.client.CompleteJob(ctx, job.ID, false, err.Error())
 		return err
 	}
 }
 
+// placesSandbox reports whether jobs of a type create their sandbox's
+// container, and so run only where their constraints are satisfied
+func placesSandbox(jobType string) bool {
+	return jobType == "create" || jobType == "restore" || jobType == "restore-checkpoint"
+}
+
+// mountsDockerSocket reports whether sandboxes of an image get the Docker
+// socket: builder images need it for docker build/push
+func mountsDockerSocket(image string) bool {
+	return strings.Contains(image, "/builder") || strings.Contains(image, "/workspace/")
+}
+
 // handleCreateJob handles a create sandbox job
 func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
-	log.Printf("Creating sandbox %s with image %s", job.SandboxID, job.Image)
+	log := jobLogger(job)
+	log.Info("Creating sandbox", "image", job.Image)
 
 	// Check if sandbox already exists
 	existingSandbox, err := r.sandbox.GetByName(ctx, job.SandboxID)
 	if err == nil && existingSandbox != nil {
-		log.Printf("Sandbox %s already exists (container: %s), checking status...", job.SandboxID, existingSandbox.ContainerID)
+		log.Info("Sandbox already exists, checking status", "container_id", existingSandbox.ContainerID)
 
 		// Check if it's already running
 		status, err := r.sandbox.GetStatus(ctx, existingSandbox)
 		if err == nil && status == sandbox.SandboxStatusRunning {
-			log.Printf("Sandbox %s is already running", job.SandboxID)
+			log.Info("Sandbox is already running")
 
 			// Report status: running
 			if err := r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
@@ -207,7 +518,7 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 				Host:        r.getHost(),
 				Message:     "Sandbox already running",
 			}); err != nil {
-				log.Printf("Warning: failed to report running status: %v", err)
+				log.Warn("Failed to report running status", "error", err)
 			}
 
 			// Complete the job successfully
@@ -216,9 +527,12 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		}
 
 		// Sandbox exists but not running, try to start it
-		log.Printf("Sandbox %s exists but not running, starting...", job.SandboxID)
+		log.Info("Sandbox exists but is not running, starting")
 		if err := r.sandbox.Start(ctx, existingSandbox); err != nil {
-			log.Printf("Failed to start existing sandbox %s: %v", job.SandboxID, err)
+			log.Warn("Failed to start existing sandbox", "error", err)
+			// Continue to recreate
+		} else if err := r.waitForAgent(ctx, job.SandboxID, existingSandbox); err != nil {
+			log.Warn("Agent in existing sandbox did not become ready", "error", err)
 			// Continue to recreate
 		} else {
 			// Report status: running
@@ -229,16 +543,16 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 				Host:        r.getHost(),
 				Message:     "Sandbox started",
 			}); err != nil {
-				log.Printf("Warning: failed to report running status: %v", err)
+				log.Warn("Failed to report running status", "error", err)
 			}
 			r.client.CompleteJob(ctx, job.ID, true, "Sandbox started")
 			return nil
 		}
 
 		// Delete the existing sandbox and recreate
-		log.Printf("Deleting existing sandbox %s for recreation", job.SandboxID)
+		log.Info("Deleting existing sandbox for recreation")
 		if err := r.sandbox.Delete(ctx, existingSandbox); err != nil {
-			log.Printf("Warning: failed to delete existing sandbox: %v", err)
+			log.Warn("Failed to delete existing sandbox", "error", err)
 		}
 	}
 
@@ -247,7 +561,7 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		Status:  "creating",
 		Message: "Creating container",
 	}); err != nil {
-		log.Printf("Warning: failed to report creating status: %v", err)
+		log.Warn("Failed to report creating status", "error", err)
 	}
 
 	// Use token from job if provided, otherwise generate one
@@ -259,15 +573,15 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 	// Fetch SSH CA public key from server for certificate authentication
 	caPublicKey, err := r.client.GetSSHCAPublicKey(ctx)
 	if err != nil {
-		log.Printf("Warning: failed to fetch SSH CA public key: %v", err)
+		log.Warn("Failed to fetch SSH CA public key", "error", err)
 		// Continue without CA key - will fall back to token auth
 	}
 
 	// Build environment variables
 	env := map[string]string{
-		"AGENT_TOKEN":       agentToken,
+		"AGENT_TOKEN":      agentToken,
 		"AGENT_SANDBOX_ID": job.SandboxID,
-		"AGENT_SERVER_URL":  r.cfg.Server.URL,
+		"AGENT_SERVER_URL": r.cfg.Server.URL,
 		"AGENT_SSH_PORT":   "2222", // Unified port for SSH and gRPC
 	}
 
@@ -276,43 +590,53 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		env["AGENT_TRUSTED_USER_CA_KEYS"] = base64.StdEncoding.EncodeToString([]byte(caPublicKey))
 	}
 
+	// Serve agent gRPC over TLS with a certificate from the server's CA
+	var agentConfig []byte
+	if r.cfg.Agent.TLS {
+		agentConfig, err = r.agentTLSConfig(ctx, job.SandboxID)
+		if err != nil {
+			log.Error("Failed to prepare agent TLS", "error", err)
+			r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
+				Status:  "failed",
+				Message: err.Error(),
+			})
+			r.client.CompleteJob(ctx, job.ID, false, err.Error())
+			return err
+		}
+	}
+
+	// Let the agent continue the job's trace at startup
+	for k, v := range tracing.Env(ctx) {
+		env[k] = v
+	}
+
 	// Merge job-specific environment variables
 	for k, v := range job.Env {
 		env[k] = v
 	}
 
 	// Prepare create options
-	// Mount Docker socket for builder images (needed for docker build/push)
-	mountDockerSocket := strings.Contains(job.Image, "/builder") || strings.Contains(job.Image, "/workspace/")
-
-	// Convert job volumes to sandbox volumes
-	volumes := make([]sandbox.VolumeInfo, len(job.Volumes))
-	for i, v := range job.Volumes {
-		volumes[i] = sandbox.VolumeInfo{
-			VolumeID:  v.VolumeID,
-			MountPath: v.MountPath,
-			ReadOnly:  v.ReadOnly,
-		}
-	}
+	mountDockerSocket := mountsDockerSocket(job.Image)
 
 	opts := &sandbox.CreateOptions{
-		Name:             job.SandboxID,
-		Image:            job.Image,
-		Env:              env,
-		Memory:           job.Memory,
-		CPU:              job.CPU,
-		NetworkMode:      r.cfg.Docker.Network,
-		AgentBinaryPath:  r.cfg.Agent.BinaryPath,
-		AgentToken:       agentToken,
-		AgentServerURL:   r.cfg.Server.URL,
+		Name:              job.SandboxID,
+		Image:             job.Image,
+		Env:               env,
+		Memory:            job.Memory,
+		CPU:               job.CPU,
+		NetworkMode:       r.cfg.Docker.Network,
+		AgentBinaryPath:   r.cfg.Agent.BinaryPath,
+		AgentToken:        agentToken,
+		AgentServerURL:    r.cfg.Server.URL,
 		MountDockerSocket: mountDockerSocket,
-		Volumes:          volumes,
+		Volumes:           sandboxVolumes(job.Volumes),
+		AgentConfig:       agentConfig,
 	}
 
 	// Create sandbox
 	sb, err := r.sandbox.Create(ctx, opts)
 	if err != nil {
-		log.Printf("Failed to create sandbox %s: %v", job.SandboxID, err)
+		log.Error("Failed to create sandbox", "error", err)
 		r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
 			Status:  "failed",
 			Message: err.Error(),
@@ -327,12 +651,12 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		ContainerID: sb.ContainerID,
 		Message:     "Starting container",
 	}); err != nil {
-		log.Printf("Warning: failed to report starting status: %v", err)
+		log.Warn("Failed to report starting status", "error", err)
 	}
 
 	// Start sandbox
 	if err := r.sandbox.Start(ctx, sb); err != nil {
-		log.Printf("Failed to start sandbox %s: %v", job.SandboxID, err)
+		log.Error("Failed to start sandbox", "error", err)
 		r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
 			Status:  "failed",
 			Message: err.Error(),
@@ -341,6 +665,18 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		return err
 	}
 
+	// Wait for the agent to report healthy before declaring the sandbox running
+	if err := r.waitForAgent(ctx, job.SandboxID, sb); err != nil {
+		log.Error("Agent did not become ready", "error", err)
+		r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
+			Status:      "failed",
+			ContainerID: sb.ContainerID,
+			Message:     fmt.Sprintf("Agent did not become ready: %v", err),
+		})
+		r.client.CompleteJob(ctx, job.ID, false, fmt.Sprintf("Agent did not become ready: %v", err))
+		return err
+	}
+
 	// Report status: running
 	if err := r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
 		Status:      "running",
@@ -349,28 +685,29 @@ func (r *Runner) handleCreateJob(ctx context.Context, job *Job) error {
 		Host:        r.getHost(),
 		Message:     "Sandbox running",
 	}); err != nil {
-		log.Printf("Warning: failed to report running status: %v", err)
+		log.Warn("Failed to report running status", "error", err)
 	}
 
 	// Complete the job successfully
 	if err := r.client.CompleteJob(ctx, job.ID, true, "Sandbox created and started"); err != nil {
-		log.Printf("Warning: failed to complete job: %v", err)
+		log.Warn("Failed to complete job", "error", err)
 	}
 
-	log.Printf("Sandbox %s created and started successfully (container: %s, port: %d)", job.SandboxID, sb.ContainerID, sb.Port)
+	log.Info("Sandbox created and started", "container_id", sb.ContainerID, "port", sb.Port)
 	return nil
 }
 
 // handleDeleteJob handles a delete sandbox job
 func (r *Runner) handleDeleteJob(ctx context.Context, job *Job) error {
-	log.Printf("Deleting sandbox %s", job.SandboxID)
+	log := jobLogger(job)
+	log.Info("Deleting sandbox")
 
 	// Report status: deleting
 	if err := r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
 		Status:  "deleting",
 		Message: "Deleting container",
 	}); err != nil {
-		log.Printf("Warning: failed to report deleting status: %v", err)
+		log.Warn("Failed to report deleting status", "error", err)
 	}
 
 	// Try to find the sandbox by name (not by container ID)
@@ -379,17 +716,16 @@ func (r *Runner) handleDeleteJob(ctx context.Context, job *Job) error {
 		// Debug: list all containers to see what's available
 		containers, listErr := r.sandbox.List(ctx)
 		if listErr == nil {
-			log.Printf("Available sandboxes:")
 			for _, s := range containers {
-				log.Printf("  - ID: %s, Name: %s, ContainerID: %s", s.ID, s.Name, s.ContainerID)
+				log.Debug("Available sandbox", "id", s.ID, "name", s.Name, "container_id", s.ContainerID)
 			}
 		}
 
 		// Sandbox not found - try to find by container ID prefix
-		log.Printf("Sandbox %s not found by name, trying by label", job.SandboxID)
+		log.Info("Sandbox not found by name, trying by label")
 		for _, s := range containers {
 			if strings.HasPrefix(s.ContainerID, job.SandboxID) || strings.Contains(job.SandboxID, s.ID) {
-				log.Printf("Found matching sandbox by ID: %s", s.Name)
+				log.Info("Found matching sandbox by ID", "name", s.Name)
 				sb = s
 				err = nil
 				break
@@ -398,7 +734,7 @@ func (r *Runner) handleDeleteJob(ctx context.Context, job *Job) error {
 
 		if err != nil {
 			// Sandbox not found - may have already been deleted
-			log.Printf("Sandbox %s not found, marking job as complete", job.SandboxID)
+			log.Info("Sandbox not found, marking job as complete")
 			r.client.CompleteJob(ctx, job.ID, true, "Sandbox not found (may already be deleted)")
 			return nil
 		}
@@ -406,14 +742,14 @@ func (r *Runner) handleDeleteJob(ctx context.Context, job *Job) error {
 
 	// Stop the sandbox
 	if err := r.sandbox.Stop(ctx, sb); err != nil {
-		log.Printf("Failed to stop sandbox %s: %v", job.SandboxID, err)
+		log.Error("Failed to stop sandbox", "error", err)
 		r.client.CompleteJob(ctx, job.ID, false, fmt.Sprintf("Failed to stop sandbox: %v", err))
 		return err
 	}
 
 	// Delete the sandbox
 	if err := r.sandbox.Delete(ctx, sb); err != nil {
-		log.Printf("Failed to delete sandbox %s: %v", job.SandboxID, err)
+		log.Error("Failed to delete sandbox", "error", err)
 		r.client.UpdateSandboxStatus(ctx, job.SandboxID, &SandboxStatusUpdate{
 			Status:  "failed",
 			Message: err.Error(),
@@ -422,7 +758,7 @@ func (r *Runner) handleDeleteJob(ctx context.Context, job *Job) error {
 		return err
 	}
 
-	log.Printf("Sandbox %s deleted successfully", job.SandboxID)
+	log.Info("Sandbox deleted")
 	r.client.CompleteJob(ctx, job.ID, true, "Sandbox deleted successfully")
 	return nil
 }
@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/config"
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+	"github.com/codepod/codepod/sandbox/runner/pkg/workpool"
+)
+
+// serverRequest is a request the fake server received
+type serverRequest struct {
+	method string
+	path   string
+	body   []byte
+}
+
+// fakeServer records the runner's HTTP calls to the server
+type fakeServer struct {
+	mu          sync.Mutex
+	requests    []serverRequest
+	failUploads bool // Checkpoint uploads get a 500
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, serverRequest{method: r.Method, path: r.URL.Path, body: body})
+	failUploads := s.failUploads
+	s.mu.Unlock()
+
+	if failUploads && r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/checkpoint") {
+		http.Error(w, "storage unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/accept") {
+		w.Write([]byte(`{"success":true}`))
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+// bodies returns the bodies of the POSTs to paths under prefix ending in
+// suffix, in order
+func (s *fakeServer) bodies(prefix, suffix string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bodies [][]byte
+	for _, req := range s.requests {
+		if req.method == http.MethodPost && strings.HasPrefix(req.path, prefix) && strings.HasSuffix(req.path, suffix) {
+			bodies = append(bodies, req.body)
+		}
+	}
+	return bodies
+}
+
+// sandboxStatuses returns the sandbox status updates reported, in order
+func (s *fakeServer) sandboxStatuses(t *testing.T) []SandboxStatusUpdate {
+	t.Helper()
+	var updates []SandboxStatusUpdate
+	for _, body := range s.bodies("/api/v1/sandboxes/", "/runner-status") {
+		var update SandboxStatusUpdate
+		if err := json.Unmarshal(body, &update); err != nil {
+			t.Fatalf("failed to decode sandbox status: %v", err)
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// snapshotStatuses returns the snapshot status updates reported, in order
+func (s *fakeServer) snapshotStatuses(t *testing.T) []SnapshotStatusUpdate {
+	t.Helper()
+	var updates []SnapshotStatusUpdate
+	for _, body := range s.bodies("/api/v1/snapshots/", "/runner-status") {
+		var update SnapshotStatusUpdate
+		if err := json.Unmarshal(body, &update); err != nil {
+			t.Fatalf("failed to decode snapshot status: %v", err)
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// jobCompletion is the body of a job completion
+type jobCompletion struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// completions returns the job completions reported, in order
+func (s *fakeServer) completions(t *testing.T) []jobCompletion {
+	t.Helper()
+	var completions []jobCompletion
+	for _, body := range s.bodies("/api/v1/jobs/", "/complete") {
+		var c jobCompletion
+		if err := json.Unmarshal(body, &c); err != nil {
+			t.Fatalf("failed to decode job completion: %v", err)
+		}
+		completions = append(completions, c)
+	}
+	return completions
+}
+
+// uploads returns the paths checkpoints were uploaded to
+func (s *fakeServer) uploads() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for _, req := range s.requests {
+		if req.method == http.MethodPut {
+			paths = append(paths, req.path)
+		}
+	}
+	return paths
+}
+
+// newTestRunner returns a runner backed by the mock Docker client, reporting
+// to a fake server
+func newTestRunner(t *testing.T) (*Runner, *docker.MockClient, *fakeServer) {
+	t.Helper()
+	srv := &fakeServer{}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	client, err := NewGrpcClient(&GrpcClientConfig{
+		ServerURL: ts.URL,
+		GRPCAddr:  "127.0.0.1:0",
+		RunnerID:  "runner-test",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	cfg := &config.Config{}
+	cfg.Docker.CheckpointDir = t.TempDir()
+
+	mock := docker.NewMockClient()
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	t.Cleanup(cancelJobs)
+	return &Runner{
+		cfg:        cfg,
+		docker:     mock,
+		sandbox:    sandbox.NewManager(mock),
+		client:     client,
+		jobs:       workpool.New(2),
+		running:    make(map[string]*Job),
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}, mock, srv
+}
+
+// startSandbox creates and starts a sandbox on r's mock Docker host
+func startSandbox(t *testing.T, r *Runner, name string) *sandbox.Sandbox {
+	t.Helper()
+	ctx := context.Background()
+	sb, err := r.sandbox.Create(ctx, &sandbox.CreateOptions{Image: "python:3.11", Name: name})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := r.sandbox.Start(ctx, sb); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+	return sb
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/sandbox"
+)
+
+// snapshotRepository is the repository snapshot images are tagged in,
+// under the configured registry
+const snapshotRepository = "codepod-snapshots"
+
+// handleSnapshotJob commits a sandbox's container to a snapshot image and
+// pushes it to the configured registry. The sandbox keeps its status; its
+// processes are only frozen while the filesystem is copied.
+func (r *Runner) handleSnapshotJob(ctx context.Context, job *Job) error {
+	log := jobLogger(job).With("snapshot_id", job.SnapshotID)
+
+	sb, err := r.sandboxForJob(ctx, job)
+	if err != nil {
+		r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{Status: "failed", Message: err.Error()})
+		return err
+	}
+
+	image := r.snapshotReference(job.SnapshotID)
+	log.Info("Taking snapshot", "image", image, "exclude_volumes", job.ExcludeVolumes)
+	imageID, err := r.sandbox.Snapshot(ctx, sb, &sandbox.SnapshotOptions{
+		ID:             job.SnapshotID,
+		Reference:      image,
+		Memory:         job.Memory,
+		CPU:            job.CPU,
+		Volumes:        sandboxVolumes(job.Volumes),
+		ExcludeVolumes: job.ExcludeVolumes,
+		Push:           r.cfg.Docker.Registry != "",
+		Auth:           r.registryAuth(),
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to snapshot sandbox: %w", err)
+		log.Error("Snapshot job failed", "error", err)
+		r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{Status: "failed", Message: err.Error()})
+		r.client.CompleteJob(ctx, job.ID, false, err.Error())
+		return err
+	}
+
+	r.reportSnapshot(ctx, job, &SnapshotStatusUpdate{
+		Status:  "ready",
+		Image:   image,
+		ImageID: imageID,
+		Message: "Snapshot taken",
+	})
+	log.Info("Snapshot taken", "image", image, "image_id", imageID)
+	return r.completeLifecycleJob(ctx, job, fmt.Sprintf("Snapshot %s taken", job.SnapshotID))
+}
+
+// handleRestoreJob creates a new sandbox from a snapshot image. The server
+// sends the source sandbox's env, resources and volumes with the job.
+func (r *Runner) handleRestoreJob(ctx context.Context, job *Job) error {
+	log := jobLogger(job).With("snapshot_id", job.SnapshotID)
+	log.Info("Restoring sandbox from snapshot", "image", job.Image)
+
+	if err := r.sandbox.PullSnapshot(ctx, job.Image, job.SnapshotID, r.registryAuth()); err != nil {
+		log.Error("Restore job failed", "error", err)
+		r.reportStatus(ctx, job, &SandboxStatusUpdate{Status: "failed", Message: err.Error()})
+		r.client.CompleteJob(ctx, job.ID, false, err.Error())
+		return err
+	}
+	return r.handleCreateJob(ctx, job)
+}
+
+// snapshotReference returns the image reference a snapshot is tagged with
+func (r *Runner) snapshotReference(snapshotID string) string {
+	repository := snapshotRepository
+	if r.cfg.Docker.Registry != "" {
+		repository = r.cfg.Docker.Registry + "/" + repository
+	}
+	return repository + ":" + snapshotID
+}
+
+// registryAuth returns the credentials for the snapshot registry, nil when
+// it needs none
+func (r *Runner) registryAuth() *docker.AuthConfig {
+	if r.cfg.Docker.RegistryUsername == "" {
+		return nil
+	}
+	return &docker.AuthConfig{
+		Username: r.cfg.Docker.RegistryUsername,
+		Password: r.cfg.Docker.RegistryPassword,
+		Registry: r.cfg.Docker.Registry,
+	}
+}
+
+// reportSnapshot reports the outcome of a snapshot job, logging rather than
+// failing the job when the server cannot be reached
+func (r *Runner) reportSnapshot(ctx context.Context, job *Job, update *SnapshotStatusUpdate) {
+	if err := r.client.UpdateSnapshotStatus(ctx, job.SnapshotID, update); err != nil {
+		jobLogger(job).Warn("Failed to report snapshot status", "status", update.Status, "error", err)
+	}
+}
+
+// sandboxVolumes converts job volumes to sandbox volumes
+func sandboxVolumes(volumes []VolumeInfo) []sandbox.VolumeInfo {
+	result := make([]sandbox.VolumeInfo, len(volumes))
+	for i, v := range volumes {
+		result[i] = sandbox.VolumeInfo{
+			VolumeID:  v.VolumeID,
+			MountPath: v.MountPath,
+			ReadOnly:  v.ReadOnly,
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+)
+
+func TestHandleSnapshotJob(t *testing.T) {
+	r, mock, srv := newTestRunner(t)
+	r.cfg.Docker.Registry = "registry:5000"
+	sb := startSandbox(t, r, "sb-1")
+
+	job := &Job{ID: "job-1", Type: "snapshot", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleSnapshotJob(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ref := "registry:5000/codepod-snapshots:snap-1"
+	if !mock.Pushed(ref) {
+		t.Error("expected the snapshot image to be pushed")
+	}
+	snapshots := srv.snapshotStatuses(t)
+	if len(snapshots) != 1 || snapshots[0].Status != "ready" || snapshots[0].Image != ref || snapshots[0].ImageID == "" {
+		t.Errorf("expected the snapshot to be ready, got %+v", snapshots)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || !completions[0].Success {
+		t.Errorf("expected the job to succeed, got %+v", completions)
+	}
+
+	// The sandbox keeps its status
+	if status, _ := mock.ContainerStatus(context.Background(), sb.ContainerID); status != string(docker.ContainerStateRunning) {
+		t.Errorf("expected the container to keep running, got %s", status)
+	}
+	if statuses := srv.sandboxStatuses(t); len(statuses) != 0 {
+		t.Errorf("expected no sandbox status updates, got %+v", statuses)
+	}
+}
+
+func TestHandleSnapshotJobMissingSandbox(t *testing.T) {
+	r, _, srv := newTestRunner(t)
+
+	job := &Job{ID: "job-1", Type: "snapshot", SandboxID: "sb-1", SnapshotID: "snap-1"}
+	if err := r.handleSnapshotJob(context.Background(), job); err == nil {
+		t.Fatal("expected error for a missing sandbox")
+	}
+
+	snapshots := srv.snapshotStatuses(t)
+	if len(snapshots) != 1 || snapshots[0].Status != "failed" ||
+		!strings.HasPrefix(snapshots[0].Message, "sandbox container not found") {
+		t.Errorf("expected the snapshot to fail, got %+v", snapshots)
+	}
+	if completions := srv.completions(t); len(completions) != 1 || completions[0].Success {
+		t.Errorf("expected the job to fail, got %+v", completions)
+	}
+}
@@ -0,0 +1,189 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codepod/codepod/sandbox/runner/internal/runner/pb"
+	"github.com/codepod/codepod/sandbox/runner/pkg/placement"
+)
+
+// JobHandler receives the jobs pushed over the job stream
+type JobHandler struct {
+	Connected func()           // Called once the stream is open
+	Submit    func(*Job) error // An error hands the job back to the server with the error as the reason
+	Drain     func(*DrainRequest)
+}
+
+// StreamJobs opens the server's job stream and hands each pushed job to h
+// until the stream fails or ctx is cancelled. While the stream is open, job
+// acks, completions and heartbeats are sent over it.
+func (c *GrpcClient) StreamJobs(ctx context.Context, h JobHandler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := pb.NewRunnerServiceClient(c.conn).Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open job stream: %w", err)
+	}
+	if err := stream.Send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Hello{Hello: &pb.Hello{
+		RunnerId: c.config.RunnerID,
+		Capacity: int32(c.config.Capacity),
+		Labels:   c.config.Labels,
+		Taints:   c.config.Taints,
+	}}}); err != nil {
+		return fmt.Errorf("failed to send hello: %w", err)
+	}
+
+	c.setStream(stream)
+	defer c.setStream(nil)
+	if h.Connected != nil {
+		h.Connected()
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if drain := msg.GetDrain(); drain != nil {
+			if h.Drain != nil {
+				h.Drain(&DrainRequest{
+					Reason:          drain.Reason,
+					DeadlineSeconds: int(drain.DeadlineSeconds),
+					Reschedule:      drain.Reschedule,
+				})
+			}
+			continue
+		}
+		if assignment := msg.GetAssignment(); assignment != nil {
+			c.assign(assignment)
+			continue
+		}
+		job := msg.GetJob()
+		if job == nil {
+			continue
+		}
+		if err := h.Submit(jobFromProto(job)); err != nil {
+			c.send(&pb.RunnerMessage{Message: &pb.RunnerMessage_Ack{Ack: &pb.JobAck{
+				JobId:   job.Id,
+				Message: err.Error(),
+			}}})
+		}
+	}
+}
+
+// setStream sets the stream acks and completions are sent over, nil when
+// disconnected. Accepts still waiting for an answer when the stream closes
+// fall back to HTTP.
+func (c *GrpcClient) setStream(stream pb.RunnerService_ConnectClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stream = stream
+	if stream == nil {
+		for id, answer := range c.assignments {
+			close(answer)
+			delete(c.assignments, id)
+		}
+	}
+}
+
+// assign passes the server's answer to a job accepted over the stream on to
+// the AcceptJob waiting for it
+func (c *GrpcClient) assign(assignment *pb.JobAssignment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if answer, ok := c.assignments[assignment.JobId]; ok {
+		answer <- assignment
+		delete(c.assignments, assignment.JobId)
+	}
+}
+
+// send sends msg over the job stream, reporting false when it is not open
+func (c *GrpcClient) send(msg *pb.RunnerMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stream == nil {
+		return false
+	}
+	if err := c.stream.Send(msg); err != nil {
+		logger.Debug("Failed to send over job stream", "error", err)
+		return false
+	}
+	return true
+}
+
+// jobFromProto converts a pushed job to the type returned by PollJobs
+func jobFromProto(job *pb.Job) *Job {
+	return &Job{
+		ID:             job.Id,
+		Type:           job.Type,
+		SandboxID:      job.SandboxId,
+		Image:          job.Image,
+		Token:          job.Token,
+		Status:         "pending",
+		Env:            job.Env,
+		Memory:         job.Memory,
+		CPU:            int(job.Cpu),
+		NetworkMode:    job.NetworkMode,
+		TraceContext:   job.TraceContext,
+		Constraints:    constraintsFromProto(job.Constraints),
+		Volumes:        volumesFromProto(job.Volumes),
+		SnapshotID:     job.SnapshotId,
+		ExcludeVolumes: job.ExcludeVolumes,
+		LeaveRunning:   job.LeaveRunning,
+	}
+}
+
+// volumesFromProto converts the volumes of a pushed job
+func volumesFromProto(volumes []*pb.JobVolume) []VolumeInfo {
+	var result []VolumeInfo
+	for _, v := range volumes {
+		result = append(result, VolumeInfo{
+			VolumeID:  v.VolumeId,
+			MountPath: v.MountPath,
+			ReadOnly:  v.ReadOnly,
+		})
+	}
+	return result
+}
+
+// constraintsFromProto converts a pushed job's constraints, nil when it has none
+func constraintsFromProto(c *pb.JobConstraints) *placement.Constraints {
+	if c == nil || (len(c.NodeSelector) == 0 && len(c.Tolerations) == 0) {
+		return nil
+	}
+	return &placement.Constraints{
+		NodeSelector: c.NodeSelector,
+		Tolerations:  c.Tolerations,
+	}
+}
+
+// heartbeatToProto converts a heartbeat for the job stream
+func heartbeatToProto(hb *Heartbeat) *pb.Heartbeat {
+	msg := &pb.Heartbeat{
+		ActiveJobs:       int32(hb.ActiveJobs),
+		FreeSlots:        int32(hb.FreeSlots),
+		RunningSandboxes: int32(hb.RunningSandboxes),
+		Version:          hb.Version,
+		Labels:           hb.Labels,
+		Images:           hb.Images,
+		Healthy:          hb.Healthy,
+		HealthMessage:    hb.HealthMessage,
+		Draining:         hb.Draining,
+	}
+	if res := hb.Resources; res != nil {
+		msg.Resources = &pb.HostResources{
+			Cpus:            int32(res.CPUs),
+			Load1:           res.Load1,
+			MemoryTotal:     res.MemoryTotal,
+			MemoryAvailable: res.MemoryAvailable,
+			DiskTotal:       res.DiskTotal,
+			DiskFree:        res.DiskFree,
+		}
+	}
+	return msg
+}
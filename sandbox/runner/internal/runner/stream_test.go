@@ -0,0 +1,179 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/internal/runner/pb"
+	"github.com/codepod/codepod/sandbox/runner/pkg/workpool"
+	"google.golang.org/grpc"
+)
+
+// fakeJobStream pushes jobs to the runner that connects, passes on the acks
+// and completions it sends back and answers accepted jobs
+type fakeJobStream struct {
+	pb.UnimplementedRunnerServiceServer
+	jobs        []*pb.Job
+	refuse      bool // Accepted jobs are not assigned to the runner
+	acks        chan *pb.JobAck
+	completions chan *pb.JobCompletion
+}
+
+func newFakeJobStream(jobs ...*pb.Job) *fakeJobStream {
+	return &fakeJobStream{
+		jobs:        jobs,
+		acks:        make(chan *pb.JobAck, 4),
+		completions: make(chan *pb.JobCompletion, 4),
+	}
+}
+
+func (s *fakeJobStream) Connect(stream grpc.BidiStreamingServer[pb.RunnerMessage, pb.ServerMessage]) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	for _, job := range s.jobs {
+		if err := stream.Send(&pb.ServerMessage{Message: &pb.ServerMessage_Job{Job: job}}); err != nil {
+			return err
+		}
+	}
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if ack := msg.GetAck(); ack != nil {
+			s.acks <- ack
+			if ack.Accepted {
+				stream.Send(&pb.ServerMessage{Message: &pb.ServerMessage_Assignment{Assignment: &pb.JobAssignment{
+					JobId:    ack.JobId,
+					Assigned: !s.refuse,
+				}}})
+			}
+		}
+		if completion := msg.GetCompletion(); completion != nil {
+			s.completions <- completion
+		}
+	}
+}
+
+// streamJobs connects r to fake and returns the first ack r sends
+func streamJobs(t *testing.T, r *Runner, fake *fakeJobStream) *pb.JobAck {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterRunnerServiceServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	client, err := NewGrpcClient(&GrpcClientConfig{
+		ServerURL: r.client.GetConfig().ServerURL,
+		GRPCAddr:  lis.Addr().String(),
+		RunnerID:  "runner-test",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	r.client = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go client.StreamJobs(ctx, JobHandler{
+		Submit: func(job *Job) error {
+			return r.submitJob(ctx, job)
+		},
+	})
+
+	select {
+	case ack := <-fake.acks:
+		return ack
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job to be acked")
+		return nil
+	}
+}
+
+func TestStreamJobsAcceptsJob(t *testing.T) {
+	r, _, _ := newTestRunner(t)
+
+	ack := streamJobs(t, r, newFakeJobStream(&pb.Job{Id: "job-1", Type: "stop", SandboxId: "sb-1"}))
+	if ack.JobId != "job-1" || !ack.Accepted {
+		t.Errorf("expected the job to be accepted, got %+v", ack)
+	}
+}
+
+func TestStreamJobsRunsAssignedJob(t *testing.T) {
+	r, _, _ := newTestRunner(t)
+	fake := newFakeJobStream(&pb.Job{Id: "job-1", Type: "stop", SandboxId: "sb-1"})
+
+	streamJobs(t, r, fake)
+	select {
+	case completion := <-fake.completions:
+		if completion.JobId != "job-1" {
+			t.Errorf("expected job-1 to be completed, got %+v", completion)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the job to be completed")
+	}
+}
+
+func TestStreamJobsDropsRefusedJob(t *testing.T) {
+	r, _, srv := newTestRunner(t)
+	fake := newFakeJobStream(&pb.Job{Id: "job-1", Type: "stop", SandboxId: "sb-1"})
+	fake.refuse = true
+
+	streamJobs(t, r, fake)
+	r.jobs.Wait()
+	select {
+	case completion := <-fake.completions:
+		t.Errorf("expected the refused job not to be completed, got %+v", completion)
+	default:
+	}
+	if bodies := srv.bodies("/api/v1/jobs/", "/complete"); len(bodies) != 0 {
+		t.Errorf("expected no completion over HTTP, got %d", len(bodies))
+	}
+}
+
+func TestStreamJobsDeclinesWhenFull(t *testing.T) {
+	r, _, _ := newTestRunner(t)
+	block := make(chan struct{})
+	defer close(block)
+	r.jobs = workpool.New(1)
+	if err := r.jobs.Submit("job-0", "sb-0", func() { <-block }); err != nil {
+		t.Fatalf("failed to fill the pool: %v", err)
+	}
+
+	ack := streamJobs(t, r, newFakeJobStream(&pb.Job{Id: "job-1", Type: "stop", SandboxId: "sb-1"}))
+	if ack.JobId != "job-1" || ack.Accepted || ack.Message != "runner is at capacity" {
+		t.Errorf("expected the job to be declined, got %+v", ack)
+	}
+}
+
+func TestStreamJobsDeclinesWhileDraining(t *testing.T) {
+	r, _, _ := newTestRunner(t)
+	r.draining.Store(true)
+
+	ack := streamJobs(t, r, newFakeJobStream(&pb.Job{Id: "job-1", Type: "create", SandboxId: "sb-1"}))
+	if ack.Accepted || ack.Message != "runner is draining" {
+		t.Errorf("expected the job to be declined, got %+v", ack)
+	}
+}
+
+func TestStreamJobsDeclinesUnsatisfiedConstraints(t *testing.T) {
+	r, _, _ := newTestRunner(t)
+
+	ack := streamJobs(t, r, newFakeJobStream(&pb.Job{
+		Id:          "job-1",
+		Type:        "create",
+		SandboxId:   "sb-1",
+		Constraints: &pb.JobConstraints{NodeSelector: map[string]string{"gpu": "true"}},
+	}))
+	if ack.Accepted || ack.Message == "" {
+		t.Errorf("expected the job to be declined, got %+v", ack)
+	}
+}
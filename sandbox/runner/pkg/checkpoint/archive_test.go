@@ -0,0 +1,78 @@
+package checkpoint
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackUnpack(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "config.json"), []byte(`{"id":"cp-1"}`), 0600)
+	os.MkdirAll(filepath.Join(src, "criu.work"), 0700)
+	os.WriteFile(filepath.Join(src, "criu.work", "pages-1.img"), []byte("pages"), 0600)
+
+	var buf bytes.Buffer
+	if err := Pack(src, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "cp-1")
+	if err := Unpack(&buf, dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "config.json"))
+	if err != nil || string(data) != `{"id":"cp-1"}` {
+		t.Errorf("unexpected config.json: %q, %v", data, err)
+	}
+	data, err = os.ReadFile(filepath.Join(dst, "criu.work", "pages-1.img"))
+	if err != nil || string(data) != "pages" {
+		t.Errorf("unexpected pages-1.img: %q, %v", data, err)
+	}
+}
+
+func TestPackMissingDir(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Pack(filepath.Join(t.TempDir(), "missing"), &buf); err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}
+
+func TestUnpackRejectsEscapingPaths(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/evil", "a/../../evil"} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600, Size: 4})
+		tw.Write([]byte("evil"))
+		tw.Close()
+		gz.Close()
+
+		if err := Unpack(&buf, t.TempDir()); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
+
+func TestUnpackRejectsLinks(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	gz.Close()
+
+	if err := Unpack(&buf, t.TempDir()); err == nil {
+		t.Error("expected error for a symlink")
+	}
+}
+
+func TestUnpackInvalidArchive(t *testing.T) {
+	if err := Unpack(bytes.NewReader([]byte("not gzip")), t.TempDir()); err == nil {
+		t.Error("expected error for an invalid archive")
+	}
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config represents the Runner configuration
@@ -14,32 +17,48 @@ type Config struct {
 	Runner  RunnerConfig
 	Agent   AgentConfig
 	Logging LoggingConfig
+	Metrics MetricsConfig
+	Tracing TracingConfig
 }
 
 // AgentConfig holds Agent settings
 type AgentConfig struct {
-	BinaryPath string // Path to the agent binary
-	Token      string // Default token for agent authentication
-	HostKeys   string // Path to SSH host keys directory
+	BinaryPath        string        // Path to the agent binary
+	Token             string        // Default token for agent authentication
+	HostKeys          string        // Path to SSH host keys directory
+	ReadyTimeout      time.Duration // How long to wait for a started agent to report healthy
+	TLS               bool          // Serve agent gRPC over TLS with a certificate from the server's CA
+	RequireClientCert bool          // Agents accept only clients with a CA-issued certificate (mTLS)
 }
 
 // ServerConfig holds Server connection settings
 type ServerConfig struct {
-	URL   string
-	Token string
+	URL             string
+	Token           string // Join token exchanged for a runner credential at enrollment
+	GRPCAddr        string // host:port of the server's job stream; defaults to the URL host on port 50051
+	CAFile          string // PEM bundle trusted for the server's certificate, in addition to the system roots
+	CredentialsFile string // Where the credential issued at enrollment is kept across restarts
 }
 
 // DockerConfig holds Docker settings
 type DockerConfig struct {
-	Host    string
-	Network string
+	Host             string
+	Network          string
+	Registry         string // Registry snapshots are pushed to, e.g. registry:5000; empty keeps them on the Docker host
+	RegistryUsername string
+	RegistryPassword string
+	CheckpointDir    string // Host directory process checkpoints are written to and restored from
 }
 
 // RunnerConfig holds Runner settings
 type RunnerConfig struct {
-	ID      string
-	MaxJobs int
-	Host    string // Network address for SSH connections (e.g., IP or hostname)
+	ID                string
+	MaxJobs           int               // Jobs run concurrently; jobs for the same sandbox run one at a time
+	Host              string            // Network address for SSH connections (e.g., IP or hostname)
+	ReconcileInterval time.Duration     // How often containers are compared with the server's state; 0 disables it
+	Labels            map[string]string // Matched against job node selectors and reported to the server, e.g. zone=eu-1
+	Taints            map[string]string // Only jobs that tolerate every taint run here, e.g. dedicated=ci
+	DrainTimeout      time.Duration     // How long a draining runner waits for running jobs before leaving
 }
 
 // LoggingConfig holds logging settings
@@ -48,6 +67,19 @@ type LoggingConfig struct {
 	Format string
 }
 
+// MetricsConfig holds Prometheus metrics settings
+type MetricsConfig struct {
+	Addr string // Listen address for /metrics, e.g. ":9090"; empty disables it
+}
+
+// TracingConfig holds OpenTelemetry tracing settings
+type TracingConfig struct {
+	Exporter string // none, otlp or file
+	Endpoint string // OTLP gRPC host:port
+	Insecure bool   // Send OTLP without TLS
+	File     string // Path spans are appended to with the file exporter
+}
+
 // Load reads and parses the configuration file (simple YAML parser)
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -58,7 +90,7 @@ func Load(path string) (*Config, error) {
 	cfg := &Config{
 		Server:  ServerConfig{},
 		Docker:  DockerConfig{},
-		Runner:  RunnerConfig{},
+		Runner:  RunnerConfig{ReconcileInterval: 60 * time.Second},
 		Logging: LoggingConfig{},
 	}
 
@@ -105,6 +137,12 @@ func Load(path string) (*Config, error) {
 				cfg.Server.URL = value
 			case "token":
 				cfg.Server.Token = value
+			case "grpc_addr":
+				cfg.Server.GRPCAddr = value
+			case "ca_file":
+				cfg.Server.CAFile = value
+			case "credentials_file":
+				cfg.Server.CredentialsFile = value
 			}
 		case "docker":
 			switch key {
@@ -112,6 +150,14 @@ func Load(path string) (*Config, error) {
 				cfg.Docker.Host = value
 			case "network":
 				cfg.Docker.Network = value
+			case "registry":
+				cfg.Docker.Registry = value
+			case "registry_username":
+				cfg.Docker.RegistryUsername = value
+			case "registry_password":
+				cfg.Docker.RegistryPassword = value
+			case "checkpoint_dir":
+				cfg.Docker.CheckpointDir = value
 			}
 		case "runner":
 			switch key {
@@ -121,6 +167,16 @@ func Load(path string) (*Config, error) {
 				cfg.Runner.MaxJobs, _ = strconv.Atoi(value)
 			case "host":
 				cfg.Runner.Host = value
+			case "reconcile_interval":
+				secs, _ := strconv.Atoi(value)
+				cfg.Runner.ReconcileInterval = time.Duration(secs) * time.Second
+			case "labels":
+				cfg.Runner.Labels = ParseLabels(value)
+			case "taints":
+				cfg.Runner.Taints = ParseLabels(value)
+			case "drain_timeout":
+				secs, _ := strconv.Atoi(value)
+				cfg.Runner.DrainTimeout = time.Duration(secs) * time.Second
 			}
 		case "logging":
 			switch key {
@@ -129,6 +185,22 @@ func Load(path string) (*Config, error) {
 			case "format":
 				cfg.Logging.Format = value
 			}
+		case "metrics":
+			switch key {
+			case "addr":
+				cfg.Metrics.Addr = value
+			}
+		case "tracing":
+			switch key {
+			case "exporter":
+				cfg.Tracing.Exporter = value
+			case "endpoint":
+				cfg.Tracing.Endpoint = value
+			case "insecure":
+				cfg.Tracing.Insecure, _ = strconv.ParseBool(value)
+			case "file":
+				cfg.Tracing.File = value
+			}
 		}
 	}
 
@@ -140,15 +212,27 @@ func Load(path string) (*Config, error) {
 
 // applyDefaults sets default values for missing configuration
 func (c *Config) applyDefaults() {
+	if c.Server.GRPCAddr == "" {
+		c.Server.GRPCAddr = defaultGRPCAddr(c.Server.URL)
+	}
+	if c.Server.CredentialsFile == "" {
+		c.Server.CredentialsFile = "/var/lib/codepod/runner-credential.json"
+	}
 	if c.Docker.Host == "" {
 		c.Docker.Host = "unix:///var/run/docker.sock"
 	}
 	if c.Docker.Network == "" {
 		c.Docker.Network = "codepod"
 	}
+	if c.Docker.CheckpointDir == "" {
+		c.Docker.CheckpointDir = "/var/lib/codepod/checkpoints"
+	}
 	if c.Runner.MaxJobs == 0 {
 		c.Runner.MaxJobs = 10
 	}
+	if c.Runner.DrainTimeout <= 0 {
+		c.Runner.DrainTimeout = 5 * time.Minute
+	}
 	if c.Runner.ID == "" {
 		// Generate a default runner ID using hostname
 		hostname, err := os.Hostname()
@@ -168,6 +252,25 @@ func (c *Config) applyDefaults() {
 	if c.Agent.BinaryPath == "" {
 		c.Agent.BinaryPath = "/usr/local/bin/agent"
 	}
+	if c.Agent.ReadyTimeout == 0 {
+		c.Agent.ReadyTimeout = 60 * time.Second
+	}
+}
+
+// defaultGRPCAddr returns the job stream address for a server URL: the
+// URL's host on port 50051
+func defaultGRPCAddr(serverURL string) string {
+	if serverURL == "" {
+		return ""
+	}
+	host := serverURL
+	if u, err := url.Parse(serverURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return net.JoinHostPort(host, "50051")
 }
 
 // Validate checks if the configuration is valid
@@ -185,32 +288,70 @@ func (c *Config) Validate() error {
 func LoadFromEnv() *Config {
 	cfg := &Config{
 		Server: ServerConfig{
-			URL:   os.Getenv("CODEPOD_SERVER_URL"),
-			Token: os.Getenv("CODEPOD_SERVER_TOKEN"),
+			URL:             os.Getenv("CODEPOD_SERVER_URL"),
+			Token:           os.Getenv("CODEPOD_SERVER_TOKEN"),
+			GRPCAddr:        os.Getenv("CODEPOD_SERVER_GRPC_ADDR"),
+			CAFile:          os.Getenv("CODEPOD_SERVER_CA_FILE"),
+			CredentialsFile: os.Getenv("CODEPOD_SERVER_CREDENTIALS_FILE"),
 		},
 		Docker: DockerConfig{
-			Host:    getEnvOrDefault("CODEPOD_DOCKER_HOST", "unix:///var/run/docker.sock"),
-			Network: getEnvOrDefault("CODEPOD_DOCKER_NETWORK", "codepod"),
+			Host:             getEnvOrDefault("CODEPOD_DOCKER_HOST", "unix:///var/run/docker.sock"),
+			Network:          getEnvOrDefault("CODEPOD_DOCKER_NETWORK", "codepod"),
+			Registry:         os.Getenv("CODEPOD_DOCKER_REGISTRY"),
+			RegistryUsername: os.Getenv("CODEPOD_DOCKER_REGISTRY_USERNAME"),
+			RegistryPassword: os.Getenv("CODEPOD_DOCKER_REGISTRY_PASSWORD"),
+			CheckpointDir:    os.Getenv("CODEPOD_CHECKPOINT_DIR"),
 		},
 		Runner: RunnerConfig{
-			ID:      os.Getenv("CODEPOD_RUNNER_ID"),
-			MaxJobs: getEnvIntOrDefault("CODEPOD_MAX_JOBS", 10),
-			Host:    os.Getenv("CODEPOD_RUNNER_HOST"),
+			ID:                os.Getenv("CODEPOD_RUNNER_ID"),
+			MaxJobs:           getEnvIntOrDefault("CODEPOD_MAX_JOBS", 10),
+			Host:              os.Getenv("CODEPOD_RUNNER_HOST"),
+			ReconcileInterval: time.Duration(getEnvIntOrDefault("CODEPOD_RECONCILE_INTERVAL", 60)) * time.Second,
+			Labels:            ParseLabels(os.Getenv("CODEPOD_RUNNER_LABELS")),
+			Taints:            ParseLabels(os.Getenv("CODEPOD_RUNNER_TAINTS")),
+			DrainTimeout:      time.Duration(getEnvIntOrDefault("CODEPOD_DRAIN_TIMEOUT", 300)) * time.Second,
 		},
 		Agent: AgentConfig{
-			BinaryPath: getEnvOrDefault("CODEPOD_AGENT_BINARY_PATH", ""),
-			Token:      os.Getenv("CODEPOD_AGENT_TOKEN"),
+			BinaryPath:        getEnvOrDefault("CODEPOD_AGENT_BINARY_PATH", ""),
+			Token:             os.Getenv("CODEPOD_AGENT_TOKEN"),
+			ReadyTimeout:      time.Duration(getEnvIntOrDefault("CODEPOD_AGENT_READY_TIMEOUT", 60)) * time.Second,
+			TLS:               getEnvBoolOrDefault("CODEPOD_AGENT_TLS", false),
+			RequireClientCert: getEnvBoolOrDefault("CODEPOD_AGENT_TLS_REQUIRE_CLIENT_CERT", false),
 		},
 		Logging: LoggingConfig{
 			Level:  getEnvOrDefault("CODEPOD_LOG_LEVEL", "info"),
 			Format: getEnvOrDefault("CODEPOD_LOG_FORMAT", "json"),
 		},
+		Metrics: MetricsConfig{
+			Addr: os.Getenv("CODEPOD_METRICS_ADDR"),
+		},
+		Tracing: TracingConfig{
+			Exporter: os.Getenv("CODEPOD_TRACING_EXPORTER"),
+			Endpoint: os.Getenv("CODEPOD_TRACING_ENDPOINT"),
+			Insecure: getEnvBoolOrDefault("CODEPOD_TRACING_INSECURE", false),
+			File:     os.Getenv("CODEPOD_TRACING_FILE"),
+		},
 	}
 
 	cfg.applyDefaults()
 	return cfg
 }
 
+// ParseLabels parses comma-separated key=value pairs such as "zone=eu-1,gpu=true".
+// Entries without a key are skipped.
+func ParseLabels(value string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		labels[key] = strings.TrimSpace(val)
+	}
+	return labels
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -227,3 +368,15 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	result, _ := strconv.Atoi(value)
 	return result
 }
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
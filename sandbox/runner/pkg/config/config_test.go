@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -11,18 +12,36 @@ func TestLoadConfig(t *testing.T) {
 server:
   url: "localhost:50051"
   token: "test-token"
+  grpc_addr: "server:50052"
+  ca_file: "/etc/codepod/ca.pem"
+  credentials_file: "/tmp/runner-credential.json"
 
 docker:
   host: "unix:///var/run/docker.sock"
   network: "codepod-test"
+  registry: "registry:5000"
+  registry_username: "codepod"
+  checkpoint_dir: "/tmp/checkpoints"
 
 runner:
   id: "runner-test-001"
   max_jobs: 5
+  reconcile_interval: 30
+  labels: "zone=eu-1, gpu=true"
+  taints: "dedicated=ci"
+  drain_timeout: 120
 
 logging:
   level: "debug"
   format: "json"
+
+metrics:
+  addr: ":9090"
+
+tracing:
+  exporter: "otlp"
+  endpoint: "collector:4317"
+  insecure: true
 `
 
 	tmpFile, err := os.CreateTemp("", "config-*.yaml")
@@ -47,21 +66,57 @@ logging:
 	if cfg.Server.Token != "test-token" {
 		t.Errorf("expected server token test-token, got %s", cfg.Server.Token)
 	}
+	if cfg.Server.GRPCAddr != "server:50052" {
+		t.Errorf("expected server gRPC addr server:50052, got %s", cfg.Server.GRPCAddr)
+	}
+	if cfg.Server.CAFile != "/etc/codepod/ca.pem" {
+		t.Errorf("expected server CA file /etc/codepod/ca.pem, got %s", cfg.Server.CAFile)
+	}
+	if cfg.Server.CredentialsFile != "/tmp/runner-credential.json" {
+		t.Errorf("expected credentials file /tmp/runner-credential.json, got %s", cfg.Server.CredentialsFile)
+	}
 	if cfg.Docker.Host != "unix:///var/run/docker.sock" {
 		t.Errorf("expected docker host unix:///var/run/docker.sock, got %s", cfg.Docker.Host)
 	}
 	if cfg.Docker.Network != "codepod-test" {
 		t.Errorf("expected docker network codepod-test, got %s", cfg.Docker.Network)
 	}
+	if cfg.Docker.Registry != "registry:5000" {
+		t.Errorf("expected docker registry registry:5000, got %s", cfg.Docker.Registry)
+	}
+	if cfg.Docker.RegistryUsername != "codepod" {
+		t.Errorf("expected docker registry username codepod, got %s", cfg.Docker.RegistryUsername)
+	}
+	if cfg.Docker.CheckpointDir != "/tmp/checkpoints" {
+		t.Errorf("expected checkpoint dir /tmp/checkpoints, got %s", cfg.Docker.CheckpointDir)
+	}
 	if cfg.Runner.ID != "runner-test-001" {
 		t.Errorf("expected runner id runner-test-001, got %s", cfg.Runner.ID)
 	}
 	if cfg.Runner.MaxJobs != 5 {
 		t.Errorf("expected max jobs 5, got %d", cfg.Runner.MaxJobs)
 	}
+	if cfg.Runner.ReconcileInterval != 30*time.Second {
+		t.Errorf("expected reconcile interval 30s, got %s", cfg.Runner.ReconcileInterval)
+	}
+	if len(cfg.Runner.Labels) != 2 || cfg.Runner.Labels["zone"] != "eu-1" || cfg.Runner.Labels["gpu"] != "true" {
+		t.Errorf("unexpected runner labels: %v", cfg.Runner.Labels)
+	}
+	if len(cfg.Runner.Taints) != 1 || cfg.Runner.Taints["dedicated"] != "ci" {
+		t.Errorf("unexpected runner taints: %v", cfg.Runner.Taints)
+	}
+	if cfg.Runner.DrainTimeout != 2*time.Minute {
+		t.Errorf("expected drain timeout 2m, got %s", cfg.Runner.DrainTimeout)
+	}
 	if cfg.Logging.Level != "debug" {
 		t.Errorf("expected log level debug, got %s", cfg.Logging.Level)
 	}
+	if cfg.Metrics.Addr != ":9090" {
+		t.Errorf("expected metrics addr :9090, got %s", cfg.Metrics.Addr)
+	}
+	if cfg.Tracing.Exporter != "otlp" || cfg.Tracing.Endpoint != "collector:4317" || !cfg.Tracing.Insecure {
+		t.Errorf("unexpected tracing config: %+v", cfg.Tracing)
+	}
 }
 
 func TestLoadConfigDefaults(t *testing.T) {
@@ -97,6 +152,52 @@ server:
 	if cfg.Logging.Level != "info" {
 		t.Errorf("expected default log level info, got %s", cfg.Logging.Level)
 	}
+	if cfg.Agent.ReadyTimeout != 60*time.Second {
+		t.Errorf("expected default agent ready timeout 60s, got %s", cfg.Agent.ReadyTimeout)
+	}
+	if cfg.Server.GRPCAddr != "localhost:50051" {
+		t.Errorf("expected default server gRPC addr localhost:50051, got %s", cfg.Server.GRPCAddr)
+	}
+	if cfg.Runner.ReconcileInterval != 60*time.Second {
+		t.Errorf("expected default reconcile interval 60s, got %s", cfg.Runner.ReconcileInterval)
+	}
+	if cfg.Server.CredentialsFile != "/var/lib/codepod/runner-credential.json" {
+		t.Errorf("expected default credentials file, got %s", cfg.Server.CredentialsFile)
+	}
+	if cfg.Runner.DrainTimeout != 5*time.Minute {
+		t.Errorf("expected default drain timeout 5m, got %s", cfg.Runner.DrainTimeout)
+	}
+	if cfg.Docker.CheckpointDir != "/var/lib/codepod/checkpoints" {
+		t.Errorf("expected default checkpoint dir, got %s", cfg.Docker.CheckpointDir)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels := ParseLabels("zone=eu-1,,=skipped, arch = arm64 ,flag")
+	if len(labels) != 3 || labels["zone"] != "eu-1" || labels["arch"] != "arm64" || labels["flag"] != "" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	if labels := ParseLabels(""); len(labels) != 0 {
+		t.Errorf("expected no labels, got %v", labels)
+	}
+}
+
+func TestDefaultGRPCAddr(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://localhost:8080", "localhost:50051"},
+		{"https://codepod.example.com", "codepod.example.com:50051"},
+		{"http://[::1]:8080/", "[::1]:50051"},
+		{"localhost:8080", "localhost:50051"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := defaultGRPCAddr(tt.url); got != tt.want {
+			t.Errorf("defaultGRPCAddr(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
 }
 
 func TestLoadConfigEnvVar(t *testing.T) {
@@ -0,0 +1,73 @@
+// Package credential keeps the credential a runner is issued when it enrolls
+// with the server, and decides when to rotate it
+package credential
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Credential is a bearer token the server issued to a runner
+type Credential struct {
+	RunnerID  string    `json:"runnerId"`
+	Token     string    `json:"token"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Valid reports whether c belongs to runnerID and has not expired at now
+func (c *Credential) Valid(runnerID string, now time.Time) bool {
+	return c != nil && c.Token != "" && c.RunnerID == runnerID && now.Before(c.ExpiresAt)
+}
+
+// NeedsRotation reports whether two thirds of c's lifetime have passed at now
+func (c *Credential) NeedsRotation(now time.Time) bool {
+	lifetime := c.ExpiresAt.Sub(c.IssuedAt)
+	return !now.Before(c.IssuedAt.Add(lifetime * 2 / 3))
+}
+
+// Load reads a credential saved by Save. The error wraps os.ErrNotExist when
+// there is none.
+func Load(path string) (*Credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential: %w", err)
+	}
+	var c Credential
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse credential: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes c to path, readable only by the runner's user. The file is
+// replaced atomically so a crash never leaves a partial credential.
+func Save(path string, c *Credential) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create credential directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".credential-*")
+	if err != nil {
+		return fmt.Errorf("failed to create credential file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credential: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credential: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package credential
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValid(t *testing.T) {
+	now := time.Now()
+	c := &Credential{RunnerID: "runner-1", Token: "cpr_x", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+
+	if !c.Valid("runner-1", now) {
+		t.Error("expected credential to be valid")
+	}
+	if c.Valid("runner-2", now) {
+		t.Error("expected credential of another runner to be invalid")
+	}
+	if c.Valid("runner-1", now.Add(2*time.Hour)) {
+		t.Error("expected expired credential to be invalid")
+	}
+	var missing *Credential
+	if missing.Valid("runner-1", now) {
+		t.Error("expected nil credential to be invalid")
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Credential{IssuedAt: issued, ExpiresAt: issued.Add(3 * time.Hour)}
+
+	if c.NeedsRotation(issued.Add(time.Hour)) {
+		t.Error("expected no rotation after a third of the lifetime")
+	}
+	if !c.NeedsRotation(issued.Add(2 * time.Hour)) {
+		t.Error("expected rotation after two thirds of the lifetime")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner", "credential.json")
+
+	if _, err := Load(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Credential{RunnerID: "runner-1", Token: "cpr_x", IssuedAt: issued, ExpiresAt: issued.Add(time.Hour)}
+	if err := Save(path, c); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if *loaded != *c {
+		t.Errorf("expected %+v, got %+v", c, loaded)
+	}
+}
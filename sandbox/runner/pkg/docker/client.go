@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 )
 
 // Client is a minimal Docker client interface for MVP
 type Client interface {
+	// Ping checks that the Docker daemon is reachable
+	Ping(ctx context.Context) error
+
 	// Container operations
 	CreateContainer(ctx context.Context, config *ContainerConfig) (string, error)
 	StartContainer(ctx context.Context, containerID string) error
@@ -16,10 +20,23 @@ type Client interface {
 	RemoveContainer(ctx context.Context, containerID string, force bool) error
 	ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error)
 	ContainerStatus(ctx context.Context, containerID string) (string, error)
+	InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error)
+	PauseContainer(ctx context.Context, containerID string) error // Freezes the container's processes with the cgroup freezer
+	UnpauseContainer(ctx context.Context, containerID string) error
+	UpdateContainerResources(ctx context.Context, containerID string, resources *Resources) error
+	CommitContainer(ctx context.Context, containerID string, opts *CommitOptions) (string, error) // Returns the new image's ID
+
+	// Checkpoint operations, through Docker's experimental checkpoint API
+	// (needs CRIU on the host and the daemon's experimental features)
+	CheckpointContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error
+	RestoreContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error // Starts the container from a checkpoint
 
 	// Image operations
 	PullImage(ctx context.Context, image string, auth *AuthConfig) error
 	ImageExists(ctx context.Context, image string) (bool, error)
+	InspectImage(ctx context.Context, image string) (*ImageInfo, error)
+	ListImages(ctx context.Context) ([]string, error) // Tags of the local images
+	PushImage(ctx context.Context, image string, auth *AuthConfig) error
 
 	// Network operations
 	CreateNetwork(ctx context.Context, name string) (string, error)
@@ -27,12 +44,14 @@ type Client interface {
 
 	// Volume operations
 	EnsureVolume(ctx context.Context, name string) error
+	ListVolumes(ctx context.Context) ([]string, error) // Names of the volumes created by EnsureVolume
+	RemoveVolume(ctx context.Context, name string) error
 
 	// Logs
 	ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error)
 
 	// File operations
-	CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader) error
+	CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader, mode os.FileMode) error
 }
 
 // ContainerConfig holds Docker container configuration
@@ -54,6 +73,27 @@ type ContainerConfig struct {
 	ExtraHosts   []string
 }
 
+// Resources holds the resource limits of a running container; zero fields
+// are left unchanged
+type Resources struct {
+	Memory    int64 // Bytes
+	CPUShares int64
+}
+
+// CommitOptions holds the options for committing a container to an image
+type CommitOptions struct {
+	Reference string            // Repository and tag of the new image
+	Labels    map[string]string // Added to the labels the container's image already has
+	Comment   string
+}
+
+// CheckpointOptions identifies a checkpoint of a container's processes
+type CheckpointOptions struct {
+	ID   string
+	Dir  string // Host directory holding the checkpoint under its ID; Docker's own when empty
+	Exit bool   // Stop the container once it is checkpointed
+}
+
 // VolumeMount represents a volume mount
 type VolumeMount struct {
 	Type     string // "bind", "volume", "tmpfs"
@@ -76,6 +116,17 @@ type AuthConfig struct {
 	Registry string
 }
 
+// ImageInfo holds the parts of an image configuration the runner cares about
+type ImageInfo struct {
+	ID         string
+	Entrypoint []string
+	Cmd        []string
+	WorkingDir string
+	User       string
+	Env        []string
+	Labels     map[string]string
+}
+
 // ContainerInfo holds container information
 type ContainerInfo struct {
 	ID        string
@@ -88,6 +139,21 @@ type ContainerInfo struct {
 	CreatedAt string
 }
 
+// ContainerDetails holds the state of a single container, including why it
+// stopped
+type ContainerDetails struct {
+	ID         string
+	Name       string
+	State      string
+	ExitCode   int
+	OOMKilled  bool
+	Error      string // Error Docker reported when the container failed to run
+	FinishedAt string
+}
+
+// VolumeLabel marks the volumes created by EnsureVolume
+const VolumeLabel = "codepod.volume"
+
 // ContainerState represents container state
 type ContainerState string
 
@@ -4,20 +4,30 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"os"
+	"sort"
+	"strconv"
 	"time"
 
-	dockerimage "github.com/docker/docker/api/types/image"
+	"github.com/codepod/codepod/libs/go-common/logging"
+
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/checkpoint"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerimage "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	nat "github.com/docker/go-connections/nat"
 )
 
+var logger = logging.Component("docker")
+
 // RealClient is a real Docker client implementation
 type RealClient struct {
 	cli        *client.Client
@@ -40,9 +50,15 @@ func NewRealClient(dockerHost string) (*RealClient, error) {
 	}, nil
 }
 
+// Ping checks that the Docker daemon is reachable
+func (r *RealClient) Ping(ctx context.Context) error {
+	_, err := r.cli.Ping(ctx)
+	return err
+}
+
 // CreateContainer creates a Docker container
 func (r *RealClient) CreateContainer(ctx context.Context, config *ContainerConfig) (string, error) {
-	log.Printf("DEBUG: Creating container with ExtraHosts: %v", config.ExtraHosts)
+	logger.Debug("Creating container", "extra_hosts", config.ExtraHosts)
 
 	hostConfig := &container.HostConfig{
 		NetworkMode: container.NetworkMode(config.NetworkMode),
@@ -50,7 +66,7 @@ func (r *RealClient) CreateContainer(ctx context.Context, config *ContainerConfi
 
 	// Add volume mounts if specified
 	if len(config.Volumes) > 0 {
-		log.Printf("DEBUG: Adding %d volume mounts", len(config.Volumes))
+		logger.Debug("Adding volume mounts", "count", len(config.Volumes))
 		bindMounts := make([]mount.Mount, 0, len(config.Volumes))
 		for _, v := range config.Volumes {
 			bindMounts = append(bindMounts, mount.Mount{
@@ -61,13 +77,13 @@ func (r *RealClient) CreateContainer(ctx context.Context, config *ContainerConfi
 			})
 		}
 		hostConfig.Mounts = bindMounts
-		log.Printf("DEBUG: Added Mounts to hostConfig: %v", hostConfig.Mounts)
+		logger.Debug("Added mounts to host config", "mounts", hostConfig.Mounts)
 	}
 
 	// Add extra hosts if specified
 	if len(config.ExtraHosts) > 0 {
 		hostConfig.ExtraHosts = config.ExtraHosts
-		log.Printf("DEBUG: Added ExtraHosts to hostConfig: %v", hostConfig.ExtraHosts)
+		logger.Debug("Added extra hosts to host config", "extra_hosts", hostConfig.ExtraHosts)
 	}
 
 	// Set resource limits if specified
@@ -127,6 +143,79 @@ func (r *RealClient) StopContainer(ctx context.Context, containerID string, time
 	})
 }
 
+// PauseContainer pauses a Docker container
+func (r *RealClient) PauseContainer(ctx context.Context, containerID string) error {
+	return r.cli.ContainerPause(ctx, containerID)
+}
+
+// UnpauseContainer resumes a paused Docker container
+func (r *RealClient) UnpauseContainer(ctx context.Context, containerID string) error {
+	return r.cli.ContainerUnpause(ctx, containerID)
+}
+
+// UpdateContainerResources changes the resource limits of a container
+// without restarting it
+func (r *RealClient) UpdateContainerResources(ctx context.Context, containerID string, resources *Resources) error {
+	update := container.UpdateConfig{}
+	if resources.Memory > 0 {
+		// Docker refuses a memory limit above the swap limit, which defaults
+		// to twice the memory limit at creation
+		update.Memory = resources.Memory
+		update.MemorySwap = resources.Memory * 2
+	}
+	if resources.CPUShares > 0 {
+		update.CPUShares = resources.CPUShares
+	}
+	_, err := r.cli.ContainerUpdate(ctx, containerID, update)
+	return err
+}
+
+// CommitContainer commits a container's filesystem to a new image, pausing
+// the container while it is copied. The contents of mounted volumes are not
+// part of the image.
+func (r *RealClient) CommitContainer(ctx context.Context, containerID string, opts *CommitOptions) (string, error) {
+	// Sorted so the image's history is the same for the same labels
+	keys := make([]string, 0, len(opts.Labels))
+	for k := range opts.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	changes := make([]string, 0, len(keys))
+	for _, k := range keys {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%s", strconv.Quote(k), strconv.Quote(opts.Labels[k])))
+	}
+
+	resp, err := r.cli.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: opts.Reference,
+		Comment:   opts.Comment,
+		Changes:   changes,
+		Pause:     true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit container %s: %w", containerID, err)
+	}
+	return resp.ID, nil
+}
+
+// CheckpointContainer saves the state of a container's processes with CRIU
+func (r *RealClient) CheckpointContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	return r.cli.CheckpointCreate(ctx, containerID, checkpoint.CreateOptions{
+		CheckpointID:  opts.ID,
+		CheckpointDir: opts.Dir,
+		Exit:          opts.Exit,
+	})
+}
+
+// RestoreContainer starts a container from a checkpoint instead of running
+// its entrypoint. The container must have been created from the same
+// filesystem as the checkpointed one.
+func (r *RealClient) RestoreContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	return r.cli.ContainerStart(ctx, containerID, container.StartOptions{
+		CheckpointID:  opts.ID,
+		CheckpointDir: opts.Dir,
+	})
+}
+
 // RemoveContainer removes a Docker container
 func (r *RealClient) RemoveContainer(ctx context.Context, containerID string, force bool) error {
 	return r.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
@@ -189,9 +278,26 @@ func (r *RealClient) ContainerStatus(ctx context.Context, containerID string) (s
 	return info.State.Status, nil
 }
 
+// InspectContainer returns the state of a container
+func (r *RealClient) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	info, err := r.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	details := &ContainerDetails{ID: info.ID, Name: info.Name}
+	if info.State != nil {
+		details.State = info.State.Status
+		details.ExitCode = info.State.ExitCode
+		details.OOMKilled = info.State.OOMKilled
+		details.Error = info.State.Error
+		details.FinishedAt = info.State.FinishedAt
+	}
+	return details, nil
+}
+
 // PullImage pulls a Docker image
 func (r *RealClient) PullImage(ctx context.Context, image string, auth *AuthConfig) error {
-	log.Printf("Pulling Docker image: %s", image)
+	logger.Info("Pulling Docker image", "image", image)
 
 	// Check if image already exists
 	exists, err := r.ImageExists(ctx, image)
@@ -199,12 +305,16 @@ func (r *RealClient) PullImage(ctx context.Context, image string, auth *AuthConf
 		return fmt.Errorf("failed to check if image exists: %w", err)
 	}
 	if exists {
-		log.Printf("Image %s already exists", image)
+		logger.Info("Image already exists", "image", image)
 		return nil
 	}
 
 	// Pull the image
-	pullResp, err := r.cli.ImagePull(ctx, image, dockerimage.PullOptions{})
+	encodedAuth, err := encodeAuth(auth)
+	if err != nil {
+		return err
+	}
+	pullResp, err := r.cli.ImagePull(ctx, image, dockerimage.PullOptions{RegistryAuth: encodedAuth})
 	if err != nil {
 		return fmt.Errorf("failed to start image pull: %w", err)
 	}
@@ -216,10 +326,62 @@ func (r *RealClient) PullImage(ctx context.Context, image string, auth *AuthConf
 		return fmt.Errorf("failed to pull image: %w", err)
 	}
 
-	log.Printf("Successfully pulled image: %s", image)
+	logger.Info("Pulled image", "image", image)
+	return nil
+}
+
+// PushImage pushes a local image to its registry
+func (r *RealClient) PushImage(ctx context.Context, image string, auth *AuthConfig) error {
+	logger.Info("Pushing Docker image", "image", image)
+
+	// The daemon rejects pushes without an auth header, even to open registries
+	encodedAuth, err := encodeAuth(auth)
+	if err != nil {
+		return err
+	}
+
+	pushResp, err := r.cli.ImagePush(ctx, image, dockerimage.PushOptions{RegistryAuth: encodedAuth})
+	if err != nil {
+		return fmt.Errorf("failed to start image push: %w", err)
+	}
+	defer pushResp.Close()
+
+	// Push failures arrive as messages in the progress stream
+	decoder := json.NewDecoder(pushResp)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to push image: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to push image: %s", msg.Error)
+		}
+	}
+
+	logger.Info("Pushed image", "image", image)
 	return nil
 }
 
+// encodeAuth encodes registry credentials for the Docker API; nil encodes
+// anonymous access
+func encodeAuth(auth *AuthConfig) (string, error) {
+	authConfig := registry.AuthConfig{}
+	if auth != nil {
+		authConfig.Username = auth.Username
+		authConfig.Password = auth.Password
+		authConfig.ServerAddress = auth.Registry
+	}
+	encoded, err := registry.EncodeAuthConfig(authConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode registry auth: %w", err)
+	}
+	return encoded, nil
+}
+
 // ImageExists checks if image exists
 func (r *RealClient) ImageExists(ctx context.Context, image string) (bool, error) {
 	_, _, err := r.cli.ImageInspectWithRaw(ctx, image)
@@ -232,6 +394,44 @@ func (r *RealClient) ImageExists(ctx context.Context, image string) (bool, error
 	return true, nil
 }
 
+// InspectImage returns the configuration of a local image
+func (r *RealClient) InspectImage(ctx context.Context, image string) (*ImageInfo, error) {
+	info, _, err := r.cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+
+	result := &ImageInfo{ID: info.ID}
+	if info.Config != nil {
+		result.Entrypoint = info.Config.Entrypoint
+		result.Cmd = info.Config.Cmd
+		result.WorkingDir = info.Config.WorkingDir
+		result.User = info.Config.User
+		result.Env = info.Config.Env
+		result.Labels = info.Config.Labels
+	}
+	return result, nil
+}
+
+// ListImages returns the tags of the local images
+func (r *RealClient) ListImages(ctx context.Context) ([]string, error) {
+	images, err := r.cli.ImageList(ctx, dockerimage.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	var tags []string
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			// Untagged images are listed as <none>:<none>
+			if tag != "<none>:<none>" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags, nil
+}
+
 // CreateNetwork creates a network
 func (r *RealClient) CreateNetwork(ctx context.Context, name string) (string, error) {
 	resp, err := r.cli.NetworkCreate(ctx, name, types.NetworkCreate{
@@ -254,28 +454,50 @@ func (r *RealClient) EnsureVolume(ctx context.Context, name string) error {
 	volumes, err := r.cli.VolumeList(ctx, volume.ListOptions{})
 	if err != nil {
 		// If listing fails, try to create anyway
-		log.Printf("Warning: failed to list volumes: %v, trying to create", err)
+		logger.Warn("Failed to list volumes, trying to create", "error", err)
 	}
 
 	// Check if our volume exists in the list
 	for _, v := range volumes.Volumes {
 		if v.Name == name {
-			log.Printf("Volume %s already exists", name)
+			logger.Info("Volume already exists", "volume", name)
 			return nil
 		}
 	}
 
 	// Volume doesn't exist, create it
 	_, err = r.cli.VolumeCreate(ctx, volume.CreateOptions{
-		Name: name,
+		Name:   name,
+		Labels: map[string]string{VolumeLabel: name},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create volume %s: %w", name, err)
 	}
-	log.Printf("Created volume %s", name)
+	logger.Info("Created volume", "volume", name)
 	return nil
 }
 
+// ListVolumes returns the names of the volumes created by EnsureVolume
+func (r *RealClient) ListVolumes(ctx context.Context) ([]string, error) {
+	volumes, err := r.cli.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", VolumeLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var names []string
+	for _, v := range volumes.Volumes {
+		names = append(names, v.Name)
+	}
+	return names, nil
+}
+
+// RemoveVolume removes a volume; Docker refuses while a container uses it
+func (r *RealClient) RemoveVolume(ctx context.Context, name string) error {
+	return r.cli.VolumeRemove(ctx, name, false)
+}
+
 // ContainerLogs returns container logs
 func (r *RealClient) ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
 	logs, err := r.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
@@ -289,8 +511,8 @@ func (r *RealClient) ContainerLogs(ctx context.Context, containerID string, foll
 	return logs, nil
 }
 
-// CopyFileToContainer copies a file to the container
-func (r *RealClient) CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader) error {
+// CopyFileToContainer copies a file with the given permissions to the container
+func (r *RealClient) CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader, mode os.FileMode) error {
 	// Read all content from the reader
 	data, err := io.ReadAll(content)
 	if err != nil {
@@ -301,10 +523,9 @@ func (r *RealClient) CopyFileToContainer(ctx context.Context, containerID, destP
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
 
-	// Get file info (use default permissions)
 	hdr := &tar.Header{
 		Name:     destPath,
-		Mode:     0755,
+		Mode:     int64(mode.Perm()),
 		Size:     int64(len(data)),
 		Typeflag: tar.TypeReg,
 	}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,32 +15,58 @@ import (
 type MockClient struct {
 	mu          sync.RWMutex
 	containers  map[string]*mockContainer
-	images     map[string]bool
-	networks   map[string]string
-	nextID     int
+	images      map[string]bool
+	imageInfo   map[string]*ImageInfo
+	pushed      map[string]bool
+	checkpoints map[string]bool // Checkpoints in Docker's own directory, by container and checkpoint ID
+	networks    map[string]string
+	volumes     map[string]bool
+	nextID      int
 }
 
 // mockContainer represents a mock container
 type mockContainer struct {
-	config     *ContainerConfig
-	id         string
-	name       string
-	state      ContainerState
-	exitCode   int
-	createdAt  time.Time
-	startedAt  time.Time
+	config    *ContainerConfig
+	id        string
+	name      string
+	state     ContainerState
+	exitCode  int
+	oomKilled bool
+	createdAt time.Time
+	startedAt time.Time
+	files     map[string]os.FileMode // Copied files by path, with their permissions
+}
+
+// Config returns the configuration the mock container was created with (for testing)
+func (c *mockContainer) Config() *ContainerConfig {
+	return c.config
+}
+
+// FileMode returns the permissions of a file copied into the mock container (for testing)
+func (c *mockContainer) FileMode(path string) (os.FileMode, bool) {
+	mode, ok := c.files[path]
+	return mode, ok
 }
 
 // NewMockClient creates a new mock Docker client
 func NewMockClient() *MockClient {
 	return &MockClient{
-		containers: make(map[string]*mockContainer),
-		images:     make(map[string]bool),
-		networks:   make(map[string]string),
-		nextID:     1,
+		containers:  make(map[string]*mockContainer),
+		images:      make(map[string]bool),
+		imageInfo:   make(map[string]*ImageInfo),
+		pushed:      make(map[string]bool),
+		checkpoints: make(map[string]bool),
+		networks:    make(map[string]string),
+		volumes:     make(map[string]bool),
+		nextID:      1,
 	}
 }
 
+// Ping always succeeds for the mock client
+func (m *MockClient) Ping(ctx context.Context) error {
+	return nil
+}
+
 // CreateContainer creates a mock container
 func (m *MockClient) CreateContainer(ctx context.Context, config *ContainerConfig) (string, error) {
 	m.mu.Lock()
@@ -53,6 +81,7 @@ func (m *MockClient) CreateContainer(ctx context.Context, config *ContainerConfi
 		name:      config.Name,
 		state:     ContainerStateCreated,
 		createdAt: time.Now(),
+		files:     make(map[string]os.FileMode),
 	}
 
 	return id, nil
@@ -87,6 +116,156 @@ func (m *MockClient) StopContainer(ctx context.Context, containerID string, time
 	return nil
 }
 
+// PauseContainer pauses a running mock container
+func (m *MockClient) PauseContainer(ctx context.Context, containerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+	if c.state != ContainerStateRunning {
+		return &Error{Code: "CONFLICT", Message: "Container is not running"}
+	}
+
+	c.state = ContainerStatePaused
+	return nil
+}
+
+// UnpauseContainer resumes a paused mock container
+func (m *MockClient) UnpauseContainer(ctx context.Context, containerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+	if c.state != ContainerStatePaused {
+		return &Error{Code: "CONFLICT", Message: "Container is not paused"}
+	}
+
+	c.state = ContainerStateRunning
+	return nil
+}
+
+// UpdateContainerResources updates the limits in a mock container's config
+func (m *MockClient) UpdateContainerResources(ctx context.Context, containerID string, resources *Resources) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+
+	if resources.Memory > 0 {
+		c.config.Memory = resources.Memory
+	}
+	if resources.CPUShares > 0 {
+		c.config.CPUShares = resources.CPUShares
+	}
+	return nil
+}
+
+// CommitContainer records a mock image that runs what the container runs,
+// with the container's labels and the given ones
+func (m *MockClient) CommitContainer(ctx context.Context, containerID string, opts *CommitOptions) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return "", &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+
+	id := fmt.Sprintf("sha256:image-%d", m.nextID)
+	m.nextID++
+
+	labels := make(map[string]string, len(c.config.Labels)+len(opts.Labels))
+	for k, v := range c.config.Labels {
+		labels[k] = v
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	info := &ImageInfo{
+		ID:         id,
+		Entrypoint: c.config.Entrypoint,
+		Cmd:        c.config.Cmd,
+		Env:        c.config.Env,
+		Labels:     labels,
+	}
+	m.images[id] = true
+	m.imageInfo[id] = info
+	if opts.Reference != "" {
+		m.images[opts.Reference] = true
+		m.imageInfo[opts.Reference] = info
+	}
+	return id, nil
+}
+
+// CheckpointContainer records a checkpoint of a running mock container. With
+// a directory, the checkpoint is written to disk under its ID like Docker's.
+func (m *MockClient) CheckpointContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+	if c.state != ContainerStateRunning {
+		return &Error{Code: "CONFLICT", Message: "Container is not running"}
+	}
+
+	if opts.Dir != "" {
+		dir := filepath.Join(opts.Dir, opts.ID)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		data := fmt.Sprintf("{\"container\":%q,\"checkpoint\":%q}\n", c.name, opts.ID)
+		if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0600); err != nil {
+			return err
+		}
+	} else {
+		m.checkpoints[containerID+"/"+opts.ID] = true
+	}
+
+	if opts.Exit {
+		c.state = ContainerStateExited
+	}
+	return nil
+}
+
+// RestoreContainer starts a mock container from a checkpoint taken by
+// CheckpointContainer
+func (m *MockClient) RestoreContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+	if c.state == ContainerStateRunning || c.state == ContainerStatePaused {
+		return &Error{Code: "CONFLICT", Message: "Container is already running"}
+	}
+
+	if opts.Dir != "" {
+		if _, err := os.Stat(filepath.Join(opts.Dir, opts.ID, "config.json")); err != nil {
+			return &Error{Code: "NOT_FOUND", Message: "Checkpoint not found"}
+		}
+	} else if !m.checkpoints[containerID+"/"+opts.ID] {
+		return &Error{Code: "NOT_FOUND", Message: "Checkpoint not found"}
+	}
+
+	c.state = ContainerStateRunning
+	c.startedAt = time.Now()
+	return nil
+}
+
 // RemoveContainer removes a mock container
 func (m *MockClient) RemoveContainer(ctx context.Context, containerID string, force bool) error {
 	m.mu.Lock()
@@ -142,6 +321,25 @@ func (m *MockClient) ContainerStatus(ctx context.Context, containerID string) (s
 	return string(c.state), nil
 }
 
+// InspectContainer returns the state of a mock container
+func (m *MockClient) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.containers[containerID]
+	if !ok {
+		return nil, &Error{Code: "NOT_FOUND", Message: "Container not found"}
+	}
+
+	return &ContainerDetails{
+		ID:        c.id,
+		Name:      "/" + c.name,
+		State:     string(c.state),
+		ExitCode:  c.exitCode,
+		OOMKilled: c.oomKilled,
+	}, nil
+}
+
 // PullImage simulates pulling a mock image
 func (m *MockClient) PullImage(ctx context.Context, image string, auth *AuthConfig) error {
 	m.mu.Lock()
@@ -159,6 +357,62 @@ func (m *MockClient) ImageExists(ctx context.Context, image string) (bool, error
 	return m.images[image], nil
 }
 
+// InspectImage returns the configuration of a mock image
+func (m *MockClient) InspectImage(ctx context.Context, image string) (*ImageInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.images[image] {
+		return nil, &Error{Code: "NOT_FOUND", Message: "Image not found"}
+	}
+	if info, ok := m.imageInfo[image]; ok {
+		return info, nil
+	}
+	return &ImageInfo{ID: image}, nil
+}
+
+// ListImages returns the mock images
+func (m *MockClient) ListImages(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var images []string
+	for image := range m.images {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// PushImage records a push of a mock image
+func (m *MockClient) PushImage(ctx context.Context, image string, auth *AuthConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.images[image] {
+		return &Error{Code: "NOT_FOUND", Message: "Image not found"}
+	}
+	m.pushed[image] = true
+	return nil
+}
+
+// Pushed reports whether a mock image was pushed (for testing)
+func (m *MockClient) Pushed(image string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.pushed[image]
+}
+
+// SetImageInfo registers a mock image with the given configuration (for testing)
+func (m *MockClient) SetImageInfo(image string, info *ImageInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.images[image] = true
+	m.imageInfo[image] = info
+}
+
 // CreateNetwork creates a mock network
 func (m *MockClient) CreateNetwork(ctx context.Context, name string) (string, error) {
 	m.mu.Lock()
@@ -202,15 +456,16 @@ func (m *MockClient) ContainerLogs(ctx context.Context, containerID string, foll
 }
 
 // CopyFileToContainer copies a file to the container
-func (m *MockClient) CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *MockClient) CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	_, ok := m.containers[containerID]
+	c, ok := m.containers[containerID]
 	if !ok {
 		return &Error{Code: "NOT_FOUND", Message: "Container not found"}
 	}
 
+	c.files[destPath] = mode
 	return nil
 }
 
@@ -256,6 +511,43 @@ func (m *MockClient) EnsureVolume(ctx context.Context, name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	log.Printf("Mock: EnsureVolume %s", name)
+	logger.Debug("Mock: EnsureVolume", "volume", name)
+	m.volumes[name] = true
 	return nil
 }
+
+// ListVolumes returns the names of mock volumes
+func (m *MockClient) ListVolumes(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for name := range m.volumes {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// RemoveVolume removes a mock volume
+func (m *MockClient) RemoveVolume(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.volumes[name] {
+		return &Error{Code: "NOT_FOUND", Message: "Volume not found"}
+	}
+	delete(m.volumes, name)
+	return nil
+}
+
+// SetContainerExit stops a mock container with the given exit code (for testing)
+func (m *MockClient) SetContainerExit(id string, exitCode int, oomKilled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.containers[id]; ok {
+		c.state = ContainerStateExited
+		c.exitCode = exitCode
+		c.oomKilled = oomKilled
+	}
+}
@@ -3,6 +3,8 @@ package docker
 import (
 	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -96,6 +98,49 @@ func TestMockClient_StopContainer(t *testing.T) {
 	}
 }
 
+func TestMockClient_PauseContainer(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	id, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "python:3.11", Name: "test"})
+	if err := client.PauseContainer(ctx, id); err == nil {
+		t.Error("expected error pausing a container that is not running")
+	}
+
+	client.StartContainer(ctx, id)
+	if err := client.PauseContainer(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := client.GetContainer(id); c.state != ContainerStatePaused {
+		t.Errorf("expected state paused, got %s", c.state)
+	}
+
+	if err := client.UnpauseContainer(ctx, id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := client.GetContainer(id); c.state != ContainerStateRunning {
+		t.Errorf("expected state running, got %s", c.state)
+	}
+}
+
+func TestMockClient_UpdateContainerResources(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	id, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "python:3.11", Name: "test", Memory: 512, CPUShares: 1024})
+	if err := client.UpdateContainerResources(ctx, id, &Resources{Memory: 1024}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config := client.GetContainer(id).Config()
+	if config.Memory != 1024 || config.CPUShares != 1024 {
+		t.Errorf("expected memory 1024 and unchanged CPU shares, got %d and %d", config.Memory, config.CPUShares)
+	}
+	if err := client.UpdateContainerResources(ctx, "missing", &Resources{Memory: 1024}); err == nil {
+		t.Error("expected error for a missing container")
+	}
+}
+
 func TestMockClient_RemoveContainer(t *testing.T) {
 	client := NewMockClient()
 	ctx := context.Background()
@@ -204,6 +249,39 @@ func TestMockClient_PullImage(t *testing.T) {
 	if !exists {
 		t.Error("expected image to exist")
 	}
+
+	images, err := client.ListImages(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images) != 1 || images[0] != "python:3.11" {
+		t.Errorf("expected [python:3.11], got %v", images)
+	}
+}
+
+func TestMockClient_InspectImage(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	if _, err := client.InspectImage(ctx, "postgres:16"); !IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	client.SetImageInfo("postgres:16", &ImageInfo{
+		Entrypoint: []string{"docker-entrypoint.sh"},
+		Cmd:        []string{"postgres"},
+	})
+
+	info, err := client.InspectImage(ctx, "postgres:16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(info.Entrypoint) != 1 || info.Entrypoint[0] != "docker-entrypoint.sh" {
+		t.Errorf("expected entrypoint docker-entrypoint.sh, got %v", info.Entrypoint)
+	}
+	if len(info.Cmd) != 1 || info.Cmd[0] != "postgres" {
+		t.Errorf("expected cmd postgres, got %v", info.Cmd)
+	}
 }
 
 func TestMockClient_CreateNetwork(t *testing.T) {
@@ -257,6 +335,161 @@ func TestMockClient_SetContainerState(t *testing.T) {
 	}
 }
 
+func TestMockClient_InspectContainer(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	config := &ContainerConfig{Image: "img", Name: "test"}
+	id, _ := client.CreateContainer(ctx, config)
+	client.SetContainerExit(id, 137, true)
+
+	details, err := client.InspectContainer(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.State != "exited" || details.ExitCode != 137 || !details.OOMKilled {
+		t.Errorf("unexpected details: %+v", details)
+	}
+
+	if _, err := client.InspectContainer(ctx, "nonexistent"); err == nil {
+		t.Error("expected error for nonexistent container")
+	}
+}
+
+func TestMockClient_CommitAndPush(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	config := &ContainerConfig{
+		Image:      "img",
+		Name:       "test",
+		Entrypoint: []string{"/agent", "start"},
+		Labels:     map[string]string{"codepod.sandbox": "test"},
+	}
+	id, _ := client.CreateContainer(ctx, config)
+
+	if err := client.PushImage(ctx, "registry:5000/snap:1", nil); err == nil {
+		t.Error("expected error pushing an image that does not exist")
+	}
+
+	imageID, err := client.CommitContainer(ctx, id, &CommitOptions{
+		Reference: "registry:5000/snap:1",
+		Labels:    map[string]string{"codepod.snapshot": "1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := client.InspectImage(ctx, "registry:5000/snap:1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.ID != imageID || info.Entrypoint[0] != "/agent" {
+		t.Errorf("unexpected image: %+v", info)
+	}
+	if info.Labels["codepod.snapshot"] != "1" || info.Labels["codepod.sandbox"] != "test" {
+		t.Errorf("unexpected labels: %v", info.Labels)
+	}
+
+	if err := client.PushImage(ctx, "registry:5000/snap:1", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !client.Pushed("registry:5000/snap:1") {
+		t.Error("expected image to be pushed")
+	}
+
+	if _, err := client.CommitContainer(ctx, "nonexistent", &CommitOptions{}); err == nil {
+		t.Error("expected error for nonexistent container")
+	}
+}
+
+func TestMockClient_CheckpointAndRestore(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	id, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "img", Name: "test"})
+	opts := &CheckpointOptions{ID: "cp-1", Dir: dir, Exit: true}
+
+	if err := client.CheckpointContainer(ctx, id, opts); err == nil {
+		t.Error("expected error checkpointing a container that is not running")
+	}
+
+	client.StartContainer(ctx, id)
+	if err := client.CheckpointContainer(ctx, id, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := client.GetContainer(id); c.state != ContainerStateExited {
+		t.Errorf("expected exited after checkpoint, got %s", c.state)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cp-1", "config.json")); err != nil {
+		t.Errorf("expected checkpoint on disk: %v", err)
+	}
+
+	// A new container restores from the checkpoint directory
+	restored, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "img", Name: "restored"})
+	if err := client.RestoreContainer(ctx, restored, &CheckpointOptions{ID: "cp-2", Dir: dir}); err == nil {
+		t.Error("expected error for a missing checkpoint")
+	}
+	if err := client.RestoreContainer(ctx, restored, &CheckpointOptions{ID: "cp-1", Dir: dir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := client.GetContainer(restored); c.state != ContainerStateRunning {
+		t.Errorf("expected running after restore, got %s", c.state)
+	}
+	if err := client.RestoreContainer(ctx, restored, &CheckpointOptions{ID: "cp-1", Dir: dir}); err == nil {
+		t.Error("expected error restoring a running container")
+	}
+}
+
+func TestMockClient_CheckpointDefaultDir(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	id, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "img", Name: "test"})
+	client.StartContainer(ctx, id)
+
+	// Without Exit the container keeps running
+	if err := client.CheckpointContainer(ctx, id, &CheckpointOptions{ID: "cp-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := client.GetContainer(id); c.state != ContainerStateRunning {
+		t.Errorf("expected running after checkpoint, got %s", c.state)
+	}
+
+	client.StopContainer(ctx, id, 10)
+	if err := client.RestoreContainer(ctx, id, &CheckpointOptions{ID: "cp-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	other, _ := client.CreateContainer(ctx, &ContainerConfig{Image: "img", Name: "other"})
+	if err := client.RestoreContainer(ctx, other, &CheckpointOptions{ID: "cp-1"}); err == nil {
+		t.Error("expected error restoring another container's checkpoint")
+	}
+}
+
+func TestMockClient_Volumes(t *testing.T) {
+	client := NewMockClient()
+	ctx := context.Background()
+
+	if err := client.EnsureVolume(ctx, "vol-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names, err := client.ListVolumes(ctx)
+	if err != nil || len(names) != 1 || names[0] != "vol-1" {
+		t.Fatalf("expected [vol-1], got %v (%v)", names, err)
+	}
+
+	if err := client.RemoveVolume(ctx, "vol-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names, _ := client.ListVolumes(ctx); len(names) != 0 {
+		t.Errorf("expected no volumes, got %v", names)
+	}
+	if err := client.RemoveVolume(ctx, "vol-1"); err == nil {
+		t.Error("expected error removing a missing volume")
+	}
+}
+
 func TestMockClient_ConcurrentAccess(t *testing.T) {
 	client := NewMockClient()
 	ctx := context.Background()
@@ -0,0 +1,219 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/codepod/codepod/libs/go-common/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/codepod/codepod/sandbox/runner/pkg/docker")
+
+// TracedClient records a span for every Docker call of the wrapped client
+type TracedClient struct {
+	client Client
+}
+
+// NewTracedClient wraps client so its calls show up in job traces
+func NewTracedClient(client Client) *TracedClient {
+	return &TracedClient{client: client}
+}
+
+// start starts a client span for a Docker operation
+func (t *TracedClient) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "docker."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (t *TracedClient) Ping(ctx context.Context) error {
+	ctx, span := t.start(ctx, "Ping")
+	err := t.client.Ping(ctx)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) CreateContainer(ctx context.Context, config *ContainerConfig) (string, error) {
+	ctx, span := t.start(ctx, "CreateContainer", attribute.String("container.name", config.Name), attribute.String("container.image.name", config.Image))
+	id, err := t.client.CreateContainer(ctx, config)
+	span.SetAttributes(attribute.String("container.id", id))
+	tracing.End(span, err)
+	return id, err
+}
+
+func (t *TracedClient) StartContainer(ctx context.Context, containerID string) error {
+	ctx, span := t.start(ctx, "StartContainer", attribute.String("container.id", containerID))
+	err := t.client.StartContainer(ctx, containerID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) StopContainer(ctx context.Context, containerID string, timeout int) error {
+	ctx, span := t.start(ctx, "StopContainer", attribute.String("container.id", containerID))
+	err := t.client.StopContainer(ctx, containerID, timeout)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) PauseContainer(ctx context.Context, containerID string) error {
+	ctx, span := t.start(ctx, "PauseContainer", attribute.String("container.id", containerID))
+	err := t.client.PauseContainer(ctx, containerID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) UnpauseContainer(ctx context.Context, containerID string) error {
+	ctx, span := t.start(ctx, "UnpauseContainer", attribute.String("container.id", containerID))
+	err := t.client.UnpauseContainer(ctx, containerID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) UpdateContainerResources(ctx context.Context, containerID string, resources *Resources) error {
+	ctx, span := t.start(ctx, "UpdateContainerResources", attribute.String("container.id", containerID))
+	err := t.client.UpdateContainerResources(ctx, containerID, resources)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) CommitContainer(ctx context.Context, containerID string, opts *CommitOptions) (string, error) {
+	ctx, span := t.start(ctx, "CommitContainer",
+		attribute.String("container.id", containerID),
+		attribute.String("container.image.name", opts.Reference))
+	id, err := t.client.CommitContainer(ctx, containerID, opts)
+	tracing.End(span, err)
+	return id, err
+}
+
+func (t *TracedClient) CheckpointContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	ctx, span := t.start(ctx, "CheckpointContainer",
+		attribute.String("container.id", containerID),
+		attribute.String("checkpoint.id", opts.ID))
+	err := t.client.CheckpointContainer(ctx, containerID, opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) RestoreContainer(ctx context.Context, containerID string, opts *CheckpointOptions) error {
+	ctx, span := t.start(ctx, "RestoreContainer",
+		attribute.String("container.id", containerID),
+		attribute.String("checkpoint.id", opts.ID))
+	err := t.client.RestoreContainer(ctx, containerID, opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) RemoveContainer(ctx context.Context, containerID string, force bool) error {
+	ctx, span := t.start(ctx, "RemoveContainer", attribute.String("container.id", containerID))
+	err := t.client.RemoveContainer(ctx, containerID, force)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) ListContainers(ctx context.Context, all bool) ([]ContainerInfo, error) {
+	ctx, span := t.start(ctx, "ListContainers")
+	containers, err := t.client.ListContainers(ctx, all)
+	tracing.End(span, err)
+	return containers, err
+}
+
+func (t *TracedClient) ContainerStatus(ctx context.Context, containerID string) (string, error) {
+	ctx, span := t.start(ctx, "ContainerStatus", attribute.String("container.id", containerID))
+	status, err := t.client.ContainerStatus(ctx, containerID)
+	tracing.End(span, err)
+	return status, err
+}
+
+func (t *TracedClient) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	ctx, span := t.start(ctx, "InspectContainer", attribute.String("container.id", containerID))
+	details, err := t.client.InspectContainer(ctx, containerID)
+	tracing.End(span, err)
+	return details, err
+}
+
+func (t *TracedClient) PullImage(ctx context.Context, image string, auth *AuthConfig) error {
+	ctx, span := t.start(ctx, "PullImage", attribute.String("container.image.name", image))
+	err := t.client.PullImage(ctx, image, auth)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) ImageExists(ctx context.Context, image string) (bool, error) {
+	ctx, span := t.start(ctx, "ImageExists", attribute.String("container.image.name", image))
+	exists, err := t.client.ImageExists(ctx, image)
+	tracing.End(span, err)
+	return exists, err
+}
+
+func (t *TracedClient) InspectImage(ctx context.Context, image string) (*ImageInfo, error) {
+	ctx, span := t.start(ctx, "InspectImage", attribute.String("container.image.name", image))
+	info, err := t.client.InspectImage(ctx, image)
+	tracing.End(span, err)
+	return info, err
+}
+
+func (t *TracedClient) ListImages(ctx context.Context) ([]string, error) {
+	ctx, span := t.start(ctx, "ListImages")
+	images, err := t.client.ListImages(ctx)
+	tracing.End(span, err)
+	return images, err
+}
+
+func (t *TracedClient) PushImage(ctx context.Context, image string, auth *AuthConfig) error {
+	ctx, span := t.start(ctx, "PushImage", attribute.String("container.image.name", image))
+	err := t.client.PushImage(ctx, image, auth)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) CreateNetwork(ctx context.Context, name string) (string, error) {
+	ctx, span := t.start(ctx, "CreateNetwork", attribute.String("network.name", name))
+	id, err := t.client.CreateNetwork(ctx, name)
+	tracing.End(span, err)
+	return id, err
+}
+
+func (t *TracedClient) RemoveNetwork(ctx context.Context, networkID string) error {
+	ctx, span := t.start(ctx, "RemoveNetwork", attribute.String("network.id", networkID))
+	err := t.client.RemoveNetwork(ctx, networkID)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) EnsureVolume(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "EnsureVolume", attribute.String("volume.name", name))
+	err := t.client.EnsureVolume(ctx, name)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracedClient) ListVolumes(ctx context.Context) ([]string, error) {
+	ctx, span := t.start(ctx, "ListVolumes")
+	names, err := t.client.ListVolumes(ctx)
+	tracing.End(span, err)
+	return names, err
+}
+
+func (t *TracedClient) RemoveVolume(ctx context.Context, name string) error {
+	ctx, span := t.start(ctx, "RemoveVolume", attribute.String("volume.name", name))
+	err := t.client.RemoveVolume(ctx, name)
+	tracing.End(span, err)
+	return err
+}
+
+// ContainerLogs is traced until the log stream is opened, not while it is read
+func (t *TracedClient) ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	ctx, span := t.start(ctx, "ContainerLogs", attribute.String("container.id", containerID))
+	logs, err := t.client.ContainerLogs(ctx, containerID, follow)
+	tracing.End(span, err)
+	return logs, err
+}
+
+func (t *TracedClient) CopyFileToContainer(ctx context.Context, containerID, destPath string, content io.Reader, mode os.FileMode) error {
+	ctx, span := t.start(ctx, "CopyFileToContainer", attribute.String("container.id", containerID), attribute.String("file.path", destPath))
+	err := t.client.CopyFileToContainer(ctx, containerID, destPath, content, mode)
+	tracing.End(span, err)
+	return err
+}
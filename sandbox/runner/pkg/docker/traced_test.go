@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedClient(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	client := NewTracedClient(NewMockClient())
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "runner.job")
+
+	id, err := client.CreateContainer(ctx, &ContainerConfig{Image: "python:3.11", Name: "traced"})
+	if err != nil {
+		t.Fatalf("CreateContainer failed: %v", err)
+	}
+	if err := client.StartContainer(ctx, "missing"); err == nil {
+		t.Fatal("expected error starting a missing container")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	create, start := spans[0], spans[1]
+	if create.Name() != "docker.CreateContainer" || create.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected docker.CreateContainer child of the job span, got %s", create.Name())
+	}
+	found := false
+	for _, attr := range create.Attributes() {
+		if attr.Key == "container.id" && attr.Value.AsString() == id {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected container.id attribute %s", id)
+	}
+	if start.Name() != "docker.StartContainer" || start.Status().Code != codes.Error {
+		t.Errorf("expected failed docker.StartContainer span, got %s %v", start.Name(), start.Status())
+	}
+}
@@ -0,0 +1,107 @@
+// Package hostinfo reads the host resources a runner reports in heartbeats
+package hostinfo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Resources is a snapshot of the host's capacity and usage
+type Resources struct {
+	CPUs            int     `json:"cpus"`
+	Load1           float64 `json:"load1"`           // One-minute load average
+	MemoryTotal     uint64  `json:"memoryTotal"`     // Bytes
+	MemoryAvailable uint64  `json:"memoryAvailable"` // Bytes
+	DiskTotal       uint64  `json:"diskTotal"`       // Bytes on the filesystem holding the disk path
+	DiskFree        uint64  `json:"diskFree"`        // Bytes available to unprivileged users
+}
+
+// Reader reads resources from /proc and the filesystem holding diskPath
+type Reader struct {
+	procRoot string
+	diskPath string
+}
+
+// New creates a reader reporting disk usage for the filesystem holding diskPath
+func New(diskPath string) *Reader {
+	return &Reader{procRoot: "/proc", diskPath: diskPath}
+}
+
+// Read returns the current resources
+func (r *Reader) Read() (*Resources, error) {
+	res := &Resources{CPUs: runtime.NumCPU()}
+
+	load, err := r.readLoad()
+	if err != nil {
+		return nil, err
+	}
+	res.Load1 = load
+
+	res.MemoryTotal, res.MemoryAvailable, err = r.readMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(r.diskPath, &fs); err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", r.diskPath, err)
+	}
+	res.DiskTotal = fs.Blocks * uint64(fs.Bsize)
+	res.DiskFree = fs.Bavail * uint64(fs.Bsize)
+	return res, nil
+}
+
+// readLoad returns the one-minute load average from /proc/loadavg
+func (r *Reader) readLoad() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, "loadavg"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read load average: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty load average")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid load average %q: %w", fields[0], err)
+	}
+	return load, nil
+}
+
+// readMemory returns MemTotal and MemAvailable from /proc/meminfo in bytes
+func (r *Reader) readMemory() (total, available uint64, err error) {
+	f, err := os.Open(filepath.Join(r.procRoot, "meminfo"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory info: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:       16316412 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory info: %w", err)
+	}
+	return total, available, nil
+}
@@ -0,0 +1,46 @@
+package hostinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "loadavg"), []byte("1.25 0.80 0.40 2/512 12345\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         2000000 kB\nMemAvailable:    8000000 kB\n"
+	if err := os.WriteFile(filepath.Join(root, "meminfo"), []byte(meminfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(root)
+	r.procRoot = root
+	res, err := r.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.CPUs < 1 {
+		t.Errorf("expected at least one CPU, got %d", res.CPUs)
+	}
+	if res.Load1 != 1.25 {
+		t.Errorf("expected load 1.25, got %v", res.Load1)
+	}
+	if res.MemoryTotal != 16000000*1024 || res.MemoryAvailable != 8000000*1024 {
+		t.Errorf("unexpected memory: total %d, available %d", res.MemoryTotal, res.MemoryAvailable)
+	}
+	if res.DiskTotal == 0 || res.DiskFree > res.DiskTotal {
+		t.Errorf("unexpected disk: total %d, free %d", res.DiskTotal, res.DiskFree)
+	}
+}
+
+func TestReadMissingProc(t *testing.T) {
+	r := New(t.TempDir())
+	r.procRoot = t.TempDir()
+	if _, err := r.Read(); err == nil {
+		t.Error("expected error without /proc files")
+	}
+}
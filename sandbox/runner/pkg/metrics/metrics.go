@@ -0,0 +1,167 @@
+// Package metrics defines the runner's Prometheus metrics and serves them on
+// an optional HTTP listener
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/codepod/codepod/libs/go-common/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var logger = logging.Component("metrics")
+
+// Path is where metrics are served
+const Path = "/metrics"
+
+// Container operations timed by ContainerDuration
+const (
+	OperationCreate = "create"
+	OperationStart  = "start"
+)
+
+// scrapeTimeout bounds how long counting sandboxes may delay a scrape
+const scrapeTimeout = 5 * time.Second
+
+// Registry holds the runner's metrics along with Go runtime and process
+// metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// JobsPolled counts jobs received from the server by type
+	JobsPolled = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_runner_jobs_polled_total",
+		Help: "Jobs received from the server, by type.",
+	}, []string{"type"})
+
+	// JobsAccepted counts jobs the runner accepted by type
+	JobsAccepted = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_runner_jobs_accepted_total",
+		Help: "Jobs accepted by the runner, by type.",
+	}, []string{"type"})
+
+	// JobsFailed counts jobs that failed by type
+	JobsFailed = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_runner_jobs_failed_total",
+		Help: "Jobs that failed, by type.",
+	}, []string{"type"})
+
+	// ReconcileActions counts the corrections made by the reconciler by action
+	ReconcileActions = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "codepod_runner_reconcile_actions_total",
+		Help: "State reports and garbage collection done by the reconciler, by action.",
+	}, []string{"action"})
+
+	// ContainerDuration observes container create and start latency
+	ContainerDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "codepod_runner_container_operation_duration_seconds",
+		Help:    "Container operation latency, by operation.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	// ImagePullDuration observes how long image pulls took
+	ImagePullDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Name:    "codepod_runner_image_pull_duration_seconds",
+		Help:    "Image pull duration, including images already present.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600},
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveSince records the time elapsed since start on a histogram
+func ObserveSince(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+// StateCounter counts sandboxes by state
+type StateCounter func(ctx context.Context) (map[string]int, error)
+
+// sandboxCollector reports the number of sandboxes per state, counted when
+// metrics are scraped
+type sandboxCollector struct {
+	count StateCounter
+	desc  *prometheus.Desc
+}
+
+// RegisterSandboxStates reports codepod_runner_sandboxes by state, as counted
+// by count at scrape time
+func RegisterSandboxStates(count StateCounter) error {
+	return Registry.Register(&sandboxCollector{
+		count: count,
+		desc: prometheus.NewDesc(
+			"codepod_runner_sandboxes",
+			"Sandboxes managed by the runner, by state.",
+			[]string{"state"}, nil,
+		),
+	})
+}
+
+func (c *sandboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *sandboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, fmt.Errorf("failed to count sandboxes: %w", err))
+		return
+	}
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), state)
+	}
+}
+
+// RegisterActiveJobs reports codepod_runner_jobs_active, the number of jobs
+// running or queued behind another job for the same sandbox, as returned by
+// active at scrape time
+func RegisterActiveJobs(active func() int) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "codepod_runner_jobs_active",
+		Help: "Jobs running or queued on the runner.",
+	}, func() float64 { return float64(active()) }))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve serves metrics at Path on lis until ctx is cancelled
+func Serve(ctx context.Context, lis net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	logger.Info("Metrics listening", "addr", lis.Addr().String(), "path", Path)
+	if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
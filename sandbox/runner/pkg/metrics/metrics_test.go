@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSandboxCollector(t *testing.T) {
+	collector := &sandboxCollector{
+		count: func(ctx context.Context) (map[string]int, error) {
+			return map[string]int{"running": 2, "exited": 1}, nil
+		},
+		desc: prometheus.NewDesc("codepod_runner_sandboxes", "Sandboxes managed by the runner, by state.", []string{"state"}, nil),
+	}
+
+	expected := `
+# HELP codepod_runner_sandboxes Sandboxes managed by the runner, by state.
+# TYPE codepod_runner_sandboxes gauge
+codepod_runner_sandboxes{state="exited"} 1
+codepod_runner_sandboxes{state="running"} 2
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSandboxCollectorError(t *testing.T) {
+	collector := &sandboxCollector{
+		count: func(ctx context.Context) (map[string]int, error) {
+			return nil, errors.New("docker unavailable")
+		},
+		desc: prometheus.NewDesc("codepod_runner_sandboxes", "help", []string{"state"}, nil),
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	if _, err := registry.Gather(); err == nil {
+		t.Error("expected gather to report the counting error")
+	}
+}
+
+func TestRegisterActiveJobs(t *testing.T) {
+	if err := RegisterActiveJobs(func() int { return 3 }); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	expected := `
+# HELP codepod_runner_jobs_active Jobs running or queued on the runner.
+# TYPE codepod_runner_jobs_active gauge
+codepod_runner_jobs_active 3
+`
+	if err := testutil.GatherAndCompare(Registry, strings.NewReader(expected), "codepod_runner_jobs_active"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObserveSince(t *testing.T) {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds"})
+	ObserveSince(histogram, time.Now().Add(-time.Second))
+
+	if n := testutil.CollectAndCount(histogram); n != 1 {
+		t.Errorf("expected 1 histogram, got %d", n)
+	}
+}
+
+func TestServe(t *testing.T) {
+	JobsPolled.WithLabelValues("create").Inc()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, lis) }()
+
+	resp, err := http.Get("http://" + lis.Addr().String() + Path)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `codepod_runner_jobs_polled_total{type="create"}`) {
+		t.Errorf("expected job metrics, got %d %q", resp.StatusCode, body)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package placement decides whether a job may run on a runner, based on the
+// runner's labels and taints and the job's constraints
+package placement
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Constraints restrict the runners a job may run on
+type Constraints struct {
+	// NodeSelector lists labels the runner must have. An empty value only
+	// requires the label to be present.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations lists the taints the job accepts. An empty value tolerates
+	// the taint whatever its value.
+	Tolerations map[string]string `json:"tolerations,omitempty"`
+}
+
+// Check reports why a runner with labels and taints cannot take a job with
+// constraints c, or nil when it can. A nil c matches untainted runners.
+func Check(c *Constraints, labels, taints map[string]string) error {
+	var selector, tolerations map[string]string
+	if c != nil {
+		selector, tolerations = c.NodeSelector, c.Tolerations
+	}
+
+	for _, key := range sortedKeys(selector) {
+		want := selector[key]
+		got, ok := labels[key]
+		switch {
+		case !ok:
+			return fmt.Errorf("runner has no label %q", key)
+		case want != "" && got != want:
+			return fmt.Errorf("runner label %s=%s does not match %s=%s", key, got, key, want)
+		}
+	}
+
+	for _, key := range sortedKeys(taints) {
+		tolerated, ok := tolerations[key]
+		if !ok || (tolerated != "" && tolerated != taints[key]) {
+			return fmt.Errorf("job does not tolerate taint %s=%s", key, taints[key])
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order, so the first mismatch reported
+// is stable
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package placement
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	labels := map[string]string{"zone": "eu-1", "gpu": "true"}
+	taints := map[string]string{"dedicated": "ci"}
+
+	tests := []struct {
+		name        string
+		constraints *Constraints
+		labels      map[string]string
+		taints      map[string]string
+		wantErr     bool
+	}{
+		{"no constraints, untainted runner", nil, labels, nil, false},
+		{"no constraints, tainted runner", nil, labels, taints, true},
+		{"matching selector", &Constraints{NodeSelector: map[string]string{"zone": "eu-1"}}, labels, nil, false},
+		{"selector value differs", &Constraints{NodeSelector: map[string]string{"zone": "us-1"}}, labels, nil, true},
+		{"selector label missing", &Constraints{NodeSelector: map[string]string{"disk": "ssd"}}, labels, nil, true},
+		{"selector requires presence only", &Constraints{NodeSelector: map[string]string{"gpu": ""}}, labels, nil, false},
+		{"toleration matches value", &Constraints{Tolerations: map[string]string{"dedicated": "ci"}}, labels, taints, false},
+		{"toleration matches any value", &Constraints{Tolerations: map[string]string{"dedicated": ""}}, labels, taints, false},
+		{"toleration value differs", &Constraints{Tolerations: map[string]string{"dedicated": "gpu"}}, labels, taints, true},
+		{"toleration without taint", &Constraints{Tolerations: map[string]string{"dedicated": "ci"}}, labels, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.constraints, tt.labels, tt.taints)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckReportsFirstMismatch(t *testing.T) {
+	c := &Constraints{NodeSelector: map[string]string{"zone": "us-1", "arch": "arm64"}}
+	err := Check(c, map[string]string{"zone": "eu-1", "arch": "amd64"}, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "runner label arch=amd64 does not match arch=arm64"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
@@ -0,0 +1,95 @@
+// Package reconcile compares the sandboxes the server expects on a runner
+// with the containers Docker actually has, and plans how to bring them back
+// in line
+package reconcile
+
+// Desired is a sandbox as the server records it
+type Desired struct {
+	ID       string
+	Status   string // Server sandbox status, such as running or stopped
+	RunnerID string // Runner that owns the sandbox, empty until one reports on it
+}
+
+// Container is a codepod.sandbox container found on the runner
+type Container struct {
+	SandboxID   string
+	ContainerID string
+	State       string // Docker container state
+	Port        int
+}
+
+// Kind is what an action does
+type Kind string
+
+const (
+	// ReportStopped reports a container the server thinks is running as stopped
+	ReportStopped Kind = "report_stopped"
+	// ReportMissing reports a sandbox whose container is gone as failed
+	ReportMissing Kind = "report_missing"
+	// ReportRunning reports a container the server thinks is down as running
+	ReportRunning Kind = "report_running"
+	// RemoveOrphan removes a container the server no longer knows about
+	RemoveOrphan Kind = "remove_orphan"
+)
+
+// Action is a single step of a plan
+type Action struct {
+	Kind      Kind
+	SandboxID string
+	Container *Container // nil for ReportMissing
+}
+
+// Plan compares the server's sandboxes with the runner's containers. Sandboxes
+// owned by other runners are left alone, as are sandboxes for which busy
+// reports a job in progress, since their state is about to change anyway.
+func Plan(runnerID string, desired []Desired, containers []Container, busy func(sandboxID string) bool) []Action {
+	known := make(map[string]Desired, len(desired))
+	for _, d := range desired {
+		known[d.ID] = d
+	}
+
+	var actions []Action
+	found := make(map[string]bool, len(containers))
+	for i := range containers {
+		c := &containers[i]
+		found[c.SandboxID] = true
+		if busy(c.SandboxID) {
+			continue
+		}
+
+		d, ok := known[c.SandboxID]
+		switch {
+		case !ok:
+			actions = append(actions, Action{Kind: RemoveOrphan, SandboxID: c.SandboxID, Container: c})
+		case d.RunnerID != "" && d.RunnerID != runnerID:
+			// Another runner on the same Docker host owns it
+		case d.Status == "running" && (c.State == "exited" || c.State == "dead"):
+			actions = append(actions, Action{Kind: ReportStopped, SandboxID: c.SandboxID, Container: c})
+		case (d.Status == "stopped" || d.Status == "failed") && c.State == "running":
+			actions = append(actions, Action{Kind: ReportRunning, SandboxID: c.SandboxID, Container: c})
+		}
+	}
+
+	for _, d := range desired {
+		if d.RunnerID == runnerID && d.Status == "running" && !found[d.ID] && !busy(d.ID) {
+			actions = append(actions, Action{Kind: ReportMissing, SandboxID: d.ID})
+		}
+	}
+	return actions
+}
+
+// OrphanVolumes returns the volumes that are not among the known ones
+func OrphanVolumes(known, volumes []string) []string {
+	keep := make(map[string]bool, len(known))
+	for _, name := range known {
+		keep[name] = true
+	}
+
+	var orphans []string
+	for _, name := range volumes {
+		if !keep[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	return orphans
+}
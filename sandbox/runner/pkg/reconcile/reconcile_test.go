@@ -0,0 +1,65 @@
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func notBusy(string) bool { return false }
+
+func TestPlan(t *testing.T) {
+	desired := []Desired{
+		{ID: "sbx-running", Status: "running", RunnerID: "runner-1"},
+		{ID: "sbx-exited", Status: "running", RunnerID: "runner-1"},
+		{ID: "sbx-missing", Status: "running", RunnerID: "runner-1"},
+		{ID: "sbx-revived", Status: "failed", RunnerID: "runner-1"},
+		{ID: "sbx-stopped", Status: "stopped", RunnerID: "runner-1"},
+		{ID: "sbx-other", Status: "running", RunnerID: "runner-2"},
+	}
+	containers := []Container{
+		{SandboxID: "sbx-running", ContainerID: "c1", State: "running"},
+		{SandboxID: "sbx-exited", ContainerID: "c2", State: "exited"},
+		{SandboxID: "sbx-revived", ContainerID: "c3", State: "running"},
+		{SandboxID: "sbx-stopped", ContainerID: "c4", State: "exited"},
+		{SandboxID: "sbx-other", ContainerID: "c5", State: "exited"},
+		{SandboxID: "sbx-orphan", ContainerID: "c6", State: "running"},
+	}
+
+	var got []string
+	for _, a := range Plan("runner-1", desired, containers, notBusy) {
+		got = append(got, string(a.Kind)+":"+a.SandboxID)
+	}
+	want := []string{
+		"report_stopped:sbx-exited",
+		"report_running:sbx-revived",
+		"remove_orphan:sbx-orphan",
+		"report_missing:sbx-missing",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPlanSkipsBusySandboxes(t *testing.T) {
+	desired := []Desired{
+		{ID: "sbx-creating", Status: "running", RunnerID: "runner-1"},
+		{ID: "sbx-deleting", Status: "running", RunnerID: "runner-1"},
+	}
+	containers := []Container{
+		{SandboxID: "sbx-deleting", ContainerID: "c1", State: "exited"},
+		{SandboxID: "sbx-new", ContainerID: "c2", State: "created"},
+	}
+	busy := func(id string) bool { return true }
+
+	if actions := Plan("runner-1", desired, containers, busy); len(actions) != 0 {
+		t.Errorf("expected no actions while jobs run, got %v", actions)
+	}
+}
+
+func TestOrphanVolumes(t *testing.T) {
+	got := OrphanVolumes([]string{"vol-1", "vol-2"}, []string{"vol-1", "vol-3", "vol-2", "vol-4"})
+	want := []string{"vol-3", "vol-4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
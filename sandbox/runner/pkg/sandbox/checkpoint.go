@@ -0,0 +1,49 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/metrics"
+)
+
+// Checkpoint saves the state of a running sandbox's processes to dir/id.
+// With exit the sandbox is stopped once it is saved; otherwise it keeps
+// running from where it was frozen.
+func (m *Manager) Checkpoint(ctx context.Context, sb *Sandbox, id, dir string, exit bool) error {
+	if err := m.docker.CheckpointContainer(ctx, sb.ContainerID, &docker.CheckpointOptions{
+		ID:   id,
+		Dir:  dir,
+		Exit: exit,
+	}); err != nil {
+		return fmt.Errorf("failed to checkpoint container: %w", err)
+	}
+	logger.Info("Checkpointed sandbox", "container_id", sb.ContainerID, "checkpoint_id", id, "exit", exit)
+
+	if exit {
+		sb.Status = SandboxStatusStopped
+	}
+	return nil
+}
+
+// StartFromCheckpoint starts a created sandbox from the checkpoint in
+// dir/id instead of running its entrypoint. The sandbox's container must
+// have the filesystem the checkpoint was taken from, e.g. a snapshot image
+// committed alongside it.
+func (m *Manager) StartFromCheckpoint(ctx context.Context, sb *Sandbox, id, dir string) error {
+	start := time.Now()
+	if err := m.docker.RestoreContainer(ctx, sb.ContainerID, &docker.CheckpointOptions{
+		ID:  id,
+		Dir: dir,
+	}); err != nil {
+		return fmt.Errorf("failed to restore container from checkpoint: %w", err)
+	}
+	metrics.ObserveSince(metrics.ContainerDuration.WithLabelValues(metrics.OperationStart), start)
+
+	sb.Status = SandboxStatusRunning
+	sb.StartedAt = time.Now()
+	m.resolvePort(ctx, sb)
+	return nil
+}
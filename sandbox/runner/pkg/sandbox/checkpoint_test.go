@@ -0,0 +1,67 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+)
+
+func TestCheckpointAndRestore(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-checkpoint",
+	})
+	if err := mgr.Checkpoint(ctx, sb, "cp-1", dir, false); err == nil {
+		t.Error("expected error checkpointing a sandbox that is not running")
+	}
+
+	mgr.Start(ctx, sb)
+	if err := mgr.Checkpoint(ctx, sb, "cp-1", dir, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sb.Status != SandboxStatusStopped {
+		t.Errorf("expected stopped after checkpoint, got %s", sb.Status)
+	}
+
+	restored, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-restored",
+	})
+	if err := mgr.StartFromCheckpoint(ctx, restored, "cp-2", dir); err == nil {
+		t.Error("expected error for a missing checkpoint")
+	}
+	if err := mgr.StartFromCheckpoint(ctx, restored, "cp-1", dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Status != SandboxStatusRunning || restored.StartedAt.IsZero() {
+		t.Errorf("expected running after restore, got %s", restored.Status)
+	}
+	if status, _ := mgr.GetStatus(ctx, restored); status != SandboxStatusRunning {
+		t.Errorf("expected container running, got %s", status)
+	}
+}
+
+func TestCheckpointLeaveRunning(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-checkpoint",
+	})
+	mgr.Start(ctx, sb)
+
+	if err := mgr.Checkpoint(ctx, sb, "cp-1", t.TempDir(), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status, _ := mgr.GetStatus(ctx, sb); status != SandboxStatusRunning {
+		t.Errorf("expected running, got %s", status)
+	}
+}
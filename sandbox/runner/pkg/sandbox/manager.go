@@ -3,14 +3,21 @@ package sandbox
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/codepod/codepod/libs/go-common/logging"
 	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+	"github.com/codepod/codepod/sandbox/runner/pkg/metrics"
 )
 
+var logger = logging.Component("sandbox")
+
 // Manager manages sandbox containers
 type Manager struct {
 	docker docker.Client
@@ -23,7 +30,7 @@ type Sandbox struct {
 	ContainerID string
 	Image       string
 	Status      SandboxStatus
-	Port        int  // SSH port mapped to host (unified port for SSH and gRPC)
+	Port        int // SSH port mapped to host (unified port for SSH and gRPC)
 	CreatedAt   time.Time
 	StartedAt   time.Time
 	Config      *Config
@@ -34,11 +41,12 @@ type Sandbox struct {
 type SandboxStatus string
 
 const (
-	SandboxStatusPending   SandboxStatus = "pending"
-	SandboxStatusRunning   SandboxStatus = "running"
-	SandboxStatusStopped   SandboxStatus = "stopped"
-	SandboxStatusFailed    SandboxStatus = "failed"
-	SandboxStatusDeleting  SandboxStatus = "deleting"
+	SandboxStatusPending  SandboxStatus = "pending"
+	SandboxStatusRunning  SandboxStatus = "running"
+	SandboxStatusStopped  SandboxStatus = "stopped"
+	SandboxStatusPaused   SandboxStatus = "paused"
+	SandboxStatusFailed   SandboxStatus = "failed"
+	SandboxStatusDeleting SandboxStatus = "deleting"
 )
 
 // Config holds sandbox configuration
@@ -50,29 +58,50 @@ type Config struct {
 	CPU         int64
 	NetworkMode string
 	Labels      map[string]string
+	Entrypoint  []string // Original image entrypoint, run by the agent as the workload
+	Cmd         []string // Original image cmd, run by the agent as the workload
 }
 
+// agentPath is where the agent binary is injected inside the container
+const agentPath = "/tmp/agent"
+
+// agentConfigPath is where the agent config file is injected, readable only
+// by the container's root user
+const agentConfigPath = "/etc/codepod/agent.json"
+
+// The agent's shutdown budget, in seconds: it drains SSH sessions and Execute
+// calls for the grace period, gives the workload its own stop timeout, then
+// reports its final status and flushes traces. Containers are stopped with
+// room for all of it before Docker kills them.
+const (
+	agentShutdownGracePeriod = 5  // Passed to the agent as AGENT_SHUTDOWN_GRACE_PERIOD
+	agentWorkloadStopTimeout = 10 // The agent's timeout for stopping the workload
+	agentShutdownFlush       = 15 // Final status report and trace flush
+	stopTimeout              = agentShutdownGracePeriod + agentWorkloadStopTimeout + agentShutdownFlush
+)
+
 // VolumeInfo represents a volume to mount
 type VolumeInfo struct {
-	VolumeID   string
-	MountPath  string
-	ReadOnly   bool
+	VolumeID  string
+	MountPath string
+	ReadOnly  bool
 }
 
 // CreateOptions holds options for creating a sandbox
 type CreateOptions struct {
-	Image           string
-	Name            string
-	Env             map[string]string
-	Memory          string
-	CPU             int
-	Timeout         time.Duration
-	NetworkMode     string  // "bridge", "host", or network name
-	AgentBinaryPath  string  // Path to agent binary on host
-	AgentToken      string  // Token for agent to authenticate
-	AgentServerURL  string  // Server URL for agent to connect
-	MountDockerSocket bool  // Mount /var/run/docker.sock for Docker-in-Docker
-	Volumes         []VolumeInfo // Volumes to mount
+	Image             string
+	Name              string
+	Env               map[string]string
+	Memory            string
+	CPU               int
+	Timeout           time.Duration
+	NetworkMode       string       // "bridge", "host", or network name
+	AgentBinaryPath   string       // Path to agent binary on host
+	AgentToken        string       // Token for agent to authenticate
+	AgentServerURL    string       // Server URL for agent to connect
+	MountDockerSocket bool         // Mount /var/run/docker.sock for Docker-in-Docker
+	Volumes           []VolumeInfo // Volumes to mount
+	AgentConfig       []byte       // Agent config file holding secrets kept out of the environment
 }
 
 // NewManager creates a new sandbox manager
@@ -85,9 +114,11 @@ func NewManager(dockerClient docker.Client) *Manager {
 // Create creates a new sandbox
 func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, error) {
 	// Pull image first (auto-pull if not exists)
+	pullStart := time.Now()
 	if err := m.docker.PullImage(ctx, opts.Image, nil); err != nil {
 		return nil, fmt.Errorf("failed to pull image %s: %w", opts.Image, err)
 	}
+	metrics.ObserveSince(metrics.ImagePullDuration, pullStart)
 
 	// Build environment variables
 	env := []string{}
@@ -106,13 +137,13 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 	needsAgentInjection := agentBinaryPath != ""
 
 	config := &docker.ContainerConfig{
-		Image:      opts.Image,
-		Name:       opts.Name,
-		Env:        env,
-		Labels:     map[string]string{"codepod.sandbox": opts.Name},
-		Memory:     memory,
-		CPUPeriod:  100000,
-		CPUShares:  int64(opts.CPU * 1024),
+		Image:     opts.Image,
+		Name:      opts.Name,
+		Env:       env,
+		Labels:    map[string]string{"codepod.sandbox": opts.Name},
+		Memory:    memory,
+		CPUPeriod: 100000,
+		CPUShares: int64(opts.CPU * 1024),
 		// Publish SSH port (2222) - unified port for SSH and gRPC
 		Ports: []docker.PortBinding{
 			{ContainerPort: 2222, HostPort: 0, Protocol: "tcp"},
@@ -123,7 +154,7 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 
 	// Mount Docker socket if requested (for builder containers)
 	if opts.MountDockerSocket {
-		log.Printf("DEBUG: Mounting Docker socket for container %s", opts.Name)
+		logger.Debug("Mounting Docker socket", "container", opts.Name)
 		config.Volumes = append(config.Volumes, docker.VolumeMount{
 			Type:     "bind",
 			Source:   "/var/run/docker.sock",
@@ -134,12 +165,12 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 
 	// Mount volumes if specified
 	if len(opts.Volumes) > 0 {
-		log.Printf("DEBUG: Mounting %d volumes for container %s", len(opts.Volumes), opts.Name)
+		logger.Debug("Mounting volumes", "container", opts.Name, "count", len(opts.Volumes))
 		for _, vol := range opts.Volumes {
 			// Create the volume if it doesn't exist
 			volumeName := vol.VolumeID
 			if err := m.docker.EnsureVolume(ctx, volumeName); err != nil {
-				log.Printf("Warning: failed to ensure volume %s: %v", volumeName, err)
+				logger.Warn("Failed to ensure volume", "volume", volumeName, "error", err)
 				continue
 			}
 			// Mount the volume
@@ -148,7 +179,7 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 				Source: volumeName,
 				Target: vol.MountPath,
 			})
-			log.Printf("DEBUG: Mounted volume %s at %s", volumeName, vol.MountPath)
+			logger.Debug("Mounted volume", "volume", volumeName, "mount_path", vol.MountPath)
 		}
 	}
 	// Use provided network mode or default to bridge
@@ -158,22 +189,41 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 		config.NetworkMode = opts.NetworkMode
 	}
 
+	var workloadEntrypoint, workloadCmd []string
 	if needsAgentInjection {
+		// Record the image's own entrypoint and cmd before overriding them,
+		// so the agent can run them as the sandbox's main workload
+		workloadEntrypoint, workloadCmd, err = m.imageWorkload(ctx, opts.Image)
+		if err != nil {
+			return nil, err
+		}
+		workloadEnv, err := workloadEnv(workloadEntrypoint, workloadCmd)
+		if err != nil {
+			return nil, err
+		}
+
 		// Add agent environment variables
 		config.Env = append(config.Env,
 			fmt.Sprintf("AGENT_TOKEN=%s", opts.AgentToken),
 			fmt.Sprintf("AGENT_SERVER_URL=%s", opts.AgentServerURL),
+			fmt.Sprintf("AGENT_SHUTDOWN_GRACE_PERIOD=%d", agentShutdownGracePeriod),
 		)
+		config.Env = append(config.Env, workloadEnv...)
+		if len(opts.AgentConfig) > 0 {
+			config.Env = append(config.Env, fmt.Sprintf("AGENT_CONFIG=%s", agentConfigPath))
+		}
 
 		// Set entrypoint to run agent
-		config.Entrypoint = []string{"/tmp/agent", "start"}
+		config.Entrypoint = []string{agentPath, "start"}
 	}
 
 	// Create container
+	createStart := time.Now()
 	containerID, err := m.docker.CreateContainer(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create container: %w", err)
 	}
+	metrics.ObserveSince(metrics.ContainerDuration.WithLabelValues(metrics.OperationCreate), createStart)
 
 	// Copy agent binary to container (after creation, before start)
 	if needsAgentInjection {
@@ -184,13 +234,19 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 		}
 
 		// Copy binary to container
-		if err := m.docker.CopyFileToContainer(ctx, containerID, "/tmp/agent", bytes.NewReader(binaryContent)); err != nil {
+		if err := m.docker.CopyFileToContainer(ctx, containerID, agentPath, bytes.NewReader(binaryContent), 0755); err != nil {
 			return nil, fmt.Errorf("failed to copy agent binary to container: %w", err)
 		}
 
+		if len(opts.AgentConfig) > 0 {
+			if err := m.docker.CopyFileToContainer(ctx, containerID, agentConfigPath, bytes.NewReader(opts.AgentConfig), 0600); err != nil {
+				return nil, fmt.Errorf("failed to copy agent config to container: %w", err)
+			}
+		}
+
 		// Note: SSH host keys are now generated by the agent itself at startup
 		// using Go crypto library (self-contained, no external dependencies)
-		log.Printf("Agent binary copied to container - agent will generate its own SSH host keys")
+		logger.Info("Agent binary copied to container", "container_id", containerID)
 	}
 
 	return &Sandbox{
@@ -201,53 +257,134 @@ func (m *Manager) Create(ctx context.Context, opts *CreateOptions) (*Sandbox, er
 		Status:      SandboxStatusPending,
 		CreatedAt:   time.Now(),
 		Config: &Config{
-			Image:  opts.Image,
-			Name:   opts.Name,
-			Env:    env,
-			Memory: memory,
-			CPU:    int64(opts.CPU),
+			Image:      opts.Image,
+			Name:       opts.Name,
+			Env:        env,
+			Memory:     memory,
+			CPU:        int64(opts.CPU),
+			Entrypoint: workloadEntrypoint,
+			Cmd:        workloadCmd,
 		},
 		NetworkMode: opts.NetworkMode,
 	}, nil
 }
 
+// imageWorkload returns the entrypoint and cmd the image would run on its own
+func (m *Manager) imageWorkload(ctx context.Context, image string) ([]string, []string, error) {
+	info, err := m.docker.InspectImage(ctx, image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+
+	// Images committed from a sandbox already have the agent as entrypoint;
+	// running it again as the workload would start a second agent
+	if len(info.Entrypoint) > 0 && info.Entrypoint[0] == agentPath {
+		return nil, nil, nil
+	}
+	// A shell or REPL run without a terminal exits at once, which would
+	// mark the sandbox stopped right after it started
+	if interactiveWorkload(info.Entrypoint, info.Cmd) {
+		return nil, nil, nil
+	}
+	return info.Entrypoint, info.Cmd, nil
+}
+
+// passThroughEntrypoints only prepare the container and exec the image's cmd
+var passThroughEntrypoints = map[string]bool{
+	"docker-entrypoint.sh":  true,
+	"docker-php-entrypoint": true,
+}
+
+// interactiveCommands are shells and REPLs, by name without a version suffix
+var interactiveCommands = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "ash": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "ipython": true, "node": true, "irb": true, "php": true, "lua": true,
+	"jshell": true, "ghci": true, "iex": true, "erl": true, "R": true, "pwsh": true,
+}
+
+// interactiveWorkload reports whether an image's default command is a shell
+// or REPL, such as python3 in python images or bash in ubuntu
+func interactiveWorkload(entrypoint, cmd []string) bool {
+	if len(entrypoint) > 1 || (len(entrypoint) == 1 && !passThroughEntrypoints[path.Base(entrypoint[0])]) {
+		return false
+	}
+	if len(cmd) == 0 {
+		return true
+	}
+	// Flags such as php -a keep it interactive; a script or -c command does not
+	for _, arg := range cmd[1:] {
+		if !strings.HasPrefix(arg, "-") || arg == "-c" {
+			return false
+		}
+	}
+	name := strings.TrimRight(path.Base(cmd[0]), "0123456789.")
+	return interactiveCommands[name]
+}
+
+// workloadEnv encodes the workload entrypoint and cmd as agent environment variables
+func workloadEnv(entrypoint, cmd []string) ([]string, error) {
+	var env []string
+	if len(entrypoint) > 0 {
+		data, err := json.Marshal(entrypoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode workload entrypoint: %w", err)
+		}
+		env = append(env, fmt.Sprintf("AGENT_WORKLOAD_ENTRYPOINT=%s", data))
+	}
+	if len(cmd) > 0 {
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode workload cmd: %w", err)
+		}
+		env = append(env, fmt.Sprintf("AGENT_WORKLOAD_CMD=%s", data))
+	}
+	return env, nil
+}
+
 // Start starts a sandbox
 func (m *Manager) Start(ctx context.Context, sb *Sandbox) error {
+	start := time.Now()
 	if err := m.docker.StartContainer(ctx, sb.ContainerID); err != nil {
 		return fmt.Errorf("failed to start container: %w", err)
 	}
+	metrics.ObserveSince(metrics.ContainerDuration.WithLabelValues(metrics.OperationStart), start)
 
 	sb.Status = SandboxStatusRunning
 	sb.StartedAt = time.Now()
+	m.resolvePort(ctx, sb)
+	return nil
+}
 
+// resolvePort sets the host port a started sandbox serves SSH on
+func (m *Manager) resolvePort(ctx context.Context, sb *Sandbox) {
 	// Determine SSH port based on network mode
 	// If using host network mode, use container port directly
 	if sb.NetworkMode == "host" {
 		// In host network mode, use the container port directly
 		sb.Port = 2222
-	} else {
-		// Query Docker for the actual port mappings
-		containerInfo, err := m.docker.ListContainers(ctx, false)
-		if err == nil {
-			for _, c := range containerInfo {
-				if c.ID == sb.ContainerID {
-					for _, p := range c.Ports {
-						if p.ContainerPort == 2222 && p.Protocol == "tcp" {
-							sb.Port = p.HostPort
-						}
-					}
-					break
+		return
+	}
+
+	// Query Docker for the actual port mappings
+	containerInfo, err := m.docker.ListContainers(ctx, false)
+	if err != nil {
+		return
+	}
+	for _, c := range containerInfo {
+		if c.ID == sb.ContainerID {
+			for _, p := range c.Ports {
+				if p.ContainerPort == 2222 && p.Protocol == "tcp" {
+					sb.Port = p.HostPort
 				}
 			}
+			break
 		}
 	}
-
-	return nil
 }
 
 // Stop stops a sandbox
 func (m *Manager) Stop(ctx context.Context, sb *Sandbox) error {
-	if err := m.docker.StopContainer(ctx, sb.ContainerID, 10); err != nil {
+	if err := m.docker.StopContainer(ctx, sb.ContainerID, stopTimeout); err != nil {
 		return fmt.Errorf("failed to stop container: %w", err)
 	}
 
@@ -255,6 +392,68 @@ func (m *Manager) Stop(ctx context.Context, sb *Sandbox) error {
 	return nil
 }
 
+// Restart stops a sandbox and starts it again, keeping its container and
+// volumes
+func (m *Manager) Restart(ctx context.Context, sb *Sandbox) error {
+	if err := m.Stop(ctx, sb); err != nil {
+		return err
+	}
+	return m.Start(ctx, sb)
+}
+
+// Pause freezes a sandbox's processes, keeping their memory
+func (m *Manager) Pause(ctx context.Context, sb *Sandbox) error {
+	if err := m.docker.PauseContainer(ctx, sb.ContainerID); err != nil {
+		return fmt.Errorf("failed to pause container: %w", err)
+	}
+
+	sb.Status = SandboxStatusPaused
+	return nil
+}
+
+// Unpause resumes a paused sandbox
+func (m *Manager) Unpause(ctx context.Context, sb *Sandbox) error {
+	if err := m.docker.UnpauseContainer(ctx, sb.ContainerID); err != nil {
+		return fmt.Errorf("failed to unpause container: %w", err)
+	}
+
+	sb.Status = SandboxStatusRunning
+	return nil
+}
+
+// Resize changes a sandbox's memory and CPU limits without restarting it.
+// An empty memory or zero cpu leaves that limit unchanged.
+func (m *Manager) Resize(ctx context.Context, sb *Sandbox, memory string, cpu int) error {
+	resources := &docker.Resources{CPUShares: int64(cpu * 1024)}
+	if memory != "" {
+		limit, err := parseMemory(memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory: %w", err)
+		}
+		if limit <= 0 {
+			return fmt.Errorf("invalid memory: %s", memory)
+		}
+		resources.Memory = limit
+	}
+	if resources.Memory == 0 && resources.CPUShares == 0 {
+		return fmt.Errorf("no memory or cpu limit to change")
+	}
+
+	if err := m.docker.UpdateContainerResources(ctx, sb.ContainerID, resources); err != nil {
+		return fmt.Errorf("failed to update container resources: %w", err)
+	}
+
+	if sb.Config != nil {
+		if resources.Memory > 0 {
+			sb.Config.Memory = resources.Memory
+		}
+		if cpu > 0 {
+			sb.Config.CPU = int64(cpu)
+		}
+	}
+	return nil
+}
+
 // Delete deletes a sandbox
 func (m *Manager) Delete(ctx context.Context, sb *Sandbox) error {
 	sb.Status = SandboxStatusDeleting
@@ -277,8 +476,10 @@ func (m *Manager) GetStatus(ctx context.Context, sb *Sandbox) (SandboxStatus, er
 	switch docker.ContainerState(state) {
 	case docker.ContainerStateRunning:
 		return SandboxStatusRunning, nil
-	case docker.ContainerStateCreated, docker.ContainerStatePaused:
+	case docker.ContainerStateCreated:
 		return SandboxStatusPending, nil
+	case docker.ContainerStatePaused:
+		return SandboxStatusPaused, nil
 	case docker.ContainerStateExited, docker.ContainerStateDead:
 		return SandboxStatusStopped, nil
 	default:
@@ -320,6 +521,19 @@ func (m *Manager) List(ctx context.Context) ([]*Sandbox, error) {
 	return sandboxes, nil
 }
 
+// CountByState counts sandboxes by their container state
+func (m *Manager) CountByState(ctx context.Context) (map[string]int, error) {
+	sandboxes, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, sb := range sandboxes {
+		counts[string(sb.Status)]++
+	}
+	return counts, nil
+}
+
 // Get gets a sandbox by ID
 func (m *Manager) Get(ctx context.Context, id string) (*Sandbox, error) {
 	containers, err := m.docker.ListContainers(ctx, true)
@@ -388,42 +602,41 @@ func (m *Manager) GetByName(ctx context.Context, name string) (*Sandbox, error)
 	return nil, fmt.Errorf("sandbox not found: %s", name)
 }
 
-// parseMemory parses memory string to bytes
+// memoryUnits maps the memory suffixes accepted by parseMemory to bytes
+var memoryUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1024,
+	"KB":  1024,
+	"Ki":  1024,
+	"KiB": 1024,
+	"M":   1024 * 1024,
+	"MB":  1024 * 1024,
+	"Mi":  1024 * 1024,
+	"MiB": 1024 * 1024,
+	"G":   1024 * 1024 * 1024,
+	"GB":  1024 * 1024 * 1024,
+	"Gi":  1024 * 1024 * 1024,
+	"GiB": 1024 * 1024 * 1024,
+}
+
+// parseMemory parses a memory size such as 512M or 1Gi to bytes
 func parseMemory(mem string) (int64, error) {
 	if mem == "" {
 		return 512 * 1024 * 1024, nil // Default 512MB
 	}
 
-	var multiplier int64 = 1
-	switch {
-	case len(mem) >= 2 && mem[len(mem)-2:] == "Mi":
-		multiplier = 1024 * 1024
-	case len(mem) >= 2 && mem[len(mem)-2:] == "Gi":
-		multiplier = 1024 * 1024 * 1024
-	case len(mem) >= 1 && mem[len(mem)-1:] == "M":
-		multiplier = 1024 * 1024
-	case len(mem) >= 1 && mem[len(mem)-1:] == "G":
-		multiplier = 1024 * 1024 * 1024
-	case len(mem) >= 2 && mem[len(mem)-2:] == "KB":
-		multiplier = 1024
-	case len(mem) >= 2 && mem[len(mem)-2:] == "GB":
-		multiplier = 1024 * 1024 * 1024
-	}
-
-	// Extract numeric part
-	numPart := mem
-	for len(numPart) > 0 {
-		c := numPart[len(numPart)-1]
-		if c < '0' || c > '9' {
-			break
-		}
-		numPart = numPart[:len(numPart)-1]
+	i := 0
+	for i < len(mem) && mem[i] >= '0' && mem[i] <= '9' {
+		i++
 	}
-
-	var value int64
-	if numPart != mem {
-		fmt.Sscanf(numPart, "%d", &value)
+	multiplier, ok := memoryUnits[mem[i:]]
+	if i == 0 || !ok {
+		return 0, fmt.Errorf("invalid memory size: %s", mem)
+	}
+	value, err := strconv.ParseInt(mem[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size: %s", mem)
 	}
-
 	return value * multiplier, nil
 }
@@ -2,6 +2,8 @@ package sandbox
 
 import (
 	"context"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -103,6 +105,136 @@ func TestCreateWithEnv(t *testing.T) {
 	}
 }
 
+func TestCreateRecordsImageWorkload(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	agentBinary, err := os.CreateTemp("", "agent-*")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(agentBinary.Name())
+	agentBinary.Close()
+
+	mock.SetImageInfo("postgres:16", &docker.ImageInfo{
+		Entrypoint: []string{"docker-entrypoint.sh"},
+		Cmd:        []string{"postgres"},
+	})
+
+	sb, err := mgr.Create(ctx, &CreateOptions{
+		Image:           "postgres:16",
+		Name:            "test-workload",
+		AgentBinaryPath: agentBinary.Name(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := mock.GetContainer(sb.ContainerID)
+	env := strings.Join(c.Config().Env, "\n")
+	if !strings.Contains(env, `AGENT_WORKLOAD_ENTRYPOINT=["docker-entrypoint.sh"]`) {
+		t.Errorf("expected workload entrypoint in env, got %s", env)
+	}
+	if !strings.Contains(env, `AGENT_WORKLOAD_CMD=["postgres"]`) {
+		t.Errorf("expected workload cmd in env, got %s", env)
+	}
+	if c.Config().Entrypoint[0] != "/tmp/agent" {
+		t.Errorf("expected agent entrypoint, got %v", c.Config().Entrypoint)
+	}
+	if len(sb.Config.Cmd) != 1 || sb.Config.Cmd[0] != "postgres" {
+		t.Errorf("expected recorded cmd postgres, got %v", sb.Config.Cmd)
+	}
+}
+
+func TestCreateInjectsAgentConfig(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	agentBinary, err := os.CreateTemp("", "agent-*")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(agentBinary.Name())
+	agentBinary.Close()
+
+	sb, err := mgr.Create(ctx, &CreateOptions{
+		Image:           "alpine:latest",
+		Name:            "test-config",
+		AgentBinaryPath: agentBinary.Name(),
+		AgentConfig:     []byte(`{"grpc":{"tls":{"key":"secret"}}}`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := mock.GetContainer(sb.ContainerID)
+	env := strings.Join(c.Config().Env, "\n")
+	if !strings.Contains(env, "AGENT_CONFIG=/etc/codepod/agent.json") {
+		t.Errorf("expected agent config path in env, got %s", env)
+	}
+	if strings.Contains(env, "secret") {
+		t.Errorf("expected agent config to stay out of the env, got %s", env)
+	}
+	if mode, ok := c.FileMode("/etc/codepod/agent.json"); !ok || mode != 0600 {
+		t.Errorf("expected agent config copied with mode 0600, got %v (copied: %v)", mode, ok)
+	}
+}
+
+func TestCreateSkipsInteractiveWorkload(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	agentBinary, err := os.CreateTemp("", "agent-*")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(agentBinary.Name())
+	agentBinary.Close()
+
+	mock.SetImageInfo("python:3.11", &docker.ImageInfo{Cmd: []string{"python3"}})
+
+	sb, err := mgr.Create(ctx, &CreateOptions{
+		Image:           "python:3.11",
+		Name:            "test-repl",
+		AgentBinaryPath: agentBinary.Name(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env := strings.Join(mock.GetContainer(sb.ContainerID).Config().Env, "\n")
+	if strings.Contains(env, "AGENT_WORKLOAD_") {
+		t.Errorf("expected no workload for a REPL cmd, got %s", env)
+	}
+}
+
+func TestInteractiveWorkload(t *testing.T) {
+	tests := []struct {
+		entrypoint []string
+		cmd        []string
+		want       bool
+	}{
+		{nil, []string{"python3"}, true},
+		{nil, []string{"/bin/bash"}, true},
+		{nil, []string{"python3.11"}, true},
+		{[]string{"docker-entrypoint.sh"}, []string{"node"}, true},
+		{[]string{"docker-php-entrypoint"}, []string{"php", "-a"}, true},
+		{nil, []string{"python3", "app.py"}, false},
+		{nil, []string{"sh", "-c", "sleep infinity"}, false},
+		{[]string{"docker-entrypoint.sh"}, []string{"postgres"}, false},
+		{[]string{"/usr/bin/server"}, []string{"bash"}, false},
+		{[]string{"nginx", "-g", "daemon off;"}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := interactiveWorkload(tt.entrypoint, tt.cmd); got != tt.want {
+			t.Errorf("interactiveWorkload(%v, %v) = %v, want %v", tt.entrypoint, tt.cmd, got, tt.want)
+		}
+	}
+}
+
 func TestStart(t *testing.T) {
 	mock := docker.NewMockClient()
 	mgr := NewManager(mock)
@@ -147,6 +279,101 @@ func TestStop(t *testing.T) {
 	}
 }
 
+func TestRestart(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-restart",
+	})
+	mgr.Start(ctx, sb)
+	containerID := sb.ContainerID
+
+	if err := mgr.Restart(ctx, sb); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sb.Status != SandboxStatusRunning {
+		t.Errorf("expected status running, got %s", sb.Status)
+	}
+	if sb.ContainerID != containerID {
+		t.Errorf("expected the container to be kept, got %s", sb.ContainerID)
+	}
+}
+
+func TestPauseUnpause(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-pause",
+	})
+	mgr.Start(ctx, sb)
+
+	if err := mgr.Pause(ctx, sb); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err := mgr.GetStatus(ctx, sb)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sb.Status != SandboxStatusPaused || status != SandboxStatusPaused {
+		t.Errorf("expected paused, got %s (container %s)", sb.Status, status)
+	}
+
+	if err := mgr.Unpause(ctx, sb); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, _ = mgr.GetStatus(ctx, sb)
+	if sb.Status != SandboxStatusRunning || status != SandboxStatusRunning {
+		t.Errorf("expected running, got %s (container %s)", sb.Status, status)
+	}
+
+	if err := mgr.Unpause(ctx, sb); err == nil {
+		t.Error("expected error unpausing a running sandbox")
+	}
+}
+
+func TestResize(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image:  "python:3.11",
+		Name:   "test-resize",
+		Memory: "512M",
+		CPU:    1,
+	})
+	mgr.Start(ctx, sb)
+
+	if err := mgr.Resize(ctx, sb, "2G", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := mock.GetContainer(sb.ContainerID).Config()
+	if config.Memory != 2*1024*1024*1024 {
+		t.Errorf("expected memory 2G, got %d", config.Memory)
+	}
+	if config.CPUShares != 1024 {
+		t.Errorf("expected CPU shares to stay 1024, got %d", config.CPUShares)
+	}
+
+	if err := mgr.Resize(ctx, sb, "", 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config := mock.GetContainer(sb.ContainerID).Config(); config.CPUShares != 2048 {
+		t.Errorf("expected CPU shares 2048, got %d", config.CPUShares)
+	}
+
+	if err := mgr.Resize(ctx, sb, "", 0); err == nil {
+		t.Error("expected error when nothing changes")
+	}
+}
+
 func TestDelete(t *testing.T) {
 	mock := docker.NewMockClient()
 	mgr := NewManager(mock)
@@ -229,6 +456,32 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestCountByState(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	mgr.Create(ctx, &CreateOptions{Image: "img1", Name: "sb1"})
+	sb2, _ := mgr.Create(ctx, &CreateOptions{Image: "img2", Name: "sb2"})
+	mgr.Start(ctx, sb2)
+
+	counts, err := mgr.CountByState(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if counts["running"] != 1 {
+		t.Errorf("expected 1 running sandbox, got %d", counts["running"])
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total != 2 {
+		t.Errorf("expected 2 sandboxes in total, got %d", total)
+	}
+}
+
 func TestGet(t *testing.T) {
 	mock := docker.NewMockClient()
 	mgr := NewManager(mock)
@@ -268,12 +521,19 @@ func TestParseMemory(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		_, err := parseMemory(tt.input)
+		got, err := parseMemory(tt.input)
 		if err != nil {
 			t.Errorf("unexpected error for %s: %v", tt.input, err)
 		}
-		// Note: Our simple parser doesn't handle the full format
-		// This is a known limitation for MVP
+		if got != tt.expected {
+			t.Errorf("parseMemory(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"G", "12X", "-1G"} {
+		if _, err := parseMemory(input); err == nil {
+			t.Errorf("expected error for %s", input)
+		}
 	}
 }
 
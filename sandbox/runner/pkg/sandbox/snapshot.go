@@ -0,0 +1,91 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+)
+
+// Labels recording where a snapshot image came from. The sandbox's
+// environment is left out, as it may hold secrets.
+const (
+	SnapshotLabel               = "codepod.snapshot" // Snapshot ID
+	SnapshotSandboxLabel        = "codepod.snapshot.sandbox"
+	SnapshotSourceImageLabel    = "codepod.snapshot.source-image"
+	SnapshotCreatedAtLabel      = "codepod.snapshot.created-at"
+	SnapshotMemoryLabel         = "codepod.snapshot.memory"
+	SnapshotCPULabel            = "codepod.snapshot.cpu"
+	SnapshotVolumesLabel        = "codepod.snapshot.volumes" // JSON list of the volumes a restore mounts
+	SnapshotExcludeVolumesLabel = "codepod.snapshot.exclude-volumes"
+)
+
+// SnapshotOptions holds options for snapshotting a sandbox
+type SnapshotOptions struct {
+	ID             string
+	Reference      string // Repository and tag of the snapshot image
+	Memory         string // Resources a restored sandbox gets
+	CPU            int
+	Volumes        []VolumeInfo // Volumes a restored sandbox mounts
+	ExcludeVolumes bool         // Restored sandboxes start without the volumes
+	Push           bool         // Push the image to the registry in Reference
+	Auth           *docker.AuthConfig
+}
+
+// Snapshot commits a sandbox's container filesystem to an image and returns
+// the image's ID. Volume contents are never copied into the image; unless
+// excluded, the volumes are recorded so a restore mounts them again.
+func (m *Manager) Snapshot(ctx context.Context, sb *Sandbox, opts *SnapshotOptions) (string, error) {
+	labels := map[string]string{
+		SnapshotLabel:               opts.ID,
+		SnapshotSandboxLabel:        sb.Name,
+		SnapshotSourceImageLabel:    sb.Image,
+		SnapshotCreatedAtLabel:      time.Now().UTC().Format(time.RFC3339),
+		SnapshotMemoryLabel:         opts.Memory,
+		SnapshotCPULabel:            strconv.Itoa(opts.CPU),
+		SnapshotExcludeVolumesLabel: strconv.FormatBool(opts.ExcludeVolumes),
+	}
+	if !opts.ExcludeVolumes && len(opts.Volumes) > 0 {
+		data, err := json.Marshal(opts.Volumes)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode snapshot volumes: %w", err)
+		}
+		labels[SnapshotVolumesLabel] = string(data)
+	}
+
+	imageID, err := m.docker.CommitContainer(ctx, sb.ContainerID, &docker.CommitOptions{
+		Reference: opts.Reference,
+		Labels:    labels,
+		Comment:   fmt.Sprintf("Snapshot %s of sandbox %s", opts.ID, sb.Name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit container: %w", err)
+	}
+	logger.Info("Committed sandbox snapshot", "container_id", sb.ContainerID, "image", opts.Reference, "image_id", imageID)
+
+	if opts.Push {
+		if err := m.docker.PushImage(ctx, opts.Reference, opts.Auth); err != nil {
+			return "", fmt.Errorf("failed to push snapshot image: %w", err)
+		}
+	}
+	return imageID, nil
+}
+
+// PullSnapshot pulls a snapshot image and checks it holds the snapshot with
+// the given ID
+func (m *Manager) PullSnapshot(ctx context.Context, image, id string, auth *docker.AuthConfig) error {
+	if err := m.docker.PullImage(ctx, image, auth); err != nil {
+		return fmt.Errorf("failed to pull snapshot image %s: %w", image, err)
+	}
+	info, err := m.docker.InspectImage(ctx, image)
+	if err != nil {
+		return fmt.Errorf("failed to inspect snapshot image %s: %w", image, err)
+	}
+	if got := info.Labels[SnapshotLabel]; got != id {
+		return fmt.Errorf("image %s holds snapshot %q, not %q", image, got, id)
+	}
+	return nil
+}
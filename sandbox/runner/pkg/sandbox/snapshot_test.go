@@ -0,0 +1,108 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codepod/codepod/sandbox/runner/pkg/docker"
+)
+
+func TestSnapshot(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-snapshot",
+	})
+	mgr.Start(ctx, sb)
+
+	ref := "registry:5000/codepod-snapshots:snap-1"
+	imageID, err := mgr.Snapshot(ctx, sb, &SnapshotOptions{
+		ID:        "snap-1",
+		Reference: ref,
+		Memory:    "1Gi",
+		CPU:       2,
+		Volumes:   []VolumeInfo{{VolumeID: "vol-1", MountPath: "/data"}},
+		Push:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mock.Pushed(ref) {
+		t.Error("expected the snapshot image to be pushed")
+	}
+
+	info, err := mock.InspectImage(ctx, ref)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.ID != imageID {
+		t.Errorf("expected image %s, got %s", imageID, info.ID)
+	}
+	labels := info.Labels
+	if labels[SnapshotLabel] != "snap-1" || labels[SnapshotSandboxLabel] != "test-snapshot" ||
+		labels[SnapshotSourceImageLabel] != "python:3.11" || labels[SnapshotMemoryLabel] != "1Gi" ||
+		labels[SnapshotCPULabel] != "2" || labels[SnapshotCreatedAtLabel] == "" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	if labels[SnapshotVolumesLabel] == "" || labels[SnapshotExcludeVolumesLabel] != "false" {
+		t.Errorf("expected the volumes to be recorded, got %v", labels)
+	}
+
+	// The sandbox keeps running
+	if status, _ := mgr.GetStatus(ctx, sb); status != SandboxStatusRunning {
+		t.Errorf("expected running, got %s", status)
+	}
+}
+
+func TestSnapshotExcludeVolumes(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	sb, _ := mgr.Create(ctx, &CreateOptions{
+		Image: "python:3.11",
+		Name:  "test-snapshot",
+	})
+
+	ref := "codepod-snapshots:snap-2"
+	if _, err := mgr.Snapshot(ctx, sb, &SnapshotOptions{
+		ID:             "snap-2",
+		Reference:      ref,
+		Volumes:        []VolumeInfo{{VolumeID: "vol-1", MountPath: "/data"}},
+		ExcludeVolumes: true,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.Pushed(ref) {
+		t.Error("expected the snapshot image to stay local")
+	}
+
+	info, _ := mock.InspectImage(ctx, ref)
+	if _, ok := info.Labels[SnapshotVolumesLabel]; ok || info.Labels[SnapshotExcludeVolumesLabel] != "true" {
+		t.Errorf("expected the volumes to be left out, got %v", info.Labels)
+	}
+}
+
+func TestPullSnapshot(t *testing.T) {
+	mock := docker.NewMockClient()
+	mgr := NewManager(mock)
+	ctx := context.Background()
+
+	mock.SetImageInfo("codepod-snapshots:snap-1", &docker.ImageInfo{
+		ID:     "sha256:snap",
+		Labels: map[string]string{SnapshotLabel: "snap-1"},
+	})
+
+	if err := mgr.PullSnapshot(ctx, "codepod-snapshots:snap-1", "snap-1", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mgr.PullSnapshot(ctx, "codepod-snapshots:snap-1", "snap-2", nil); err == nil {
+		t.Error("expected error for an image holding another snapshot")
+	}
+	if err := mgr.PullSnapshot(ctx, "python:3.11", "snap-1", nil); err == nil {
+		t.Error("expected error for an image that is not a snapshot")
+	}
+}
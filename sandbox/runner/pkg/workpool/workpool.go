@@ -0,0 +1,119 @@
+// Package workpool runs tasks concurrently on a bounded number of slots,
+// while tasks that share a key run one at a time in submission order
+package workpool
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrFull is returned when every slot is taken
+	ErrFull = errors.New("work pool is full")
+	// ErrDuplicate is returned when a task with the same ID is already queued or running
+	ErrDuplicate = errors.New("task is already queued or running")
+)
+
+// task is a submitted function with its ID
+type task struct {
+	id string
+	fn func()
+}
+
+// Pool runs submitted tasks, each holding a slot from submission until it
+// finishes. A task waiting for an earlier task with the same key holds its
+// slot too, so at most size tasks are ever accepted at once.
+type Pool struct {
+	size int
+
+	mu      sync.Mutex
+	ids     map[string]bool   // IDs of queued and running tasks
+	pending map[string][]task // Tasks waiting per key; a key is present while one of its tasks runs
+	wg      sync.WaitGroup
+}
+
+// New creates a pool with size slots; size below 1 is treated as 1
+func New(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		size:    size,
+		ids:     make(map[string]bool),
+		pending: make(map[string][]task),
+	}
+}
+
+// Submit queues fn under id and key. It runs at once unless a task with the
+// same key is running, in which case it runs after the tasks queued before
+// it. Submit never blocks: it returns ErrFull when no slot is free and
+// ErrDuplicate when id is already queued or running.
+func (p *Pool) Submit(id, key string, fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ids[id] {
+		return ErrDuplicate
+	}
+	if len(p.ids) >= p.size {
+		return ErrFull
+	}
+	p.ids[id] = true
+	p.wg.Add(1)
+
+	t := task{id: id, fn: fn}
+	if queue, busy := p.pending[key]; busy {
+		p.pending[key] = append(queue, t)
+		return nil
+	}
+	p.pending[key] = nil
+	go p.run(key, t)
+	return nil
+}
+
+// run runs t and then every task queued behind it for key
+func (p *Pool) run(key string, t task) {
+	for {
+		t.fn()
+
+		p.mu.Lock()
+		delete(p.ids, t.id)
+		p.wg.Done()
+		queue := p.pending[key]
+		if len(queue) == 0 {
+			delete(p.pending, key)
+			p.mu.Unlock()
+			return
+		}
+		t = queue[0]
+		p.pending[key] = queue[1:]
+		p.mu.Unlock()
+	}
+}
+
+// Available returns the number of free slots
+func (p *Pool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size - len(p.ids)
+}
+
+// Active returns the number of tasks queued or running
+func (p *Pool) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ids)
+}
+
+// Busy reports whether a task for key is queued or running
+func (p *Pool) Busy(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, busy := p.pending[key]
+	return busy
+}
+
+// Wait blocks until every submitted task has finished
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
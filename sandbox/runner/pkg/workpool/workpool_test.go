@@ -0,0 +1,119 @@
+package workpool
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubmitRunsConcurrently(t *testing.T) {
+	pool := New(3)
+	release := make(chan struct{})
+	var running atomic.Int32
+	started := make(chan struct{}, 3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := pool.Submit("job-"+key, key, func() {
+			running.Add(1)
+			started <- struct{}{}
+			<-release
+		}); err != nil {
+			t.Fatalf("submit %s: %v", key, err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected 3 tasks to run concurrently, %d started", running.Load())
+		}
+	}
+	if pool.Available() != 0 {
+		t.Errorf("expected no free slots, got %d", pool.Available())
+	}
+
+	close(release)
+	pool.Wait()
+	if pool.Available() != 3 {
+		t.Errorf("expected 3 free slots after Wait, got %d", pool.Available())
+	}
+}
+
+func TestSubmitFull(t *testing.T) {
+	pool := New(1)
+	release := make(chan struct{})
+	defer close(release)
+
+	if err := pool.Submit("job-1", "a", func() { <-release }); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if err := pool.Submit("job-2", "b", func() {}); !errors.Is(err, ErrFull) {
+		t.Errorf("expected ErrFull, got %v", err)
+	}
+}
+
+func TestSubmitDuplicate(t *testing.T) {
+	pool := New(2)
+	release := make(chan struct{})
+	defer close(release)
+
+	if err := pool.Submit("job-1", "a", func() { <-release }); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if err := pool.Submit("job-1", "a", func() {}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestSameKeyRunsInOrder(t *testing.T) {
+	pool := New(10)
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var order []string
+	var concurrent, maxConcurrent atomic.Int32
+
+	for _, id := range []string{"create", "delete", "create-again"} {
+		id := id
+		if err := pool.Submit(id, "sbox-1", func() {
+			n := concurrent.Add(1)
+			if n > maxConcurrent.Load() {
+				maxConcurrent.Store(n)
+			}
+			<-release
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			concurrent.Add(-1)
+		}); err != nil {
+			t.Fatalf("submit %s: %v", id, err)
+		}
+	}
+
+	// Queued tasks hold their slots
+	if pool.Active() != 3 {
+		t.Errorf("expected 3 active tasks, got %d", pool.Active())
+	}
+	if !pool.Busy("sbox-1") || pool.Busy("sbox-2") {
+		t.Error("expected only sbox-1 to be busy")
+	}
+
+	close(release)
+	pool.Wait()
+
+	if pool.Busy("sbox-1") {
+		t.Error("expected sbox-1 to be idle after Wait")
+	}
+	if maxConcurrent.Load() != 1 {
+		t.Errorf("expected tasks for one key to run one at a time, got %d at once", maxConcurrent.Load())
+	}
+	want := []string{"create", "delete", "create-again"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+}
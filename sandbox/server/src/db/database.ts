@@ -50,9 +50,44 @@ export class SqliteDB {
         env TEXT,
         memory TEXT,
         cpu INTEGER,
-        network_mode TEXT
+        network_mode TEXT,
+        trace_context TEXT,
+        constraints TEXT,
+        volumes TEXT,
+        snapshot_id TEXT,
+        exclude_volumes INTEGER NOT NULL DEFAULT 0,
+        leave_running INTEGER NOT NULL DEFAULT 0
       )
     `);
+    this.addColumnIfMissing('jobs', 'trace_context', 'TEXT');
+    this.addColumnIfMissing('jobs', 'constraints', 'TEXT');
+    this.addColumnIfMissing('jobs', 'volumes', 'TEXT');
+    this.addColumnIfMissing('jobs', 'snapshot_id', 'TEXT');
+    this.addColumnIfMissing('jobs', 'exclude_volumes', 'INTEGER NOT NULL DEFAULT 0');
+    this.addColumnIfMissing('jobs', 'leave_running', 'INTEGER NOT NULL DEFAULT 0');
+
+    // Create snapshots table (sandbox filesystems committed to images, with
+    // what a restore needs to recreate the sandbox)
+    this.db.exec(`
+      CREATE TABLE IF NOT EXISTS snapshots (
+        id TEXT PRIMARY KEY,
+        sandbox_id TEXT NOT NULL,
+        status TEXT NOT NULL DEFAULT 'pending',
+        image TEXT,
+        image_id TEXT,
+        source_image TEXT NOT NULL,
+        env TEXT,
+        memory TEXT,
+        cpu INTEGER,
+        volumes TEXT,
+        exclude_volumes INTEGER NOT NULL DEFAULT 0,
+        checkpoint INTEGER NOT NULL DEFAULT 0,
+        constraints TEXT,
+        message TEXT,
+        created_at TEXT NOT NULL
+      )
+    `);
+    this.addColumnIfMissing('snapshots', 'checkpoint', 'INTEGER NOT NULL DEFAULT 0');
 
     // Create api_keys table
     this.db.exec(`
@@ -66,6 +101,29 @@ export class SqliteDB {
       )
     `);
 
+    // Create runner_join_tokens table (one-time tokens runners enroll with)
+    this.db.exec(`
+      CREATE TABLE IF NOT EXISTS runner_join_tokens (
+        id TEXT PRIMARY KEY,
+        token_hash TEXT UNIQUE NOT NULL,
+        created_at TEXT NOT NULL,
+        expires_at TEXT NOT NULL,
+        used_at TEXT,
+        runner_id TEXT
+      )
+    `);
+
+    // Create runner_credentials table (bearer tokens issued at enrollment)
+    this.db.exec(`
+      CREATE TABLE IF NOT EXISTS runner_credentials (
+        id TEXT PRIMARY KEY,
+        runner_id TEXT NOT NULL,
+        token_hash TEXT UNIQUE NOT NULL,
+        created_at TEXT NOT NULL,
+        expires_at TEXT NOT NULL
+      )
+    `);
+
     // Create audit_logs table
     this.db.exec(`
       CREATE TABLE IF NOT EXISTS audit_logs (
@@ -83,11 +141,22 @@ export class SqliteDB {
     this.db.exec(`
       CREATE INDEX IF NOT EXISTS idx_sandboxes_status ON sandboxes(status);
       CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+      CREATE INDEX IF NOT EXISTS idx_snapshots_sandbox ON snapshots(sandbox_id);
+      CREATE INDEX IF NOT EXISTS idx_runner_credentials_runner ON runner_credentials(runner_id);
       CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource);
       CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp ON audit_logs(timestamp);
     `);
   }
 
+  // Columns added after a table was first created are missing from older
+  // database files
+  private addColumnIfMissing(table: string, column: string, type: string): void {
+    const columns = this.db.prepare(`PRAGMA table_info(${table})`).all() as { name: string }[];
+    if (!columns.some((c) => c.name === column)) {
+      this.db.exec(`ALTER TABLE ${table} ADD COLUMN ${column} ${type}`);
+    }
+  }
+
   getDatabase(): DatabaseType {
     return this.db;
   }
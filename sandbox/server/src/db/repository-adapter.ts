@@ -9,6 +9,7 @@ import {
   AuditLog,
   CreateSandboxRequest,
   AgentInfo,
+  ListeningPort,
   Volume,
   VolumeStatus,
   CreateVolumeRequest,
@@ -105,6 +106,16 @@ export class RepositoryAdapter {
     return this.sandboxRepo.update(id, updated);
   }
 
+  updateAgentPorts(id: string, ports: ListeningPort[]): Sandbox | undefined {
+    const sandbox = this.sandboxRepo.getById(id);
+    if (!sandbox || !sandbox.agentInfo) return undefined;
+
+    return this.sandboxRepo.update(id, {
+      ...sandbox,
+      agentInfo: { ...sandbox.agentInfo, ports },
+    });
+  }
+
   updateAgentAddress(id: string, address: { host?: string; port?: number; token?: string }): Sandbox | undefined {
     const sandbox = this.sandboxRepo.getById(id);
     if (!sandbox) return undefined;
@@ -234,6 +245,7 @@ export class RepositoryAdapter {
     const db = getDatabase().getDatabase();
     db.exec('DELETE FROM sandboxes');
     db.exec('DELETE FROM jobs');
+    db.exec('DELETE FROM snapshots');
     db.exec('DELETE FROM api_keys');
     db.exec('DELETE FROM audit_logs');
 
@@ -22,6 +22,22 @@ describe('Repositories', () => {
     expect(repo).toBeDefined();
   });
 
+  it('should release the running jobs of a runner', () => {
+    const repo = new JobRepository(db);
+    const running = repo.create({ type: 'create', sandboxId: 'sbox-1', image: 'python:3.11', token: '' });
+    const other = repo.create({ type: 'create', sandboxId: 'sbox-2', image: 'python:3.11', token: '' });
+    const done = repo.create({ type: 'create', sandboxId: 'sbox-3', image: 'python:3.11', token: '' });
+    repo.assign(running.id, 'runner-1');
+    repo.assign(other.id, 'runner-2');
+    repo.assign(done.id, 'runner-1');
+    repo.complete(done.id, true);
+
+    expect(repo.release('runner-1')).toBe(1);
+    expect(repo.getById(running.id)).toMatchObject({ status: 'pending', runnerId: undefined });
+    expect(repo.getById(other.id)).toMatchObject({ status: 'running', runnerId: 'runner-2' });
+    expect(repo.getById(done.id)?.status).toBe('completed');
+  });
+
   it('should create APIKeyRepository', () => {
     const repo = new APIKeyRepository(db);
     expect(repo).toBeDefined();
@@ -1,5 +1,5 @@
 import { SqliteDB } from './database';
-import { Sandbox, SandboxStatus, APIKey, AuditLog, CreateSandboxRequest } from '../types';
+import { Sandbox, SandboxStatus, APIKey, AuditLog, CreateSandboxRequest, JobConstraints, SnapshotStatus } from '../types';
 
 export class SandboxRepository {
   private db: SqliteDB;
@@ -153,6 +153,12 @@ export interface JobData {
   memory?: string;
   cpu?: number;
   networkMode?: string;
+  traceContext?: Record<string, string>;
+  constraints?: JobConstraints;
+  volumes?: { volumeId: string; mountPath: string }[];
+  snapshotId?: string;
+  excludeVolumes?: boolean;
+  leaveRunning?: boolean;
 }
 
 export class JobRepository {
@@ -168,8 +174,8 @@ export class JobRepository {
     const now = new Date().toISOString();
 
     const stmt = database.prepare(`
-      INSERT INTO jobs (id, type, sandbox_id, image, token, status, runner_id, created_at, env, memory, cpu, network_mode)
-      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+      INSERT INTO jobs (id, type, sandbox_id, image, token, status, runner_id, created_at, env, memory, cpu, network_mode, trace_context, constraints, volumes, snapshot_id, exclude_volumes, leave_running)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `);
 
     stmt.run(
@@ -184,7 +190,13 @@ export class JobRepository {
       data.env ? JSON.stringify(data.env) : null,
       data.memory || null,
       data.cpu || null,
-      data.networkMode || null
+      data.networkMode || null,
+      data.traceContext ? JSON.stringify(data.traceContext) : null,
+      data.constraints ? JSON.stringify(data.constraints) : null,
+      data.volumes && data.volumes.length > 0 ? JSON.stringify(data.volumes) : null,
+      data.snapshotId || null,
+      data.excludeVolumes ? 1 : 0,
+      data.leaveRunning ? 1 : 0
     );
 
     return this.getById(id)!;
@@ -238,6 +250,16 @@ export class JobRepository {
     return this.updateStatus(id, success ? 'completed' : 'failed');
   }
 
+  /**
+   * Return the jobs a runner accepted but never completed to the pending
+   * queue. Returns the number of jobs released.
+   */
+  release(runnerId: string): number {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare("UPDATE jobs SET status = 'pending', runner_id = NULL WHERE status = 'running' AND runner_id = ?");
+    return stmt.run(runnerId).changes;
+  }
+
   private mapToJob(row: any): JobData {
     return {
       id: row.id,
@@ -252,6 +274,110 @@ export class JobRepository {
       memory: row.memory || undefined,
       cpu: row.cpu || undefined,
       networkMode: row.network_mode || undefined,
+      traceContext: row.trace_context ? JSON.parse(row.trace_context) : undefined,
+      constraints: row.constraints ? JSON.parse(row.constraints) : undefined,
+      volumes: row.volumes ? JSON.parse(row.volumes) : undefined,
+      snapshotId: row.snapshot_id || undefined,
+      excludeVolumes: row.exclude_volumes === 1 || undefined,
+      leaveRunning: row.leave_running === 1 || undefined,
+    };
+  }
+}
+
+export interface SnapshotData {
+  id: string;
+  sandboxId: string;
+  status: SnapshotStatus;
+  image?: string;
+  imageId?: string;
+  sourceImage: string;
+  env?: Record<string, string>;
+  memory?: string;
+  cpu?: number;
+  volumes?: { volumeId: string; mountPath: string }[];
+  excludeVolumes: boolean;
+  checkpoint: boolean;
+  constraints?: JobConstraints;
+  message?: string;
+  createdAt: string;
+}
+
+export class SnapshotRepository {
+  private db: SqliteDB;
+
+  constructor(db?: SqliteDB) {
+    this.db = db || require('./database').getDatabase();
+  }
+
+  create(data: Omit<SnapshotData, 'id' | 'status' | 'createdAt'>): SnapshotData {
+    const database = this.db.getDatabase();
+    const id = `snap-${Date.now()}-${Math.random().toString(36).slice(2, 10)}`;
+    const stmt = database.prepare(`
+      INSERT INTO snapshots (id, sandbox_id, status, source_image, env, memory, cpu, volumes, exclude_volumes, checkpoint, constraints, created_at)
+      VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `);
+    stmt.run(
+      id,
+      data.sandboxId,
+      'pending',
+      data.sourceImage,
+      data.env ? JSON.stringify(data.env) : null,
+      data.memory || null,
+      data.cpu || null,
+      data.volumes && data.volumes.length > 0 ? JSON.stringify(data.volumes) : null,
+      data.excludeVolumes ? 1 : 0,
+      data.checkpoint ? 1 : 0,
+      data.constraints ? JSON.stringify(data.constraints) : null,
+      new Date().toISOString()
+    );
+    return this.getById(id)!;
+  }
+
+  getById(id: string): SnapshotData | undefined {
+    const database = this.db.getDatabase();
+    const row = database.prepare('SELECT * FROM snapshots WHERE id = ?').get(id);
+    return row ? this.mapToSnapshot(row) : undefined;
+  }
+
+  getBySandbox(sandboxId: string): SnapshotData[] {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare('SELECT * FROM snapshots WHERE sandbox_id = ? ORDER BY created_at DESC');
+    return stmt.all(sandboxId).map((row: any) => this.mapToSnapshot(row));
+  }
+
+  /**
+   * Record the outcome of a snapshot job
+   */
+  updateStatus(
+    id: string,
+    update: { status: SnapshotStatus; image?: string; imageId?: string; message?: string }
+  ): SnapshotData | undefined {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare(`
+      UPDATE snapshots SET status = ?, image = COALESCE(?, image), image_id = COALESCE(?, image_id), message = ?
+      WHERE id = ?
+    `);
+    stmt.run(update.status, update.image || null, update.imageId || null, update.message || null, id);
+    return this.getById(id);
+  }
+
+  private mapToSnapshot(row: any): SnapshotData {
+    return {
+      id: row.id,
+      sandboxId: row.sandbox_id,
+      status: row.status,
+      image: row.image || undefined,
+      imageId: row.image_id || undefined,
+      sourceImage: row.source_image,
+      env: row.env ? JSON.parse(row.env) : undefined,
+      memory: row.memory || undefined,
+      cpu: row.cpu || undefined,
+      volumes: row.volumes ? JSON.parse(row.volumes) : undefined,
+      excludeVolumes: row.exclude_volumes === 1,
+      checkpoint: row.checkpoint === 1,
+      constraints: row.constraints ? JSON.parse(row.constraints) : undefined,
+      message: row.message || undefined,
+      createdAt: row.created_at,
     };
   }
 }
@@ -343,6 +469,105 @@ export class APIKeyRepository {
   }
 }
 
+export interface RunnerCredentialData {
+  id: string;
+  runnerId: string;
+  createdAt: string;
+  expiresAt: string;
+}
+
+/**
+ * Runner join tokens and credentials. Only SHA-256 hashes of the tokens are
+ * stored.
+ */
+export class RunnerCredentialRepository {
+  private db: SqliteDB;
+
+  constructor(db?: SqliteDB) {
+    this.db = db || require('./database').getDatabase();
+  }
+
+  createJoinToken(tokenHash: string, expiresAt: string): void {
+    const database = this.db.getDatabase();
+    const id = Math.random().toString(36).slice(2);
+    const stmt = database.prepare(`
+      INSERT INTO runner_join_tokens (id, token_hash, created_at, expires_at)
+      VALUES (?, ?, ?, ?)
+    `);
+    stmt.run(id, tokenHash, new Date().toISOString(), expiresAt);
+  }
+
+  /**
+   * Mark an unused, unexpired join token as used by runnerId. Returns false
+   * when there is no such token.
+   */
+  consumeJoinToken(tokenHash: string, runnerId: string): boolean {
+    const database = this.db.getDatabase();
+    const now = new Date().toISOString();
+    const stmt = database.prepare(`
+      UPDATE runner_join_tokens SET used_at = ?, runner_id = ?
+      WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+    `);
+    return stmt.run(now, runnerId, tokenHash, now).changes > 0;
+  }
+
+  create(runnerId: string, tokenHash: string, expiresAt: string): RunnerCredentialData {
+    const database = this.db.getDatabase();
+    const id = Math.random().toString(36).slice(2);
+    const stmt = database.prepare(`
+      INSERT INTO runner_credentials (id, runner_id, token_hash, created_at, expires_at)
+      VALUES (?, ?, ?, ?, ?)
+    `);
+    stmt.run(id, runnerId, tokenHash, new Date().toISOString(), expiresAt);
+    return this.getById(id)!;
+  }
+
+  getById(id: string): RunnerCredentialData | undefined {
+    const database = this.db.getDatabase();
+    const row = database.prepare('SELECT * FROM runner_credentials WHERE id = ?').get(id);
+    return row ? this.mapToCredential(row) : undefined;
+  }
+
+  getByHash(tokenHash: string): RunnerCredentialData | undefined {
+    const database = this.db.getDatabase();
+    const row = database.prepare('SELECT * FROM runner_credentials WHERE token_hash = ?').get(tokenHash);
+    return row ? this.mapToCredential(row) : undefined;
+  }
+
+  /**
+   * Bring a credential's expiry forward to expiresAt, if it is later
+   */
+  expire(id: string, expiresAt: string): void {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare('UPDATE runner_credentials SET expires_at = MIN(expires_at, ?) WHERE id = ?');
+    stmt.run(expiresAt, id);
+  }
+
+  /**
+   * Whether the runner holds a credential that has not expired
+   */
+  hasLive(runnerId: string): boolean {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare('SELECT 1 FROM runner_credentials WHERE runner_id = ? AND expires_at > ? LIMIT 1');
+    return stmt.get(runnerId, new Date().toISOString()) !== undefined;
+  }
+
+  deleteForRunner(runnerId: string): number {
+    const database = this.db.getDatabase();
+    const stmt = database.prepare('DELETE FROM runner_credentials WHERE runner_id = ?');
+    return stmt.run(runnerId).changes;
+  }
+
+  private mapToCredential(row: any): RunnerCredentialData {
+    return {
+      id: row.id,
+      runnerId: row.runner_id,
+      createdAt: row.created_at,
+      expiresAt: row.expires_at,
+    };
+  }
+}
+
 export class AuditLogRepository {
   private db: SqliteDB;
 
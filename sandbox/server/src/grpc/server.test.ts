@@ -0,0 +1,115 @@
+/**
+ * Unit tests for job dispatch over runner job streams
+ */
+
+import { GrpcServer } from './server';
+import { repository } from '../db/repository-adapter';
+import { createJob, getJob, assignJob } from '../services/job';
+
+// A job stream that records the jobs pushed to the runner
+function fakeStream() {
+  const written: any[] = [];
+  return {
+    written,
+    stream: {
+      getPeer: () => 'test',
+      write: (msg: any) => written.push(msg),
+      end: () => undefined,
+    },
+  };
+}
+
+const heartbeat = (freeSlots: number) => ({
+  activeJobs: 1 - freeSlots,
+  freeSlots,
+  runningSandboxes: 0,
+  version: 'test',
+  labels: {},
+  images: [],
+  healthy: true,
+});
+
+describe('GrpcServer', () => {
+  let server: GrpcServer;
+
+  beforeEach(() => {
+    repository.reset();
+    server = new GrpcServer(0);
+  });
+
+  test('should offer a declined job again only once the runner has more room', () => {
+    const { written, stream } = fakeStream();
+    const conn = (server as any).addConnection(stream, { runnerId: 'runner-1', capacity: 1, labels: {}, taints: {} });
+    const job = createJob({ type: 'create', sandboxId: 'sbox-1', image: 'python:3.11', token: '' });
+
+    (server as any).dispatch();
+    expect(written).toHaveLength(1);
+
+    (server as any).handleAck(conn, { jobId: job.id, accepted: false, message: 'pool full' });
+    server.recordHeartbeat('runner-1', heartbeat(0));
+    expect(written).toHaveLength(1);
+
+    server.recordHeartbeat('runner-1', heartbeat(1));
+    expect(written).toHaveLength(2);
+  });
+
+  test('should requeue accepted jobs once their runner misses its heartbeats', () => {
+    server.registerRunner({ id: 'runner-1', address: 'test', capacity: 1, status: 'offline', lastHeartbeat: new Date(Date.now() - 120_000).toISOString() });
+    server.registerRunner({ id: 'runner-2', address: 'test', capacity: 1, status: 'available' });
+    const stale = createJob({ type: 'create', sandboxId: 'sbox-1', image: 'python:3.11', token: '' });
+    const live = createJob({ type: 'create', sandboxId: 'sbox-2', image: 'python:3.11', token: '' });
+    assignJob(stale.id, 'runner-1');
+    assignJob(live.id, 'runner-2');
+
+    (server as any).markOfflineRunners();
+
+    expect(getJob(stale.id)).toMatchObject({ status: 'pending', runnerId: undefined });
+    expect(getJob(live.id)).toMatchObject({ status: 'running', runnerId: 'runner-2' });
+  });
+
+  test('should ignore acks and completions for jobs of other runners', () => {
+    const first = fakeStream();
+    const second = fakeStream();
+    const owner = (server as any).addConnection(first.stream, { runnerId: 'runner-1', capacity: 1, labels: {}, taints: {} });
+    const other = (server as any).addConnection(second.stream, { runnerId: 'runner-2', capacity: 1, labels: {}, taints: {} });
+    const job = createJob({ type: 'delete', sandboxId: 'sbox-1', image: '', token: '' });
+
+    (server as any).dispatch();
+    const pushedTo = first.written.length > 0 ? owner : other;
+    const intruder = pushedTo === owner ? other : owner;
+
+    (server as any).handleAck(intruder, { jobId: job.id, accepted: true, message: '' });
+    expect(getJob(job.id)).toMatchObject({ status: 'pending' });
+
+    (server as any).handleAck(pushedTo, { jobId: job.id, accepted: true, message: '' });
+    (server as any).handleCompletion(intruder, { jobId: job.id, success: true, message: '' });
+    expect(getJob(job.id)).toMatchObject({ status: 'running', runnerId: pushedTo.id });
+
+    (server as any).handleCompletion(pushedTo, { jobId: job.id, success: true, message: '' });
+    expect(getJob(job.id)).toMatchObject({ status: 'completed' });
+  });
+
+  test('should refuse an accept for a job pushed to another runner after the stream dropped', () => {
+    const first = fakeStream();
+    const dropped = (server as any).addConnection(first.stream, { runnerId: 'runner-1', capacity: 1, labels: {}, taints: {} });
+    const job = createJob({ type: 'delete', sandboxId: 'sbox-1', image: '', token: '' });
+    (server as any).dispatch();
+    expect(first.written).toHaveLength(1);
+
+    // The stream closes before the ack arrives and the job goes elsewhere
+    (server as any).connections.delete('runner-1');
+    dropped.inFlight.clear();
+    const second = fakeStream();
+    const other = (server as any).addConnection(second.stream, { runnerId: 'runner-2', capacity: 1, labels: {}, taints: {} });
+    (server as any).dispatch();
+    expect(second.written).toHaveLength(1);
+
+    (server as any).handleAck(dropped, { jobId: job.id, accepted: true, message: '' });
+    expect(first.written[1]).toMatchObject({ assignment: { jobId: job.id, assigned: false } });
+    expect(getJob(job.id)).toMatchObject({ status: 'pending' });
+
+    (server as any).handleAck(other, { jobId: job.id, accepted: true, message: '' });
+    expect(second.written[1]).toMatchObject({ assignment: { jobId: job.id, assigned: true } });
+    expect(getJob(job.id)).toMatchObject({ status: 'running', runnerId: 'runner-2' });
+  });
+});
@@ -1,29 +1,130 @@
 import * as grpc from '@grpc/grpc-js';
+import * as protoLoader from '@grpc/proto-loader';
+import * as path from 'path';
 import { logger } from '../logger';
+import { repository } from '../db/repository-adapter';
+import { Job, jobEvents, getJob, getPendingJobs, assignJob, completeJob, isAssignedTo, releaseRunnerJobs } from '../services/job';
+import { checkPlacement } from '../services/placement';
+import { runnerAuthService } from '../services/runner-auth';
+import { sandboxService } from '../services/sandbox';
+
+// Shared with the runner, whose Go code is generated from it
+const PROTO_PATH = path.join(__dirname, '../../proto/runner.proto');
+
+// A runner that has not sent a heartbeat for this long is marked offline, and
+// the jobs it accepted but did not complete are requeued
+const RUNNER_TIMEOUT_MS = 60_000;
 
 export interface RunnerInfo {
   id: string;
   address: string;
   capacity: number;
-  status: 'available' | 'busy';
+  status: 'available' | 'busy' | 'unhealthy' | 'draining' | 'offline';
+  labels?: Record<string, string>; // Matched against the node selectors of create jobs
+  taints?: Record<string, string>; // Only create jobs that tolerate every taint go here
+  lastHeartbeat?: string;
+  heartbeat?: RunnerHeartbeat;
+  pendingDrain?: DrainRequest; // Sent in the next HTTP heartbeat reply to a runner without a job stream
+}
+
+// Asks a runner to stop taking jobs and leave once its running jobs finish
+export interface DrainRequest {
+  reason: string;
+  deadlineSeconds: number; // 0 uses the runner's configured drain timeout
+  reschedule: boolean;     // Hand the runner's sandboxes back for placement elsewhere
+}
+
+export interface HostResources {
+  cpus: number;
+  load1: number;
+  memoryTotal: number;
+  memoryAvailable: number;
+  diskTotal: number;
+  diskFree: number;
+}
+
+// Capacity, load and inventory reported periodically by a runner
+export interface RunnerHeartbeat {
+  activeJobs: number;
+  freeSlots: number;
+  runningSandboxes: number;
+  version: string;
+  labels: Record<string, string>;
+  resources?: HostResources;
+  images: string[];
+  healthy: boolean;
+  healthMessage?: string;
+  draining?: boolean;
+}
+
+interface RunnerMessage {
+  message?: 'hello' | 'ack' | 'completion' | 'heartbeat' | 'leaving';
+  hello?: { runnerId: string; capacity: number; labels: Record<string, string>; taints: Record<string, string> };
+  ack?: { jobId: string; accepted: boolean; message: string };
+  completion?: { jobId: string; success: boolean; message: string };
+  heartbeat?: RunnerHeartbeat;
+  leaving?: { reason: string; reschedule: boolean };
+}
+
+// Answers a runner's accept; the runner runs the job only once assigned
+interface JobAssignment {
+  jobId: string;
+  assigned: boolean;
+  message: string;
+}
+
+type RunnerStream = grpc.ServerDuplexStream<RunnerMessage, { job: object } | { drain: DrainRequest } | { assignment: JobAssignment }>;
+
+// A runner connected over the job stream
+interface RunnerConnection {
+  id: string;
+  stream: RunnerStream;
+  capacity: number;
+  activeJobs: number;         // Last reported by the runner's heartbeat
+  freeSlots: number;          // Last reported by the runner's heartbeat
+  inFlight: Set<string>;      // Jobs pushed to the runner and not yet completed
+  rejected: Set<string>;      // Jobs the runner turned down since its free slots last went up
 }
 
 export class GrpcServer {
   private server: grpc.Server;
   private port: string;
   private runners: Map<string, RunnerInfo>;
+  private connections: Map<string, RunnerConnection>;
+  private onJobCreated = () => this.dispatch();
+  private sweepTimer?: NodeJS.Timeout;
+  private credentials: grpc.ServerCredentials;
 
-  constructor(port: number = 50051) {
+  // Serves TLS with tls's certificate and key when given
+  constructor(port: number = 50051, tls?: { cert: Buffer; key: Buffer }) {
     this.server = new grpc.Server();
     this.port = `0.0.0.0:${port}`;
+    this.credentials = tls
+      ? grpc.ServerCredentials.createSsl(null, [{ cert_chain: tls.cert, private_key: tls.key }], false)
+      : grpc.ServerCredentials.createInsecure();
     this.runners = new Map();
+    this.connections = new Map();
+
+    const definition = protoLoader.loadSync(PROTO_PATH, {
+      keepCase: false,
+      longs: Number,
+      enums: String,
+      defaults: true,
+      oneofs: true,
+    });
+    const proto = grpc.loadPackageDefinition(definition).runner as any;
+    this.server.addService(proto.RunnerService.service, {
+      connect: (stream: RunnerStream) => this.connect(stream),
+    });
   }
 
   async start(): Promise<void> {
+    jobEvents.on('created', this.onJobCreated);
+    this.sweepTimer = setInterval(() => this.markOfflineRunners(), RUNNER_TIMEOUT_MS / 4);
     return new Promise((resolve, reject) => {
       this.server.bindAsync(
         this.port,
-        grpc.ServerCredentials.createInsecure(),
+        this.credentials,
         (err, port) => {
           if (err) {
             reject(err);
@@ -37,11 +138,118 @@ export class GrpcServer {
   }
 
   stop(): void {
+    jobEvents.off('created', this.onJobCreated);
+    clearInterval(this.sweepTimer);
     this.server.forceShutdown();
   }
 
   registerRunner(info: RunnerInfo): void {
-    this.runners.set(info.id, info);
+    const previous = this.runners.get(info.id);
+    this.runners.set(info.id, {
+      ...info,
+      lastHeartbeat: info.lastHeartbeat ?? previous?.lastHeartbeat ?? new Date().toISOString(),
+      heartbeat: info.heartbeat ?? previous?.heartbeat,
+    });
+  }
+
+  /**
+   * Record a heartbeat received over the job stream or HTTP
+   */
+  recordHeartbeat(id: string, heartbeat: RunnerHeartbeat): RunnerInfo {
+    let runner = this.runners.get(id);
+    if (!runner) {
+      runner = {
+        id,
+        address: '',
+        capacity: heartbeat.activeJobs + heartbeat.freeSlots,
+        status: 'available',
+      };
+      this.runners.set(id, runner);
+    }
+    runner.heartbeat = heartbeat;
+    runner.labels = heartbeat.labels;
+    runner.lastHeartbeat = new Date().toISOString();
+    if (heartbeat.draining) {
+      runner.status = 'draining';
+    }
+
+    const conn = this.connections.get(id);
+    if (conn) {
+      conn.activeJobs = heartbeat.activeJobs;
+      // Declined jobs are offered again once the runner has room for more
+      if (heartbeat.freeSlots > conn.freeSlots) {
+        conn.rejected.clear();
+      }
+      conn.freeSlots = heartbeat.freeSlots;
+      this.updateStatus(conn);
+      this.dispatch();
+    } else if (runner.status !== 'draining') {
+      runner.status = !heartbeat.healthy ? 'unhealthy' : heartbeat.freeSlots > 0 ? 'available' : 'busy';
+    }
+    return runner;
+  }
+
+  /**
+   * Ask a runner to drain: it stops taking jobs, finishes the running ones
+   * and then leaves. Runners without a job stream get the request in their
+   * next heartbeat reply. Returns false for unknown or offline runners.
+   */
+  drainRunner(id: string, request: DrainRequest): boolean {
+    const runner = this.runners.get(id);
+    if (!runner || runner.status === 'offline') {
+      return false;
+    }
+    runner.status = 'draining';
+    logger.info(`Draining runner ${id}: ${request.reason}`);
+
+    const conn = this.connections.get(id);
+    if (conn) {
+      conn.stream.write({ drain: request });
+    } else {
+      runner.pendingDrain = request;
+    }
+    return true;
+  }
+
+  /**
+   * Take the pending drain request of a runner without a job stream
+   */
+  takePendingDrain(id: string): DrainRequest | undefined {
+    const runner = this.runners.get(id);
+    const request = runner?.pendingDrain;
+    if (runner) {
+      runner.pendingDrain = undefined;
+    }
+    return request;
+  }
+
+  /**
+   * Handle a runner that drained and is shutting down. With reschedule, the
+   * sandboxes it ran are queued for placement on the remaining runners.
+   */
+  runnerLeft(id: string, reason: string, reschedule: boolean): void {
+    const runner = this.runners.get(id);
+    if (runner) {
+      runner.status = 'offline';
+      runner.pendingDrain = undefined;
+    }
+    const conn = this.connections.get(id);
+    if (conn) {
+      this.connections.delete(id);
+      conn.stream.end();
+    }
+    logger.info(`Runner ${id} left: ${reason || 'no reason given'}`);
+    this.releaseJobs(id);
+
+    if (reschedule) {
+      for (const sandbox of repository.listSandboxes()) {
+        if (sandbox.runnerId === id && sandbox.status !== 'deleting' && sandbox.status !== 'deleted') {
+          sandboxService.reschedule(sandbox.id);
+          logger.info(`Rescheduled sandbox ${sandbox.id} from runner ${id}`);
+        }
+      }
+    }
+    this.dispatch();
   }
 
   getRunner(id: string): RunnerInfo | undefined {
@@ -51,4 +259,322 @@ export class GrpcServer {
   listRunners(): RunnerInfo[] {
     return Array.from(this.runners.values());
   }
+
+  /**
+   * Whether a runner may take a job. Jobs that place a sandbox (create,
+   * restore and restore-checkpoint) are constrained by their placement
+   * constraints; lifecycle, snapshot and checkpoint jobs go only to
+   * the runner that has the sandbox's container. Delete jobs may go anywhere, so a sandbox whose
+   * runner is gone can still be deleted.
+   */
+  placeable(job: Job, runnerId: string): boolean {
+    if (job.type === 'delete') {
+      return true;
+    }
+    if (job.type !== 'create' && job.type !== 'restore' && job.type !== 'restore-checkpoint') {
+      return repository.getSandbox(job.sandboxId)?.runnerId === runnerId;
+    }
+    const runner = this.runners.get(runnerId);
+    return checkPlacement(job.constraints, runner?.labels, runner?.taints) === undefined;
+  }
+
+  /**
+   * Handle a runner's job stream. The runner authenticates with its
+   * credential in the call metadata and identifies itself with Hello, then
+   * receives jobs and answers with acks, completions and heartbeats.
+   */
+  private connect(stream: RunnerStream): void {
+    let conn: RunnerConnection | undefined;
+
+    const header = stream.metadata.get('authorization')[0];
+    const runnerId = typeof header === 'string' && header.startsWith('Bearer ')
+      ? runnerAuthService.authenticate(header.slice('Bearer '.length))
+      : undefined;
+    if (!runnerId) {
+      stream.emit('error', { code: grpc.status.UNAUTHENTICATED, details: 'invalid or missing runner credential' });
+      return;
+    }
+
+    stream.on('data', (msg: RunnerMessage) => {
+      if (!conn) {
+        if (msg.message !== 'hello' || !msg.hello?.runnerId) {
+          stream.emit('error', { code: grpc.status.FAILED_PRECONDITION, details: 'expected hello' });
+          return;
+        }
+        if (msg.hello.runnerId !== runnerId) {
+          stream.emit('error', { code: grpc.status.PERMISSION_DENIED, details: 'credential belongs to a different runner' });
+          return;
+        }
+        conn = this.addConnection(stream, msg.hello);
+        this.dispatch();
+        return;
+      }
+
+      switch (msg.message) {
+        case 'ack':
+          this.handleAck(conn, msg.ack!);
+          break;
+        case 'completion':
+          this.handleCompletion(conn, msg.completion!);
+          break;
+        case 'heartbeat':
+          this.recordHeartbeat(conn.id, msg.heartbeat!);
+          break;
+        case 'leaving':
+          this.runnerLeft(conn.id, msg.leaving!.reason, msg.leaving!.reschedule);
+          conn = undefined;
+          break;
+      }
+    });
+
+    const close = () => {
+      if (conn && this.connections.get(conn.id) === conn) {
+        this.connections.delete(conn.id);
+        const runner = this.runners.get(conn.id);
+        if (runner) {
+          runner.status = 'offline';
+        }
+        logger.info(`Runner ${conn.id} disconnected from job stream`);
+        conn = undefined;
+        this.dispatch();
+      }
+    };
+    stream.on('end', () => {
+      close();
+      stream.end();
+    });
+    stream.on('error', (err: Error) => {
+      logger.warn(`Runner job stream error: ${err.message}`);
+      close();
+    });
+    stream.on('cancelled', close);
+  }
+
+  private addConnection(stream: RunnerStream, hello: NonNullable<RunnerMessage['hello']>): RunnerConnection {
+    const { runnerId: id, capacity } = hello;
+
+    // A reconnecting runner replaces its old stream
+    const previous = this.connections.get(id);
+    if (previous) {
+      previous.stream.end();
+    }
+
+    const conn: RunnerConnection = {
+      id,
+      stream,
+      capacity: capacity > 0 ? capacity : 10,
+      activeJobs: 0,
+      freeSlots: capacity > 0 ? capacity : 10,
+      inFlight: new Set(),
+      rejected: new Set(),
+    };
+    this.connections.set(id, conn);
+    this.registerRunner({
+      id,
+      address: stream.getPeer(),
+      capacity: conn.capacity,
+      status: 'available',
+      labels: hello.labels,
+      taints: hello.taints,
+    });
+    logger.info(`Runner ${id} connected to job stream (capacity ${conn.capacity})`);
+    return conn;
+  }
+
+  private handleAck(conn: RunnerConnection, ack: NonNullable<RunnerMessage['ack']>): void {
+    if (ack.accepted) {
+      this.handleAccept(conn, ack.jobId);
+      return;
+    }
+    if (!conn.inFlight.has(ack.jobId)) {
+      logger.warn(`Runner ${conn.id} declined job ${ack.jobId}, which was not pushed to it`);
+      return;
+    }
+    logger.debug(`Runner ${conn.id} rejected job ${ack.jobId}: ${ack.message}`);
+    conn.inFlight.delete(ack.jobId);
+    conn.rejected.add(ack.jobId);
+    this.dispatch();
+  }
+
+  /**
+   * Answer a runner that accepted a job. Runners may only take the pending
+   * jobs pushed to them: a job pushed over a stream that dropped before the
+   * ack may have gone to another runner since, so the runner waits for the
+   * answer before it runs the job.
+   */
+  private handleAccept(conn: RunnerConnection, jobId: string): void {
+    const job = getJob(jobId);
+    const assigned = isAssignedTo(jobId, conn.id) ||
+      (conn.inFlight.has(jobId) && job?.status === 'pending' && assignJob(jobId, conn.id));
+    conn.stream.write({
+      assignment: { jobId, assigned, message: assigned ? '' : 'Job is not offered to this runner' },
+    });
+    if (assigned) {
+      return;
+    }
+    logger.warn(`Refused job ${jobId} to runner ${conn.id}, which it is not offered to`);
+    if (conn.inFlight.delete(jobId)) {
+      this.updateStatus(conn);
+      this.dispatch();
+    }
+  }
+
+  private handleCompletion(conn: RunnerConnection, completion: NonNullable<RunnerMessage['completion']>): void {
+    // Jobs accepted over an earlier stream are no longer in flight but stay
+    // assigned to the runner
+    if (!conn.inFlight.has(completion.jobId) && !isAssignedTo(completion.jobId, conn.id)) {
+      logger.warn(`Runner ${conn.id} completed job ${completion.jobId}, which is not assigned to it`);
+      return;
+    }
+    const message = completion.message || (completion.success ? 'Job completed' : 'Job failed');
+    completeJob(completion.jobId, completion.success);
+    repository.log('COMPLETE', 'job', completion.jobId, conn.id, { success: completion.success, message });
+    conn.inFlight.delete(completion.jobId);
+    this.updateStatus(conn);
+    this.dispatch();
+  }
+
+  private updateStatus(conn: RunnerConnection): void {
+    const runner = this.runners.get(conn.id);
+    // A draining runner stays draining until it leaves or registers again
+    if (!runner || runner.status === 'draining') {
+      return;
+    }
+    if (runner.heartbeat && !runner.heartbeat.healthy) {
+      runner.status = 'unhealthy';
+    } else {
+      runner.status = this.freeSlots(conn) > 0 ? 'available' : 'busy';
+    }
+  }
+
+  /**
+   * Mark runners that stopped sending heartbeats offline, closing their job
+   * stream so the jobs pushed to them go to other runners. Their lease on the
+   * jobs they accepted has expired too, including runners whose stream
+   * closed earlier and that did not reconnect.
+   */
+  private markOfflineRunners(): void {
+    const cutoff = Date.now() - RUNNER_TIMEOUT_MS;
+    for (const runner of this.runners.values()) {
+      if (!runner.lastHeartbeat || Date.parse(runner.lastHeartbeat) > cutoff) {
+        continue;
+      }
+      this.releaseJobs(runner.id);
+      if (runner.status === 'offline') {
+        continue;
+      }
+      logger.warn(`Runner ${runner.id} missed its heartbeats, marking it offline`);
+      runner.status = 'offline';
+
+      const conn = this.connections.get(runner.id);
+      if (conn) {
+        this.connections.delete(runner.id);
+        conn.stream.end();
+      }
+    }
+    this.dispatch();
+  }
+
+  /**
+   * Requeue the jobs a runner that is gone had accepted
+   */
+  private releaseJobs(runnerId: string): void {
+    const released = releaseRunnerJobs(runnerId);
+    if (released > 0) {
+      logger.warn(`Requeued ${released} job(s) accepted by runner ${runnerId}`);
+    }
+  }
+
+  private freeSlots(conn: RunnerConnection): number {
+    return conn.capacity - Math.max(conn.inFlight.size, conn.activeJobs);
+  }
+
+  /**
+   * Push pending jobs that are not already with a runner. A sandbox's jobs go
+   * to the runner that owns it when that runner is connected.
+   */
+  private dispatch(): void {
+    if (this.connections.size === 0) {
+      return;
+    }
+
+    const offered = new Set<string>();
+    for (const conn of this.connections.values()) {
+      conn.inFlight.forEach((id) => offered.add(id));
+    }
+
+    for (const job of getPendingJobs()) {
+      if (offered.has(job.id)) {
+        continue;
+      }
+      const conn = this.pickRunner(job);
+      if (!conn) {
+        continue;
+      }
+      conn.inFlight.add(job.id);
+      offered.add(job.id);
+      this.updateStatus(conn);
+      conn.stream.write({ job: toJobMessage(job) });
+      logger.debug(`Pushed job ${job.id} to runner ${conn.id}`);
+    }
+  }
+
+  private pickRunner(job: Job): RunnerConnection | undefined {
+    const available = (conn: RunnerConnection) =>
+      this.freeSlots(conn) > 0 && !conn.rejected.has(job.id) && this.accepting(conn.id) && this.placeable(job, conn.id);
+
+    const owner = repository.getSandbox(job.sandboxId)?.runnerId;
+    if (owner) {
+      const conn = this.connections.get(owner);
+      if (conn && available(conn)) {
+        return conn;
+      }
+    }
+
+    // Otherwise the least-loaded runner: most free slots, then lowest load per CPU
+    let best: RunnerConnection | undefined;
+    for (const conn of this.connections.values()) {
+      if (!available(conn)) {
+        continue;
+      }
+      if (!best || this.freeSlots(conn) > this.freeSlots(best) ||
+          (this.freeSlots(conn) === this.freeSlots(best) && this.loadPerCPU(conn) < this.loadPerCPU(best))) {
+        best = conn;
+      }
+    }
+    return best;
+  }
+
+  /**
+   * Whether a runner takes new jobs: not unhealthy and not draining
+   */
+  accepting(runnerId: string): boolean {
+    const status = this.runners.get(runnerId)?.status;
+    return status !== 'unhealthy' && status !== 'draining';
+  }
+
+  private loadPerCPU(conn: RunnerConnection): number {
+    const resources = this.runners.get(conn.id)?.heartbeat?.resources;
+    return resources && resources.cpus > 0 ? resources.load1 / resources.cpus : 0;
+  }
+}
+
+function toJobMessage(job: Job): object {
+  return {
+    id: job.id,
+    type: job.type,
+    sandboxId: job.sandboxId,
+    image: job.image,
+    token: job.token,
+    env: job.env || {},
+    memory: job.memory || '',
+    cpu: job.cpu || 0,
+    networkMode: job.networkMode || '',
+    traceContext: job.traceContext || {},
+    constraints: job.constraints || null,
+    volumes: job.volumes || [],
+    snapshotId: job.snapshotId || '',
+    excludeVolumes: job.excludeVolumes || false,
+    leaveRunning: job.leaveRunning || false,
+  };
 }
@@ -7,13 +7,28 @@ import { createServer as httpCreateServer, IncomingMessage, ServerResponse } fro
 import { createServer as httpsCreateServer } from 'https';
 import * as fs from 'fs';
 import * as path from 'path';
+import { createHmac, timingSafeEqual } from 'crypto';
 import { sandboxService } from './services/sandbox';
 import { volumeService } from './services/volume';
-import { createJob, getPendingJobs, assignJob, completeJob, getAllJobs } from './services/job';
+import { snapshotService } from './services/snapshot';
+import { createJob, getJob, getPendingJobs, assignJob, completeJob, isAssignedTo, getAllJobs, Job, JobType, LifecycleJobType } from './services/job';
 import { repository } from './db/repository-adapter';
-import { Sandbox, CreateSandboxRequest, ErrorResponse, SandboxStatus } from './types';
-import { GrpcServer } from './grpc/server';
+import {
+  Sandbox,
+  CreateSandboxRequest,
+  ErrorResponse,
+  SandboxStatus,
+  AgentMetrics,
+  ListeningPort,
+  CreateSnapshotRequest,
+  CreateCheckpointRequest,
+  RestoreSnapshotRequest,
+  SnapshotStatus,
+} from './types';
+import { GrpcServer, RunnerHeartbeat } from './grpc/server';
 import { sshCAService } from './services/ssh-ca';
+import { tlsCAService, CertificateUsage, authorizeCertificate, CertificateSandbox } from './services/tls-ca';
+import { runnerAuthService, IssuedCredential } from './services/runner-auth';
 import { v2Router } from './registry/routes/v2';
 import { createRegistryMiddleware } from './registry/proxy';
 import { logger } from './logger';
@@ -46,7 +61,7 @@ app.use(express.json());
 app.use((req: Request, res: Response, next: NextFunction) => {
   res.header('Access-Control-Allow-Origin', '*');
   res.header('Access-Control-Allow-Methods', 'GET, POST, PUT, DELETE, OPTIONS');
-  res.header('Access-Control-Allow-Headers', 'Content-Type, X-API-Key, X-Runner-Id, Accept');
+  res.header('Access-Control-Allow-Headers', 'Content-Type, Authorization, X-API-Key, X-Runner-Id, Accept');
 
   if (req.method === 'OPTIONS') {
     return res.status(204).end();
@@ -92,6 +107,68 @@ function sendError(res: Response, code: number, message: string, details?: strin
   res.status(code).json(error);
 }
 
+// W3C trace context of the request, stored on the jobs it queues so the
+// runner continues the caller's trace
+function traceContextFromHeaders(req: Request): Record<string, string> | undefined {
+  const traceparent = req.headers['traceparent'] as string;
+  if (!traceparent) return undefined;
+
+  const traceContext: Record<string, string> = { traceparent };
+  const tracestate = req.headers['tracestate'] as string;
+  if (tracestate) traceContext.tracestate = tracestate;
+  return traceContext;
+}
+
+// Agent event as posted to the events endpoint
+interface AgentEvent {
+  type: string;
+  severity?: string;
+  timestamp?: string;
+  attributes?: Record<string, string>;
+}
+
+// Check that a posted event has a type made of letters, digits, dots,
+// dashes and underscores, and optional string fields and attributes
+function isAgentEvent(event: unknown): event is AgentEvent {
+  if (!event || typeof event !== 'object') return false;
+  const { type, severity, timestamp, attributes } = event as Record<string, unknown>;
+  if (typeof type !== 'string' || !/^[A-Za-z0-9._-]+$/.test(type)) return false;
+  if (severity !== undefined && typeof severity !== 'string') return false;
+  if (timestamp !== undefined && typeof timestamp !== 'string') return false;
+  if (attributes === undefined) return true;
+  return !!attributes && typeof attributes === 'object' && !Array.isArray(attributes) &&
+    Object.values(attributes).every((value) => typeof value === 'string');
+}
+
+// Track the ports the agent reports opening and closing, so clients can
+// list them and offer to forward them
+function applyPortEvents(sandbox: Sandbox, events: AgentEvent[]): void {
+  const portEvents = events.filter((event) => event.type === 'port.opened' || event.type === 'port.closed');
+  if (portEvents.length === 0) return;
+
+  const ports = new Map<number, ListeningPort>();
+  for (const port of sandbox.agentInfo?.ports || []) {
+    ports.set(port.port, port);
+  }
+  for (const event of portEvents) {
+    const port = parseInt(event.attributes?.port || '', 10);
+    if (!port) continue;
+    if (event.type === 'port.closed') {
+      ports.delete(port);
+      continue;
+    }
+    ports.set(port, {
+      port,
+      address: event.attributes?.address,
+      protocol: event.attributes?.protocol,
+      pid: parseInt(event.attributes?.pid || '', 10) || undefined,
+      process: event.attributes?.process || undefined,
+      openedAt: event.timestamp || new Date().toISOString(),
+    });
+  }
+  repository.updateAgentPorts(sandbox.id, [...ports.values()].sort((a, b) => a.port - b.port));
+}
+
 // API Key authentication middleware
 async function authenticate(req: Request): Promise<boolean> {
   const apiKey = req.headers['x-api-key'] as string;
@@ -100,6 +177,96 @@ async function authenticate(req: Request): Promise<boolean> {
   return repository.validateAPIKey(apiKey) !== undefined;
 }
 
+// Runner authentication: runners send the credential they were issued at
+// enrollment as a bearer token. Sends 401 and returns undefined when it is
+// missing or not valid, otherwise returns the runner's ID.
+function authenticateRunner(req: Request, res: Response): string | undefined {
+  const header = req.headers['authorization'] as string;
+  const runnerId = header && header.startsWith('Bearer ')
+    ? runnerAuthService.authenticate(header.slice('Bearer '.length))
+    : undefined;
+  if (!runnerId) {
+    sendError(res, 401, 'Invalid or missing runner credential');
+    return undefined;
+  }
+  return runnerId;
+}
+
+// Authenticate a runner and check it is the one named in the request path
+function authorizeRunnerPath(req: Request, res: Response, pathRunnerId: string): boolean {
+  const runnerId = authenticateRunner(req, res);
+  if (!runnerId) {
+    return false;
+  }
+  if (runnerId !== pathRunnerId) {
+    sendError(res, 403, 'Credential belongs to a different runner');
+    return false;
+  }
+  return true;
+}
+
+// Whether a polling runner is offered a pending job. Create jobs it cannot
+// satisfy and lifecycle jobs for other runners' sandboxes are left to those
+// runners, and every job to others while it drains.
+function offeredTo(job: Job, runnerId: string): boolean {
+  if (grpcServer.getRunner(runnerId)?.status === 'draining') {
+    return false;
+  }
+  return job.runnerId === runnerId || grpcServer.placeable(job, runnerId);
+}
+
+// Look up a sandbox named in a certificate request. Its runner is the one
+// working on a job for it, since the runner only reports the sandbox once
+// its agent is up, and otherwise the one it was last placed on.
+function certificateSandbox(sandboxId: string): CertificateSandbox | undefined {
+  const sandbox = repository.getSandbox(sandboxId);
+  if (!sandbox) {
+    return undefined;
+  }
+  const assigned = getAllJobs().find((job) => job.sandboxId === sandboxId && job.status === 'running');
+  return { token: sandbox.token, runnerId: assigned?.runnerId || sandbox.runnerId };
+}
+
+// Heartbeat interval suggested to agents in status responses
+const AGENT_HEARTBEAT_INTERVAL_SECS = parseInt(process.env.AGENT_HEARTBEAT_INTERVAL_SECS || '30', 10);
+
+// Agent token authentication: agents send their sandbox token as a bearer token.
+// A sandbox without a token cannot be reported on.
+function agentTokenMatches(req: Request, sandbox: Sandbox): boolean {
+  if (!sandbox.token) return false;
+
+  const header = req.headers['authorization'] as string;
+  if (!header || !header.startsWith('Bearer ')) return false;
+
+  const given = Buffer.from(header.slice('Bearer '.length));
+  const expected = Buffer.from(sandbox.token);
+  return given.length === expected.length && timingSafeEqual(given, expected);
+}
+
+// Maximum lifetime of signed preview URLs and scoped agent tokens
+const SIGNED_TTL_MAX_SECS = 7 * 24 * 60 * 60;
+
+// Default lifetime of signed preview URLs
+const PREVIEW_URL_TTL_SECS = parseInt(process.env.PREVIEW_URL_TTL_SECS || '3600', 10);
+
+// Sign a preview URL the way the agent's preview proxy verifies it:
+// HMAC-SHA256 over "<sandboxId>:<port>:<expires>" keyed with the agent token
+function signPreview(token: string, sandboxId: string, port: number, expires: number): string {
+  return createHmac('sha256', token).update(`${sandboxId}:${port}:${expires}`).digest('hex');
+}
+
+// Default lifetime of scoped agent tokens
+const SCOPED_TOKEN_TTL_SECS = parseInt(process.env.SCOPED_TOKEN_TTL_SECS || '3600', 10);
+const SCOPED_TOKEN_SCOPES = ['*', 'exec'];
+
+// Sign a scoped token the way the agent verifies it:
+// cp1.<base64url claims>.<base64url HMAC-SHA256(agent token, claims)>
+function signScopedToken(token: string, sandboxId: string, scopes: string[], expires: number): string {
+  const payload = Buffer.from(JSON.stringify({ sid: sandboxId, scopes, exp: expires })).toString('base64url');
+  const signature = createHmac('sha256', token).update(payload).digest('base64url');
+  return `cp1.${payload}.${signature}`;
+}
+
 // API routes handler - adapted for Express
 async function handleAPIRequest(req: Request, res: Response): Promise<void> {
   const url = req.originalUrl;
@@ -114,7 +281,7 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
       return;
     }
 
-    const result = sandboxService.create(body as CreateSandboxRequest);
+    const result = sandboxService.create(body as CreateSandboxRequest, traceContextFromHeaders(req));
     repository.log('CREATE', 'sandbox', result.sandbox.id, undefined, { image: result.sandbox.image });
     res.status(201).json(result);
     return;
@@ -130,11 +297,9 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
   const statusMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/status$/);
   if (statusMatch && method === 'POST') {
     const sandboxId = statusMatch[1];
-    const data = req.body as {
+    const data = req.body as AgentMetrics & {
       status: string;
-      cpuPercent?: number;
-      memoryMB?: number;
-      sessionCount?: number;
+      hostname?: string;
     };
 
     const sandbox = repository.getSandbox(sandboxId);
@@ -143,21 +308,74 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
       return;
     }
 
+    if (!agentTokenMatches(req, sandbox)) {
+      sendError(res, 401, 'Invalid agent token');
+      return;
+    }
+
     // Update agent info
     repository.updateAgentInfo(sandboxId, {
+      hostname: data.hostname,
       metrics: {
         cpuPercent: data.cpuPercent,
         memoryMB: data.memoryMB,
+        memoryLimitMB: data.memoryLimitMB,
+        pids: data.pids,
+        processCount: data.processCount,
         sessionCount: data.sessionCount,
+        ioReadBytes: data.ioReadBytes,
+        ioWriteBytes: data.ioWriteBytes,
+        netRxBytes: data.netRxBytes,
+        netTxBytes: data.netTxBytes,
+        diskUsedMB: data.diskUsedMB,
+        diskTotalMB: data.diskTotalMB,
+        uptimeSecs: data.uptimeSecs,
       }
     });
 
-    // If status is stopped, update sandbox status
-    if (data.status === 'stopped') {
-      repository.updateSandbox(sandboxId, { status: 'stopped' });
+    // If the agent (or its workload) stopped or failed, update sandbox status
+    if (data.status === 'stopped' || data.status === 'failed') {
+      repository.updateSandbox(sandboxId, { status: data.status as SandboxStatus });
     }
 
-    res.status(200).json({ success: true, sandboxId });
+    res.status(200).json({ success: true, sandboxId, heartbeatIntervalSecs: AGENT_HEARTBEAT_INTERVAL_SECS });
+    return;
+  }
+
+  // Agent event endpoint: in-sandbox activity recorded in the audit log
+  const eventsMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/events$/);
+  if (eventsMatch && method === 'POST') {
+    const sandboxId = eventsMatch[1];
+    const data = req.body as { events?: unknown[] };
+
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    if (!agentTokenMatches(req, sandbox)) {
+      sendError(res, 401, 'Invalid agent token');
+      return;
+    }
+
+    // Malformed events are skipped rather than failing the whole batch, and
+    // attributes cannot override the event's own fields
+    const posted = Array.isArray(data?.events) ? data.events : [];
+    const events = posted.filter(isAgentEvent);
+    if (events.length < posted.length) {
+      logger.warn(`Skipped ${posted.length - events.length} malformed event(s) from sandbox ${sandboxId}`);
+    }
+    for (const event of events) {
+      repository.log(`AGENT_${event.type.toUpperCase().replace(/\./g, '_')}`, 'sandbox', sandboxId, 'agent', {
+        ...event.attributes,
+        severity: event.severity,
+        timestamp: event.timestamp,
+      });
+    }
+    applyPortEvents(sandbox, events);
+
+    res.status(200).json({ success: true, sandboxId, accepted: events.length, skipped: posted.length - events.length });
     return;
   }
 
@@ -165,10 +383,8 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
   const runnerStatusMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/runner-status$/);
   if (runnerStatusMatch && method === 'POST') {
     const sandboxId = runnerStatusMatch[1];
-    const runnerId = req.headers['x-runner-id'] as string;
-
+    const runnerId = authenticateRunner(req, res);
     if (!runnerId) {
-      sendError(res, 400, 'Missing X-Runner-Id header');
       return;
     }
 
@@ -192,6 +408,12 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
       return;
     }
 
+    // A started or unpaused agent gets a full heartbeat window before the
+    // cleanup task counts it as gone
+    if (data.status === 'running' && sandbox.status !== 'running' && sandbox.agentInfo) {
+      repository.updateAgentInfo(sandboxId, {});
+    }
+
     // Update sandbox status
     repository.updateSandboxRunnerStatus(sandboxId, {
       runnerId,
@@ -211,14 +433,97 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // Snapshot job outcome, reported by the runner that took it
+  const snapshotStatusMatch = path.match(/^\/api\/v1\/snapshots\/([a-zA-Z0-9-]+)\/runner-status$/);
+  if (snapshotStatusMatch && method === 'POST') {
+    const snapshotId = snapshotStatusMatch[1];
+    const runnerId = authenticateRunner(req, res);
+    if (!runnerId) {
+      return;
+    }
+
+    const data = req.body as { status: SnapshotStatus; image?: string; imageId?: string; message?: string };
+    if (data?.status !== 'ready' && data?.status !== 'failed') {
+      sendError(res, 400, 'status must be ready or failed');
+      return;
+    }
+
+    const snapshot = snapshotService.get(snapshotId);
+    if (!snapshot) {
+      sendError(res, 404, 'Snapshot not found');
+      return;
+    }
+    const sandbox = repository.getSandbox(snapshot.sandboxId);
+    if (sandbox?.runnerId && sandbox.runnerId !== runnerId) {
+      sendError(res, 403, 'Sandbox is assigned to a different runner');
+      return;
+    }
+
+    snapshotService.updateStatus(snapshotId, data);
+    repository.log('UPDATE', 'snapshot', snapshotId, runnerId, {
+      status: data.status,
+      image: data.image,
+      message: data.message,
+    });
+    res.status(200).json({ success: true, snapshotId, status: data.status });
+    return;
+  }
+
+  // Checkpoint archives, uploaded by the runner that took the checkpoint and
+  // downloaded by the runner that restores it
+  const checkpointArchiveMatch = path.match(/^\/api\/v1\/snapshots\/([a-zA-Z0-9-]+)\/checkpoint$/);
+  if (checkpointArchiveMatch && (method === 'PUT' || method === 'GET')) {
+    const snapshotId = checkpointArchiveMatch[1];
+    const runnerId = authenticateRunner(req, res);
+    if (!runnerId) {
+      return;
+    }
+
+    const snapshot = snapshotService.get(snapshotId);
+    if (!snapshot || !snapshot.checkpoint) {
+      sendError(res, 404, 'Checkpoint not found');
+      return;
+    }
+    const sandbox = repository.getSandbox(snapshot.sandboxId);
+    if (sandbox?.runnerId && sandbox.runnerId !== runnerId) {
+      sendError(res, 403, 'Sandbox is assigned to a different runner');
+      return;
+    }
+
+    if (method === 'PUT') {
+      await snapshotService.saveCheckpointArchive(snapshotId, req);
+      repository.log('UPLOAD', 'snapshot', snapshotId, runnerId, { checkpoint: true });
+      res.status(200).json({ success: true, snapshotId });
+      return;
+    }
+
+    const archive = snapshotService.openCheckpointArchive(snapshotId);
+    if (!archive) {
+      sendError(res, 404, 'Checkpoint archive not found');
+      return;
+    }
+    res.status(200).type('application/gzip');
+    archive.pipe(res);
+    return;
+  }
+
+  const snapshotMatch = path.match(/^\/api\/v1\/snapshots\/([a-zA-Z0-9-]+)$/);
+  if (snapshotMatch && method === 'GET') {
+    const snapshot = snapshotService.get(snapshotMatch[1]);
+    if (!snapshot) {
+      sendError(res, 404, 'Snapshot not found');
+      return;
+    }
+    res.status(200).json({ snapshot });
+    return;
+  }
+
   // Agent address update endpoint (push agent gRPC address from runner)
   const agentAddressMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/agent-address$/);
   if (agentAddressMatch && method === 'POST') {
     const sandboxId = agentAddressMatch[1];
-    const runnerId = req.headers['x-runner-id'] as string;
-
+    const runnerId = authenticateRunner(req, res);
     if (!runnerId) {
-      sendError(res, 400, 'Missing X-Runner-Id header');
       return;
     }
 
@@ -276,6 +581,119 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // Snapshots of a sandbox's container filesystem (must be before generic :id routes)
+  const snapshotsMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/snapshots$/);
+  if (snapshotsMatch && method === 'POST') {
+    const sandboxId = snapshotsMatch[1];
+    if (!sandboxService.get(sandboxId)) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    const data = (req.body || {}) as CreateSnapshotRequest;
+    let result;
+    try {
+      result = snapshotService.create(sandboxId, data, traceContextFromHeaders(req));
+    } catch (error) {
+      sendError(res, 409, error instanceof Error ? error.message : String(error));
+      return;
+    }
+    repository.log('SNAPSHOT', 'sandbox', sandboxId, undefined, {
+      snapshotId: result.snapshot.id,
+      jobId: result.job.id,
+      excludeVolumes: result.snapshot.excludeVolumes,
+    });
+    res.status(202).json({ snapshot: result.snapshot, jobId: result.job.id });
+    return;
+  }
+
+  if (snapshotsMatch && method === 'GET') {
+    const sandboxId = snapshotsMatch[1];
+    const snapshots = snapshotService.list(sandboxId);
+    res.status(200).json({ snapshots, total: snapshots.length });
+    return;
+  }
+
+  // Restore a snapshot into a new sandbox
+  const restoreMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/snapshots\/([a-zA-Z0-9-]+)\/restore$/);
+  if (restoreMatch && method === 'POST') {
+    const [, sandboxId, snapshotId] = restoreMatch;
+    const snapshot = snapshotService.get(snapshotId);
+    if (!snapshot || snapshot.sandboxId !== sandboxId) {
+      sendError(res, 404, 'Snapshot not found');
+      return;
+    }
+
+    const data = (req.body || {}) as RestoreSnapshotRequest;
+    let result;
+    try {
+      result = snapshotService.restore(snapshotId, data, traceContextFromHeaders(req));
+    } catch (error) {
+      sendError(res, 409, error instanceof Error ? error.message : String(error));
+      return;
+    }
+    repository.log('RESTORE', 'sandbox', result.sandbox.id, undefined, { snapshotId, sourceSandboxId: sandboxId });
+    res.status(202).json(result);
+    return;
+  }
+
+  // Checkpoints of a sandbox's processes and filesystem
+  const checkpointsMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/checkpoints$/);
+  if (checkpointsMatch && method === 'POST') {
+    const sandboxId = checkpointsMatch[1];
+    if (!sandboxService.get(sandboxId)) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    const data = (req.body || {}) as CreateCheckpointRequest;
+    let result;
+    try {
+      result = snapshotService.checkpoint(sandboxId, data, traceContextFromHeaders(req));
+    } catch (error) {
+      sendError(res, 409, error instanceof Error ? error.message : String(error));
+      return;
+    }
+    repository.log('CHECKPOINT', 'sandbox', sandboxId, undefined, {
+      snapshotId: result.snapshot.id,
+      jobId: result.job.id,
+      leaveRunning: result.job.leaveRunning === true,
+    });
+    res.status(202).json({ snapshot: result.snapshot, jobId: result.job.id });
+    return;
+  }
+
+  if (checkpointsMatch && method === 'GET') {
+    const sandboxId = checkpointsMatch[1];
+    const checkpoints = snapshotService.listCheckpoints(sandboxId);
+    res.status(200).json({ checkpoints, total: checkpoints.length });
+    return;
+  }
+
+  // Resume a stopped sandbox from one of its checkpoints
+  const restoreCheckpointMatch = path.match(
+    /^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/checkpoints\/([a-zA-Z0-9-]+)\/restore$/
+  );
+  if (restoreCheckpointMatch && method === 'POST') {
+    const [, sandboxId, snapshotId] = restoreCheckpointMatch;
+    if (!sandboxService.get(sandboxId)) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    let sandbox;
+    try {
+      sandbox = snapshotService.restoreCheckpoint(sandboxId, snapshotId, traceContextFromHeaders(req));
+    } catch (error) {
+      const message = error instanceof Error ? error.message : String(error);
+      sendError(res, message === 'Checkpoint not found' ? 404 : 409, message);
+      return;
+    }
+    repository.log('RESTORE', 'sandbox', sandboxId, undefined, { snapshotId, checkpoint: true });
+    res.status(202).json({ sandbox });
+    return;
+  }
+
   if (path.startsWith('/api/v1/sandboxes/') && method === 'GET') {
     const id = path.split('/').pop();
     if (!id) {
@@ -314,6 +732,7 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
         sandboxId: id,
         image: sandbox.image,
         token: sandbox.token || '',
+        traceContext: traceContextFromHeaders(req),
       });
       // Update status to deleting
       sandboxService.updateStatus(id, 'deleting');
@@ -326,6 +745,101 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // Lifecycle of an existing sandbox, carried out by its runner
+  const lifecycleMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/(stop|start|restart|pause|unpause|resize)$/);
+  if (lifecycleMatch && method === 'POST') {
+    const [, sandboxId, action] = lifecycleMatch;
+    if (!sandboxService.get(sandboxId)) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    const data = (req.body || {}) as { memory?: string; cpu?: number };
+    let job;
+    try {
+      job = sandboxService.lifecycle(sandboxId, action as LifecycleJobType, data, traceContextFromHeaders(req));
+    } catch (error) {
+      sendError(res, 409, error instanceof Error ? error.message : String(error));
+      return;
+    }
+    repository.log(action.toUpperCase(), 'sandbox', sandboxId, undefined, { jobId: job.id, memory: data.memory, cpu: data.cpu });
+    res.status(202).json({ success: true, jobId: job.id });
+    return;
+  }
+
+  // TCP ports listening inside the sandbox, as last reported by the agent
+  const portsMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/ports$/);
+  if (portsMatch && method === 'GET') {
+    const sandboxId = portsMatch[1];
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    res.status(200).json({ sandboxId, ports: sandbox.agentInfo?.ports || [] });
+    return;
+  }
+
+  // Signed preview URL for a web server listening inside the sandbox
+  const previewMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/preview$/);
+  if (previewMatch && method === 'POST') {
+    const sandboxId = previewMatch[1];
+    const port = parseInt(String(req.body?.port), 10);
+    if (!port || port <= 0 || port > 65535) {
+      sendError(res, 400, 'Invalid port');
+      return;
+    }
+
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox || !sandbox.token || !sandbox.port) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    const ttl = parseInt(String(req.body?.ttlSeconds || PREVIEW_URL_TTL_SECS), 10);
+    if (!ttl || ttl <= 0 || ttl > SIGNED_TTL_MAX_SECS) {
+      sendError(res, 400, 'Invalid ttlSeconds');
+      return;
+    }
+
+    const expires = Math.floor(Date.now() / 1000) + ttl;
+    const signature = signPreview(sandbox.token, sandboxId, port, expires);
+    const url = `http://${sandbox.host}:${sandbox.port}/proxy/${port}/?preview_expires=${expires}&preview_signature=${signature}`;
+
+    res.status(200).json({ url, expiresAt: new Date(expires * 1000).toISOString() });
+    return;
+  }
+
+  // Scoped, expiring token for the sandbox's agent
+  const scopedTokenMatch = path.match(/^\/api\/v1\/sandboxes\/([a-zA-Z0-9-]+)\/scoped-token$/);
+  if (scopedTokenMatch && method === 'POST') {
+    const sandboxId = scopedTokenMatch[1];
+    const scopes: string[] = Array.isArray(req.body?.scopes) ? req.body.scopes : [];
+    if (scopes.length === 0 || scopes.some((scope) => !SCOPED_TOKEN_SCOPES.includes(scope))) {
+      sendError(res, 400, `Invalid scopes: expected any of ${SCOPED_TOKEN_SCOPES.join(', ')}`);
+      return;
+    }
+
+    const ttl = parseInt(String(req.body?.ttlSeconds || SCOPED_TOKEN_TTL_SECS), 10);
+    if (!ttl || ttl <= 0 || ttl > SIGNED_TTL_MAX_SECS) {
+      sendError(res, 400, 'Invalid ttlSeconds');
+      return;
+    }
+
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox || !sandbox.token) {
+      sendError(res, 404, 'Sandbox not found');
+      return;
+    }
+
+    const expires = Math.floor(Date.now() / 1000) + ttl;
+    const token = signScopedToken(sandbox.token, sandboxId, scopes, expires);
+
+    res.status(200).json({ token, expiresAt: new Date(expires * 1000).toISOString() });
+    return;
+  }
+
   if (path.startsWith('/api/v1/sandboxes/') && path.endsWith('/token') && method === 'POST') {
     const parts = path.split('/');
     const id = parts[parts.length - 2];
@@ -389,23 +903,84 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // Runner enrollment: admins create join tokens, runners exchange them for
+  // credentials and rotate those before they expire
+  if (path === '/api/v1/runners/join-tokens' && method === 'POST') {
+    if (!(await authenticate(req))) {
+      sendError(res, 401, 'Invalid or missing API key');
+      return;
+    }
+    const data = (req.body || {}) as { ttlSeconds?: number };
+    const joinToken = runnerAuthService.createJoinToken(data.ttlSeconds);
+    repository.log('CREATE', 'runner_join_token', undefined, undefined, { expiresAt: joinToken.expiresAt });
+    res.status(201).json(joinToken);
+    return;
+  }
+
+  if (path === '/api/v1/runners/enroll' && method === 'POST') {
+    const data = (req.body || {}) as { runnerId?: string; joinToken?: string };
+    if (!data.runnerId || !data.joinToken) {
+      sendError(res, 400, 'Missing runnerId or joinToken');
+      return;
+    }
+    let credential: IssuedCredential | undefined;
+    try {
+      credential = runnerAuthService.enroll(data.joinToken, data.runnerId);
+    } catch (error) {
+      sendError(res, 409, error instanceof Error ? error.message : String(error));
+      return;
+    }
+    if (!credential) {
+      sendError(res, 401, 'Invalid or expired join token');
+      return;
+    }
+    repository.log('ENROLL', 'runner', data.runnerId, data.runnerId, { expiresAt: credential.expiresAt });
+    res.status(200).json(credential);
+    return;
+  }
+
+  if (path === '/api/v1/runners/credentials/rotate' && method === 'POST') {
+    const header = req.headers['authorization'] as string;
+    const credential = header && header.startsWith('Bearer ')
+      ? runnerAuthService.rotate(header.slice('Bearer '.length))
+      : undefined;
+    if (!credential) {
+      sendError(res, 401, 'Invalid or missing runner credential');
+      return;
+    }
+    res.status(200).json(credential);
+    return;
+  }
+
   // Runner registration routes
   if (path === '/api/v1/runners/register' && method === 'POST') {
+    const authenticatedId = authenticateRunner(req, res);
+    if (!authenticatedId) {
+      return;
+    }
     const body = req.body;
     const data = body as Record<string, unknown>;
     const runnerId = data.id as string;
     const capacity = data.capacity as number || 10;
+    const labels = (data.labels as Record<string, string>) || {};
+    const taints = (data.taints as Record<string, string>) || {};
 
     if (!runnerId) {
       sendError(res, 400, 'Missing runner ID');
       return;
     }
+    if (runnerId !== authenticatedId) {
+      sendError(res, 403, 'Credential belongs to a different runner');
+      return;
+    }
 
     const runner = {
       id: runnerId,
       address: '', // Will be populated from request
       capacity,
       status: 'available' as const,
+      labels,
+      taints,
     };
 
     grpcServer.registerRunner(runner);
@@ -413,10 +988,86 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  if (path === '/api/v1/runners' && method === 'GET') {
+    res.status(200).json({ runners: grpcServer.listRunners() });
+    return;
+  }
+
+  // Heartbeat from a runner that is not connected over the job stream
+  const heartbeatMatch = path.match(/^\/api\/v1\/runners\/([^\/]+)\/heartbeat$/);
+  if (heartbeatMatch && method === 'POST') {
+    if (!authorizeRunnerPath(req, res, heartbeatMatch[1])) {
+      return;
+    }
+    const body = req.body;
+    if (!body || typeof body !== 'object') {
+      sendError(res, 400, 'Missing request body');
+      return;
+    }
+    const runner = grpcServer.recordHeartbeat(heartbeatMatch[1], body as RunnerHeartbeat);
+    const drain = grpcServer.takePendingDrain(heartbeatMatch[1]);
+    res.status(200).json({ success: true, status: runner.status, drain });
+    return;
+  }
+
+  // Drain a runner: it stops taking jobs and leaves once the running ones
+  // finish. Admins use an API key; a runner may ask to drain itself.
+  const drainMatch = path.match(/^\/api\/v1\/runners\/([^\/]+)\/drain$/);
+  if (drainMatch && method === 'POST') {
+    const runnerId = drainMatch[1];
+    if (!(await authenticate(req)) && !authorizeRunnerPath(req, res, runnerId)) {
+      return;
+    }
+    const data = (req.body || {}) as { reason?: string; deadlineSeconds?: number; reschedule?: boolean };
+    const request = {
+      reason: data.reason || 'drain requested',
+      deadlineSeconds: data.deadlineSeconds || 0,
+      reschedule: data.reschedule === true,
+    };
+    if (!grpcServer.drainRunner(runnerId, request)) {
+      sendError(res, 404, 'Runner not found or offline');
+      return;
+    }
+    repository.log('DRAIN', 'runner', runnerId, undefined, request);
+    res.status(202).json({ success: true, runnerId });
+    return;
+  }
+
+  // A drained runner leaving, for runners without a job stream
+  const leaveMatch = path.match(/^\/api\/v1\/runners\/([^\/]+)\/leave$/);
+  if (leaveMatch && method === 'POST') {
+    if (!authorizeRunnerPath(req, res, leaveMatch[1])) {
+      return;
+    }
+    const data = (req.body || {}) as { reason?: string; reschedule?: boolean };
+    grpcServer.runnerLeft(leaveMatch[1], data.reason || '', data.reschedule === true);
+    repository.log('LEAVE', 'runner', leaveMatch[1], leaveMatch[1], { reason: data.reason, reschedule: data.reschedule === true });
+    res.status(200).json({ success: true });
+    return;
+  }
+
+  // Desired state for runner reconciliation: every sandbox the server knows,
+  // with the runner that owns it, and every volume
+  const desiredStateMatch = path.match(/^\/api\/v1\/runners\/([^\/]+)\/desired-state$/);
+  if (desiredStateMatch && method === 'GET') {
+    if (!authorizeRunnerPath(req, res, desiredStateMatch[1])) {
+      return;
+    }
+    const sandboxes = repository.listSandboxes()
+      .filter((sandbox) => sandbox.status !== 'deleted')
+      .map((sandbox) => ({ id: sandbox.id, status: sandbox.status, runnerId: sandbox.runnerId }));
+    const volumes = repository.listVolumes().map((volume) => volume.id);
+    res.status(200).json({ runnerId: desiredStateMatch[1], sandboxes, volumes });
+    return;
+  }
+
   // Job routes for runner polling
   if (path === '/api/v1/jobs' && method === 'GET') {
-    const runnerId = req.headers['x-runner-id'] as string;
-    const pendingJobs = getPendingJobs(runnerId);
+    const runnerId = authenticateRunner(req, res);
+    if (!runnerId) {
+      return;
+    }
+    const pendingJobs = getPendingJobs(runnerId).filter((job) => offeredTo(job, runnerId));
     res.status(200).json({ jobs: pendingJobs });
     return;
   }
@@ -430,10 +1081,11 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
 
     const data = body as Record<string, unknown>;
     const job = createJob({
-      type: data.type as 'create' | 'delete',
+      type: data.type as JobType,
       sandboxId: data.sandboxId as string,
       image: data.image as string,
       token: data.token as string || '',
+      traceContext: (data.traceContext as Record<string, string>) || traceContextFromHeaders(req),
     });
     res.status(201).json({ job });
     return;
@@ -445,9 +1097,20 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
       sendError(res, 400, 'Missing job ID');
       return;
     }
-    const runnerId = req.headers['x-runner-id'] as string;
+    const runnerId = authenticateRunner(req, res);
     if (!runnerId) {
-      sendError(res, 400, 'Missing X-Runner-Id header');
+      return;
+    }
+    // Runners may only take the pending jobs they are offered when polling.
+    // Accepting a job already assigned to the runner succeeds again, for a
+    // runner whose accept over the job stream went unanswered.
+    if (isAssignedTo(jobId, runnerId)) {
+      res.status(200).json({ success: true });
+      return;
+    }
+    const job = getJob(jobId);
+    if (!job || job.status !== 'pending' || !offeredTo(job, runnerId)) {
+      sendError(res, 403, 'Job is not offered to this runner');
       return;
     }
     const success = assignJob(jobId, runnerId);
@@ -459,9 +1122,12 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
   const completeMatch = path.match(/^\/api\/v1\/jobs\/([^\/]+)\/complete$/);
   if (completeMatch && method === 'POST') {
     const jobId = completeMatch[1];
-    const runnerId = req.headers['x-runner-id'] as string;
+    const runnerId = authenticateRunner(req, res);
     if (!runnerId) {
-      sendError(res, 400, 'Missing X-Runner-Id header');
+      return;
+    }
+    if (!isAssignedTo(jobId, runnerId)) {
+      sendError(res, 403, 'Job is not assigned to this runner');
       return;
     }
 
@@ -476,8 +1142,13 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // Every job for operators, without the sandbox tokens create jobs carry
   if (path === '/api/v1/all-jobs' && method === 'GET') {
-    const allJobs = getAllJobs();
+    if (!(await authenticate(req))) {
+      sendError(res, 401, 'Invalid or missing API key');
+      return;
+    }
+    const allJobs = getAllJobs().map(({ token, ...job }) => job);
     res.status(200).json({ jobs: allJobs });
     return;
   }
@@ -602,6 +1273,63 @@ async function handleAPIRequest(req: Request, res: Response): Promise<void> {
     return;
   }
 
+  // TLS CA routes, for runners (by credential) and SDK clients (by API key)
+  // Get CA certificate (for clients verifying agent gRPC servers)
+  if (path === '/api/v1/tls/ca' && method === 'GET') {
+    if (!(await authenticate(req)) && !authenticateRunner(req, res)) {
+      return;
+    }
+    try {
+      res.status(200).type('text/plain').send(tlsCAService.getCACertificate());
+    } catch (e) {
+      sendError(res, 500, 'Failed to get CA certificate', String(e));
+    }
+    return;
+  }
+
+  // Issue a server certificate for an agent or a client certificate for mTLS
+  if (path === '/api/v1/tls/cert' && method === 'POST') {
+    const apiKey = await authenticate(req);
+    const runnerId = apiKey ? undefined : authenticateRunner(req, res);
+    if (!apiKey && !runnerId) {
+      return;
+    }
+    const data = req.body as Record<string, unknown>;
+    const commonName = data.commonName as string;
+    const usage = (data.usage as CertificateUsage) || 'client';
+
+    if (!commonName) {
+      sendError(res, 400, 'Missing commonName');
+      return;
+    }
+    if (usage !== 'server' && usage !== 'client') {
+      sendError(res, 400, 'Invalid usage');
+      return;
+    }
+
+    const certReq = {
+      commonName,
+      dnsNames: data.dnsNames as string[] | undefined,
+      ipAddresses: data.ipAddresses as string[] | undefined,
+      usage,
+      validityDays: data.validityDays as number | undefined,
+    };
+    const refused = authorizeCertificate(certReq, { runnerId, sandboxToken: data.sandboxToken as string | undefined }, certificateSandbox);
+    if (refused) {
+      sendError(res, 403, refused);
+      return;
+    }
+
+    try {
+      const issued = tlsCAService.issueCertificate(certReq);
+      repository.log('CREATE', 'tls-certificate', commonName, runnerId, { usage });
+      res.status(200).json(issued);
+    } catch (e) {
+      sendError(res, 500, 'Failed to issue certificate', String(e));
+    }
+    return;
+  }
+
   // Cleanup endpoint: remove stuck sandboxes
   if (path === '/api/v1/cleanup' && method === 'POST') {
     const sandboxes = repository.listSandboxes();
@@ -644,11 +1372,12 @@ export function createServer(): { httpServer: ReturnType<typeof httpCreateServer
 
   let httpServer: ReturnType<typeof httpCreateServer> | undefined;
   let httpsServer: ReturnType<typeof httpsCreateServer> | null = null;
+  let sslOptions: { cert: Buffer; key: Buffer } | undefined;
 
   // Only start HTTPS server if certificates exist
   if (fs.existsSync(certPath) && fs.existsSync(keyPath)) {
     const https = require('https');
-    const sslOptions = {
+    sslOptions = {
       cert: fs.readFileSync(certPath),
       key: fs.readFileSync(keyPath),
     };
@@ -699,12 +1428,16 @@ export function createServer(): { httpServer: ReturnType<typeof httpCreateServer
     await sshCAService.initialize();
     logger.info('SSH CA initialized');
 
+    // Initialize TLS CA for agent gRPC certificates
+    await tlsCAService.initialize();
+    logger.info('TLS CA initialized');
+
     // Start cleanup task
     startCleanupTask();
     logger.info('Cleanup task started');
 
-    // Create and start gRPC server
-    grpcServer = new GrpcServer(50051);
+    // Create and start gRPC server, with the same certificate as HTTPS
+    grpcServer = new GrpcServer(50051, sslOptions);
     grpcServer.start().catch((err) => logger.error('gRPC server error: %s', err));
 
     // Start appropriate server
@@ -2,10 +2,12 @@
  * Job Service - SQLite-based job storage for runner job dispatch
  */
 
+import { EventEmitter } from 'events';
 import { getDatabase } from '../db/database';
 import { JobRepository } from '../db/repository';
 import { repository } from '../db/repository-adapter';
 import { logger } from '../logger';
+import { JobConstraints } from '../types';
 
 let jobRepo: JobRepository | null = null;
 
@@ -17,9 +19,24 @@ function getJobRepo(): JobRepository {
   return jobRepo;
 }
 
+// Jobs that change the state of an existing sandbox's container
+export type LifecycleJobType = 'stop' | 'start' | 'restart' | 'pause' | 'unpause' | 'resize';
+
+// Snapshot jobs commit a sandbox's container to an image; restore jobs
+// create a new sandbox from one. Checkpoint jobs also save the sandbox's
+// processes, which restore-checkpoint jobs resume in the same sandbox.
+export type JobType =
+  | 'create'
+  | 'delete'
+  | 'snapshot'
+  | 'restore'
+  | 'checkpoint'
+  | 'restore-checkpoint'
+  | LifecycleJobType;
+
 export interface Job {
   id: string;
-  type: 'create' | 'delete';
+  type: JobType;
   sandboxId: string;
   image: string;
   token: string;
@@ -27,21 +44,31 @@ export interface Job {
   runnerId?: string;
   createdAt: string;
   env?: Record<string, string>;
-  memory?: string;
-  cpu?: number;
+  memory?: string;                       // Memory limit such as 512M; the new limit for resize jobs
+  cpu?: number;                          // CPU count; the new count for resize jobs
   networkMode?: string;
   volumes?: { volumeId: string; mountPath: string }[];
+  traceContext?: Record<string, string>; // W3C trace context of the request that queued the job
+  constraints?: JobConstraints;          // Runners a create or restore job may be placed on
+  snapshotId?: string;                   // Snapshot a snapshot job takes or a restore job restores
+  excludeVolumes?: boolean;              // Restores of the snapshot start without the volumes
+  leaveRunning?: boolean;                // A checkpoint job keeps the sandbox running
 }
 
+/**
+ * Emits 'created' with each new job, so connected runners get it at once
+ */
+export const jobEvents = new EventEmitter();
+
 /**
  * Create a new job
  */
 export function createJob(data: Omit<Job, 'id' | 'status' | 'createdAt'>): Job {
   const repo = getJobRepo();
   const job = repo.create(data);
-  return {
+  const created: Job = {
     id: job.id,
-    type: job.type as 'create' | 'delete',
+    type: job.type as JobType,
     sandboxId: job.sandboxId,
     image: job.image,
     token: job.token,
@@ -52,7 +79,15 @@ export function createJob(data: Omit<Job, 'id' | 'status' | 'createdAt'>): Job {
     memory: job.memory,
     cpu: job.cpu,
     networkMode: job.networkMode,
+    volumes: job.volumes,
+    traceContext: job.traceContext,
+    constraints: job.constraints,
+    snapshotId: job.snapshotId,
+    excludeVolumes: job.excludeVolumes,
+    leaveRunning: job.leaveRunning,
   };
+  jobEvents.emit('created', created);
+  return created;
 }
 
 /**
@@ -64,7 +99,7 @@ export function getJob(id: string): Job | undefined {
   if (!job) return undefined;
   return {
     id: job.id,
-    type: job.type as 'create' | 'delete',
+    type: job.type as JobType,
     sandboxId: job.sandboxId,
     image: job.image,
     token: job.token,
@@ -75,6 +110,12 @@ export function getJob(id: string): Job | undefined {
     memory: job.memory,
     cpu: job.cpu,
     networkMode: job.networkMode,
+    volumes: job.volumes,
+    traceContext: job.traceContext,
+    constraints: job.constraints,
+    snapshotId: job.snapshotId,
+    excludeVolumes: job.excludeVolumes,
+    leaveRunning: job.leaveRunning,
   };
 }
 
@@ -85,7 +126,7 @@ export function getPendingJobs(runnerId?: string): Job[] {
   const repo = getJobRepo();
   return repo.getPending(runnerId).map((job: any) => ({
     id: job.id,
-    type: job.type as 'create' | 'delete',
+    type: job.type as JobType,
     sandboxId: job.sandboxId,
     image: job.image,
     token: job.token,
@@ -96,6 +137,12 @@ export function getPendingJobs(runnerId?: string): Job[] {
     memory: job.memory,
     cpu: job.cpu,
     networkMode: job.networkMode,
+    volumes: job.volumes,
+    traceContext: job.traceContext,
+    constraints: job.constraints,
+    snapshotId: job.snapshotId,
+    excludeVolumes: job.excludeVolumes,
+    leaveRunning: job.leaveRunning,
   }));
 }
 
@@ -107,6 +154,28 @@ export function assignJob(jobId: string, runnerId: string): boolean {
   return repo.assign(jobId, runnerId);
 }
 
+/**
+ * Check that a job is running on a runner, which alone may complete it
+ */
+export function isAssignedTo(jobId: string, runnerId: string): boolean {
+  const repo = getJobRepo();
+  const job = repo.getById(jobId);
+  return !!job && job.status === 'running' && job.runnerId === runnerId;
+}
+
+/**
+ * Requeue the jobs a runner accepted but never completed, once the runner is
+ * gone. Returns the number of jobs requeued.
+ */
+export function releaseRunnerJobs(runnerId: string): number {
+  const repo = getJobRepo();
+  const released = repo.release(runnerId);
+  if (released > 0) {
+    jobEvents.emit('created');
+  }
+  return released;
+}
+
 import { SandboxRepository } from '../db/repository';
 
 /**
@@ -135,7 +204,7 @@ export function getAllJobs(): Job[] {
   const repo = getJobRepo();
   return repo.getAll().map((job: any) => ({
     id: job.id,
-    type: job.type as 'create' | 'delete',
+    type: job.type as JobType,
     sandboxId: job.sandboxId,
     image: job.image,
     token: job.token,
@@ -146,5 +215,24 @@ export function getAllJobs(): Job[] {
     memory: job.memory,
     cpu: job.cpu,
     networkMode: job.networkMode,
+    volumes: job.volumes,
+    traceContext: job.traceContext,
+    constraints: job.constraints,
+    snapshotId: job.snapshotId,
+    excludeVolumes: job.excludeVolumes,
+    leaveRunning: job.leaveRunning,
   }));
 }
+
+/**
+ * Get the newest job that placed a sandbox: its create job, or its restore
+ * job when it was restored from a snapshot. Restores from a checkpoint do
+ * not count, so a sandbox rescheduled after one starts afresh rather than
+ * from stale process state.
+ */
+export function getPlacementJob(sandboxId: string): Job | undefined {
+  // Jobs are listed newest first
+  return getAllJobs().find(
+    (job) => (job.type === 'create' || job.type === 'restore') && job.sandboxId === sandboxId
+  );
+}
@@ -0,0 +1,29 @@
+/**
+ * Unit tests for job placement
+ */
+
+import { checkPlacement } from './placement';
+
+describe('checkPlacement', () => {
+  const labels = { zone: 'eu-1', gpu: 'true' };
+  const taints = { dedicated: 'ci' };
+
+  test('should place unconstrained jobs on untainted runners only', () => {
+    expect(checkPlacement(undefined, labels)).toBeUndefined();
+    expect(checkPlacement(undefined, labels, taints)).toBe('job does not tolerate taint dedicated=ci');
+  });
+
+  test('should match node selectors against labels', () => {
+    expect(checkPlacement({ nodeSelector: { zone: 'eu-1' } }, labels)).toBeUndefined();
+    expect(checkPlacement({ nodeSelector: { gpu: '' } }, labels)).toBeUndefined();
+    expect(checkPlacement({ nodeSelector: { zone: 'us-1' } }, labels)).toBe('runner label zone=eu-1 does not match zone=us-1');
+    expect(checkPlacement({ nodeSelector: { disk: 'ssd' } }, labels)).toBe('runner has no label "disk"');
+  });
+
+  test('should require tolerations for every taint', () => {
+    expect(checkPlacement({ tolerations: { dedicated: 'ci' } }, labels, taints)).toBeUndefined();
+    expect(checkPlacement({ tolerations: { dedicated: '' } }, labels, taints)).toBeUndefined();
+    expect(checkPlacement({ tolerations: { dedicated: 'gpu' } }, labels, taints)).toBeDefined();
+    expect(checkPlacement({ tolerations: { dedicated: 'ci' } }, labels)).toBeUndefined();
+  });
+});
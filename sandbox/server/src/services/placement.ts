@@ -0,0 +1,36 @@
+/**
+ * Placement - matches create jobs against runner labels and taints
+ */
+
+import { JobConstraints } from '../types';
+
+/**
+ * Check whether a runner with the given labels and taints may take a job with
+ * the given constraints. Returns why it may not, or undefined when it may.
+ * The runner applies the same rules before accepting a job.
+ */
+export function checkPlacement(
+  constraints: JobConstraints | undefined,
+  labels: Record<string, string> = {},
+  taints: Record<string, string> = {}
+): string | undefined {
+  const selector = constraints?.nodeSelector || {};
+  const tolerations = constraints?.tolerations || {};
+
+  for (const key of Object.keys(selector).sort()) {
+    const want = selector[key];
+    if (!(key in labels)) {
+      return `runner has no label "${key}"`;
+    }
+    if (want !== '' && labels[key] !== want) {
+      return `runner label ${key}=${labels[key]} does not match ${key}=${want}`;
+    }
+  }
+
+  for (const key of Object.keys(taints).sort()) {
+    if (!(key in tolerations) || (tolerations[key] !== '' && tolerations[key] !== taints[key])) {
+      return `job does not tolerate taint ${key}=${taints[key]}`;
+    }
+  }
+  return undefined;
+}
@@ -0,0 +1,72 @@
+/**
+ * Unit tests for runner authentication
+ */
+
+import { RunnerAuthService } from './runner-auth';
+import { RunnerCredentialRepository } from '../db/repository';
+import { SqliteDB } from '../db/database';
+
+describe('RunnerAuthService', () => {
+  let db: SqliteDB;
+  let service: RunnerAuthService;
+
+  beforeEach(() => {
+    db = new SqliteDB(':memory:');
+    service = new RunnerAuthService(new RunnerCredentialRepository(db), 'shared-join-token');
+  });
+
+  afterEach(() => {
+    db.close();
+  });
+
+  test('should enroll a runner once per join token', () => {
+    const { token } = service.createJoinToken();
+
+    const credential = service.enroll(token, 'runner-1');
+    expect(credential).toBeDefined();
+    expect(credential!.runnerId).toBe('runner-1');
+    expect(service.authenticate(credential!.token)).toBe('runner-1');
+
+    expect(service.enroll(token, 'runner-2')).toBeUndefined();
+  });
+
+  test('should reject unknown and expired join tokens', () => {
+    expect(service.enroll('not-a-token', 'runner-1')).toBeUndefined();
+
+    const { token } = service.createJoinToken(-1);
+    expect(service.enroll(token, 'runner-1')).toBeUndefined();
+  });
+
+  test('should accept the shared join token repeatedly', () => {
+    expect(service.enroll('shared-join-token', 'runner-1')).toBeDefined();
+    expect(service.enroll('shared-join-token', 'runner-2')).toBeDefined();
+  });
+
+  test('should revoke earlier credentials when a runner re-enrolls', () => {
+    const first = service.enroll('shared-join-token', 'runner-1')!;
+    const second = service.enroll(service.createJoinToken().token, 'runner-1')!;
+
+    expect(service.authenticate(first.token)).toBeUndefined();
+    expect(service.authenticate(second.token)).toBe('runner-1');
+  });
+
+  test('should not let the shared join token take over an enrolled runner', () => {
+    const first = service.enroll('shared-join-token', 'runner-1')!;
+
+    expect(() => service.enroll('shared-join-token', 'runner-1')).toThrow(/already enrolled/);
+    expect(service.authenticate(first.token)).toBe('runner-1');
+  });
+
+  test('should rotate a credential', () => {
+    const first = service.enroll('shared-join-token', 'runner-1')!;
+    const rotated = service.rotate(first.token);
+
+    expect(rotated).toBeDefined();
+    expect(rotated!.token).not.toBe(first.token);
+    expect(service.authenticate(rotated!.token)).toBe('runner-1');
+    // The old credential keeps working during the grace period
+    expect(service.authenticate(first.token)).toBe('runner-1');
+
+    expect(service.rotate('not-a-credential')).toBeUndefined();
+  });
+});
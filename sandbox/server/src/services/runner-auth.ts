@@ -0,0 +1,135 @@
+/**
+ * Runner Authentication Service
+ *
+ * Runners enroll with a join token and get a credential back:
+ * - Admins create one-time join tokens, or configure a shared one in
+ *   RUNNER_JOIN_TOKEN for fleets that enroll on their own
+ * - A runner exchanges a join token for a bearer credential bound to its ID.
+ *   The shared token only enrolls runners without a live credential, so it
+ *   cannot take over an enrolled runner's ID
+ * - The runner sends the credential on every request and job stream, and
+ *   rotates it before it expires
+ */
+
+import { createHash, randomBytes, timingSafeEqual } from 'crypto';
+import { RunnerCredentialRepository } from '../db/repository';
+import { logger } from '../logger';
+
+// Lifetime of runner credentials and of one-time join tokens
+const CREDENTIAL_TTL_SECS = parseInt(process.env.RUNNER_CREDENTIAL_TTL_SECS || String(7 * 24 * 60 * 60), 10);
+const JOIN_TOKEN_TTL_SECS = 24 * 60 * 60;
+
+// How long a rotated credential keeps working, for requests already in flight
+const ROTATION_GRACE_SECS = 5 * 60;
+
+export interface IssuedCredential {
+  runnerId: string;
+  token: string;
+  issuedAt: string;
+  expiresAt: string;
+}
+
+export interface JoinToken {
+  token: string;
+  expiresAt: string;
+}
+
+function hashToken(token: string): string {
+  return createHash('sha256').update(token).digest('hex');
+}
+
+function generateToken(prefix: string): string {
+  return `${prefix}_${randomBytes(32).toString('base64url')}`;
+}
+
+export class RunnerAuthService {
+  private repo: RunnerCredentialRepository | null;
+  private sharedJoinToken?: string;
+
+  constructor(repo?: RunnerCredentialRepository, sharedJoinToken: string | undefined = process.env.RUNNER_JOIN_TOKEN) {
+    this.repo = repo || null;
+    this.sharedJoinToken = sharedJoinToken || undefined;
+  }
+
+  private getRepo(): RunnerCredentialRepository {
+    if (!this.repo) {
+      this.repo = new RunnerCredentialRepository();
+    }
+    return this.repo;
+  }
+
+  /**
+   * Create a join token that enrolls a single runner
+   */
+  createJoinToken(ttlSecs: number = JOIN_TOKEN_TTL_SECS): JoinToken {
+    const token = generateToken('cpj');
+    const expiresAt = new Date(Date.now() + ttlSecs * 1000).toISOString();
+    this.getRepo().createJoinToken(hashToken(token), expiresAt);
+    return { token, expiresAt };
+  }
+
+  /**
+   * Exchange a join token for a credential. Credentials issued to the runner
+   * before are revoked. Returns undefined when the join token is not valid,
+   * and throws when the shared token is used for an enrolled runner, which
+   * must re-enroll with a one-time token.
+   */
+  enroll(joinToken: string, runnerId: string): IssuedCredential | undefined {
+    if (this.isSharedJoinToken(joinToken)) {
+      if (this.getRepo().hasLive(runnerId)) {
+        throw new Error(`Runner ${runnerId} is already enrolled, re-enroll it with a one-time join token`);
+      }
+    } else if (!this.getRepo().consumeJoinToken(hashToken(joinToken), runnerId)) {
+      return undefined;
+    }
+    const revoked = this.getRepo().deleteForRunner(runnerId);
+    if (revoked > 0) {
+      logger.info(`Runner ${runnerId} re-enrolled, revoked ${revoked} earlier credential(s)`);
+    }
+    return this.issue(runnerId);
+  }
+
+  /**
+   * Return the ID of the runner a credential belongs to, or undefined when it
+   * is unknown or expired
+   */
+  authenticate(token: string): string | undefined {
+    const credential = this.getRepo().getByHash(hashToken(token));
+    if (!credential || Date.parse(credential.expiresAt) <= Date.now()) {
+      return undefined;
+    }
+    return credential.runnerId;
+  }
+
+  /**
+   * Replace a valid credential with a new one. The old credential keeps
+   * working for a short grace period.
+   */
+  rotate(token: string): IssuedCredential | undefined {
+    const repo = this.getRepo();
+    const credential = repo.getByHash(hashToken(token));
+    if (!credential || Date.parse(credential.expiresAt) <= Date.now()) {
+      return undefined;
+    }
+    repo.expire(credential.id, new Date(Date.now() + ROTATION_GRACE_SECS * 1000).toISOString());
+    return this.issue(credential.runnerId);
+  }
+
+  private issue(runnerId: string): IssuedCredential {
+    const token = generateToken('cpr');
+    const issuedAt = new Date();
+    const expiresAt = new Date(issuedAt.getTime() + CREDENTIAL_TTL_SECS * 1000);
+    this.getRepo().create(runnerId, hashToken(token), expiresAt.toISOString());
+    return { runnerId, token, issuedAt: issuedAt.toISOString(), expiresAt: expiresAt.toISOString() };
+  }
+
+  private isSharedJoinToken(joinToken: string): boolean {
+    if (!this.sharedJoinToken) {
+      return false;
+    }
+    return timingSafeEqual(Buffer.from(hashToken(joinToken)), Buffer.from(hashToken(this.sharedJoinToken)));
+  }
+}
+
+// Export singleton instance
+export const runnerAuthService = new RunnerAuthService();
@@ -4,6 +4,7 @@
 
 import { SandboxService } from './sandbox';
 import { repository } from '../db/repository-adapter';
+import { getAllJobs } from './job';
 
 describe('SandboxService', () => {
   let service: SandboxService;
@@ -104,6 +105,67 @@ describe('SandboxService', () => {
     });
   });
 
+  describe('reschedule', () => {
+    test('should clear the runner and queue a new create job', () => {
+      const created = service.create({ image: 'python:3.11', constraints: { nodeSelector: { gpu: 'true' } } });
+      repository.updateSandbox(created.sandbox.id, { status: 'running', runnerId: 'runner-1', containerId: 'abc' });
+
+      const updated = service.reschedule(created.sandbox.id);
+      expect(updated?.status).toBe('pending');
+      expect(updated?.runnerId).toBeUndefined();
+      expect(updated?.containerId).toBeUndefined();
+
+      const jobs = getAllJobs().filter((job) => job.sandboxId === created.sandbox.id && job.type === 'create');
+      expect(jobs.length).toBe(2);
+      for (const job of jobs) {
+        expect(job.token).toBe(created.token);
+        expect(job.constraints).toEqual({ nodeSelector: { gpu: 'true' } });
+      }
+    });
+
+    test('should return undefined for non-existent sandbox', () => {
+      expect(service.reschedule('non-existent')).toBeUndefined();
+    });
+  });
+
+  describe('lifecycle', () => {
+    const placed = (status: 'running' | 'paused' | 'stopped') => {
+      const created = service.create({ image: 'python:3.11' });
+      repository.updateSandbox(created.sandbox.id, { status, runnerId: 'runner-1' });
+      return created.sandbox.id;
+    };
+
+    test('should queue a job for an allowed transition', () => {
+      const id = placed('running');
+
+      const job = service.lifecycle(id, 'pause');
+      expect(job.type).toBe('pause');
+      expect(job.sandboxId).toBe(id);
+      expect(job.status).toBe('pending');
+    });
+
+    test('should carry the new limits of a resize', () => {
+      const job = service.lifecycle(placed('running'), 'resize', { memory: '2G', cpu: 2 });
+      expect(job.memory).toBe('2G');
+      expect(job.cpu).toBe(2);
+    });
+
+    test('should reject transitions the status does not allow', () => {
+      expect(() => service.lifecycle(placed('stopped'), 'pause')).toThrow('Cannot pause a sandbox that is stopped');
+      expect(() => service.lifecycle(placed('running'), 'unpause')).toThrow('Cannot unpause a sandbox that is running');
+      expect(() => service.lifecycle(placed('paused'), 'start')).toThrow('Cannot start a sandbox that is paused');
+    });
+
+    test('should reject a resize without limits', () => {
+      expect(() => service.lifecycle(placed('running'), 'resize')).toThrow('Resize needs a memory or cpu value');
+    });
+
+    test('should reject sandboxes without a runner', () => {
+      const created = service.create({ image: 'python:3.11' });
+      expect(() => service.lifecycle(created.sandbox.id, 'stop')).toThrow('Sandbox has not been placed on a runner');
+    });
+  });
+
   describe('getConnectionToken', () => {
     test('should return connection token', () => {
       const created = service.create({ image: 'python:3.11' });
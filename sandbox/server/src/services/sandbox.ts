@@ -4,7 +4,7 @@
 
 import { repository } from '../db/repository-adapter';
 import { Sandbox, SandboxStatus, CreateSandboxRequest, SandboxResponse } from '../types';
-import { createJob } from './job';
+import { createJob, getPlacementJob, Job, LifecycleJobType } from './job';
 
 // Simple UUID generator
 function generateId(): string {
@@ -15,11 +15,22 @@ function generateId(): string {
   });
 }
 
+// Sandbox statuses each lifecycle job may be queued from
+const LIFECYCLE_FROM: Record<LifecycleJobType, SandboxStatus[]> = {
+  stop: ['running', 'paused'],
+  start: ['stopped', 'failed'],
+  restart: ['running', 'paused', 'stopped', 'failed'],
+  pause: ['running'],
+  unpause: ['paused'],
+  resize: ['running', 'paused', 'stopped'],
+};
+
 export class SandboxService {
   /**
-   * Create a new sandbox
+   * Create a new sandbox. traceContext carries the caller's W3C trace
+   * context to the runner with the create job.
    */
-  create(req: CreateSandboxRequest): SandboxResponse {
+  create(req: CreateSandboxRequest, traceContext?: Record<string, string>): SandboxResponse {
     // Validate request
     if (!req.image) {
       throw new Error('Image is required');
@@ -37,7 +48,12 @@ export class SandboxService {
       sandboxId: sandbox.id,
       image: req.image || sandbox.image,
       token: token,
+      env: req.env,
+      memory: req.memory,
+      cpu: req.cpu,
       volumes: req.volumes,
+      constraints: req.constraints,
+      traceContext,
     });
 
     return {
@@ -85,6 +101,72 @@ export class SandboxService {
     return repository.updateSandbox(id, { status });
   }
 
+  /**
+   * Queue a job that stops, starts, restarts, pauses, unpauses or resizes a
+   * sandbox's container on its runner. Throws when the sandbox does not exist,
+   * has no container yet or its status does not allow the change.
+   */
+  lifecycle(
+    id: string,
+    type: LifecycleJobType,
+    resources: { memory?: string; cpu?: number } = {},
+    traceContext?: Record<string, string>
+  ): Job {
+    const sandbox = repository.getSandbox(id);
+    if (!sandbox) {
+      throw new Error('Sandbox not found');
+    }
+    if (!sandbox.runnerId) {
+      throw new Error('Sandbox has not been placed on a runner');
+    }
+    if (!LIFECYCLE_FROM[type].includes(sandbox.status)) {
+      throw new Error(`Cannot ${type} a sandbox that is ${sandbox.status}`);
+    }
+    if (type === 'resize' && !resources.memory && !resources.cpu) {
+      throw new Error('Resize needs a memory or cpu value');
+    }
+
+    return createJob({
+      type,
+      sandboxId: id,
+      image: sandbox.image,
+      token: sandbox.token || '',
+      memory: resources.memory,
+      cpu: resources.cpu,
+      traceContext,
+    });
+  }
+
+  /**
+   * Queue a sandbox for placement on another runner, repeating the create
+   * or restore job it was placed with. Returns undefined for unknown
+   * sandboxes.
+   */
+  reschedule(id: string): Sandbox | undefined {
+    const sandbox = repository.getSandbox(id);
+    if (!sandbox) return undefined;
+
+    const original = getPlacementJob(id);
+    const updated = repository.updateSandbox(id, {
+      status: 'pending',
+      runnerId: '',
+      containerId: '',
+    });
+    createJob({
+      type: original?.type === 'restore' ? 'restore' : 'create',
+      sandboxId: id,
+      image: original?.image || sandbox.image,
+      token: original?.token || sandbox.token || '',
+      env: original?.env,
+      memory: original?.memory,
+      cpu: original?.cpu,
+      volumes: original?.volumes,
+      constraints: original?.constraints,
+      snapshotId: original?.snapshotId,
+    });
+    return updated;
+  }
+
   /**
    * Get sandbox connection token
    */
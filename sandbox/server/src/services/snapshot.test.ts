@@ -0,0 +1,211 @@
+/**
+ * Unit tests for snapshot service
+ */
+
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { Readable } from 'stream';
+import { SnapshotService } from './snapshot';
+import { SandboxService } from './sandbox';
+import { repository } from '../db/repository-adapter';
+import { getAllJobs } from './job';
+
+describe('SnapshotService', () => {
+  let service: SnapshotService;
+  let sandboxes: SandboxService;
+  let checkpointDir: string;
+
+  beforeEach(() => {
+    repository.reset(); // Clear shared state
+    checkpointDir = fs.mkdtempSync(path.join(os.tmpdir(), 'codepod-checkpoints-'));
+    service = new SnapshotService(undefined, checkpointDir);
+    sandboxes = new SandboxService();
+  });
+
+  afterEach(() => {
+    fs.rmSync(checkpointDir, { recursive: true, force: true });
+  });
+
+  const placed = (status: 'running' | 'pending' = 'running') => {
+    const created = sandboxes.create({
+      image: 'python:3.11',
+      env: { MODEL: 'eval' },
+      memory: '1G',
+      cpu: 2,
+      volumes: [{ volumeId: 'vol-1', mountPath: '/data' }],
+      constraints: { nodeSelector: { gpu: 'true' } },
+    });
+    repository.updateSandbox(created.sandbox.id, { status, runnerId: 'runner-1' });
+    return created.sandbox.id;
+  };
+
+  describe('create', () => {
+    test('should queue a snapshot job with the sandbox resources', () => {
+      const id = placed();
+
+      const { snapshot, job } = service.create(id, { excludeVolumes: true });
+      expect(snapshot.status).toBe('pending');
+      expect(snapshot.sandboxId).toBe(id);
+      expect(snapshot.sourceImage).toBe('python:3.11');
+      expect(snapshot.excludeVolumes).toBe(true);
+      expect(snapshot).not.toHaveProperty('env');
+
+      expect(job.type).toBe('snapshot');
+      expect(job.snapshotId).toBe(snapshot.id);
+      expect(job.excludeVolumes).toBe(true);
+      expect(job.memory).toBe('1G');
+      expect(job.cpu).toBe(2);
+    });
+
+    test('should list snapshots by sandbox', () => {
+      const id = placed();
+      service.create(id);
+      service.create(id);
+
+      expect(service.list(id).length).toBe(2);
+      expect(service.list('other')).toEqual([]);
+    });
+
+    test('should reject sandboxes without a container', () => {
+      expect(() => service.create('non-existent')).toThrow('Sandbox not found');
+      expect(() => service.create(placed('pending'))).toThrow('Cannot snapshot a sandbox that is pending');
+    });
+  });
+
+  describe('restore', () => {
+    test('should create a sandbox from a ready snapshot', () => {
+      const source = placed();
+      const { snapshot } = service.create(source);
+      service.updateStatus(snapshot.id, { status: 'ready', image: 'registry:5000/codepod-snapshots:x', imageId: 'sha256:abc' });
+
+      const restored = service.restore(snapshot.id, { name: 'fork-1' });
+      expect(restored.sandbox.id).not.toBe(source);
+      expect(restored.sandbox.name).toBe('fork-1');
+      expect(restored.sandbox.image).toBe('registry:5000/codepod-snapshots:x');
+      expect(restored.token).toBeDefined();
+
+      const job = getAllJobs().find((j) => j.sandboxId === restored.sandbox.id);
+      expect(job?.type).toBe('restore');
+      expect(job?.snapshotId).toBe(snapshot.id);
+      expect(job?.env).toEqual({ MODEL: 'eval' });
+      expect(job?.memory).toBe('1G');
+      expect(job?.cpu).toBe(2);
+      expect(job?.volumes).toEqual([{ volumeId: 'vol-1', mountPath: '/data' }]);
+      expect(job?.constraints).toEqual({ nodeSelector: { gpu: 'true' } });
+    });
+
+    test('should leave out the volumes of a snapshot that excluded them', () => {
+      const { snapshot } = service.create(placed(), { excludeVolumes: true });
+      service.updateStatus(snapshot.id, { status: 'ready', image: 'codepod-snapshots:x' });
+
+      const restored = service.restore(snapshot.id);
+      const job = getAllJobs().find((j) => j.sandboxId === restored.sandbox.id);
+      expect(job?.volumes).toBeUndefined();
+    });
+
+    test('should reject snapshots that are not ready', () => {
+      const { snapshot } = service.create(placed());
+      expect(() => service.restore(snapshot.id)).toThrow('Cannot restore a snapshot that is pending');
+
+      service.updateStatus(snapshot.id, { status: 'failed', message: 'push failed' });
+      expect(service.get(snapshot.id)?.message).toBe('push failed');
+      expect(() => service.restore(snapshot.id)).toThrow('Cannot restore a snapshot that is failed');
+
+      expect(() => service.restore('non-existent')).toThrow('Snapshot not found');
+    });
+  });
+
+  describe('checkpoint', () => {
+    test('should queue a checkpoint job that keeps the volumes', () => {
+      const id = placed();
+
+      const { snapshot, job } = service.checkpoint(id, { leaveRunning: true });
+      expect(snapshot.checkpoint).toBe(true);
+      expect(snapshot.excludeVolumes).toBe(false);
+      expect(job.type).toBe('checkpoint');
+      expect(job.snapshotId).toBe(snapshot.id);
+      expect(job.leaveRunning).toBe(true);
+      expect(job.volumes).toEqual([{ volumeId: 'vol-1', mountPath: '/data' }]);
+
+      service.create(id);
+      expect(service.list(id).length).toBe(2);
+      expect(service.listCheckpoints(id).map((c) => c.id)).toEqual([snapshot.id]);
+    });
+
+    test('should only checkpoint running sandboxes', () => {
+      const id = placed();
+      repository.updateSandbox(id, { status: 'stopped' });
+      expect(() => service.checkpoint(id)).toThrow('Cannot checkpoint a sandbox that is stopped');
+      expect(() => service.checkpoint('non-existent')).toThrow('Sandbox not found');
+    });
+  });
+
+  describe('restoreCheckpoint', () => {
+    const checkpointed = async () => {
+      const id = placed();
+      const { snapshot } = service.checkpoint(id);
+      await service.saveCheckpointArchive(snapshot.id, Readable.from([Buffer.from('archive')]));
+      service.updateStatus(snapshot.id, { status: 'ready', image: 'codepod-snapshots:x' });
+      repository.updateSandbox(id, { status: 'stopped' });
+      return { id, checkpointId: snapshot.id };
+    };
+
+    test('should resume the same sandbox from a ready checkpoint', async () => {
+      const { id, checkpointId } = await checkpointed();
+      const token = repository.getSandbox(id)?.token;
+
+      const sandbox = service.restoreCheckpoint(id, checkpointId);
+      expect(sandbox.id).toBe(id);
+      expect(sandbox.status).toBe('pending');
+      expect(sandbox.runnerId).toBeFalsy();
+
+      const job = getAllJobs().find((j) => j.type === 'restore-checkpoint');
+      expect(job?.sandboxId).toBe(id);
+      expect(job?.token).toBe(token);
+      expect(job?.image).toBe('codepod-snapshots:x');
+      expect(job?.snapshotId).toBe(checkpointId);
+      expect(job?.volumes).toEqual([{ volumeId: 'vol-1', mountPath: '/data' }]);
+      expect(job?.constraints).toEqual({ nodeSelector: { gpu: 'true' } });
+    });
+
+    test('should not restore into a sandbox that may be running', async () => {
+      const { id, checkpointId } = await checkpointed();
+      repository.updateSandbox(id, { status: 'running' });
+      expect(() => service.restoreCheckpoint(id, checkpointId)).toThrow(
+        'Cannot restore a checkpoint into a sandbox that is running'
+      );
+    });
+
+    test('should reject missing and unfinished checkpoints', async () => {
+      const { id, checkpointId } = await checkpointed();
+      const { snapshot } = service.create(id);
+      expect(() => service.restoreCheckpoint(id, snapshot.id)).toThrow('Checkpoint not found');
+      expect(() => service.restoreCheckpoint('other', checkpointId)).toThrow('Checkpoint not found');
+
+      repository.updateSandbox(id, { status: 'running' });
+      const pending = service.checkpoint(id).snapshot;
+      repository.updateSandbox(id, { status: 'stopped' });
+      expect(() => service.restoreCheckpoint(id, pending.id)).toThrow('Cannot restore a checkpoint that is pending');
+
+      service.updateStatus(pending.id, { status: 'ready', image: 'codepod-snapshots:y' });
+      expect(() => service.restoreCheckpoint(id, pending.id)).toThrow('Checkpoint archive is missing');
+    });
+  });
+
+  describe('checkpoint archives', () => {
+    test('should store and serve uploaded archives', async () => {
+      expect(service.openCheckpointArchive('snap-1')).toBeUndefined();
+
+      await service.saveCheckpointArchive('snap-1', Readable.from([Buffer.from('first')]));
+      await service.saveCheckpointArchive('snap-1', Readable.from([Buffer.from('second')]));
+
+      const chunks: Buffer[] = [];
+      for await (const chunk of service.openCheckpointArchive('snap-1')!) {
+        chunks.push(chunk as Buffer);
+      }
+      expect(Buffer.concat(chunks).toString()).toBe('second');
+      expect(fs.readdirSync(checkpointDir)).toEqual(['snap-1.tar.gz']);
+    });
+  });
+});
@@ -0,0 +1,334 @@
+/**
+ * Snapshot Service
+ *
+ * A snapshot commits a sandbox's container filesystem to an image on its
+ * runner, which pushes it to the runner's registry. Restoring a snapshot
+ * creates a new sandbox from that image with the source sandbox's env,
+ * resources and volumes, on any runner that can pull it.
+ *
+ * A checkpoint is a snapshot that also holds the sandbox's processes, saved
+ * with CRIU and uploaded by the runner as an archive kept here. Restoring a
+ * checkpoint resumes the same sandbox, on any runner that can pull the image.
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { repository } from '../db/repository-adapter';
+import { SnapshotData, SnapshotRepository } from '../db/repository';
+import {
+  CreateCheckpointRequest,
+  CreateSnapshotRequest,
+  RestoreSnapshotRequest,
+  SandboxResponse,
+  SandboxStatus,
+  Sandbox,
+  Snapshot,
+  SnapshotStatus,
+} from '../types';
+import { createJob, getPlacementJob, Job } from './job';
+
+// Sandbox statuses a snapshot may be taken from: the container must exist
+const SNAPSHOT_FROM: SandboxStatus[] = ['running', 'paused', 'stopped'];
+
+// Sandbox statuses a checkpoint may be restored into: no container may be
+// running the sandbox elsewhere
+const RESTORE_CHECKPOINT_INTO: SandboxStatus[] = ['stopped', 'failed'];
+
+const CHECKPOINT_DIR =
+  process.env.CODEPOD_CHECKPOINT_DIR || path.join(process.env.CODEPOD_DATA_DIR || './data', 'checkpoints');
+
+export class SnapshotService {
+  private repo: SnapshotRepository | null;
+  private checkpointDir: string;
+
+  constructor(repo?: SnapshotRepository, checkpointDir: string = CHECKPOINT_DIR) {
+    this.repo = repo || null;
+    this.checkpointDir = checkpointDir;
+  }
+
+  private getRepo(): SnapshotRepository {
+    if (!this.repo) {
+      this.repo = new SnapshotRepository();
+    }
+    return this.repo;
+  }
+
+  /**
+   * Queue a snapshot of a sandbox on its runner. Throws when the sandbox
+   * does not exist or has no container to commit.
+   */
+  create(
+    sandboxId: string,
+    req: CreateSnapshotRequest = {},
+    traceContext?: Record<string, string>
+  ): { snapshot: Snapshot; job: Job } {
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox) {
+      throw new Error('Sandbox not found');
+    }
+    if (!sandbox.runnerId) {
+      throw new Error('Sandbox has not been placed on a runner');
+    }
+    if (!SNAPSHOT_FROM.includes(sandbox.status)) {
+      throw new Error(`Cannot snapshot a sandbox that is ${sandbox.status}`);
+    }
+
+    // What the sandbox was placed with is what a restore places again
+    const original = getPlacementJob(sandboxId);
+    const data = this.getRepo().create({
+      sandboxId,
+      sourceImage: original?.image || sandbox.image,
+      env: original?.env,
+      memory: original?.memory,
+      cpu: original?.cpu,
+      volumes: original?.volumes,
+      excludeVolumes: req.excludeVolumes === true,
+      checkpoint: false,
+      constraints: original?.constraints,
+    });
+
+    const job = createJob({
+      type: 'snapshot',
+      sandboxId,
+      image: sandbox.image,
+      token: sandbox.token || '',
+      memory: data.memory,
+      cpu: data.cpu,
+      volumes: data.volumes,
+      snapshotId: data.id,
+      excludeVolumes: data.excludeVolumes,
+      traceContext,
+    });
+    return { snapshot: toSnapshot(data), job };
+  }
+
+  /**
+   * Queue a checkpoint of a running sandbox's processes and filesystem on
+   * its runner. The sandbox is stopped once checkpointed unless the request
+   * leaves it running. Throws when the sandbox does not exist or is not
+   * running.
+   */
+  checkpoint(
+    sandboxId: string,
+    req: CreateCheckpointRequest = {},
+    traceContext?: Record<string, string>
+  ): { snapshot: Snapshot; job: Job } {
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox) {
+      throw new Error('Sandbox not found');
+    }
+    if (!sandbox.runnerId) {
+      throw new Error('Sandbox has not been placed on a runner');
+    }
+    if (sandbox.status !== 'running') {
+      throw new Error(`Cannot checkpoint a sandbox that is ${sandbox.status}`);
+    }
+
+    // Restored processes expect the volumes they had open, so they are kept
+    const original = getPlacementJob(sandboxId);
+    const data = this.getRepo().create({
+      sandboxId,
+      sourceImage: original?.image || sandbox.image,
+      env: original?.env,
+      memory: original?.memory,
+      cpu: original?.cpu,
+      volumes: original?.volumes,
+      excludeVolumes: false,
+      checkpoint: true,
+      constraints: original?.constraints,
+    });
+
+    const job = createJob({
+      type: 'checkpoint',
+      sandboxId,
+      image: sandbox.image,
+      token: sandbox.token || '',
+      memory: data.memory,
+      cpu: data.cpu,
+      volumes: data.volumes,
+      snapshotId: data.id,
+      leaveRunning: req.leaveRunning === true,
+      traceContext,
+    });
+    return { snapshot: toSnapshot(data), job };
+  }
+
+  /**
+   * Get a snapshot by ID
+   */
+  get(id: string): Snapshot | undefined {
+    const data = this.getRepo().getById(id);
+    return data ? toSnapshot(data) : undefined;
+  }
+
+  /**
+   * List a sandbox's snapshots, newest first
+   */
+  list(sandboxId: string): Snapshot[] {
+    return this.getRepo().getBySandbox(sandboxId).map(toSnapshot);
+  }
+
+  /**
+   * List a sandbox's checkpoints, newest first
+   */
+  listCheckpoints(sandboxId: string): Snapshot[] {
+    return this.list(sandboxId).filter((snapshot) => snapshot.checkpoint);
+  }
+
+  /**
+   * Record the outcome of a snapshot job reported by a runner
+   */
+  updateStatus(
+    id: string,
+    update: { status: SnapshotStatus; image?: string; imageId?: string; message?: string }
+  ): Snapshot | undefined {
+    const data = this.getRepo().updateStatus(id, update);
+    return data ? toSnapshot(data) : undefined;
+  }
+
+  /**
+   * Create a new sandbox from a ready snapshot. The sandbox mounts the
+   * source's volumes unless the snapshot excluded them. Throws when the
+   * snapshot does not exist or is not ready.
+   */
+  restore(id: string, req: RestoreSnapshotRequest = {}, traceContext?: Record<string, string>): SandboxResponse {
+    const data = this.getRepo().getById(id);
+    if (!data) {
+      throw new Error('Snapshot not found');
+    }
+    if (data.status !== 'ready' || !data.image) {
+      throw new Error(`Cannot restore a snapshot that is ${data.status}`);
+    }
+
+    const volumes = data.excludeVolumes ? undefined : data.volumes;
+    const sandbox = repository.createSandbox(
+      {
+        name: req.name,
+        image: data.image,
+        env: data.env,
+        memory: data.memory,
+        cpu: data.cpu,
+        volumes,
+        constraints: data.constraints,
+      },
+      { snapshotId: data.id, sourceSandboxId: data.sandboxId }
+    );
+    const token = sandbox.token || '';
+
+    createJob({
+      type: 'restore',
+      sandboxId: sandbox.id,
+      image: data.image,
+      token,
+      env: data.env,
+      memory: data.memory,
+      cpu: data.cpu,
+      volumes,
+      constraints: data.constraints,
+      snapshotId: data.id,
+      traceContext,
+    });
+
+    return {
+      sandbox: { ...sandbox, token },
+      sshHost: sandbox.host,
+      sshPort: sandbox.port || 2222,
+      sshUser: 'root',
+      token,
+    };
+  }
+
+  /**
+   * Resume a stopped sandbox from one of its ready checkpoints. The sandbox
+   * keeps its ID and token, which its restored agent still holds, and is
+   * placed again on any runner its constraints allow. Throws when the
+   * checkpoint does not exist, is not ready, or the sandbox may still be
+   * running.
+   */
+  restoreCheckpoint(sandboxId: string, id: string, traceContext?: Record<string, string>): Sandbox {
+    const data = this.getRepo().getById(id);
+    if (!data || !data.checkpoint || data.sandboxId !== sandboxId) {
+      throw new Error('Checkpoint not found');
+    }
+    if (data.status !== 'ready' || !data.image) {
+      throw new Error(`Cannot restore a checkpoint that is ${data.status}`);
+    }
+    if (!this.hasCheckpointArchive(id)) {
+      throw new Error('Checkpoint archive is missing');
+    }
+    const sandbox = repository.getSandbox(sandboxId);
+    if (!sandbox) {
+      throw new Error('Sandbox not found');
+    }
+    if (!RESTORE_CHECKPOINT_INTO.includes(sandbox.status)) {
+      throw new Error(`Cannot restore a checkpoint into a sandbox that is ${sandbox.status}`);
+    }
+
+    const updated = repository.updateSandbox(sandboxId, {
+      status: 'pending',
+      runnerId: '',
+      containerId: '',
+    });
+    createJob({
+      type: 'restore-checkpoint',
+      sandboxId,
+      image: data.image,
+      token: sandbox.token || '',
+      memory: data.memory,
+      cpu: data.cpu,
+      volumes: data.volumes,
+      constraints: data.constraints,
+      snapshotId: data.id,
+      traceContext,
+    });
+    return updated || sandbox;
+  }
+
+  /**
+   * Store the checkpoint archive a runner uploads for a checkpoint,
+   * replacing any earlier upload only once the new one is complete
+   */
+  async saveCheckpointArchive(id: string, archive: NodeJS.ReadableStream): Promise<void> {
+    fs.mkdirSync(this.checkpointDir, { recursive: true });
+    const file = this.checkpointArchivePath(id);
+    const partial = `${file}.partial`;
+
+    await new Promise<void>((resolve, reject) => {
+      const out = fs.createWriteStream(partial, { mode: 0o600 });
+      archive.on('error', reject);
+      out.on('error', reject);
+      out.on('finish', () => resolve());
+      archive.pipe(out);
+    }).catch((error) => {
+      fs.rmSync(partial, { force: true });
+      throw error;
+    });
+    fs.renameSync(partial, file);
+  }
+
+  /**
+   * Open a checkpoint's archive for reading, undefined when none was uploaded
+   */
+  openCheckpointArchive(id: string): fs.ReadStream | undefined {
+    if (!this.hasCheckpointArchive(id)) {
+      return undefined;
+    }
+    return fs.createReadStream(this.checkpointArchivePath(id));
+  }
+
+  private hasCheckpointArchive(id: string): boolean {
+    return fs.existsSync(this.checkpointArchivePath(id));
+  }
+
+  private checkpointArchivePath(id: string): string {
+    return path.join(this.checkpointDir, `${id}.tar.gz`);
+  }
+}
+
+// The env a snapshot keeps for restores may hold secrets, so it is not returned
+function toSnapshot(data: SnapshotData): Snapshot {
+  const { env, constraints, ...snapshot } = data;
+  return snapshot;
+}
+
+export const snapshotService = new SnapshotService();
@@ -0,0 +1,41 @@
+/**
+ * Unit tests for TLS certificate authorization
+ */
+
+import { authorizeCertificate, CertificateSandbox } from './tls-ca';
+
+describe('authorizeCertificate', () => {
+  const sandboxes: Record<string, CertificateSandbox> = {
+    'sbox-1': { token: 'token-1', runnerId: 'runner-1' },
+    'sbox-2': { token: 'token-2', runnerId: 'runner-2' },
+  };
+  const lookup = (id: string) => sandboxes[id];
+
+  test('should issue server certificates only to the runner hosting the sandbox', () => {
+    const req = { commonName: 'sbox-1', dnsNames: ['sbox-1', 'localhost'], usage: 'server' as const };
+
+    expect(authorizeCertificate(req, { runnerId: 'runner-1' }, lookup)).toBeUndefined();
+    expect(authorizeCertificate(req, { runnerId: 'runner-2' }, lookup)).toBeDefined();
+    expect(authorizeCertificate(req, { sandboxToken: 'token-1' }, lookup)).toBeDefined();
+    expect(authorizeCertificate({ ...req, commonName: 'sbox-missing' }, { runnerId: 'runner-1' }, lookup)).toBeDefined();
+  });
+
+  test('should refuse certificates naming another sandbox', () => {
+    const req = { commonName: 'sbox-1', dnsNames: ['sbox-1', 'sbox-2'], usage: 'server' as const };
+    expect(authorizeCertificate(req, { runnerId: 'runner-1' }, lookup)).toBeDefined();
+  });
+
+  test('should bind runner client certificates to the calling runner', () => {
+    expect(authorizeCertificate({ commonName: 'runner-1', usage: 'client' }, { runnerId: 'runner-1' }, lookup)).toBeUndefined();
+    expect(authorizeCertificate({ commonName: 'runner-2', usage: 'client' }, { runnerId: 'runner-1' }, lookup)).toBeDefined();
+  });
+
+  test('should bind API key client certificates to a sandbox the caller holds the token for', () => {
+    const req = { commonName: 'sbox-1', usage: 'client' as const };
+
+    expect(authorizeCertificate(req, { sandboxToken: 'token-1' }, lookup)).toBeUndefined();
+    expect(authorizeCertificate(req, { sandboxToken: 'token-2' }, lookup)).toBeDefined();
+    expect(authorizeCertificate(req, {}, lookup)).toBeDefined();
+    expect(authorizeCertificate({ ...req, commonName: 'client-1' }, { sandboxToken: 'token-1' }, lookup)).toBeDefined();
+  });
+});
@@ -0,0 +1,208 @@
+/**
+ * TLS Certificate Authority Service
+ *
+ * Issues X.509 certificates for agent gRPC traffic:
+ * - Server generates an RSA CA key pair and self-signed CA certificate
+ * - Runners request server certificates for the agents they start
+ * - Runners and SDK clients request client certificates for mutual TLS
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { timingSafeEqual } from 'crypto';
+import { logger } from '../logger';
+
+// node-forge ships without type declarations in this project
+// eslint-disable-next-line @typescript-eslint/no-var-requires
+const forge = require('node-forge');
+
+export type CertificateUsage = 'server' | 'client';
+
+export interface IssueCertificateRequest {
+  commonName: string;
+  dnsNames?: string[];
+  ipAddresses?: string[];
+  usage: CertificateUsage;
+  validityDays?: number;
+}
+
+export interface IssuedCertificate {
+  certificate: string;    // PEM certificate
+  privateKey: string;     // PEM private key
+  caCertificate: string;  // PEM CA certificate
+}
+
+// Who is asking for a certificate: a runner by its credential, or an API key
+// holder proving access to a sandbox with that sandbox's token
+export interface CertificateCaller {
+  runnerId?: string;
+  sandboxToken?: string;
+}
+
+// What the server knows about a sandbox named in a certificate request
+export interface CertificateSandbox {
+  token?: string;
+  runnerId?: string; // Runner the sandbox is placed on or assigned to
+}
+
+/**
+ * Check that caller may obtain the requested certificate. Server certificates
+ * are only issued to the runner hosting the sandbox they name; client
+ * certificates are bound to the calling runner, or to a sandbox whose token
+ * the API key holder presents. Returns the reason for refusing, if any.
+ */
+export function authorizeCertificate(
+  req: IssueCertificateRequest,
+  caller: CertificateCaller,
+  lookup: (sandboxId: string) => CertificateSandbox | undefined
+): string | undefined {
+  // No certificate may name another sandbox, or it could impersonate its agent
+  if ((req.dnsNames || []).some((name) => name !== req.commonName && lookup(name))) {
+    return 'Certificate names another sandbox';
+  }
+
+  if (req.usage === 'server') {
+    if (!caller.runnerId) {
+      return 'Server certificates are only issued to runners';
+    }
+    if (lookup(req.commonName)?.runnerId !== caller.runnerId) {
+      return 'Sandbox is not assigned to this runner';
+    }
+    return undefined;
+  }
+
+  if (caller.runnerId) {
+    return req.commonName === caller.runnerId ? undefined : 'Client certificate must name the calling runner';
+  }
+  const sandbox = lookup(req.commonName);
+  if (!sandbox?.token || !caller.sandboxToken) {
+    return 'Client certificate must name a sandbox and present its token';
+  }
+  const given = Buffer.from(caller.sandboxToken);
+  const expected = Buffer.from(sandbox.token);
+  if (given.length !== expected.length || !timingSafeEqual(given, expected)) {
+    return 'Invalid sandbox token';
+  }
+  return undefined;
+}
+
+class TLSCAService {
+  private caCert: any = null;
+  private caKey: any = null;
+  private caCertPath: string;
+  private caKeyPath: string;
+
+  constructor(dataDir: string) {
+    this.caCertPath = path.join(dataDir, 'tls-ca.pem');
+    this.caKeyPath = path.join(dataDir, 'tls-ca-key.pem');
+  }
+
+  /**
+   * Initialize or load the CA certificate and key
+   */
+  async initialize(): Promise<void> {
+    if (fs.existsSync(this.caCertPath) && fs.existsSync(this.caKeyPath)) {
+      try {
+        this.caCert = forge.pki.certificateFromPem(fs.readFileSync(this.caCertPath, 'utf-8'));
+        this.caKey = forge.pki.privateKeyFromPem(fs.readFileSync(this.caKeyPath, 'utf-8'));
+        logger.info('[TLS-CA] Loaded existing TLS CA');
+        return;
+      } catch (e: any) {
+        logger.warn('[TLS-CA] Failed to load existing CA: %s', e.message);
+      }
+    }
+
+    this.generateCA();
+  }
+
+  /**
+   * Generate a new self-signed CA valid for 10 years
+   */
+  private generateCA(): void {
+    logger.info('[TLS-CA] Generating new TLS CA...');
+
+    const keys = forge.pki.rsa.generateKeyPair(2048);
+    const cert = forge.pki.createCertificate();
+    cert.publicKey = keys.publicKey;
+    cert.serialNumber = randomSerial();
+    cert.validity.notBefore = new Date();
+    cert.validity.notAfter = new Date();
+    cert.validity.notAfter.setFullYear(cert.validity.notBefore.getFullYear() + 10);
+
+    const attrs = [{ name: 'commonName', value: 'CodePod TLS CA' }];
+    cert.setSubject(attrs);
+    cert.setIssuer(attrs);
+    cert.setExtensions([
+      { name: 'basicConstraints', cA: true, critical: true },
+      { name: 'keyUsage', keyCertSign: true, cRLSign: true, critical: true },
+    ]);
+    cert.sign(keys.privateKey, forge.md.sha256.create());
+
+    fs.writeFileSync(this.caKeyPath, forge.pki.privateKeyToPem(keys.privateKey), { mode: 0o600 });
+    fs.writeFileSync(this.caCertPath, forge.pki.certificateToPem(cert), { mode: 0o644 });
+
+    this.caCert = cert;
+    this.caKey = keys.privateKey;
+    logger.info('[TLS-CA] Generated new TLS CA');
+  }
+
+  /**
+   * Get the CA certificate in PEM format (for clients to trust)
+   */
+  getCACertificate(): string {
+    if (!this.caCert) {
+      throw new Error('TLS CA not initialized');
+    }
+    return forge.pki.certificateToPem(this.caCert);
+  }
+
+  /**
+   * Issue a server or client certificate signed by the CA
+   */
+  issueCertificate(req: IssueCertificateRequest): IssuedCertificate {
+    if (!this.caCert || !this.caKey) {
+      throw new Error('TLS CA not initialized');
+    }
+
+    logger.info('[TLS-CA] Issuing %s certificate for %s', req.usage, req.commonName);
+
+    const keys = forge.pki.rsa.generateKeyPair(2048);
+    const cert = forge.pki.createCertificate();
+    cert.publicKey = keys.publicKey;
+    cert.serialNumber = randomSerial();
+    cert.validity.notBefore = new Date(Date.now() - 2 * 60 * 1000); // tolerate clock skew
+    cert.validity.notAfter = new Date(Date.now() + (req.validityDays || 30) * 24 * 60 * 60 * 1000);
+    cert.setSubject([{ name: 'commonName', value: req.commonName }]);
+    cert.setIssuer(this.caCert.subject.attributes);
+
+    const altNames = [
+      ...(req.dnsNames || []).map((value) => ({ type: 2, value })),
+      ...(req.ipAddresses || []).map((ip) => ({ type: 7, ip })),
+    ];
+    const extensions: any[] = [
+      { name: 'basicConstraints', cA: false },
+      { name: 'keyUsage', digitalSignature: true, keyEncipherment: true, critical: true },
+      req.usage === 'server'
+        ? { name: 'extKeyUsage', serverAuth: true }
+        : { name: 'extKeyUsage', clientAuth: true },
+    ];
+    if (altNames.length > 0) {
+      extensions.push({ name: 'subjectAltName', altNames });
+    }
+    cert.setExtensions(extensions);
+    cert.sign(this.caKey, forge.md.sha256.create());
+
+    return {
+      certificate: forge.pki.certificateToPem(cert),
+      privateKey: forge.pki.privateKeyToPem(keys.privateKey),
+      caCertificate: this.getCACertificate(),
+    };
+  }
+}
+
+// randomSerial returns a positive random serial number in hex
+function randomSerial(): string {
+  return '01' + forge.util.bytesToHex(forge.random.getBytesSync(15));
+}
+
+export const tlsCAService = new TLSCAService(process.env.CODEPOD_DATA_DIR || './data');
@@ -2,20 +2,43 @@
  * Core types for CodePod Server
  */
 
-export type SandboxStatus = 'pending' | 'running' | 'stopped' | 'failed' | 'deleted' | 'deleting';
+export type SandboxStatus = 'pending' | 'running' | 'stopping' | 'stopped' | 'paused' | 'failed' | 'deleted' | 'deleting';
+
+export interface AgentMetrics {
+  cpuPercent?: number;
+  memoryMB?: number;
+  memoryLimitMB?: number;
+  pids?: number;
+  processCount?: number;
+  sessionCount?: number;
+  ioReadBytes?: number;
+  ioWriteBytes?: number;
+  netRxBytes?: number;
+  netTxBytes?: number;
+  diskUsedMB?: number;
+  diskTotalMB?: number;
+  uptimeSecs?: number;
+}
+
+// A TCP port a process inside the sandbox is listening on, as reported by the agent
+export interface ListeningPort {
+  port: number;
+  address?: string;
+  protocol?: string; // tcp or tcp6
+  pid?: number;
+  process?: string;
+  openedAt: string; // ISO timestamp
+}
 
 export interface AgentInfo {
   lastHeartbeat: string; // ISO timestamp
   ipAddress?: string;
   hostname?: string;
-  metrics?: {
-    cpuPercent?: number;
-    memoryMB?: number;
-    sessionCount?: number;
-  };
+  metrics?: AgentMetrics;
   // Agent address (from runner) - unified address
   address?: string;
   addressToken?: string;
+  ports?: ListeningPort[];
 }
 
 export interface Sandbox {
@@ -43,6 +66,13 @@ export interface CreateSandboxRequest {
   env?: Record<string, string>;
   timeout?: number;
   volumes?: { volumeId: string; mountPath: string }[];
+  constraints?: JobConstraints;
+}
+
+// Restricts the runners a sandbox may be placed on
+export interface JobConstraints {
+  nodeSelector?: Record<string, string>; // Labels the runner must have; an empty value only requires the label
+  tolerations?: Record<string, string>;  // Taints the sandbox accepts; an empty value tolerates any value
 }
 
 export interface SandboxResponse {
@@ -117,3 +147,36 @@ export interface CreateVolumeResponse {
   volumeId: string;
   hostPath: string;
 }
+
+// Snapshot types
+export type SnapshotStatus = 'pending' | 'ready' | 'failed';
+
+// A sandbox's container filesystem committed to an image. The sandbox's
+// env is kept for restores but never returned.
+export interface Snapshot {
+  id: string;
+  sandboxId: string;
+  status: SnapshotStatus;
+  image?: string;         // Image reference restores pull, set once ready
+  imageId?: string;
+  sourceImage: string;    // Image the sandbox was created from
+  memory?: string;
+  cpu?: number;
+  volumes?: { volumeId: string; mountPath: string }[];
+  excludeVolumes: boolean; // Restored sandboxes start without the volumes
+  checkpoint: boolean;     // Also holds a CRIU checkpoint of the sandbox's processes
+  message?: string;
+  createdAt: string;
+}
+
+export interface CreateSnapshotRequest {
+  excludeVolumes?: boolean;
+}
+
+export interface RestoreSnapshotRequest {
+  name?: string;
+}
+
+export interface CreateCheckpointRequest {
+  leaveRunning?: boolean; // Keep the sandbox running once checkpointed instead of stopping it
+}